@@ -8,6 +8,7 @@ const (
 	OpErr
 	AuthErr
 	LevelErr
+	LimitErr
 )
 
 var ErrorMapper = map[uint64]string{
@@ -18,6 +19,7 @@ var ErrorMapper = map[uint64]string{
 	5: "操作错误",
 	6: "鉴权错误",
 	7: "权限错误",
+	8: "频率限制",
 }
 
 func ErrNew(err error, errType gin.ErrorType) error {
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"finaltenzor/config"
+	"fmt"
+	"time"
+)
+
+// 所有接口返回的时间统一为RFC 3339格式并带有学校所在时区的偏移,
+// 例如 2024-09-02T08:00:00+08:00；只表示日期的字段使用 2006-01-02
+const (
+	TimeLayout = time.RFC3339
+	DateLayout = "2006-01-02"
+)
+
+// 可接受的时间输入格式,不带时区的输入按学校所在时区解释
+var timeInputLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ParseTime 解析传入的时间
+func ParseTime(value string) (time.Time, error) {
+	var firstErr error
+	for _, layout := range timeInputLayouts {
+		t, err := time.ParseInLocation(layout, value, config.Config.Location)
+		if err == nil {
+			return t.In(config.Config.Location), nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// ParseDate 解析传入的日期,返回学校所在时区当天的零点
+func ParseDate(value string) (time.Time, error) {
+	return time.ParseInLocation(DateLayout, value, config.Config.Location)
+}
+
+// ParseClock 解析HH:MM格式的时刻, 返回距离零点的时长
+func ParseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("时刻格式错误: %s", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// FormatTime 按统一格式输出时间
+func FormatTime(t time.Time) string {
+	return t.In(config.Config.Location).Format(TimeLayout)
+}
+
+// FormatDate 按统一格式输出日期
+func FormatDate(t time.Time) string {
+	return t.In(config.Config.Location).Format(DateLayout)
+}
+
+// Now 返回学校所在时区的当前时间
+func Now() time.Time {
+	return time.Now().In(config.Config.Location)
+}
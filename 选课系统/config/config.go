@@ -1,11 +1,20 @@
 package config
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata"
 
 	_ "github.com/joho/godotenv/autoload"
+	"golang.org/x/crypto/hkdf"
 )
 
+const defaultAppSecret = "gin-example:secret"
+
 var Config struct {
 	AppProd      bool
 	AppMode      string
@@ -19,6 +28,12 @@ var Config struct {
 	AllowOrigins string
 	AllowHeaders string
 	LogLevel     string
+	TermStart    string
+	Timezone     string
+	Location     *time.Location
+
+	TeacherRoomBooking bool   // 是否允许教师自行预订教室
+	AttendanceSecret   []byte // 签到码的签名密钥, 为空表示不能生成签到码
 }
 
 func envOr(env string, or string) string {
@@ -36,7 +51,7 @@ func initConfig() {
 	} else {
 		Config.AppMode = "debug"
 	}
-	Config.AppSecret = envOr("APP_SECRET", "gin-example:secret")
+	Config.AppSecret = envOr("APP_SECRET", defaultAppSecret)
 	Config.AppLanguage = envOr("APP_LANGUAGE", "en")
 	Config.MysqlHost = envOr("APP_MYSQL_HOST", "127.0.0.1")
 	Config.MysqlPort = envOr("APP_MYSQL_PORT", "3306")
@@ -46,4 +61,34 @@ func initConfig() {
 	Config.AllowOrigins = envOr("APP_ALLOW_ORIGINS", "*")
 	Config.AllowHeaders = envOr("APP_ALLOW_HEADERS", "Origin|Content-Length|Content-Type|Authorization")
 	Config.LogLevel = envOr("APP_LOG_LEVEL", "info")
+	Config.TermStart = os.Getenv("APP_TERM_START")
+	Config.Timezone = envOr("APP_TIMEZONE", "Asia/Shanghai")
+	location, err := time.LoadLocation(Config.Timezone)
+	if err != nil {
+		panic(fmt.Errorf("invalid APP_TIMEZONE %s: %w", Config.Timezone, err))
+	}
+	Config.Location = location
+	if value := os.Getenv("APP_TEACHER_ROOM_BOOKING"); value != "" {
+		Config.TeacherRoomBooking, err = strconv.ParseBool(value)
+		if err != nil {
+			panic(fmt.Errorf("invalid APP_TEACHER_ROOM_BOOKING %s: %w", value, err))
+		}
+	}
+	Config.AttendanceSecret = attendanceSecret()
+}
+
+// attendanceSecret 优先使用APP_ATTENDANCE_SECRET, 否则由APP_SECRET派生, 不与session共用同一个密钥;
+// 两者都未配置时返回nil, 以免用公开的默认密钥签发签到码
+func attendanceSecret() []byte {
+	if secret := os.Getenv("APP_ATTENDANCE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	if Config.AppSecret == defaultAppSecret {
+		return nil
+	}
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(Config.AppSecret), nil, []byte("finaltenzor attendance")), secret); err != nil {
+		panic(fmt.Errorf("derive attendance secret: %w", err))
+	}
+	return secret
 }
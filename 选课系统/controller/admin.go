@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"finaltenzor/common"
 	"finaltenzor/model"
+	"finaltenzor/service"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,15 +19,25 @@ type Admin struct{}
 // AddCourse 添加课程
 func (a *Admin) AddCourse(c *gin.Context) {
 	type timeform struct {
-		StartTime string `json:"startTime" binding:"required"`
-		EndTime   string `json:"endTime" binding:"required"`
+		StartTime   string `json:"startTime" binding:"required"`
+		EndTime     string `json:"endTime" binding:"required"`
+		RoomID      int64  `json:"roomId" binding:"min=0"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink" binding:"omitempty,url,max=512"`
 	}
 	var form struct {
 		CourseName     string     `json:"courseName" binding:"required"`
+		CourseCode     string     `json:"courseCode" binding:"max=32"`
 		Capacity       int        `json:"capacity" binding:"required"`
-		CourseTeachers []string   `json:"teachers" binding:"required"`
+		CourseTeachers []string   `json:"teachers"`
+		TeacherIDs     []int64    `json:"teacherIds"`
 		Time           []timeform `json:"time" binding:"required"`
-		Location       string     `json:"location" binding:"required"`
+		RoomID         int64      `json:"roomId" binding:"min=0"`
+		MinEnrollment  int        `json:"minEnrollment" binding:"min=0"`
+		AlternativeID  int64      `json:"alternativeCourseId" binding:"min=0"`
+		Credits        float64    `json:"credits" binding:"min=0,max=99"`
+		GradeScaleID   int64      `json:"gradeScaleId" binding:"min=0"`
+		Category       string     `json:"category" binding:"max=64"`
 	}
 	if err := c.ShouldBindJSON(&form); err != nil {
 		logrus.Errorf("参数错误: %v", err)
@@ -34,29 +46,58 @@ func (a *Admin) AddCourse(c *gin.Context) {
 	}
 	var srvtime []model.CourseTime
 	for _, timeItem := range form.Time {
-		startTime, err := time.Parse("2006-01-02 15:04:05", timeItem.StartTime)
+		startTime, err := common.ParseTime(timeItem.StartTime)
 		if err != nil {
 			logrus.Errorf("开始时间格式错误")
 			c.Error(common.ErrNew(err, common.ParamErr))
 			return
 		}
-		endTime, err := time.Parse("2006-01-02 15:04:05", timeItem.EndTime)
+		endTime, err := common.ParseTime(timeItem.EndTime)
 		if err != nil {
 			logrus.Errorf("结束时间格式错误")
 			c.Error(common.ErrNew(err, common.ParamErr))
 			return
 		}
-		srvtime = append(srvtime, model.CourseTime{
-			StartTime: startTime,
-			EndTime:   endTime,
-		})
-	}
-	courseID, err := srv.AddCourse(form.CourseName, form.Capacity, form.CourseTeachers, srvtime, form.Location)
+		srvtime = append(srvtime, newSessionTime(0, startTime, endTime, timeItem.RoomID, timeItem.Online, timeItem.MeetingLink))
+	}
+	category := strings.TrimSpace(form.Category)
+	courseID, warnings, err := srv.AddCourse(service.CourseInput{
+		CourseName:          form.CourseName,
+		CourseCode:          &form.CourseCode,
+		Capacity:            form.Capacity,
+		Teachers:            form.CourseTeachers,
+		TeacherIDs:          form.TeacherIDs,
+		Times:               srvtime,
+		RoomID:              form.RoomID,
+		MinEnrollment:       form.MinEnrollment,
+		AlternativeCourseID: form.AlternativeID,
+		Credits:             &form.Credits,
+		GradeScaleID:        &form.GradeScaleID,
+		Category:            &category,
+	})
 	if err != nil {
 		c.Error(common.ErrNew(err, common.OpErr))
 		return
 	}
-	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": courseID}))
+	if warnings == nil {
+		warnings = []string{}
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": courseID, "warnings": warnings}))
+}
+
+// newSessionTime 根据表单生成一次上课时间, roomId为0表示使用课程的教室
+func newSessionTime(courseID int64, startTime time.Time, endTime time.Time, roomID int64, online bool, meetingLink string) model.CourseTime {
+	courseTime := model.CourseTime{
+		CourseID:    courseID,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Online:      online,
+		MeetingLink: meetingLink,
+	}
+	if roomID > 0 {
+		courseTime.RoomID = &roomID
+	}
+	return courseTime
 }
 
 // DeleteCourse 删除课程
@@ -79,16 +120,26 @@ func (a *Admin) DeleteCourse(c *gin.Context) {
 // UpdateCourse 更新课程信息处理函数
 func (a *Admin) UpdateCourse(c *gin.Context) {
 	type timeform struct {
-		StartTime string `form:"startTime"`
-		EndTime   string `form:"endTime"`
+		StartTime   string `form:"startTime"`
+		EndTime     string `form:"endTime"`
+		RoomID      int64  `json:"roomId" binding:"min=0"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink" binding:"omitempty,url,max=512"`
 	}
 	var form struct {
 		CourseId       int64      `json:"courseId" binding:"required"`
 		CourseName     string     `json:"courseName"`
+		CourseCode     *string    `json:"courseCode" binding:"omitempty,max=32"`
 		Capacity       int        `json:"capacity"`
 		CourseTeachers []string   `json:"teachers"`
+		TeacherIDs     []int64    `json:"teacherIds"`
 		Time           []timeform `json:"time"`
-		Location       string     `json:"location"`
+		RoomID         int64      `json:"roomId" binding:"min=0"`
+		MinEnrollment  int        `json:"minEnrollment" binding:"min=0"`
+		AlternativeID  int64      `json:"alternativeCourseId" binding:"min=0"`
+		Credits        *float64   `json:"credits" binding:"omitempty,min=0,max=99"`
+		GradeScaleID   *int64     `json:"gradeScaleId" binding:"omitempty,min=0"`
+		Category       *string    `json:"category" binding:"omitempty,max=64"`
 	}
 	if err := c.ShouldBindJSON(&form); err != nil {
 		logrus.Errorf("参数错误: %v", err)
@@ -97,30 +148,46 @@ func (a *Admin) UpdateCourse(c *gin.Context) {
 	}
 	var srvtime []model.CourseTime
 	for _, timeItem := range form.Time {
-		startTime, err := time.Parse("2006-01-02 15:04:05", timeItem.StartTime)
+		startTime, err := common.ParseTime(timeItem.StartTime)
 		if err != nil {
 			logrus.Errorf("开始时间格式错误")
 			c.Error(common.ErrNew(err, common.ParamErr))
 			return
 		}
-		endTime, err := time.Parse("2006-01-02 15:04:05", timeItem.EndTime)
+		endTime, err := common.ParseTime(timeItem.EndTime)
 		if err != nil {
 			logrus.Errorf("结束时间格式错误")
 			c.Error(common.ErrNew(err, common.ParamErr))
 			return
 		}
-		srvtime = append(srvtime, model.CourseTime{
-			CourseID:  form.CourseId,
-			StartTime: startTime,
-			EndTime:   endTime,
-		})
-	}
-	err := srv.UpdateCourse(form.CourseId, form.CourseName, form.Capacity, form.CourseTeachers, srvtime, form.Location)
+		srvtime = append(srvtime, newSessionTime(form.CourseId, startTime, endTime, timeItem.RoomID, timeItem.Online, timeItem.MeetingLink))
+	}
+	if form.Category != nil {
+		category := strings.TrimSpace(*form.Category)
+		form.Category = &category
+	}
+	warnings, err := srv.UpdateCourse(form.CourseId, service.CourseInput{
+		CourseName:          form.CourseName,
+		CourseCode:          form.CourseCode,
+		Capacity:            form.Capacity,
+		Teachers:            form.CourseTeachers,
+		TeacherIDs:          form.TeacherIDs,
+		Times:               srvtime,
+		RoomID:              form.RoomID,
+		MinEnrollment:       form.MinEnrollment,
+		AlternativeCourseID: form.AlternativeID,
+		Credits:             form.Credits,
+		GradeScaleID:        form.GradeScaleID,
+		Category:            form.Category,
+	})
 	if err != nil {
 		c.Error(common.ErrNew(err, common.OpErr))
 		return
 	}
-	c.JSON(http.StatusOK, ResponseNew(c, nil))
+	if warnings == nil {
+		warnings = []string{}
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"warnings": warnings}))
 }
 
 // GetCourses 查询课程
@@ -129,8 +196,10 @@ func (a *Admin) GetCourses(c *gin.Context) {
 		Page       int      `form:"page" binding:"required,gt=0"`
 		Limit      int      `form:"limit" binding:"required,gt=0"`
 		CourseName string   `form:"courseName"`
+		TeacherIDs []int64  `form:"teacherIds"`
 		Teachers   []string `form:"teachers"`
 		Location   string   `form:"location"`
+		RoomID     int64    `form:"roomId"`
 		Time       string   `form:"time"`
 	}
 	var params QueryParams
@@ -141,8 +210,12 @@ func (a *Admin) GetCourses(c *gin.Context) {
 	}
 	var times []model.CourseTime
 	type TimeForm struct {
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
 	}
 	if params.Time != "" {
 		var timeForms []TimeForm
@@ -152,13 +225,13 @@ func (a *Admin) GetCourses(c *gin.Context) {
 			return
 		}
 		for _, timeItem := range timeForms {
-			startTime, err := time.Parse("2006-01-02 15:04:05", timeItem.StartTime)
+			startTime, err := common.ParseTime(timeItem.StartTime)
 			if err != nil {
 				logrus.Errorf("开始时间格式错误: %v", timeItem.StartTime)
 				c.Error(common.ErrNew(err, common.ParamErr))
 				return
 			}
-			endTime, err := time.Parse("2006-01-02 15:04:05", timeItem.EndTime)
+			endTime, err := common.ParseTime(timeItem.EndTime)
 			if err != nil {
 				logrus.Errorf("结束时间格式错误: %v", timeItem.EndTime)
 				c.Error(common.ErrNew(err, common.ParamErr))
@@ -170,7 +243,7 @@ func (a *Admin) GetCourses(c *gin.Context) {
 			})
 		}
 	}
-	courses, total, err := srv.GetCourses(params.Page, params.Limit, params.CourseName, params.Teachers, times, params.Location)
+	courses, total, err := srv.GetCourses(params.Page, params.Limit, params.CourseName, params.TeacherIDs, params.Teachers, times, params.Location, params.RoomID)
 	if err != nil {
 		logrus.Errorf("查询课程失败: %v", err)
 		c.Error(common.ErrNew(err, common.OpErr))
@@ -179,18 +252,26 @@ func (a *Admin) GetCourses(c *gin.Context) {
 	type responseformat struct {
 		CourseID       int64      `json:"id"`
 		CourseName     string     `json:"courseName"`
+		CourseCode     string     `json:"courseCode"`
 		Capacity       int        `json:"capacity"`
 		CourseTeachers []string   `json:"teachers"`
 		Time           []TimeForm `json:"time"`
 		Location       string     `json:"location"`
+		RoomID         *int64     `json:"roomId"`
+		Credits        float64    `json:"credits"`
+		Category       string     `json:"category"`
 	}
 	var response []responseformat
 	for _, course := range courses {
 		var timeForms []TimeForm
 		for _, timeItem := range course.CourseTimes {
 			timeForms = append(timeForms, TimeForm{
-				StartTime: timeItem.StartTime.Format("2006-01-02 15:04:05"),
-				EndTime:   timeItem.EndTime.Format("2006-01-02 15:04:05"),
+				StartTime:   common.FormatTime(timeItem.StartTime),
+				EndTime:     common.FormatTime(timeItem.EndTime),
+				Location:    course.SessionLocation(timeItem),
+				RoomID:      course.SessionRoomID(timeItem),
+				Online:      timeItem.Online,
+				MeetingLink: timeItem.MeetingLink,
 			})
 		}
 		TeacherNames, err := srv.GetTeacherNamesByCourses(course.CourseID)
@@ -202,10 +283,14 @@ func (a *Admin) GetCourses(c *gin.Context) {
 		response = append(response, responseformat{
 			CourseID:       course.CourseID,
 			CourseName:     course.CourseName,
+			CourseCode:     course.CourseCode,
 			Capacity:       course.Capacity,
 			CourseTeachers: TeacherNames,
 			Time:           timeForms,
 			Location:       course.Location,
+			RoomID:         course.RoomID,
+			Credits:        course.Credits,
+			Category:       course.Category,
 		})
 	}
 	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"size": total, "rows": response}))
@@ -240,8 +325,12 @@ func (a *Admin) GetCourseDetail(c *gin.Context) {
 		return
 	}
 	type TimeForm struct {
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
 	}
 	type StudentForm struct {
 		Name      string `json:"name"`
@@ -250,9 +339,13 @@ func (a *Admin) GetCourseDetail(c *gin.Context) {
 	type responseformat struct {
 		CourseID       int64         `json:"id"`
 		CourseName     string        `json:"courseName"`
+		CourseCode     string        `json:"courseCode"`
 		Capacity       int           `json:"capacity"`
 		Time           []TimeForm    `json:"time"`
 		Location       string        `json:"location"`
+		RoomID         *int64        `json:"roomId"`
+		Credits        float64       `json:"credits"`
+		Category       string        `json:"category"`
 		CourseTeachers []string      `json:"teachers"`
 		TotalStudents  int           `json:"totalStudents"`
 		Students       []StudentForm `json:"students"`
@@ -260,8 +353,12 @@ func (a *Admin) GetCourseDetail(c *gin.Context) {
 	var timeForms []TimeForm
 	for _, timeItem := range course.CourseTimes {
 		timeForms = append(timeForms, TimeForm{
-			StartTime: timeItem.StartTime.Format("2006-01-02 15:04:05"),
-			EndTime:   timeItem.EndTime.Format("2006-01-02 15:04:05"),
+			StartTime:   common.FormatTime(timeItem.StartTime),
+			EndTime:     common.FormatTime(timeItem.EndTime),
+			Location:    course.SessionLocation(timeItem),
+			RoomID:      course.SessionRoomID(timeItem),
+			Online:      timeItem.Online,
+			MeetingLink: timeItem.MeetingLink,
 		})
 	}
 	students, err := srv.GetStudentsByCourse(page, limit, couresID)
@@ -284,9 +381,13 @@ func (a *Admin) GetCourseDetail(c *gin.Context) {
 	response := responseformat{
 		CourseID:       course.CourseID,
 		CourseName:     course.CourseName,
+		CourseCode:     course.CourseCode,
 		Capacity:       course.Capacity,
 		Time:           timeForms,
 		Location:       course.Location,
+		RoomID:         course.RoomID,
+		Credits:        course.Credits,
+		Category:       course.Category,
 		CourseTeachers: TeacherNames,
 		TotalStudents:  len(students),
 		Students:       studentForms,
@@ -301,6 +402,11 @@ func (a *Admin) GetStudentsList(c *gin.Context) {
 		Limit       int    `form:"limit" binding:"required,gt=0"`
 		StudentName string `form:"studentName"`
 		StudentID   string `form:"studentId"`
+		Department  string `form:"department"`
+		Major       string `form:"major"`
+		EntryYear   int    `form:"entryYear" binding:"min=0"`
+		ClassName   string `form:"className"`
+		Status      string `form:"status" binding:"omitempty,oneof=active suspended graduated"`
 	}
 	var params QueryParams
 	if err := c.ShouldBindQuery(&params); err != nil {
@@ -309,22 +415,43 @@ func (a *Admin) GetStudentsList(c *gin.Context) {
 		return
 	}
 	type StudentFormat struct {
-		StudentName  string `json:"studentName"`
-		StudentID    string `json:"studentId"`
-		TotalCourses int    `json:"totalCourses"`
+		StudentName  string              `json:"studentName"`
+		StudentID    string              `json:"studentId"`
+		TotalCourses int                 `json:"totalCourses"`
+		Profile      *studentProfileForm `json:"profile"`
+	}
+	filter := service.StudentFilter{
+		Department: strings.TrimSpace(params.Department),
+		Major:      strings.TrimSpace(params.Major),
+		EntryYear:  params.EntryYear,
+		ClassName:  strings.TrimSpace(params.ClassName),
+		Status:     params.Status,
+	}
+	students, courseCounts, err := srv.GetStudentsList(params.Page, params.Limit, params.StudentName, params.StudentID, filter)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
 	}
-	students, courseCounts, err := srv.GetStudentsList(params.Page, params.Limit, params.StudentName, params.StudentID)
+	studentIDs := make([]string, 0, len(students))
+	for _, student := range students {
+		studentIDs = append(studentIDs, student.UserID)
+	}
+	profiles, err := srv.GetStudentProfiles(studentIDs)
 	if err != nil {
 		c.Error(common.ErrNew(err, common.OpErr))
 		return
 	}
 	var studentForms []StudentFormat
 	for _, student := range students {
-		studentForms = append(studentForms, StudentFormat{
+		form := StudentFormat{
 			StudentName:  student.UserName,
 			StudentID:    student.UserID,
 			TotalCourses: courseCounts[student.UserID],
-		})
+		}
+		if profile, ok := profiles[student.UserID]; ok {
+			form.Profile = newStudentProfileForm(&profile)
+		}
+		studentForms = append(studentForms, form)
 	}
 	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"students": studentForms}))
 }
@@ -338,8 +465,12 @@ func (a *Admin) GetStudentDetail(c *gin.Context) {
 		return
 	}
 	type CourseTimeFormat struct {
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
 	}
 	type CourseFormat struct {
 		CourseID   int64              `json:"id"`
@@ -347,10 +478,14 @@ func (a *Admin) GetStudentDetail(c *gin.Context) {
 		Teachers   []string           `json:"teacher"`
 		Time       []CourseTimeFormat `json:"time"`
 		Location   string             `json:"location"`
+		RoomID     *int64             `json:"roomId"`
+		Credits    float64            `json:"credits"`
+		Category   string             `json:"category"`
 	}
 	type ResponseFormat struct {
-		StudentName string         `json:"studentName"`
-		Courses     []CourseFormat `json:"courses"`
+		StudentName string              `json:"studentName"`
+		Profile     *studentProfileForm `json:"profile"`
+		Courses     []CourseFormat      `json:"courses"`
 	}
 	courseForms := make([]CourseFormat, len(*courses))
 	for i, course := range *courses {
@@ -362,8 +497,12 @@ func (a *Admin) GetStudentDetail(c *gin.Context) {
 		var timeForms []CourseTimeFormat
 		for _, timeItem := range course.CourseTimes {
 			timeForms = append(timeForms, CourseTimeFormat{
-				StartTime: timeItem.StartTime.Format("2006-01-02 15:04:05"),
-				EndTime:   timeItem.EndTime.Format("2006-01-02 15:04:05"),
+				StartTime:   common.FormatTime(timeItem.StartTime),
+				EndTime:     common.FormatTime(timeItem.EndTime),
+				Location:    course.SessionLocation(timeItem),
+				RoomID:      course.SessionRoomID(timeItem),
+				Online:      timeItem.Online,
+				MeetingLink: timeItem.MeetingLink,
 			})
 		}
 		courseForms[i] = CourseFormat{
@@ -372,11 +511,95 @@ func (a *Admin) GetStudentDetail(c *gin.Context) {
 			Teachers:   Teacher,
 			Time:       timeForms,
 			Location:   course.Location,
+			RoomID:     course.RoomID,
+			Credits:    course.Credits,
+			Category:   course.Category,
 		}
 	}
+	profile, err := srv.GetStudentProfile(student.UserID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
 	response := ResponseFormat{
 		StudentName: student.UserName,
+		Profile:     newStudentProfileForm(profile),
 		Courses:     courseForms,
 	}
 	c.JSON(http.StatusOK, ResponseNew(c, response))
 }
+
+// GetUnderfilledCourses 获取选课人数不足最低开课人数的课程
+func (a *Admin) GetUnderfilledCourses(c *gin.Context) {
+	courses, err := srv.GetUnderfilledCourses()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type responseformat struct {
+		CourseID            int64  `json:"id"`
+		CourseName          string `json:"courseName"`
+		Capacity            int    `json:"capacity"`
+		MinEnrollment       int    `json:"minEnrollment"`
+		Enrolled            int    `json:"enrolled"`
+		AlternativeCourseID *int64 `json:"alternativeCourseId"`
+	}
+	response := []responseformat{}
+	for _, item := range courses {
+		response = append(response, responseformat{
+			CourseID:            item.Course.CourseID,
+			CourseName:          item.Course.CourseName,
+			Capacity:            item.Course.Capacity,
+			MinEnrollment:       item.Course.MinEnrollment,
+			Enrolled:            item.Enrolled,
+			AlternativeCourseID: item.Course.AlternativeCourseID,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"courses": response}))
+}
+
+// CancelCourse 停开课程并为已选学生提供替代课程的名额, 只能停开选课人数不足的课程, 除非指定force=true
+func (a *Admin) CancelCourse(c *gin.Context) {
+	courseIdStr := c.Param("courseId")
+	courseId, err := strconv.ParseInt(courseIdStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 courseId: %v", courseIdStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var params struct {
+		Force bool `form:"force"`
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	outcomes, err := srv.CancelCourse(courseId, params.Force)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type outcomeForm struct {
+		StudentID string `json:"studentId"`
+		OfferedTo int64  `json:"offeredTo,omitempty"`
+		Reason    string `json:"reason,omitempty"`
+	}
+	response := []outcomeForm{}
+	offered := 0
+	for _, outcome := range outcomes {
+		if outcome.OfferedTo != 0 {
+			offered++
+		}
+		response = append(response, outcomeForm{
+			StudentID: outcome.StudentID,
+			OfferedTo: outcome.OfferedTo,
+			Reason:    outcome.Reason,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"affected": len(outcomes),
+		"offered":  offered,
+		"students": response,
+	}))
+}
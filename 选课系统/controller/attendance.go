@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/service"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Attendance struct{}
+
+// GetAttendanceCode 生成某次上课当前的签到码, 前端将其显示为二维码并在过期前刷新
+func (a *Attendance) GetAttendanceCode(c *gin.Context) {
+	courseID, ok := staffCourseID(c)
+	if !ok {
+		return
+	}
+	startTime, err := common.ParseTime(c.Query("startTime"))
+	if err != nil {
+		logrus.Errorf("开始时间格式错误: %v", c.Query("startTime"))
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	now := common.Now()
+	code, expiresAt, err := srv.AttendanceCode(courseID, startTime, now)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"code":      code,
+		"expiresAt": common.FormatTime(expiresAt),
+		"expiresIn": int(math.Ceil(expiresAt.Sub(now).Seconds())),
+	}))
+}
+
+// CheckIn 学生提交签到码签到
+func (a *Attendance) CheckIn(c *gin.Context) {
+	var form struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	record, err := srv.CheckIn(studentID, form.Code, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"courseId":  record.CourseID,
+		"startTime": common.FormatTime(record.SessionStart),
+		"status":    record.Status,
+	}))
+}
+
+// MarkAttendance 手动标记学生某次上课的考勤
+func (a *Attendance) MarkAttendance(c *gin.Context) {
+	courseID, ok := staffCourseID(c)
+	if !ok {
+		return
+	}
+	var form struct {
+		StartTime string `json:"startTime" binding:"required"`
+		StudentID string `json:"studentId" binding:"required"`
+		Status    string `json:"status" binding:"required,oneof=present late absent excused"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	startTime, err := common.ParseTime(form.StartTime)
+	if err != nil {
+		logrus.Errorf("开始时间格式错误: %v", form.StartTime)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	markedBy := SessionGet(c, "user").(UserSession).UserID
+	if _, err := srv.MarkAttendance(courseID, startTime, form.StudentID, form.Status, markedBy); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetSessionAttendance 获取某次上课全部选课学生的考勤
+func (a *Attendance) GetSessionAttendance(c *gin.Context) {
+	courseID, ok := staffCourseID(c)
+	if !ok {
+		return
+	}
+	startTime, err := common.ParseTime(c.Query("startTime"))
+	if err != nil {
+		logrus.Errorf("开始时间格式错误: %v", c.Query("startTime"))
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	session, students, err := srv.GetSessionAttendance(courseID, startTime)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type studentForm struct {
+		StudentID   string `json:"studentId"`
+		StudentName string `json:"studentName"`
+		Status      string `json:"status"`
+		Method      string `json:"method,omitempty"`
+		MarkedAt    string `json:"markedAt,omitempty"`
+	}
+	// 上课结束后仍没有记录的学生视为缺勤, 上课期间显示为未签到
+	ended := !common.Now().Before(session.EndTime)
+	response := []studentForm{}
+	for _, student := range students {
+		form := studentForm{StudentID: student.Student.UserID, StudentName: student.Student.UserName}
+		switch {
+		case student.Record != nil:
+			form.Status = student.Record.Status
+			form.Method = student.Record.Method
+			form.MarkedAt = common.FormatTime(student.Record.UpdatedAt)
+		case ended:
+			form.Status = "absent"
+		default:
+			form.Status = "unmarked"
+		}
+		response = append(response, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"courseId":  courseID,
+		"startTime": common.FormatTime(session.StartTime),
+		"endTime":   common.FormatTime(session.EndTime),
+		"students":  response,
+	}))
+}
+
+type attendanceSummaryForm struct {
+	CourseID  int64   `json:"courseId"`
+	StudentID string  `json:"studentId"`
+	Sessions  int     `json:"sessions"`
+	Present   int     `json:"present"`
+	Late      int     `json:"late"`
+	Excused   int     `json:"excused"`
+	Absent    int     `json:"absent"`
+	Rate      float64 `json:"rate"`
+}
+
+func newAttendanceSummaryForms(summaries []service.AttendanceSummary) []attendanceSummaryForm {
+	forms := make([]attendanceSummaryForm, 0, len(summaries))
+	for _, summary := range summaries {
+		forms = append(forms, attendanceSummaryForm{
+			CourseID:  summary.CourseID,
+			StudentID: summary.StudentID,
+			Sessions:  summary.Sessions,
+			Present:   summary.Present,
+			Late:      summary.Late,
+			Excused:   summary.Excused,
+			Absent:    summary.Absent,
+			Rate:      math.Round(summary.Rate()*1000) / 1000,
+		})
+	}
+	return forms
+}
+
+// GetCourseAttendanceSummary 统计课程每名学生的考勤
+func (a *Attendance) GetCourseAttendanceSummary(c *gin.Context) {
+	courseID, ok := staffCourseID(c)
+	if !ok {
+		return
+	}
+	summaries, err := srv.CourseAttendanceSummary(courseID, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"students": newAttendanceSummaryForms(summaries)}))
+}
+
+// GetStudentAttendanceSummary 统计学生每门课程的考勤, 管理员通过路径参数指定学生, 学生查看自己的考勤
+func (a *Attendance) GetStudentAttendanceSummary(c *gin.Context) {
+	studentID := c.Param("studentId")
+	if studentID == "" {
+		studentID = SessionGet(c, "user").(UserSession).UserID
+	}
+	summaries, err := srv.StudentAttendanceSummary(studentID, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	courseIDs := make([]int64, 0, len(summaries))
+	for _, summary := range summaries {
+		courseIDs = append(courseIDs, summary.CourseID)
+	}
+	names, err := srv.GetCourseNames(courseIDs)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type courseForm struct {
+		attendanceSummaryForm
+		CourseName string `json:"courseName"`
+	}
+	response := []courseForm{}
+	for _, form := range newAttendanceSummaryForms(summaries) {
+		response = append(response, courseForm{attendanceSummaryForm: form, CourseName: names[form.CourseID]})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"studentId": studentID, "courses": response}))
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"encoding/csv"
+	"finaltenzor/common"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Audit struct{}
+
+// GetConflictAudit 扫描整个学期的课程冲突, format=csv时导出CSV文件
+// 不传日期范围时默认检查当前学期
+func (a *Audit) GetConflictAudit(c *gin.Context) {
+	type QueryParams struct {
+		FromDate string `form:"fromDate"`
+		ToDate   string `form:"toDate"`
+		Kind     string `form:"kind" binding:"omitempty,oneof=room_double_booking teacher_overlap student_overlap over_capacity booking_course booking_overlap"`
+		Format   string `form:"format" binding:"omitempty,oneof=json csv"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	start, end, err := srv.TermRange(common.Now())
+	if params.FromDate != "" || params.ToDate != "" {
+		start, err = common.ParseDate(params.FromDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		end, err = common.ParseDate(params.ToDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+	} else if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	report, err := srv.ConflictAudit(start, end)
+	if err != nil {
+		logrus.Errorf("冲突检查失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	var courseIDs []int64
+	for _, conflict := range report.Conflicts {
+		courseIDs = append(courseIDs, conflict.CourseIDs...)
+	}
+	names, err := srv.GetCourseNames(courseIDs)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type courseLink struct {
+		ID         int64  `json:"id"`
+		CourseName string `json:"courseName"`
+		Link       string `json:"link"`
+	}
+	type bookingForm struct {
+		ID    int64  `json:"id"`
+		Title string `json:"title"`
+	}
+	type conflictForm struct {
+		Kind        string        `json:"kind"`
+		Subject     string        `json:"subject"`
+		Courses     []courseLink  `json:"courses"`
+		Bookings    []bookingForm `json:"bookings"`
+		FirstTime   string        `json:"firstTime"`
+		Occurrences int           `json:"occurrences"`
+		Detail      string        `json:"detail"`
+	}
+	conflicts := []conflictForm{}
+	for _, conflict := range report.Conflicts {
+		if params.Kind != "" && conflict.Kind != params.Kind {
+			continue
+		}
+		form := conflictForm{
+			Kind:        conflict.Kind,
+			Subject:     conflict.Subject,
+			Occurrences: conflict.Occurrences,
+			Detail:      conflict.Detail,
+		}
+		if !conflict.FirstTime.IsZero() {
+			form.FirstTime = common.FormatTime(conflict.FirstTime)
+		}
+		for _, courseID := range conflict.CourseIDs {
+			form.Courses = append(form.Courses, courseLink{
+				ID:         courseID,
+				CourseName: names[courseID],
+				Link:       "/api/admin/courses/" + strconv.FormatInt(courseID, 10),
+			})
+		}
+		for _, booking := range conflict.Bookings {
+			form.Bookings = append(form.Bookings, bookingForm{ID: booking.ID, Title: booking.Title})
+		}
+		conflicts = append(conflicts, form)
+	}
+
+	if params.Format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=conflicts-%s.csv", common.FormatDate(start)))
+		c.Status(http.StatusOK)
+		// 写入BOM, 便于Excel正确识别中文
+		c.Writer.WriteString("\xEF\xBB\xBF")
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"类型", "对象", "课程ID", "课程名称", "首次冲突时间", "冲突次数", "说明", "课程链接", "预订ID", "预订名称"})
+		for _, conflict := range conflicts {
+			var ids, courseNames, links []string
+			for _, course := range conflict.Courses {
+				ids = append(ids, strconv.FormatInt(course.ID, 10))
+				courseNames = append(courseNames, course.CourseName)
+				links = append(links, course.Link)
+			}
+			var bookingIDs, bookingTitles []string
+			for _, booking := range conflict.Bookings {
+				bookingIDs = append(bookingIDs, strconv.FormatInt(booking.ID, 10))
+				bookingTitles = append(bookingTitles, booking.Title)
+			}
+			writer.Write([]string{
+				conflict.Kind,
+				conflict.Subject,
+				strings.Join(ids, ";"),
+				strings.Join(courseNames, ";"),
+				conflict.FirstTime,
+				strconv.Itoa(conflict.Occurrences),
+				conflict.Detail,
+				strings.Join(links, ";"),
+				strings.Join(bookingIDs, ";"),
+				strings.Join(bookingTitles, ";"),
+			})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			logrus.Errorf("导出冲突报告失败: %v", err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"fromDate":  common.FormatDate(report.Start),
+		"toDate":    common.FormatDate(report.End.AddDate(0, 0, -1)),
+		"summary":   report.Summary,
+		"conflicts": conflicts,
+	}))
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type availabilityForm struct {
+	ID         int64  `json:"id"`
+	TeacherID  int64  `json:"teacherId"`
+	Kind       string `json:"kind"`
+	FromDate   string `json:"fromDate"`
+	ToDate     string `json:"toDate"`
+	Weekday    int    `json:"weekday"`
+	StartClock string `json:"startClock"`
+	EndClock   string `json:"endClock"`
+	Note       string `json:"note"`
+}
+
+// availabilityTeacherID 管理员通过路径参数指定教师, 教师只能操作自己的时间段
+func availabilityTeacherID(c *gin.Context) (int64, bool) {
+	if teacherIDStr := c.Param("teacherId"); teacherIDStr != "" {
+		teacherID, err := strconv.ParseInt(teacherIDStr, 10, 64)
+		if err != nil {
+			logrus.Errorf("无效的 teacherId: %v", teacherIDStr)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return 0, false
+		}
+		return teacherID, true
+	}
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return 0, false
+	}
+	return teacherID, true
+}
+
+// AddTeacherAvailability 添加教师不能上课或希望上课的时间段, 不指定日期范围时默认为当前学期
+func (t *Teacher) AddTeacherAvailability(c *gin.Context) {
+	teacherID, ok := availabilityTeacherID(c)
+	if !ok {
+		return
+	}
+	var form struct {
+		Kind       string `json:"kind" binding:"required,oneof=unavailable preferred"`
+		FromDate   string `json:"fromDate"`
+		ToDate     string `json:"toDate"`
+		Weekday    int    `json:"weekday" binding:"required,min=1,max=7"`
+		StartClock string `json:"startClock" binding:"required"`
+		EndClock   string `json:"endClock" binding:"required"`
+		Note       string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var fromDate, toDate time.Time
+	if form.FromDate != "" || form.ToDate != "" {
+		var err error
+		if fromDate, err = common.ParseDate(form.FromDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		if toDate, err = common.ParseDate(form.ToDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+	} else {
+		start, end, err := srv.TermRange(common.Now())
+		if err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return
+		}
+		fromDate, toDate = start, end.AddDate(0, 0, -1)
+	}
+	block := model.TeacherAvailability{
+		TeacherID:  teacherID,
+		Kind:       form.Kind,
+		FromDate:   fromDate,
+		ToDate:     toDate,
+		Weekday:    form.Weekday,
+		StartClock: form.StartClock,
+		EndClock:   form.EndClock,
+		Note:       form.Note,
+	}
+	if err := srv.AddTeacherAvailability(&block); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": block.ID}))
+}
+
+// GetTeacherAvailability 获取教师的时间段, 可按日期范围筛选, 供排课使用
+func (t *Teacher) GetTeacherAvailability(c *gin.Context) {
+	teacherID, ok := availabilityTeacherID(c)
+	if !ok {
+		return
+	}
+	type QueryParams struct {
+		FromDate string `form:"fromDate"`
+		ToDate   string `form:"toDate"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var fromDate, toDate time.Time
+	if params.FromDate != "" || params.ToDate != "" {
+		var err error
+		if fromDate, err = common.ParseDate(params.FromDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		if toDate, err = common.ParseDate(params.ToDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+	}
+	blocks, err := srv.GetTeacherAvailability(teacherID, fromDate, toDate)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []availabilityForm{}
+	for _, block := range blocks {
+		response = append(response, availabilityForm{
+			ID:         block.ID,
+			TeacherID:  block.TeacherID,
+			Kind:       block.Kind,
+			FromDate:   common.FormatDate(block.FromDate),
+			ToDate:     common.FormatDate(block.ToDate),
+			Weekday:    block.Weekday,
+			StartClock: block.StartClock,
+			EndClock:   block.EndClock,
+			Note:       block.Note,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"availability": response}))
+}
+
+// DeleteTeacherAvailability 删除教师的时间段
+func (t *Teacher) DeleteTeacherAvailability(c *gin.Context) {
+	teacherID, ok := availabilityTeacherID(c)
+	if !ok {
+		return
+	}
+	blockIDStr := c.Param("blockId")
+	blockID, err := strconv.ParseInt(blockIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 blockId: %v", blockIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.DeleteTeacherAvailability(teacherID, blockID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
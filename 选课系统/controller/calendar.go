@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Calendar struct{}
+
+type calendarEventForm struct {
+	UID       string `json:"uid"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+}
+
+func newCalendarEventForm(event model.CalendarEvent) calendarEventForm {
+	return calendarEventForm{
+		UID:       event.UID,
+		Kind:      event.Kind,
+		Name:      event.Name,
+		StartDate: common.FormatDate(event.StartDate),
+		EndDate:   common.FormatDate(event.EndDate),
+	}
+}
+
+// ImportCalendar 导入ICS校历, commit=true时写入, 否则只预览变更(包括将要删除的事件)
+func (cal *Calendar) ImportCalendar(c *gin.Context) {
+	var form struct {
+		Commit bool `form:"commit"`
+	}
+	if err := c.ShouldBind(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		logrus.Errorf("未上传校历文件: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.SysErr))
+		return
+	}
+	defer file.Close()
+	result, err := srv.ImportCalendar(file, form.Commit)
+	if err != nil {
+		logrus.Errorf("导入校历失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type changeForm struct {
+		Action string            `json:"action"`
+		Reason string            `json:"reason,omitempty"`
+		Event  calendarEventForm `json:"event"`
+	}
+	changes := []changeForm{}
+	for _, change := range result.Changes {
+		changes = append(changes, changeForm{
+			Action: change.Action,
+			Reason: change.Reason,
+			Event:  newCalendarEventForm(change.Event),
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"committed": result.Committed,
+		"created":   result.Created,
+		"updated":   result.Updated,
+		"unchanged": result.Unchanged,
+		"skipped":   result.Skipped,
+		"deleted":   result.Deleted,
+		"changes":   changes,
+	}))
+}
+
+// GetCalendar 获取日期范围内的校历事件
+func (cal *Calendar) GetCalendar(c *gin.Context) {
+	type QueryParams struct {
+		StartDate string `form:"startDate" binding:"required"`
+		EndDate   string `form:"endDate" binding:"required"`
+		Kind      string `form:"kind" binding:"omitempty,oneof=term holiday makeup"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	start, err := common.ParseDate(params.StartDate)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	end, err := common.ParseDate(params.EndDate)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	events, err := srv.GetCalendarEvents(start, end, params.Kind)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []calendarEventForm{}
+	for _, event := range events {
+		response = append(response, newCalendarEventForm(event))
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"events": response}))
+}
@@ -3,6 +3,17 @@ package controller
 type Controller struct {
 	User
 	Admin
+	Calendar
+	Exam
+	Room
+	Timetable
+	Audit
+	Grade
+	Attendance
+	Evaluation
+	Degree
+	OfficeHour
+	Teacher
 }
 
 func New() *Controller {
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	seatAlertPollSeconds = 30 // 客户端轮询空余名额提醒的基础间隔
+	seatAlertPollJitter  = 15 // 在基础间隔上随机增加的秒数, 使各客户端错开刷新
+)
+
+// WatchCourse 关注课程
+func (u *User) WatchCourse(c *gin.Context) {
+	var form struct {
+		CourseID int64 `json:"courseId" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	if err := srv.WatchCourse(studentID, form.CourseID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// UnwatchCourse 取消关注课程
+func (u *User) UnwatchCourse(c *gin.Context) {
+	courseIDStr := c.Param("courseId")
+	courseID, err := strconv.ParseInt(courseIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 courseId: %v", courseIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	if err := srv.UnwatchCourse(studentID, courseID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetCourseWatches 获取自己的关注列表
+func (u *User) GetCourseWatches(c *gin.Context) {
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	watched, err := srv.GetCourseWatches(studentID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type watchForm struct {
+		CourseID   int64  `json:"courseId"`
+		CourseName string `json:"courseName"`
+		Capacity   int    `json:"capacity"`
+		Enrolled   int    `json:"enrolled"`
+		Remaining  int    `json:"remaining"`
+		WatchedAt  string `json:"watchedAt"`
+		NotifiedAt string `json:"notifiedAt,omitempty"`
+	}
+	response := []watchForm{}
+	for _, item := range watched {
+		form := watchForm{
+			CourseID:   item.Course.CourseID,
+			CourseName: item.Course.CourseName,
+			Capacity:   item.Course.Capacity,
+			Enrolled:   item.Enrolled,
+			Remaining:  max(item.Course.Capacity-item.Enrolled, 0),
+			WatchedAt:  common.FormatTime(item.Watch.CreatedAt),
+		}
+		if item.Watch.NotifiedAt != nil {
+			form.NotifiedAt = common.FormatTime(*item.Watch.NotifiedAt)
+		}
+		response = append(response, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"courses": response}))
+}
+
+// GetSeatAlerts 增量获取空余名额提醒, 客户端传入上次返回的cursor, 并在pollAfter秒后再次请求
+func (u *User) GetSeatAlerts(c *gin.Context) {
+	var params struct {
+		Cursor int64 `form:"cursor" binding:"min=0"`
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	notices, err := srv.GetSeatAlerts(studentID, params.Cursor)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type alertForm struct {
+		ID        int64  `json:"id"`
+		CourseID  int64  `json:"courseId"`
+		Message   string `json:"message"`
+		CreatedAt string `json:"createdAt"`
+	}
+	response := []alertForm{}
+	cursor := params.Cursor
+	for _, notice := range notices {
+		response = append(response, alertForm{
+			ID:        notice.ID,
+			CourseID:  notice.CourseID,
+			Message:   notice.Message,
+			CreatedAt: common.FormatTime(notice.CreatedAt),
+		})
+		cursor = notice.ID
+	}
+	pollAfter := seatAlertPollSeconds + rand.Intn(seatAlertPollJitter+1)
+	c.Header("Retry-After", strconv.Itoa(pollAfter))
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"alerts":    response,
+		"cursor":    cursor,
+		"pollAfter": pollAfter,
+	}))
+}
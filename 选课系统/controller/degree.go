@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Degree struct{}
+
+type degreeProgramForm struct {
+	ID           int64                 `json:"id"`
+	Major        string                `json:"major"`
+	EntryYear    int                   `json:"entryYear"`
+	Name         string                `json:"name"`
+	Requirements []service.Requirement `json:"requirements"`
+}
+
+type auditCourseForm struct {
+	ID         int64   `json:"id"`
+	CourseCode string  `json:"courseCode"`
+	CourseName string  `json:"courseName"`
+	Credits    float64 `json:"credits"`
+	Category   string  `json:"category"`
+}
+
+func newAuditCourseForms(courses []model.Course) []auditCourseForm {
+	forms := make([]auditCourseForm, 0, len(courses))
+	for _, course := range courses {
+		forms = append(forms, auditCourseForm{
+			ID:         course.CourseID,
+			CourseCode: course.CourseCode,
+			CourseName: course.CourseName,
+			Credits:    course.Credits,
+			Category:   course.Category,
+		})
+	}
+	return forms
+}
+
+// SaveDegreeProgram 添加或更新培养方案, 带id时为更新
+func (d *Degree) SaveDegreeProgram(c *gin.Context) {
+	var form struct {
+		ID           int64                 `json:"id" binding:"min=0"`
+		Major        string                `json:"major" binding:"required,max=128"`
+		EntryYear    int                   `json:"entryYear" binding:"required,min=1900,max=2100"`
+		Name         string                `json:"name" binding:"max=128"`
+		Requirements []service.Requirement `json:"requirements" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	program := model.DegreeProgram{
+		Major:     form.Major,
+		EntryYear: form.EntryYear,
+		Name:      strings.TrimSpace(form.Name),
+	}
+	program.ID = form.ID
+	if err := srv.SaveDegreeProgram(&program, form.Requirements); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": program.ID}))
+}
+
+// GetDegreePrograms 获取培养方案, 可按专业筛选
+func (d *Degree) GetDegreePrograms(c *gin.Context) {
+	programs, err := srv.GetDegreePrograms(c.Query("major"))
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []degreeProgramForm{}
+	for _, program := range programs {
+		requirements, err := service.DegreeRequirements(program)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.SysErr))
+			return
+		}
+		response = append(response, degreeProgramForm{
+			ID:           program.ID,
+			Major:        program.Major,
+			EntryYear:    program.EntryYear,
+			Name:         program.Name,
+			Requirements: requirements,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"programs": response}))
+}
+
+// DeleteDegreeProgram 删除培养方案
+func (d *Degree) DeleteDegreeProgram(c *gin.Context) {
+	programIDStr := c.Param("programId")
+	programID, err := strconv.ParseInt(programIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 programId: %v", programIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.DeleteDegreeProgram(programID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// AuditDegree 毕业要求审核, 管理员通过路径参数指定学生, 学生审核自己; 按major和entryYear选择培养方案, 未指定时取学籍信息
+func (d *Degree) AuditDegree(c *gin.Context) {
+	studentID := c.Param("studentId")
+	if studentID == "" {
+		studentID = SessionGet(c, "user").(UserSession).UserID
+	}
+	major := strings.TrimSpace(c.Query("major"))
+	entryYearStr := c.Query("entryYear")
+	var entryYear int
+	if major == "" || entryYearStr == "" {
+		profile, err := srv.GetStudentProfile(studentID)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return
+		}
+		if profile != nil && major == "" {
+			major = profile.Major
+		}
+		if profile != nil && entryYearStr == "" {
+			entryYear = profile.EntryYear
+		}
+	}
+	if major == "" {
+		logrus.Errorf("缺少专业")
+		c.Error(common.ErrNew(errors.New("请指定专业"), common.ParamErr))
+		return
+	}
+	if entryYearStr != "" {
+		var err error
+		if entryYear, err = strconv.Atoi(entryYearStr); err != nil {
+			logrus.Errorf("无效的 entryYear: %v", entryYearStr)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+	} else if entryYear == 0 {
+		logrus.Errorf("缺少入学年份")
+		c.Error(common.ErrNew(errors.New("请指定入学年份"), common.ParamErr))
+		return
+	}
+	audit, err := srv.AuditDegree(studentID, major, entryYear)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type requirementForm struct {
+		service.Requirement
+		Status         string            `json:"status"`
+		Completed      []auditCourseForm `json:"completed"`
+		InProgress     []auditCourseForm `json:"inProgress"`
+		Missing        []auditCourseForm `json:"missing"`
+		Credits        *float64          `json:"credits,omitempty"`
+		PlannedCredits *float64          `json:"plannedCredits,omitempty"`
+		Suggestions    []auditCourseForm `json:"suggestions"`
+	}
+	requirements := []requirementForm{}
+	for _, item := range audit.Requirements {
+		form := requirementForm{
+			Requirement: item.Requirement,
+			Status:      item.Status,
+			Completed:   newAuditCourseForms(item.Completed),
+			InProgress:  newAuditCourseForms(item.InProgress),
+			Missing:     newAuditCourseForms(item.Missing),
+			Suggestions: newAuditCourseForms(item.Suggestions),
+		}
+		if item.Requirement.Kind == service.RequirementCredits {
+			credits, plannedCredits := item.Credits, item.PlannedCredits
+			form.Credits = &credits
+			form.PlannedCredits = &plannedCredits
+		}
+		requirements = append(requirements, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"studentId":    studentID,
+		"programId":    audit.Program.ID,
+		"major":        audit.Program.Major,
+		"entryYear":    audit.Program.EntryYear,
+		"name":         audit.Program.Name,
+		"status":       audit.Status,
+		"requirements": requirements,
+	}))
+}
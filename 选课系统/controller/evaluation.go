@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Evaluation struct{}
+
+type evaluationSurveyForm struct {
+	ID        int64                        `json:"id"`
+	Title     string                       `json:"title"`
+	Questions []service.EvaluationQuestion `json:"questions"`
+	StartTime string                       `json:"startTime"`
+	EndTime   string                       `json:"endTime"`
+}
+
+func newEvaluationSurveyForm(survey model.EvaluationSurvey) (evaluationSurveyForm, error) {
+	questions, err := service.EvaluationQuestions(survey)
+	if err != nil {
+		return evaluationSurveyForm{}, err
+	}
+	return evaluationSurveyForm{
+		ID:        survey.ID,
+		Title:     survey.Title,
+		Questions: questions,
+		StartTime: common.FormatTime(survey.StartTime),
+		EndTime:   common.FormatTime(survey.EndTime),
+	}, nil
+}
+
+// parseSurveyID 解析路径中的问卷ID; 出错时已写入c.Error
+func parseSurveyID(c *gin.Context) (int64, bool) {
+	surveyIDStr := c.Param("surveyId")
+	surveyID, err := strconv.ParseInt(surveyIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 surveyId: %v", surveyIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return 0, false
+	}
+	return surveyID, true
+}
+
+// SaveEvaluationSurvey 添加或更新评教问卷, 带id时为更新
+func (e *Evaluation) SaveEvaluationSurvey(c *gin.Context) {
+	var form struct {
+		ID        int64                        `json:"id" binding:"min=0"`
+		Title     string                       `json:"title" binding:"required,max=128"`
+		Questions []service.EvaluationQuestion `json:"questions" binding:"required,min=1"`
+		StartTime string                       `json:"startTime" binding:"required"`
+		EndTime   string                       `json:"endTime" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	startTime, err := common.ParseTime(form.StartTime)
+	if err != nil {
+		logrus.Errorf("开始时间格式错误: %v", form.StartTime)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	endTime, err := common.ParseTime(form.EndTime)
+	if err != nil {
+		logrus.Errorf("结束时间格式错误: %v", form.EndTime)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	survey := model.EvaluationSurvey{
+		Title:     form.Title,
+		StartTime: startTime,
+		EndTime:   endTime,
+	}
+	survey.ID = form.ID
+	if err := srv.SaveEvaluationSurvey(&survey, form.Questions); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": survey.ID}))
+}
+
+// GetEvaluationSurveys 获取全部评教问卷
+func (e *Evaluation) GetEvaluationSurveys(c *gin.Context) {
+	surveys, err := srv.GetEvaluationSurveys()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []evaluationSurveyForm{}
+	for _, survey := range surveys {
+		form, err := newEvaluationSurveyForm(survey)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.SysErr))
+			return
+		}
+		response = append(response, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"surveys": response}))
+}
+
+// DeleteEvaluationSurvey 删除评教问卷
+func (e *Evaluation) DeleteEvaluationSurvey(c *gin.Context) {
+	surveyID, ok := parseSurveyID(c)
+	if !ok {
+		return
+	}
+	if err := srv.DeleteEvaluationSurvey(surveyID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetStudentEvaluations 学生获取正在进行的评教问卷及需要评价的课程
+func (e *Evaluation) GetStudentEvaluations(c *gin.Context) {
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	pending, err := srv.GetStudentEvaluations(studentID, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type courseForm struct {
+		ID         int64  `json:"id"`
+		CourseName string `json:"courseName"`
+		Submitted  bool   `json:"submitted"`
+	}
+	type surveyForm struct {
+		evaluationSurveyForm
+		Courses []courseForm `json:"courses"`
+	}
+	response := []surveyForm{}
+	for _, item := range pending {
+		form, err := newEvaluationSurveyForm(item.Survey)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.SysErr))
+			return
+		}
+		courses := []courseForm{}
+		for _, course := range item.Courses {
+			courses = append(courses, courseForm{
+				ID:         course.CourseID,
+				CourseName: course.CourseName,
+				Submitted:  item.Submitted[course.CourseID],
+			})
+		}
+		response = append(response, surveyForm{evaluationSurveyForm: form, Courses: courses})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"surveys": response}))
+}
+
+// SubmitEvaluation 学生提交对一门课程的评价
+func (e *Evaluation) SubmitEvaluation(c *gin.Context) {
+	surveyID, ok := parseSurveyID(c)
+	if !ok {
+		return
+	}
+	var form struct {
+		CourseID int64                      `json:"courseId" binding:"required,min=1"`
+		Answers  []service.EvaluationAnswer `json:"answers" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	if err := srv.SubmitEvaluation(studentID, surveyID, form.CourseID, form.Answers, common.Now()); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// newEvaluationResultResponse 将汇总结果转换为响应
+func newEvaluationResultResponse(result *service.EvaluationResult) gin.H {
+	type questionForm struct {
+		Kind         string   `json:"kind"`
+		Title        string   `json:"title"`
+		Scale        int      `json:"scale,omitempty"`
+		Answered     int      `json:"answered"`
+		Average      *float64 `json:"average,omitempty"`
+		Distribution []int    `json:"distribution,omitempty"`
+		Comments     []string `json:"comments,omitempty"`
+	}
+	questions := []questionForm{}
+	for _, item := range result.Questions {
+		form := questionForm{
+			Kind:         item.Question.Kind,
+			Title:        item.Question.Title,
+			Scale:        item.Question.Scale,
+			Answered:     item.Answered,
+			Distribution: item.Distribution,
+		}
+		if item.Average != nil {
+			average := math.Round(*item.Average*100) / 100
+			form.Average = &average
+		}
+		if item.Question.Kind == model.EvaluationText {
+			form.Comments = item.Comments
+		}
+		questions = append(questions, form)
+	}
+	var rate float64
+	if result.Enrolled > 0 {
+		rate = math.Round(float64(result.Responses)/float64(result.Enrolled)*1000) / 1000
+	}
+	return gin.H{
+		"surveyId":     result.Survey.ID,
+		"title":        result.Survey.Title,
+		"courseIds":    result.CourseIDs,
+		"enrolled":     result.Enrolled,
+		"responses":    result.Responses,
+		"responseRate": rate,
+		"questions":    questions,
+	}
+}
+
+// GetCourseEvaluation 获取课程的评教汇总结果, 教师只能查看自己的课程且须在评教结束后
+func (e *Evaluation) GetCourseEvaluation(c *gin.Context) {
+	surveyID, ok := parseSurveyID(c)
+	if !ok {
+		return
+	}
+	courseID, ok := staffCourseID(c)
+	if !ok {
+		return
+	}
+	forTeacher := SessionGet(c, "user").(UserSession).Level == 3
+	result, err := srv.GetCourseEvaluation(surveyID, courseID, forTeacher, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, newEvaluationResultResponse(result)))
+}
+
+// GetTeacherEvaluation 获取教师所授课程的评教汇总结果, 管理员通过路径参数指定教师, 教师查看自己的结果
+func (e *Evaluation) GetTeacherEvaluation(c *gin.Context) {
+	surveyID, ok := parseSurveyID(c)
+	if !ok {
+		return
+	}
+	forTeacher := SessionGet(c, "user").(UserSession).Level == 3
+	var teacherID int64
+	var err error
+	if forTeacher {
+		teacherID, err = currentTeacherID(c)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return
+		}
+	} else {
+		teacherIDStr := c.Param("teacherId")
+		teacherID, err = strconv.ParseInt(teacherIDStr, 10, 64)
+		if err != nil {
+			logrus.Errorf("无效的 teacherId: %v", teacherIDStr)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+	}
+	result, err := srv.GetTeacherEvaluation(surveyID, teacherID, forTeacher, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := newEvaluationResultResponse(result)
+	response["teacherId"] = teacherID
+	c.JSON(http.StatusOK, ResponseNew(c, response))
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"encoding/json"
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Exam struct{}
+
+type examForm struct {
+	CourseID   int64  `json:"courseId"`
+	CourseName string `json:"courseName"`
+	RoomID     *int64 `json:"roomId"`
+	Room       string `json:"room"`
+	StartTime  string `json:"startTime"`
+	EndTime    string `json:"endTime"`
+	Students   int    `json:"students"`
+}
+
+func newExamForms(exams []model.Exam) ([]examForm, error) {
+	var courseIDs []int64
+	for _, exam := range exams {
+		courseIDs = append(courseIDs, exam.CourseID)
+	}
+	names, err := srv.GetCourseNames(courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	forms := []examForm{}
+	for _, exam := range exams {
+		forms = append(forms, examForm{
+			CourseID:   exam.CourseID,
+			CourseName: names[exam.CourseID],
+			RoomID:     exam.RoomID,
+			Room:       exam.Room,
+			StartTime:  common.FormatTime(exam.StartTime),
+			EndTime:    common.FormatTime(exam.EndTime),
+			Students:   exam.Students,
+		})
+	}
+	return forms, nil
+}
+
+// CreateExamPlan 计算考试安排并保存为待审核方案
+func (e *Exam) CreateExamPlan(c *gin.Context) {
+	type windowForm struct {
+		StartTime string `json:"startTime" binding:"required"`
+		EndTime   string `json:"endTime" binding:"required"`
+	}
+	type durationForm struct {
+		CourseID int64 `json:"courseId" binding:"required"`
+		Minutes  int   `json:"minutes" binding:"required,gt=0"`
+	}
+	var form struct {
+		Name            string         `json:"name" binding:"required"`
+		CourseIDs       []int64        `json:"courseIds"`
+		Windows         []windowForm   `json:"windows" binding:"required,dive"`
+		RoomIDs         []int64        `json:"roomIds" binding:"required,min=1"`
+		Durations       []durationForm `json:"durations" binding:"dive"`
+		DefaultDuration int            `json:"defaultDuration" binding:"omitempty,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	req := service.ExamRequest{
+		Name:            form.Name,
+		CourseIDs:       form.CourseIDs,
+		RoomIDs:         form.RoomIDs,
+		Durations:       make(map[int64]time.Duration),
+		DefaultDuration: 120 * time.Minute,
+	}
+	if form.DefaultDuration > 0 {
+		req.DefaultDuration = time.Duration(form.DefaultDuration) * time.Minute
+	}
+	for _, window := range form.Windows {
+		startTime, err := common.ParseTime(window.StartTime)
+		if err != nil {
+			logrus.Errorf("开始时间格式错误: %v", window.StartTime)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		endTime, err := common.ParseTime(window.EndTime)
+		if err != nil {
+			logrus.Errorf("结束时间格式错误: %v", window.EndTime)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		req.Windows = append(req.Windows, service.ExamWindow{StartTime: startTime, EndTime: endTime})
+	}
+	for _, duration := range form.Durations {
+		req.Durations[duration.CourseID] = time.Duration(duration.Minutes) * time.Minute
+	}
+	plan, err := srv.CreateExamPlan(req)
+	if err != nil {
+		logrus.Errorf("生成考试安排失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": plan.ID}))
+}
+
+// GetExamPlans 获取考试安排方案列表
+func (e *Exam) GetExamPlans(c *gin.Context) {
+	plans, err := srv.GetExamPlans()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type planForm struct {
+		ID        int64  `json:"id"`
+		Name      string `json:"name"`
+		Status    string `json:"status"`
+		CreatedAt string `json:"createdAt"`
+	}
+	response := []planForm{}
+	for _, plan := range plans {
+		response = append(response, planForm{
+			ID:        plan.ID,
+			Name:      plan.Name,
+			Status:    plan.Status,
+			CreatedAt: common.FormatTime(plan.CreatedAt),
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"plans": response}))
+}
+
+// GetExamPlan 获取考试安排方案详情
+func (e *Exam) GetExamPlan(c *gin.Context) {
+	planIDStr := c.Param("planId")
+	planID, err := strconv.ParseInt(planIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 planId: %v", planIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	plan, err := srv.GetExamPlan(planID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	exams, err := newExamForms(plan.Exams)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	var unscheduled []service.UnscheduledExam
+	if err := json.Unmarshal(plan.Unscheduled, &unscheduled); err != nil {
+		c.Error(common.ErrNew(err, common.SysErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"id":          plan.ID,
+		"name":        plan.Name,
+		"status":      plan.Status,
+		"exams":       exams,
+		"unscheduled": unscheduled,
+	}))
+}
+
+// ApproveExamPlan 通过考试安排方案
+func (e *Exam) ApproveExamPlan(c *gin.Context) {
+	e.reviewExamPlan(c, true)
+}
+
+// RejectExamPlan 驳回考试安排方案
+func (e *Exam) RejectExamPlan(c *gin.Context) {
+	e.reviewExamPlan(c, false)
+}
+
+func (e *Exam) reviewExamPlan(c *gin.Context, approve bool) {
+	planIDStr := c.Param("planId")
+	planID, err := strconv.ParseInt(planIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 planId: %v", planIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.ReviewExamPlan(planID, approve); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetExams 获取学生自己的考试安排
+func (e *Exam) GetExams(c *gin.Context) {
+	userSession := SessionGet(c, "user")
+	studentID := userSession.(UserSession).UserID
+	exams, err := srv.GetStudentExams(studentID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response, err := newExamForms(exams)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"exams": response}))
+}
@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Grade struct{}
+
+type gradeScaleForm struct {
+	ID        int64               `json:"id"`
+	Name      string              `json:"name"`
+	Kind      string              `json:"kind"`
+	Rules     []service.GradeRule `json:"rules"`
+	IsDefault bool                `json:"isDefault"`
+}
+
+type gradeForm struct {
+	CourseID   int64    `json:"courseId"`
+	StudentID  string   `json:"studentId"`
+	Grade      string   `json:"grade"`
+	Points     *float64 `json:"points"`
+	Passed     bool     `json:"passed"`
+	RecordedBy string   `json:"recordedBy"`
+	UpdatedAt  string   `json:"updatedAt"`
+}
+
+func newGradeForm(grade model.Grade) gradeForm {
+	return gradeForm{
+		CourseID:   grade.CourseID,
+		StudentID:  grade.StudentID,
+		Grade:      grade.Value,
+		Points:     grade.Points,
+		Passed:     grade.Passed,
+		RecordedBy: grade.RecordedBy,
+		UpdatedAt:  common.FormatTime(grade.UpdatedAt),
+	}
+}
+
+// roundGPA 平均绩点保留两位小数
+func roundGPA(gpa *float64) *float64 {
+	if gpa == nil {
+		return nil
+	}
+	rounded := math.Round(*gpa*100) / 100
+	return &rounded
+}
+
+// SaveGradeScale 添加或更新成绩等级制, 带id时为更新
+func (g *Grade) SaveGradeScale(c *gin.Context) {
+	var form struct {
+		ID        int64               `json:"id" binding:"min=0"`
+		Name      string              `json:"name" binding:"required,max=64"`
+		Kind      string              `json:"kind" binding:"required,oneof=percent letter pass_fail"`
+		Rules     []service.GradeRule `json:"rules" binding:"required,min=1"`
+		IsDefault bool                `json:"isDefault"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	scale := model.GradeScale{
+		Name:      form.Name,
+		Kind:      form.Kind,
+		IsDefault: form.IsDefault,
+	}
+	scale.ID = form.ID
+	if err := srv.SaveGradeScale(&scale, form.Rules); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": scale.ID}))
+}
+
+// GetGradeScales 获取全部成绩等级制
+func (g *Grade) GetGradeScales(c *gin.Context) {
+	scales, err := srv.GetGradeScales()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []gradeScaleForm{}
+	for _, scale := range scales {
+		rules, err := service.GradeRules(scale)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.SysErr))
+			return
+		}
+		response = append(response, gradeScaleForm{
+			ID:        scale.ID,
+			Name:      scale.Name,
+			Kind:      scale.Kind,
+			Rules:     rules,
+			IsDefault: scale.IsDefault,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"scales": response}))
+}
+
+// DeleteGradeScale 删除成绩等级制
+func (g *Grade) DeleteGradeScale(c *gin.Context) {
+	scaleIDStr := c.Param("scaleId")
+	scaleID, err := strconv.ParseInt(scaleIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 scaleId: %v", scaleIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.DeleteGradeScale(scaleID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// staffCourseID 解析路径中的课程ID, 教师只能操作自己讲授的课程; 出错时已写入c.Error
+func staffCourseID(c *gin.Context) (int64, bool) {
+	courseIDStr := c.Param("courseId")
+	courseID, err := strconv.ParseInt(courseIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 courseId: %v", courseIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return 0, false
+	}
+	if SessionGet(c, "user").(UserSession).Level == 3 {
+		teacherID, err := currentTeacherID(c)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return 0, false
+		}
+		if err := srv.CheckCourseTeacher(teacherID, courseID); err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return 0, false
+		}
+	}
+	return courseID, true
+}
+
+// RecordGrade 录入或修改学生的课程成绩
+func (g *Grade) RecordGrade(c *gin.Context) {
+	courseID, ok := staffCourseID(c)
+	if !ok {
+		return
+	}
+	var form struct {
+		StudentID string `json:"studentId" binding:"required"`
+		Grade     string `json:"grade" binding:"required,max=16"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	recordedBy := SessionGet(c, "user").(UserSession).UserID
+	grade, err := srv.RecordGrade(courseID, form.StudentID, form.Grade, recordedBy)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, newGradeForm(*grade)))
+}
+
+// GetCourseGrades 获取课程的全部成绩
+func (g *Grade) GetCourseGrades(c *gin.Context) {
+	courseID, ok := staffCourseID(c)
+	if !ok {
+		return
+	}
+	grades, err := srv.GetCourseGrades(courseID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []gradeForm{}
+	for _, grade := range grades {
+		response = append(response, newGradeForm(grade))
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"grades": response}))
+}
+
+// ImportGrades 从CSV文件批量导入成绩, 每行为课程ID、学号、成绩
+// 默认仅预览, commit=true且所有行都正确时才写入
+func (g *Grade) ImportGrades(c *gin.Context) {
+	var form struct {
+		Commit bool `form:"commit"`
+	}
+	if err := c.ShouldBind(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		logrus.Errorf("未上传成绩文件: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.SysErr))
+		return
+	}
+	defer file.Close()
+	recordedBy := SessionGet(c, "user").(UserSession).UserID
+	result, err := srv.ImportGrades(file, form.Commit, recordedBy)
+	if err != nil {
+		logrus.Errorf("导入成绩失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type rowForm struct {
+		Line      int      `json:"line"`
+		CourseID  int64    `json:"courseId"`
+		StudentID string   `json:"studentId"`
+		Grade     string   `json:"grade"`
+		Points    *float64 `json:"points"`
+		Passed    bool     `json:"passed"`
+		Action    string   `json:"action,omitempty"`
+		Error     string   `json:"error,omitempty"`
+	}
+	rows := []rowForm{}
+	for _, row := range result.Rows {
+		rows = append(rows, rowForm{
+			Line:      row.Line,
+			CourseID:  row.CourseID,
+			StudentID: row.StudentID,
+			Grade:     row.Value,
+			Points:    row.Points,
+			Passed:    row.Passed,
+			Action:    row.Action,
+			Error:     row.Error,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"committed": result.Committed,
+		"created":   result.Created,
+		"updated":   result.Updated,
+		"unchanged": result.Unchanged,
+		"failed":    result.Failed,
+		"rows":      rows,
+	}))
+}
+
+// GetTranscript 获取成绩单, 管理员通过路径参数指定学生, 学生查看自己的成绩单
+func (g *Grade) GetTranscript(c *gin.Context) {
+	studentID := c.Param("studentId")
+	if studentID == "" {
+		studentID = SessionGet(c, "user").(UserSession).UserID
+	}
+	transcript, err := srv.GetTranscript(studentID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type courseForm struct {
+		ID         int64    `json:"id"`
+		CourseName string   `json:"courseName"`
+		Credits    float64  `json:"credits"`
+		Grade      string   `json:"grade"`
+		Points     *float64 `json:"points"`
+		Passed     bool     `json:"passed"`
+	}
+	type termForm struct {
+		Name          string       `json:"name"`
+		Courses       []courseForm `json:"courses"`
+		Credits       float64      `json:"credits"`
+		EarnedCredits float64      `json:"earnedCredits"`
+		GPA           *float64     `json:"gpa"`
+	}
+	terms := []termForm{}
+	for _, term := range transcript.Terms {
+		form := termForm{
+			Name:          term.Name,
+			Courses:       []courseForm{},
+			Credits:       term.Credits,
+			EarnedCredits: term.EarnedCredits,
+			GPA:           roundGPA(term.GPA),
+		}
+		for _, item := range term.Courses {
+			form.Courses = append(form.Courses, courseForm{
+				ID:         item.Course.CourseID,
+				CourseName: item.Course.CourseName,
+				Credits:    item.Course.Credits,
+				Grade:      item.Grade.Value,
+				Points:     item.Grade.Points,
+				Passed:     item.Grade.Passed,
+			})
+		}
+		terms = append(terms, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"studentId":     studentID,
+		"terms":         terms,
+		"credits":       transcript.Credits,
+		"earnedCredits": transcript.EarnedCredits,
+		"gpa":           roundGPA(transcript.GPA),
+	}))
+}
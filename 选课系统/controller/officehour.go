@@ -0,0 +1,355 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"finaltenzor/service/ics"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type OfficeHour struct{}
+
+const officeHourSlotDays = 14 // 不指定日期范围时查询的可预约天数
+
+type officeHourForm struct {
+	ID          int64  `json:"id"`
+	TeacherID   int64  `json:"teacherId"`
+	FromDate    string `json:"fromDate"`
+	ToDate      string `json:"toDate"`
+	Weekday     int    `json:"weekday"`
+	StartClock  string `json:"startClock"`
+	EndClock    string `json:"endClock"`
+	SlotMinutes int    `json:"slotMinutes"`
+	Location    string `json:"location"`
+	Note        string `json:"note"`
+}
+
+func newOfficeHourForm(block model.OfficeHour) officeHourForm {
+	return officeHourForm{
+		ID:          block.ID,
+		TeacherID:   block.TeacherID,
+		FromDate:    common.FormatDate(block.FromDate),
+		ToDate:      common.FormatDate(block.ToDate),
+		Weekday:     block.Weekday,
+		StartClock:  block.StartClock,
+		EndClock:    block.EndClock,
+		SlotMinutes: block.SlotMinutes,
+		Location:    block.Location,
+		Note:        block.Note,
+	}
+}
+
+// AddOfficeHour 教师发布每周的答疑时间段, 不指定日期范围时默认为当前学期
+func (o *OfficeHour) AddOfficeHour(c *gin.Context) {
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	var form struct {
+		FromDate    string `json:"fromDate"`
+		ToDate      string `json:"toDate"`
+		Weekday     int    `json:"weekday" binding:"required,min=1,max=7"`
+		StartClock  string `json:"startClock" binding:"required"`
+		EndClock    string `json:"endClock" binding:"required"`
+		SlotMinutes int    `json:"slotMinutes" binding:"required,min=1"`
+		Location    string `json:"location" binding:"max=128"`
+		Note        string `json:"note" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var fromDate, toDate time.Time
+	if form.FromDate != "" || form.ToDate != "" {
+		if fromDate, err = common.ParseDate(form.FromDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		if toDate, err = common.ParseDate(form.ToDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+	} else {
+		start, end, err := srv.TermRange(common.Now())
+		if err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return
+		}
+		fromDate, toDate = start, end.AddDate(0, 0, -1)
+	}
+	block := model.OfficeHour{
+		TeacherID:   teacherID,
+		FromDate:    fromDate,
+		ToDate:      toDate,
+		Weekday:     form.Weekday,
+		StartClock:  form.StartClock,
+		EndClock:    form.EndClock,
+		SlotMinutes: form.SlotMinutes,
+		Location:    strings.TrimSpace(form.Location),
+		Note:        form.Note,
+	}
+	if err := srv.AddOfficeHour(&block); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": block.ID}))
+}
+
+// GetOfficeHours 教师查看自己的答疑时间段
+func (o *OfficeHour) GetOfficeHours(c *gin.Context) {
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	blocks, err := srv.GetOfficeHours(teacherID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := make([]officeHourForm, 0, len(blocks))
+	for _, block := range blocks {
+		response = append(response, newOfficeHourForm(block))
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"officeHours": response}))
+}
+
+// DeleteOfficeHour 教师删除自己的答疑时间段
+func (o *OfficeHour) DeleteOfficeHour(c *gin.Context) {
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	blockIDStr := c.Param("officeHourId")
+	blockID, err := strconv.ParseInt(blockIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 officeHourId: %v", blockIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.DeleteOfficeHour(teacherID, blockID, common.Now()); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetOfficeHourTeachers 学生查看可以预约答疑的教师, 即所选课程的授课教师
+func (o *OfficeHour) GetOfficeHourTeachers(c *gin.Context) {
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	teachers, err := srv.GetStudentTeachers(studentID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type teacherForm struct {
+		ID         int64  `json:"id"`
+		Name       string `json:"name"`
+		Department string `json:"department"`
+	}
+	response := make([]teacherForm, 0, len(teachers))
+	for _, teacher := range teachers {
+		response = append(response, teacherForm{ID: teacher.ID, Name: teacher.Name, Department: teacher.Department})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"teachers": response}))
+}
+
+// GetOfficeHourSlots 学生查看教师的答疑时段及是否已被预约, 默认查询今后两周
+func (o *OfficeHour) GetOfficeHourSlots(c *gin.Context) {
+	teacherIDStr := c.Param("teacherId")
+	teacherID, err := strconv.ParseInt(teacherIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 teacherId: %v", teacherIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	type QueryParams struct {
+		FromDate string `form:"fromDate"`
+		ToDate   string `form:"toDate"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	now := common.Now()
+	from := now
+	to := from.AddDate(0, 0, officeHourSlotDays)
+	if params.FromDate != "" || params.ToDate != "" {
+		if from, err = common.ParseDate(params.FromDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		if to, err = common.ParseDate(params.ToDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	slots, err := srv.GetOfficeHourSlots(studentID, teacherID, from, to, now)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type slotForm struct {
+		OfficeHourID int64  `json:"officeHourId"`
+		StartTime    string `json:"startTime"`
+		EndTime      string `json:"endTime"`
+		Location     string `json:"location"`
+		Booked       bool   `json:"booked"`
+		Mine         bool   `json:"mine"`
+	}
+	response := make([]slotForm, 0, len(slots))
+	for _, slot := range slots {
+		response = append(response, slotForm{
+			OfficeHourID: slot.Block.ID,
+			StartTime:    common.FormatTime(slot.Start),
+			EndTime:      common.FormatTime(slot.End),
+			Location:     slot.Block.Location,
+			Booked:       slot.Booked,
+			Mine:         slot.Mine,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"slots": response}))
+}
+
+// BookOfficeHour 学生预约答疑时段
+func (o *OfficeHour) BookOfficeHour(c *gin.Context) {
+	var form struct {
+		OfficeHourID int64  `json:"officeHourId" binding:"required,min=1"`
+		StartTime    string `json:"startTime" binding:"required"`
+		Note         string `json:"note" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	start, err := common.ParseTime(form.StartTime)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	booking, err := srv.BookOfficeHour(studentID, form.OfficeHourID, start, form.Note, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": booking.ID}))
+}
+
+// CancelOfficeHourBooking 学生取消自己的答疑预约
+func (o *OfficeHour) CancelOfficeHourBooking(c *gin.Context) {
+	bookingIDStr := c.Param("bookingId")
+	bookingID, err := strconv.ParseInt(bookingIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 bookingId: %v", bookingIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	if err := srv.CancelOfficeHourBooking(studentID, bookingID, common.Now()); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// officeHourBookings 查询当前用户今后的答疑预约, 教师看到预约自己的学生, 学生看到自己的预约
+func officeHourBookings(c *gin.Context) ([]service.OfficeHourBookingDetail, bool) {
+	userSession := SessionGet(c, "user").(UserSession)
+	var teacherID int64
+	if userSession.Level == 3 {
+		var err error
+		if teacherID, err = currentTeacherID(c); err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return nil, false
+		}
+	}
+	bookings, err := srv.GetOfficeHourBookings(teacherID, userSession.UserID, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return nil, false
+	}
+	return bookings, true
+}
+
+// GetOfficeHourBookings 查看今后的答疑预约
+func (o *OfficeHour) GetOfficeHourBookings(c *gin.Context) {
+	bookings, ok := officeHourBookings(c)
+	if !ok {
+		return
+	}
+	type bookingForm struct {
+		ID           int64  `json:"id"`
+		OfficeHourID int64  `json:"officeHourId"`
+		TeacherID    int64  `json:"teacherId"`
+		TeacherName  string `json:"teacherName"`
+		StudentID    string `json:"studentId"`
+		StudentName  string `json:"studentName"`
+		StartTime    string `json:"startTime"`
+		EndTime      string `json:"endTime"`
+		Location     string `json:"location"`
+		Note         string `json:"note"`
+	}
+	response := make([]bookingForm, 0, len(bookings))
+	for _, item := range bookings {
+		response = append(response, bookingForm{
+			ID:           item.Booking.ID,
+			OfficeHourID: item.Booking.OfficeHourID,
+			TeacherID:    item.Booking.TeacherID,
+			TeacherName:  item.TeacherName,
+			StudentID:    item.Booking.StudentID,
+			StudentName:  item.StudentName,
+			StartTime:    common.FormatTime(item.Booking.StartTime),
+			EndTime:      common.FormatTime(item.Booking.EndTime),
+			Location:     item.Block.Location,
+			Note:         item.Booking.Note,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"bookings": response}))
+}
+
+// ExportOfficeHourBookings 把今后的答疑预约导出为ICS日历, 便于导入手机或邮箱日历
+func (o *OfficeHour) ExportOfficeHourBookings(c *gin.Context) {
+	bookings, ok := officeHourBookings(c)
+	if !ok {
+		return
+	}
+	forTeacher := SessionGet(c, "user").(UserSession).Level == 3
+	events := make([]ics.Event, 0, len(bookings))
+	for _, item := range bookings {
+		summary := fmt.Sprintf("答疑: %s老师", item.TeacherName)
+		if forTeacher {
+			summary = fmt.Sprintf("答疑: %s(%s)", item.StudentName, item.Booking.StudentID)
+		}
+		events = append(events, ics.Event{
+			UID:         fmt.Sprintf("office-hour-booking-%d@finaltenzor", item.Booking.ID),
+			Summary:     summary,
+			Description: item.Booking.Note,
+			Location:    item.Block.Location,
+			Start:       item.Booking.StartTime,
+			End:         item.Booking.EndTime,
+		})
+	}
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename=office-hours.ics")
+	c.Status(http.StatusOK)
+	if err := ics.Write(c.Writer, "答疑预约", events); err != nil {
+		logrus.Errorf("导出答疑日历失败: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/service"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RecommendCourses 为当前学生推荐课程, 优先推荐可以弥补培养方案缺口的课程, 未传入major时使用学籍信息中的专业和入学年份
+func (u *User) RecommendCourses(c *gin.Context) {
+	var params struct {
+		Major     string `form:"major"`
+		EntryYear int    `form:"entryYear"`
+		Limit     int    `form:"limit"`
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	params.Major = strings.TrimSpace(params.Major)
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	fromProfile := false
+	if params.Major == "" {
+		profile, err := srv.GetStudentProfile(studentID)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return
+		}
+		if profile != nil && profile.Major != "" && profile.EntryYear != 0 {
+			params.Major, params.EntryYear = profile.Major, profile.EntryYear
+			fromProfile = true
+		}
+	}
+	if params.Major != "" && params.EntryYear == 0 {
+		logrus.Errorf("缺少入学年份")
+		c.Error(common.ErrNew(errors.New("请指定入学年份"), common.ParamErr))
+		return
+	}
+	if params.Limit <= 0 || params.Limit > 50 {
+		params.Limit = 10
+	}
+	recommendations, err := srv.RecommendCourses(studentID, params.Major, params.EntryYear, params.Limit, common.Now())
+	if fromProfile && errors.Is(err, service.ErrNoDegreeProgram) {
+		// 学籍信息中的专业还没有培养方案时, 不按培养方案缺口推荐
+		recommendations, err = srv.RecommendCourses(studentID, "", 0, params.Limit, common.Now())
+	}
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type TimeForm struct {
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
+	}
+	type recommendationForm struct {
+		CourseID       int64      `json:"id"`
+		CourseName     string     `json:"courseName"`
+		Capacity       int        `json:"capacity"`
+		Remaining      int        `json:"remaining"`
+		CourseTeachers []string   `json:"teachers"`
+		Time           []TimeForm `json:"time"`
+		Location       string     `json:"location"`
+		Credits        float64    `json:"credits"`
+		Category       string     `json:"category"`
+		Score          float64    `json:"score"`
+		Reasons        []string   `json:"reasons"`
+	}
+	response := []recommendationForm{}
+	for _, item := range recommendations {
+		course := item.Course
+		timeForms := []TimeForm{}
+		for _, timeItem := range course.CourseTimes {
+			timeForms = append(timeForms, TimeForm{
+				StartTime:   common.FormatTime(timeItem.StartTime),
+				EndTime:     common.FormatTime(timeItem.EndTime),
+				Location:    course.SessionLocation(timeItem),
+				RoomID:      course.SessionRoomID(timeItem),
+				Online:      timeItem.Online,
+				MeetingLink: timeItem.MeetingLink,
+			})
+		}
+		TeacherNames, err := srv.GetTeacherNamesByCourses(course.CourseID)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.OpErr))
+			return
+		}
+		response = append(response, recommendationForm{
+			CourseID:       course.CourseID,
+			CourseName:     course.CourseName,
+			Capacity:       course.Capacity,
+			Remaining:      item.Remaining,
+			CourseTeachers: TeacherNames,
+			Time:           timeForms,
+			Location:       course.Location,
+			Credits:        course.Credits,
+			Category:       course.Category,
+			Score:          math.Round(item.Score*1000) / 1000,
+			Reasons:        item.Reasons,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"courses": response}))
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetRelocationOffers 查看课程停开后提供的调剂名额
+func (u *User) GetRelocationOffers(c *gin.Context) {
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	details, err := srv.GetRelocationOffers(studentID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type offerForm struct {
+		ID                  int64  `json:"id"`
+		CancelledCourseID   int64  `json:"cancelledCourseId"`
+		CancelledCourseName string `json:"cancelledCourseName"`
+		CourseID            int64  `json:"courseId"`
+		CourseName          string `json:"courseName"`
+		Capacity            int    `json:"capacity"`
+		Enrolled            int    `json:"enrolled"`
+		Status              string `json:"status"`
+		CreatedAt           string `json:"createdAt"`
+	}
+	response := []offerForm{}
+	for _, detail := range details {
+		response = append(response, offerForm{
+			ID:                  detail.Offer.ID,
+			CancelledCourseID:   detail.Offer.CancelledCourseID,
+			CancelledCourseName: detail.Offer.CancelledCourseName,
+			CourseID:            detail.Offer.CourseID,
+			CourseName:          detail.Course.CourseName,
+			Capacity:            detail.Course.Capacity,
+			Enrolled:            detail.Enrolled,
+			Status:              detail.Offer.Status,
+			CreatedAt:           common.FormatTime(detail.Offer.CreatedAt),
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"offers": response}))
+}
+
+// respondRelocationOffer 接受或拒绝调剂名额
+func respondRelocationOffer(c *gin.Context, accept bool) {
+	offerIDStr := c.Param("offerId")
+	offerID, err := strconv.ParseInt(offerIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 offerId: %v", offerIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	offer, err := srv.RespondRelocationOffer(studentID, offerID, accept)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"offerId": offer.ID, "status": offer.Status}))
+}
+
+// AcceptRelocationOffer 接受调剂名额, 容量和时间冲突检查通过后选入替代课程
+func (u *User) AcceptRelocationOffer(c *gin.Context) {
+	respondRelocationOffer(c, true)
+}
+
+// DeclineRelocationOffer 拒绝调剂名额
+func (u *User) DeclineRelocationOffer(c *gin.Context) {
+	respondRelocationOffer(c, false)
+}
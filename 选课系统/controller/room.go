@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Room struct{}
+
+type roomForm struct {
+	ID         int64  `json:"id"`
+	Building   string `json:"building"`
+	Name       string `json:"name"`
+	Seats      int    `json:"seats"`
+	Projector  bool   `json:"projector"`
+	Lab        bool   `json:"lab"`
+	Accessible bool   `json:"accessible"`
+}
+
+func newRoomForm(room model.Room) roomForm {
+	return roomForm{
+		ID:         room.ID,
+		Building:   room.Building,
+		Name:       room.Name,
+		Seats:      room.Seats,
+		Projector:  room.Projector,
+		Lab:        room.Lab,
+		Accessible: room.Accessible,
+	}
+}
+
+// AddRoom 添加教室
+func (r *Room) AddRoom(c *gin.Context) {
+	var form struct {
+		Building   string `json:"building"`
+		Name       string `json:"name" binding:"required"`
+		Seats      int    `json:"seats" binding:"required,gt=0"`
+		Projector  bool   `json:"projector"`
+		Lab        bool   `json:"lab"`
+		Accessible bool   `json:"accessible"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	room := model.Room{
+		Building:   form.Building,
+		Name:       form.Name,
+		Seats:      form.Seats,
+		Projector:  form.Projector,
+		Lab:        form.Lab,
+		Accessible: form.Accessible,
+	}
+	if err := srv.AddRoom(&room); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": room.ID}))
+}
+
+// UpdateRoom 更新教室信息
+func (r *Room) UpdateRoom(c *gin.Context) {
+	var form struct {
+		ID         int64  `json:"id" binding:"required"`
+		Building   string `json:"building"`
+		Name       string `json:"name" binding:"required"`
+		Seats      int    `json:"seats" binding:"required,gt=0"`
+		Projector  bool   `json:"projector"`
+		Lab        bool   `json:"lab"`
+		Accessible bool   `json:"accessible"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	room := model.Room{
+		Building:   form.Building,
+		Name:       form.Name,
+		Seats:      form.Seats,
+		Projector:  form.Projector,
+		Lab:        form.Lab,
+		Accessible: form.Accessible,
+	}
+	room.ID = form.ID
+	if err := srv.UpdateRoom(&room); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// DeleteRoom 删除教室
+func (r *Room) DeleteRoom(c *gin.Context) {
+	roomIDStr := c.Param("roomId")
+	roomID, err := strconv.ParseInt(roomIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 roomId: %v", roomIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.DeleteRoom(roomID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetRooms 查询教室列表
+func (r *Room) GetRooms(c *gin.Context) {
+	type QueryParams struct {
+		Page     int    `form:"page" binding:"required,gt=0"`
+		Limit    int    `form:"limit" binding:"required,gt=0"`
+		Building string `form:"building"`
+		MinSeats int    `form:"minSeats" binding:"min=0"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	rooms, total, err := srv.GetRooms(params.Page, params.Limit, params.Building, params.MinSeats)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []roomForm{}
+	for _, room := range rooms {
+		response = append(response, newRoomForm(room))
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"total": total, "rooms": response}))
+}
+
+// GetRoom 获取教室详情
+func (r *Room) GetRoom(c *gin.Context) {
+	roomIDStr := c.Param("roomId")
+	roomID, err := strconv.ParseInt(roomIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 roomId: %v", roomIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	room, err := srv.GetRoom(roomID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, newRoomForm(*room)))
+}
+
+// FindFreeRooms 查找指定时间内空闲的教室
+// 传入startTime和endTime查询单个时间段, 或传入weekday、startClock、endClock、fromDate、toDate查询每周重复的时间段
+func (r *Room) FindFreeRooms(c *gin.Context) {
+	type QueryParams struct {
+		StartTime  string `form:"startTime"`
+		EndTime    string `form:"endTime"`
+		Weekday    int    `form:"weekday" binding:"omitempty,min=1,max=7"`
+		StartClock string `form:"startClock"`
+		EndClock   string `form:"endClock"`
+		FromDate   string `form:"fromDate"`
+		ToDate     string `form:"toDate"`
+		MinSeats   int    `form:"minSeats" binding:"min=0"`
+		Features   string `form:"features"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var times []model.CourseTime
+	if params.Weekday > 0 {
+		fromDate, err := common.ParseDate(params.FromDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		toDate, err := common.ParseDate(params.ToDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		startClock, err := common.ParseClock(params.StartClock)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		endClock, err := common.ParseClock(params.EndClock)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		times, err = service.WeeklyTimes(fromDate, toDate, time.Weekday(params.Weekday%7), startClock, endClock)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+	} else {
+		startTime, err := common.ParseTime(params.StartTime)
+		if err != nil {
+			logrus.Errorf("开始时间格式错误: %v", params.StartTime)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		endTime, err := common.ParseTime(params.EndTime)
+		if err != nil {
+			logrus.Errorf("结束时间格式错误: %v", params.EndTime)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		if !endTime.After(startTime) {
+			c.Error(common.ErrNew(errors.New("结束时间必须晚于开始时间"), common.ParamErr))
+			return
+		}
+		times = []model.CourseTime{{StartTime: startTime, EndTime: endTime}}
+	}
+	req := service.RoomRequirement{MinSeats: params.MinSeats}
+	for _, feature := range strings.Split(params.Features, ",") {
+		switch strings.TrimSpace(feature) {
+		case "":
+		case "projector":
+			req.Projector = true
+		case "lab":
+			req.Lab = true
+		case "accessible":
+			req.Accessible = true
+		default:
+			c.Error(common.ErrNew(errors.New("未知的教室设施: "+feature), common.ParamErr))
+			return
+		}
+	}
+	rooms, err := srv.FindFreeRooms(times, req)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type freeRoomForm struct {
+		roomForm
+		Surplus int `json:"surplus"`
+	}
+	response := []freeRoomForm{}
+	for _, room := range rooms {
+		response = append(response, freeRoomForm{roomForm: newRoomForm(room.Room), Surplus: room.Surplus})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"occurrences": len(times), "rooms": response}))
+}
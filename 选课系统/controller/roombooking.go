@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/config"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// bookingTeacherID 教师预订教室时返回其教师ID, 管理员返回0; 未开放教师预订时已写入c.Error
+func bookingTeacherID(c *gin.Context) (int64, bool) {
+	userSession := SessionGet(c, "user").(UserSession)
+	if userSession.Level != 3 {
+		return 0, true
+	}
+	if !config.Config.TeacherRoomBooking {
+		c.Error(common.ErrNew(errors.New("未开放教师预订教室"), common.AuthErr))
+		return 0, false
+	}
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return 0, false
+	}
+	return teacherID, true
+}
+
+// BookRoom 预订教室
+// 传入startTime和endTime预订单次, 或传入weekday、startClock、endClock、fromDate、toDate按周重复预订
+func (r *Room) BookRoom(c *gin.Context) {
+	teacherID, ok := bookingTeacherID(c)
+	if !ok {
+		return
+	}
+	var form struct {
+		RoomID     int64  `json:"roomId" binding:"required,min=1"`
+		Title      string `json:"title" binding:"required,max=128"`
+		Kind       string `json:"kind" binding:"required,oneof=seminar exam club other"`
+		Note       string `json:"note" binding:"max=255"`
+		StartTime  string `json:"startTime"`
+		EndTime    string `json:"endTime"`
+		Weekday    int    `json:"weekday" binding:"omitempty,min=1,max=7"`
+		StartClock string `json:"startClock"`
+		EndClock   string `json:"endClock"`
+		FromDate   string `json:"fromDate"`
+		ToDate     string `json:"toDate"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var times []model.CourseTime
+	if form.Weekday > 0 {
+		fromDate, err := common.ParseDate(form.FromDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		toDate, err := common.ParseDate(form.ToDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		startClock, err := common.ParseClock(form.StartClock)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		endClock, err := common.ParseClock(form.EndClock)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		times, err = service.WeeklyTimes(fromDate, toDate, time.Weekday(form.Weekday%7), startClock, endClock)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+	} else {
+		startTime, err := common.ParseTime(form.StartTime)
+		if err != nil {
+			logrus.Errorf("开始时间格式错误: %v", form.StartTime)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		endTime, err := common.ParseTime(form.EndTime)
+		if err != nil {
+			logrus.Errorf("结束时间格式错误: %v", form.EndTime)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		times = []model.CourseTime{{StartTime: startTime, EndTime: endTime}}
+	}
+	booking := model.RoomBooking{
+		RoomID:   form.RoomID,
+		Title:    form.Title,
+		Kind:     form.Kind,
+		BookedBy: SessionGet(c, "user").(UserSession).UserID,
+		Note:     form.Note,
+	}
+	if teacherID > 0 {
+		booking.TeacherID = &teacherID
+	}
+	for _, bookingTime := range times {
+		booking.Times = append(booking.Times, model.RoomBookingTime{StartTime: bookingTime.StartTime, EndTime: bookingTime.EndTime})
+	}
+	if err := srv.BookRoom(&booking); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": booking.ID, "occurrences": len(booking.Times)}))
+}
+
+// GetRoomBookings 查询教室预订, 教师只能看到自己的预订
+func (r *Room) GetRoomBookings(c *gin.Context) {
+	teacherID, ok := bookingTeacherID(c)
+	if !ok {
+		return
+	}
+	type QueryParams struct {
+		RoomID   int64  `form:"roomId" binding:"min=0"`
+		FromDate string `form:"fromDate"`
+		ToDate   string `form:"toDate"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var from, to time.Time
+	if params.FromDate != "" || params.ToDate != "" {
+		var err error
+		if from, err = common.ParseDate(params.FromDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		if to, err = common.ParseDate(params.ToDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	bookings, err := srv.GetRoomBookings(params.RoomID, teacherID, from, to)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type timeForm struct {
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+	}
+	type bookingForm struct {
+		ID        int64      `json:"id"`
+		RoomID    int64      `json:"roomId"`
+		Title     string     `json:"title"`
+		Kind      string     `json:"kind"`
+		BookedBy  string     `json:"bookedBy"`
+		TeacherID *int64     `json:"teacherId"`
+		Note      string     `json:"note"`
+		Times     []timeForm `json:"times"`
+	}
+	response := []bookingForm{}
+	for _, booking := range bookings {
+		form := bookingForm{
+			ID:        booking.ID,
+			RoomID:    booking.RoomID,
+			Title:     booking.Title,
+			Kind:      booking.Kind,
+			BookedBy:  booking.BookedBy,
+			TeacherID: booking.TeacherID,
+			Note:      booking.Note,
+			Times:     []timeForm{},
+		}
+		for _, bookingTime := range booking.Times {
+			form.Times = append(form.Times, timeForm{
+				StartTime: common.FormatTime(bookingTime.StartTime),
+				EndTime:   common.FormatTime(bookingTime.EndTime),
+			})
+		}
+		response = append(response, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"bookings": response}))
+}
+
+// CancelRoomBooking 取消教室预订, 教师只能取消自己的预订
+func (r *Room) CancelRoomBooking(c *gin.Context) {
+	teacherID, ok := bookingTeacherID(c)
+	if !ok {
+		return
+	}
+	bookingIDStr := c.Param("bookingId")
+	bookingID, err := strconv.ParseInt(bookingIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 bookingId: %v", bookingIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.CancelRoomBooking(bookingID, teacherID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetRoomCalendar 获取教室在某段时间内的课程和预订, 默认为本周
+func (r *Room) GetRoomCalendar(c *gin.Context) {
+	roomIDStr := c.Param("roomId")
+	roomID, err := strconv.ParseInt(roomIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 roomId: %v", roomIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	type QueryParams struct {
+		FromDate string `form:"fromDate"`
+		ToDate   string `form:"toDate"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	start, end := service.WeekOf(common.Now())
+	if params.FromDate != "" || params.ToDate != "" {
+		if start, err = common.ParseDate(params.FromDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		if end, err = common.ParseDate(params.ToDate); err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+		if !end.After(start) || end.After(start.AddDate(0, 0, 92)) {
+			c.Error(common.ErrNew(errors.New("日期范围须在三个月以内"), common.ParamErr))
+			return
+		}
+	}
+	room, entries, err := srv.GetRoomCalendar(roomID, start, end)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type entryForm struct {
+		Kind        string `json:"kind"`
+		ID          int64  `json:"id"`
+		Title       string `json:"title"`
+		BookingKind string `json:"bookingKind,omitempty"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+	}
+	response := []entryForm{}
+	for _, entry := range entries {
+		response = append(response, entryForm{
+			Kind:        entry.Kind,
+			ID:          entry.ID,
+			Title:       entry.Title,
+			BookingKind: entry.BookingKind,
+			StartTime:   common.FormatTime(entry.StartTime),
+			EndTime:     common.FormatTime(entry.EndTime),
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"room":     newRoomForm(*room),
+		"fromDate": common.FormatDate(start),
+		"toDate":   common.FormatDate(end.AddDate(0, 0, -1)),
+		"entries":  response,
+	}))
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// BuildSchedules 排课助手, 从候选课程中找出互不冲突的选课组合
+func (u *User) BuildSchedules(c *gin.Context) {
+	var form struct {
+		Candidates []struct {
+			CourseID int64 `json:"courseId" binding:"required,min=1"`
+			MustHave bool  `json:"mustHave"`
+		} `json:"candidates" binding:"required,min=1,dive"`
+		Preferences struct {
+			EarliestStart string `json:"earliestStart"`
+			FreeWeekdays  []int  `json:"freeWeekdays"`
+		} `json:"preferences"`
+		Limit int `json:"limit" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	candidates := make([]service.ScheduleCandidate, 0, len(form.Candidates))
+	for _, candidate := range form.Candidates {
+		candidates = append(candidates, service.ScheduleCandidate{CourseID: candidate.CourseID, MustHave: candidate.MustHave})
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	options, exclusions, err := srv.BuildSchedules(studentID, candidates, service.SchedulePreferences{
+		EarliestStart: form.Preferences.EarliestStart,
+		FreeWeekdays:  form.Preferences.FreeWeekdays,
+	}, form.Limit)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type TimeForm struct {
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+		Location  string `json:"location"`
+	}
+	type courseForm struct {
+		CourseID   int64      `json:"id"`
+		CourseName string     `json:"courseName"`
+		Credits    float64    `json:"credits"`
+		Time       []TimeForm `json:"time"`
+	}
+	type optionForm struct {
+		CourseIDs []int64      `json:"courseIds"`
+		Courses   []courseForm `json:"courses"`
+		Score     float64      `json:"score"`
+		Credits   float64      `json:"credits"`
+		Notes     []string     `json:"notes"`
+	}
+	type exclusionForm struct {
+		CourseID int64  `json:"courseId"`
+		Reason   string `json:"reason"`
+	}
+	response := []optionForm{}
+	for _, option := range options {
+		item := optionForm{CourseIDs: []int64{}, Courses: []courseForm{}, Score: option.Score, Credits: option.Credits, Notes: option.Notes}
+		for _, course := range option.Courses {
+			timeForms := []TimeForm{}
+			for _, timeItem := range course.CourseTimes {
+				timeForms = append(timeForms, TimeForm{
+					StartTime: common.FormatTime(timeItem.StartTime),
+					EndTime:   common.FormatTime(timeItem.EndTime),
+					Location:  course.SessionLocation(timeItem),
+				})
+			}
+			item.CourseIDs = append(item.CourseIDs, course.CourseID)
+			item.Courses = append(item.Courses, courseForm{
+				CourseID:   course.CourseID,
+				CourseName: course.CourseName,
+				Credits:    course.Credits,
+				Time:       timeForms,
+			})
+		}
+		response = append(response, item)
+	}
+	excluded := []exclusionForm{}
+	for _, exclusion := range exclusions {
+		excluded = append(excluded, exclusionForm{CourseID: exclusion.CourseID, Reason: exclusion.Reason})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"options": response, "excluded": excluded}))
+}
+
+// GrabCourses 一次提交排课助手给出的选课组合, 全部选上或全部不选
+func (u *User) GrabCourses(c *gin.Context) {
+	var form struct {
+		CourseIDs []int64 `json:"courseIds" binding:"required,min=1,dive,min=1"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	if err := srv.GrabCourses(studentID, form.CourseIDs); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// parseSwapID 解析路径中的换课申请ID
+func parseSwapID(c *gin.Context) (int64, bool) {
+	swapIDStr := c.Param("swapId")
+	swapID, err := strconv.ParseInt(swapIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 swapId: %v", swapIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return 0, false
+	}
+	return swapID, true
+}
+
+// PostSeatSwap 发布换课申请: 让出一门已选课程, 换取另一门课程
+func (u *User) PostSeatSwap(c *gin.Context) {
+	var form struct {
+		GiveCourseID int64 `json:"giveCourseId" binding:"required,min=1"`
+		WantCourseID int64 `json:"wantCourseId" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	swap, err := srv.PostSeatSwap(studentID, form.GiveCourseID, form.WantCourseID, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": swap.ID, "status": swap.Status}))
+}
+
+// GetSeatSwapBoard 查看换课看板, 只显示各类申请的数量, 不显示发布者
+func (u *User) GetSeatSwapBoard(c *gin.Context) {
+	listings, err := srv.GetSeatSwapBoard()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type listingForm struct {
+		GiveCourseID int64  `json:"giveCourseId"`
+		GiveCourse   string `json:"giveCourse"`
+		WantCourseID int64  `json:"wantCourseId"`
+		WantCourse   string `json:"wantCourse"`
+		Count        int    `json:"count"`
+	}
+	response := make([]listingForm, 0, len(listings))
+	for _, listing := range listings {
+		response = append(response, listingForm{
+			GiveCourseID: listing.GiveCourseID,
+			GiveCourse:   listing.GiveCourse,
+			WantCourseID: listing.WantCourseID,
+			WantCourse:   listing.WantCourse,
+			Count:        listing.Count,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"listings": response}))
+}
+
+// GetSeatSwaps 查看自己的换课申请及匹配情况
+func (u *User) GetSeatSwaps(c *gin.Context) {
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	details, err := srv.GetSeatSwaps(studentID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type linkForm struct {
+		GiveCourseID int64  `json:"giveCourseId"`
+		GiveCourse   string `json:"giveCourse"`
+		WantCourseID int64  `json:"wantCourseId"`
+		WantCourse   string `json:"wantCourse"`
+		Accepted     bool   `json:"accepted"`
+		Mine         bool   `json:"mine"`
+	}
+	type matchForm struct {
+		ID        int64      `json:"id"`
+		Status    string     `json:"status"`
+		Reason    string     `json:"reason"`
+		ExpiresAt string     `json:"expiresAt"`
+		Links     []linkForm `json:"links"`
+	}
+	type swapForm struct {
+		ID           int64      `json:"id"`
+		GiveCourseID int64      `json:"giveCourseId"`
+		GiveCourse   string     `json:"giveCourse"`
+		WantCourseID int64      `json:"wantCourseId"`
+		WantCourse   string     `json:"wantCourse"`
+		Status       string     `json:"status"`
+		Accepted     bool       `json:"accepted"`
+		CreatedAt    string     `json:"createdAt"`
+		Match        *matchForm `json:"match"`
+	}
+	response := make([]swapForm, 0, len(details))
+	for _, detail := range details {
+		form := swapForm{
+			ID:           detail.Swap.ID,
+			GiveCourseID: detail.Swap.GiveCourseID,
+			GiveCourse:   detail.GiveCourse,
+			WantCourseID: detail.Swap.WantCourseID,
+			WantCourse:   detail.WantCourse,
+			Status:       detail.Swap.Status,
+			Accepted:     detail.Swap.Accepted,
+			CreatedAt:    common.FormatTime(detail.Swap.CreatedAt),
+		}
+		if detail.Match != nil {
+			form.Match = &matchForm{
+				ID:        detail.Match.ID,
+				Status:    detail.Match.Status,
+				Reason:    detail.Match.Reason,
+				ExpiresAt: common.FormatTime(detail.ExpiresAt),
+				Links:     make([]linkForm, 0, len(detail.Links)),
+			}
+			for _, link := range detail.Links {
+				form.Match.Links = append(form.Match.Links, linkForm{
+					GiveCourseID: link.GiveCourseID,
+					GiveCourse:   link.GiveCourse,
+					WantCourseID: link.WantCourseID,
+					WantCourse:   link.WantCourse,
+					Accepted:     link.Accepted,
+					Mine:         link.Mine,
+				})
+			}
+		}
+		response = append(response, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"swaps": response}))
+}
+
+// respondSeatSwap 确认或拒绝换课匹配
+func respondSeatSwap(c *gin.Context, accept bool) {
+	swapID, ok := parseSwapID(c)
+	if !ok {
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	match, err := srv.RespondSeatSwap(studentID, swapID, accept, common.Now())
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"matchId": match.ID, "status": match.Status, "reason": match.Reason}))
+}
+
+// AcceptSeatSwap 确认换课匹配, 最后一人确认后立即完成交换
+func (u *User) AcceptSeatSwap(c *gin.Context) {
+	respondSeatSwap(c, true)
+}
+
+// DeclineSeatSwap 拒绝换课匹配, 自己的申请随之取消
+func (u *User) DeclineSeatSwap(c *gin.Context) {
+	respondSeatSwap(c, false)
+}
+
+// CancelSeatSwap 撤回自己的换课申请
+func (u *User) CancelSeatSwap(c *gin.Context) {
+	swapID, ok := parseSwapID(c)
+	if !ok {
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	if err := srv.CancelSeatSwap(studentID, swapID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type studentProfileForm struct {
+	Department string `json:"department"`
+	Major      string `json:"major"`
+	EntryYear  int    `json:"entryYear"`
+	ClassName  string `json:"className"`
+	Status     string `json:"status"`
+	Email      string `json:"email"`
+	Phone      string `json:"phone"`
+}
+
+// newStudentProfileForm 没有学籍信息时返回nil
+func newStudentProfileForm(profile *model.StudentProfile) *studentProfileForm {
+	if profile == nil {
+		return nil
+	}
+	return &studentProfileForm{
+		Department: profile.Department,
+		Major:      profile.Major,
+		EntryYear:  profile.EntryYear,
+		ClassName:  profile.ClassName,
+		Status:     profile.Status,
+		Email:      profile.Email,
+		Phone:      profile.Phone,
+	}
+}
+
+// SaveStudentProfile 建立或更新学生的学籍信息
+func (a *Admin) SaveStudentProfile(c *gin.Context) {
+	var form struct {
+		Department string `json:"department" binding:"max=128"`
+		Major      string `json:"major" binding:"max=128"`
+		EntryYear  int    `json:"entryYear" binding:"min=0"`
+		ClassName  string `json:"className" binding:"max=64"`
+		Status     string `json:"status" binding:"omitempty,oneof=active suspended graduated"`
+		Email      string `json:"email" binding:"omitempty,email"`
+		Phone      string `json:"phone" binding:"max=32"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	profile := model.StudentProfile{
+		StudentID:  c.Param("studentId"),
+		Department: form.Department,
+		Major:      form.Major,
+		EntryYear:  form.EntryYear,
+		ClassName:  form.ClassName,
+		Status:     form.Status,
+		Email:      form.Email,
+		Phone:      strings.TrimSpace(form.Phone),
+	}
+	if err := srv.SaveStudentProfile(&profile); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, newStudentProfileForm(&profile)))
+}
+
+// DeleteStudentProfile 删除学生的学籍信息
+func (a *Admin) DeleteStudentProfile(c *gin.Context) {
+	if err := srv.DeleteStudentProfile(c.Param("studentId")); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// BulkUpdateStudentProfiles 批量修改学籍信息, 只修改请求中给出的字段
+func (a *Admin) BulkUpdateStudentProfiles(c *gin.Context) {
+	var form struct {
+		StudentIDs []string `json:"studentIds" binding:"required,min=1,dive,required"`
+		Department *string  `json:"department" binding:"omitempty,max=128"`
+		Major      *string  `json:"major" binding:"omitempty,max=128"`
+		EntryYear  *int     `json:"entryYear" binding:"omitempty,min=0"`
+		ClassName  *string  `json:"className" binding:"omitempty,max=64"`
+		Status     *string  `json:"status" binding:"omitempty,oneof=active suspended graduated"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	updated, err := srv.BulkUpdateStudentProfiles(form.StudentIDs, service.StudentProfileUpdate{
+		Department: form.Department,
+		Major:      form.Major,
+		EntryYear:  form.EntryYear,
+		ClassName:  form.ClassName,
+		Status:     form.Status,
+	})
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"updated": updated}))
+}
+
+// GetOwnProfile 学生查看自己的学籍信息
+func (u *User) GetOwnProfile(c *gin.Context) {
+	userSession := SessionGet(c, "user").(UserSession)
+	profile, err := srv.GetStudentProfile(userSession.UserID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"studentId":   userSession.UserID,
+		"studentName": userSession.Username,
+		"profile":     newStudentProfileForm(profile),
+	}))
+}
+
+// UpdateOwnProfile 学生修改自己的联系方式, 没有传的字段保持不变
+func (u *User) UpdateOwnProfile(c *gin.Context) {
+	var form struct {
+		Email *string `json:"email" binding:"omitempty,email"`
+		Phone *string `json:"phone" binding:"omitempty,max=32"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	studentID := SessionGet(c, "user").(UserSession).UserID
+	if form.Phone != nil {
+		phone := strings.TrimSpace(*form.Phone)
+		form.Phone = &phone
+	}
+	profile, err := srv.UpdateOwnProfile(studentID, form.Email, form.Phone)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, newStudentProfileForm(profile)))
+}
@@ -0,0 +1,382 @@
+package controller
+
+import (
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Teacher struct{}
+
+type teacherForm struct {
+	ID         int64   `json:"id"`
+	Name       string  `json:"name"`
+	StaffID    *string `json:"staffId"`
+	Department string  `json:"department"`
+	Email      string  `json:"email"`
+	Phone      string  `json:"phone"`
+}
+
+func newTeacherForm(teacher model.Teacher) teacherForm {
+	return teacherForm{
+		ID:         teacher.ID,
+		Name:       teacher.Name,
+		StaffID:    teacher.StaffID,
+		Department: teacher.Department,
+		Email:      teacher.Email,
+		Phone:      teacher.Phone,
+	}
+}
+
+// staffIDOf 空工号按未填写处理
+func staffIDOf(staffID string) *string {
+	if staffID == "" {
+		return nil
+	}
+	return &staffID
+}
+
+// currentTeacherID 获取当前登录的教师账号关联的教师ID
+func currentTeacherID(c *gin.Context) (int64, error) {
+	userSession := SessionGet(c, "user")
+	teacher, err := srv.GetTeacherByUser(userSession.(UserSession).UserID)
+	if err != nil {
+		return 0, err
+	}
+	return teacher.ID, nil
+}
+
+// CreateTeacherAccount 管理员为教师创建登录账号
+func (t *Teacher) CreateTeacherAccount(c *gin.Context) {
+	teacherIDStr := c.Param("teacherId")
+	teacherID, err := strconv.ParseInt(teacherIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 teacherId: %v", teacherIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var form struct {
+		UserID   string `json:"userId" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.CreateTeacherAccount(teacherID, form.UserID, form.Password); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetTeachingCourses 教师查看自己讲授的课程
+func (t *Teacher) GetTeachingCourses(c *gin.Context) {
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	courses, enrolled, err := srv.GetTeachingCourses(teacherID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type TimeForm struct {
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
+	}
+	type responseformat struct {
+		CourseID   int64      `json:"id"`
+		CourseName string     `json:"courseName"`
+		Capacity   int        `json:"capacity"`
+		Enrolled   int        `json:"enrolled"`
+		Time       []TimeForm `json:"time"`
+		Location   string     `json:"location"`
+		RoomID     *int64     `json:"roomId"`
+		Credits    float64    `json:"credits"`
+	}
+	response := []responseformat{}
+	for _, course := range courses {
+		var timeForms []TimeForm
+		for _, timeItem := range course.CourseTimes {
+			timeForms = append(timeForms, TimeForm{
+				StartTime:   common.FormatTime(timeItem.StartTime),
+				EndTime:     common.FormatTime(timeItem.EndTime),
+				Location:    course.SessionLocation(timeItem),
+				RoomID:      course.SessionRoomID(timeItem),
+				Online:      timeItem.Online,
+				MeetingLink: timeItem.MeetingLink,
+			})
+		}
+		response = append(response, responseformat{
+			CourseID:   course.CourseID,
+			CourseName: course.CourseName,
+			Capacity:   course.Capacity,
+			Enrolled:   enrolled[course.CourseID],
+			Time:       timeForms,
+			Location:   course.Location,
+			RoomID:     course.RoomID,
+			Credits:    course.Credits,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"courses": response}))
+}
+
+// GetCourseRoster 教师分页查看自己课程的学生名单
+func (t *Teacher) GetCourseRoster(c *gin.Context) {
+	courseIDStr := c.Param("courseId")
+	courseID, err := strconv.ParseInt(courseIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的课程ID参数: %v", courseIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	type QueryParams struct {
+		Page  int `form:"page" binding:"required,gt=0"`
+		Limit int `form:"limit" binding:"required,gt=0"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	if err := srv.CheckCourseTeacher(teacherID, courseID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	students, total, err := srv.GetCourseRoster(courseID, params.Page, params.Limit)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type StudentForm struct {
+		Name      string `json:"name"`
+		StudentID string `json:"studentId"`
+	}
+	response := []StudentForm{}
+	for _, student := range students {
+		response = append(response, StudentForm{
+			Name:      student.UserName,
+			StudentID: student.UserID,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"total": total, "students": response}))
+}
+
+// GetTeachingSchedule 教师查看自己某一周的课表, 参数与学生课表相同
+func (t *Teacher) GetTeachingSchedule(c *gin.Context) {
+	start, end, ok := scheduleRange(c)
+	if !ok {
+		return
+	}
+	teacherID, err := currentTeacherID(c)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	schedule, err := srv.GetTeachingSchedule(teacherID, start, end)
+	if err != nil {
+		logrus.Errorf("获取课表失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response, err := newScheduleResponse(schedule)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, response))
+}
+
+// AddTeacher 添加教师
+func (t *Teacher) AddTeacher(c *gin.Context) {
+	var form struct {
+		Name       string `json:"name" binding:"required"`
+		StaffID    string `json:"staffId"`
+		Department string `json:"department"`
+		Email      string `json:"email" binding:"omitempty,email"`
+		Phone      string `json:"phone"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	teacher := model.Teacher{
+		Name:       form.Name,
+		StaffID:    staffIDOf(form.StaffID),
+		Department: form.Department,
+		Email:      form.Email,
+		Phone:      form.Phone,
+	}
+	if err := srv.AddTeacher(&teacher); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"id": teacher.ID}))
+}
+
+// UpdateTeacher 更新教师信息
+func (t *Teacher) UpdateTeacher(c *gin.Context) {
+	var form struct {
+		ID         int64  `json:"id" binding:"required"`
+		Name       string `json:"name" binding:"required"`
+		StaffID    string `json:"staffId"`
+		Department string `json:"department"`
+		Email      string `json:"email" binding:"omitempty,email"`
+		Phone      string `json:"phone"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	teacher := model.Teacher{
+		Name:       form.Name,
+		StaffID:    staffIDOf(form.StaffID),
+		Department: form.Department,
+		Email:      form.Email,
+		Phone:      form.Phone,
+	}
+	teacher.ID = form.ID
+	if err := srv.UpdateTeacher(&teacher); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// DeleteTeacher 删除教师
+func (t *Teacher) DeleteTeacher(c *gin.Context) {
+	teacherIDStr := c.Param("teacherId")
+	teacherID, err := strconv.ParseInt(teacherIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 teacherId: %v", teacherIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.DeleteTeacher(teacherID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
+
+// GetTeachers 查询教师列表
+func (t *Teacher) GetTeachers(c *gin.Context) {
+	type QueryParams struct {
+		Page       int    `form:"page" binding:"required,gt=0"`
+		Limit      int    `form:"limit" binding:"required,gt=0"`
+		Name       string `form:"name"`
+		Department string `form:"department"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	teachers, total, err := srv.GetTeachers(params.Page, params.Limit, params.Name, params.Department)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	response := []teacherForm{}
+	for _, teacher := range teachers {
+		response = append(response, newTeacherForm(teacher))
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"total": total, "teachers": response}))
+}
+
+// GetTeacher 获取教师详情及其讲授的课程
+func (t *Teacher) GetTeacher(c *gin.Context) {
+	teacherIDStr := c.Param("teacherId")
+	teacherID, err := strconv.ParseInt(teacherIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 teacherId: %v", teacherIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	teacher, courseIDs, err := srv.GetTeacher(teacherID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	names, err := srv.GetCourseNames(courseIDs)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type courseForm struct {
+		ID         int64  `json:"id"`
+		CourseName string `json:"courseName"`
+	}
+	courses := []courseForm{}
+	for _, courseID := range courseIDs {
+		courses = append(courses, courseForm{ID: courseID, CourseName: names[courseID]})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"teacher": newTeacherForm(*teacher), "courses": courses}))
+}
+
+// FindTeacherDuplicates 查找姓名相同或相近、可能重复登记的教师
+func (t *Teacher) FindTeacherDuplicates(c *gin.Context) {
+	duplicates, err := srv.FindTeacherDuplicates()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type duplicateForm struct {
+		Teachers []teacherForm `json:"teachers"`
+		Reason   string        `json:"reason"`
+	}
+	response := []duplicateForm{}
+	for _, duplicate := range duplicates {
+		response = append(response, duplicateForm{
+			Teachers: []teacherForm{newTeacherForm(duplicate.Teachers[0]), newTeacherForm(duplicate.Teachers[1])},
+			Reason:   duplicate.Reason,
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"duplicates": response}))
+}
+
+// MergeTeachers 把重复登记的教师合并到指定教师
+func (t *Teacher) MergeTeachers(c *gin.Context) {
+	teacherIDStr := c.Param("teacherId")
+	teacherID, err := strconv.ParseInt(teacherIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 teacherId: %v", teacherIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var form struct {
+		SourceIDs []int64 `json:"sourceIds" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	moved, err := srv.MergeTeachers(teacherID, form.SourceIDs)
+	if err != nil {
+		logrus.Errorf("合并教师失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"movedCourses": moved}))
+}
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"encoding/json"
+	"finaltenzor/common"
+	"finaltenzor/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type Timetable struct{}
+
+// GenerateTimetable 根据课程的周学时、教师和教室要求自动排课, 生成待审核的课表草案
+func (t *Timetable) GenerateTimetable(c *gin.Context) {
+	type periodForm struct {
+		Start string `json:"start" binding:"required"`
+		End   string `json:"end" binding:"required"`
+	}
+	type courseForm struct {
+		CourseName   string  `json:"courseName" binding:"required"`
+		Capacity     int     `json:"capacity" binding:"required,gt=0"`
+		TeacherIDs   []int64 `json:"teacherIds" binding:"required,min=1"`
+		WeeklyHours  int     `json:"weeklyHours" binding:"required,gt=0"`
+		BlockPeriods int     `json:"blockPeriods" binding:"omitempty,gt=0"`
+		MinSeats     int     `json:"minSeats" binding:"min=0"`
+		Projector    bool    `json:"projector"`
+		Lab          bool    `json:"lab"`
+		Accessible   bool    `json:"accessible"`
+	}
+	type preferenceForm struct {
+		TeacherID int64 `json:"teacherId" binding:"required"`
+		Weekday   int   `json:"weekday" binding:"required,min=1,max=7"`
+		Period    int   `json:"period" binding:"min=0"`
+		Weight    int   `json:"weight" binding:"required"`
+	}
+	var form struct {
+		Name        string           `json:"name" binding:"required"`
+		FromDate    string           `json:"fromDate" binding:"required"`
+		ToDate      string           `json:"toDate" binding:"required"`
+		Weekdays    []int            `json:"weekdays"`
+		Periods     []periodForm     `json:"periods" binding:"required,min=1,dive"`
+		Courses     []courseForm     `json:"courses" binding:"required,min=1,dive"`
+		Preferences []preferenceForm `json:"preferences" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	fromDate, err := common.ParseDate(form.FromDate)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	toDate, err := common.ParseDate(form.ToDate)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	req := service.TimetableRequest{
+		Name:     form.Name,
+		FromDate: fromDate,
+		ToDate:   toDate,
+		Weekdays: form.Weekdays,
+	}
+	if len(req.Weekdays) == 0 {
+		req.Weekdays = []int{1, 2, 3, 4, 5}
+	}
+	for _, period := range form.Periods {
+		req.Periods = append(req.Periods, service.TimetablePeriod{Start: period.Start, End: period.End})
+	}
+	for _, course := range form.Courses {
+		blockPeriods := course.BlockPeriods
+		if blockPeriods == 0 {
+			blockPeriods = 2
+		}
+		req.Courses = append(req.Courses, service.TimetableCourseRequest{
+			CourseName:   course.CourseName,
+			Capacity:     course.Capacity,
+			TeacherIDs:   course.TeacherIDs,
+			WeeklyHours:  course.WeeklyHours,
+			BlockPeriods: blockPeriods,
+			Room: service.RoomRequirement{
+				MinSeats:   course.MinSeats,
+				Projector:  course.Projector,
+				Lab:        course.Lab,
+				Accessible: course.Accessible,
+			},
+		})
+	}
+	for _, pref := range form.Preferences {
+		req.Preferences = append(req.Preferences, service.TeacherPreference{
+			TeacherID: pref.TeacherID,
+			Weekday:   pref.Weekday,
+			Period:    pref.Period,
+			Weight:    pref.Weight,
+		})
+	}
+	draft, err := srv.GenerateTimetable(req)
+	if err != nil {
+		logrus.Errorf("自动排课失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	unplaced := 0
+	for _, course := range draft.Courses {
+		if course.RoomID == nil {
+			unplaced++
+		}
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"id":       draft.ID,
+		"cost":     draft.Cost,
+		"placed":   len(draft.Courses) - unplaced,
+		"unplaced": unplaced,
+	}))
+}
+
+// GetTimetableDrafts 获取课表草案列表
+func (t *Timetable) GetTimetableDrafts(c *gin.Context) {
+	drafts, err := srv.GetTimetableDrafts()
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type draftForm struct {
+		ID        int64  `json:"id"`
+		Name      string `json:"name"`
+		Status    string `json:"status"`
+		FromDate  string `json:"fromDate"`
+		ToDate    string `json:"toDate"`
+		Cost      int    `json:"cost"`
+		CreatedAt string `json:"createdAt"`
+	}
+	response := []draftForm{}
+	for _, draft := range drafts {
+		response = append(response, draftForm{
+			ID:        draft.ID,
+			Name:      draft.Name,
+			Status:    draft.Status,
+			FromDate:  common.FormatDate(draft.FromDate),
+			ToDate:    common.FormatDate(draft.ToDate),
+			Cost:      draft.Cost,
+			CreatedAt: common.FormatTime(draft.CreatedAt),
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"drafts": response}))
+}
+
+// GetTimetableDraft 获取课表草案详情, 供管理员审核每门课的安排
+func (t *Timetable) GetTimetableDraft(c *gin.Context) {
+	draftIDStr := c.Param("draftId")
+	draftID, err := strconv.ParseInt(draftIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 draftId: %v", draftIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	draft, err := srv.GetTimetableDraft(draftID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type meetingForm struct {
+		Weekday   int    `json:"weekday"`
+		Period    int    `json:"period"`
+		Length    int    `json:"length"`
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+	}
+	type courseForm struct {
+		CourseName string        `json:"courseName"`
+		Capacity   int           `json:"capacity"`
+		TeacherIDs []int64       `json:"teacherIds"`
+		RoomID     *int64        `json:"roomId"`
+		Location   string        `json:"location"`
+		Meetings   []meetingForm `json:"meetings"`
+		Reason     string        `json:"reason"`
+		CourseID   *int64        `json:"courseId"`
+	}
+	rooms := make(map[int64]string)
+	courses := []courseForm{}
+	for _, course := range draft.Courses {
+		form := courseForm{
+			CourseName: course.CourseName,
+			Capacity:   course.Capacity,
+			RoomID:     course.RoomID,
+			Reason:     course.Reason,
+			CourseID:   course.CourseID,
+			Meetings:   []meetingForm{},
+		}
+		if err := json.Unmarshal(course.TeacherIDs, &form.TeacherIDs); err != nil {
+			c.Error(common.ErrNew(err, common.SysErr))
+			return
+		}
+		if course.RoomID != nil {
+			if _, ok := rooms[*course.RoomID]; !ok {
+				room, err := srv.GetRoom(*course.RoomID)
+				if err != nil {
+					c.Error(common.ErrNew(err, common.OpErr))
+					return
+				}
+				rooms[*course.RoomID] = room.Label()
+			}
+			form.Location = rooms[*course.RoomID]
+		}
+		var meetings []service.TimetableMeeting
+		if err := json.Unmarshal(course.Meetings, &meetings); err != nil {
+			c.Error(common.ErrNew(err, common.SysErr))
+			return
+		}
+		for _, meeting := range meetings {
+			startTime, endTime, err := srv.TimetableMeetingTime(draft, meeting)
+			if err != nil {
+				c.Error(common.ErrNew(err, common.SysErr))
+				return
+			}
+			form.Meetings = append(form.Meetings, meetingForm{
+				Weekday:   meeting.Weekday,
+				Period:    meeting.Period,
+				Length:    meeting.Length,
+				StartTime: startTime,
+				EndTime:   endTime,
+			})
+		}
+		courses = append(courses, form)
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"id":       draft.ID,
+		"name":     draft.Name,
+		"status":   draft.Status,
+		"fromDate": common.FormatDate(draft.FromDate),
+		"toDate":   common.FormatDate(draft.ToDate),
+		"weekdays": draft.Weekdays,
+		"periods":  draft.Periods,
+		"cost":     draft.Cost,
+		"courses":  courses,
+	}))
+}
+
+// ApplyTimetableDraft 应用课表草案, 批量创建课程
+func (t *Timetable) ApplyTimetableDraft(c *gin.Context) {
+	draftIDStr := c.Param("draftId")
+	draftID, err := strconv.ParseInt(draftIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 draftId: %v", draftIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	created, err := srv.ApplyTimetableDraft(draftID)
+	if err != nil {
+		logrus.Errorf("应用课表草案失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"created": created}))
+}
+
+// DiscardTimetableDraft 放弃课表草案
+func (t *Timetable) DiscardTimetableDraft(c *gin.Context) {
+	draftIDStr := c.Param("draftId")
+	draftID, err := strconv.ParseInt(draftIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 draftId: %v", draftIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if err := srv.DiscardTimetableDraft(draftID); err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
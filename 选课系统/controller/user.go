@@ -5,6 +5,7 @@ import (
 	"errors"
 	"finaltenzor/common"
 	"finaltenzor/model"
+	"finaltenzor/service"
 	"net/http"
 	"strconv"
 	"time"
@@ -81,6 +82,15 @@ func (u *User) Login(c *gin.Context) {
 		SessionSet(c, "user", userSession)
 		isUser = true
 	}
+	if auth == 3 {
+		userSession := UserSession{
+			UserID:   form.StudentID,
+			Username: UserName,
+			Level:    3,
+		}
+		SessionSet(c, "user", userSession)
+		isUser = true
+	}
 	if !isUser {
 		c.Error(common.ErrNew(errors.New("您没有注册或登录权限"), common.AuthErr))
 		return
@@ -113,8 +123,10 @@ func (u *User) GetCoursesList(c *gin.Context) {
 		Page       int      `form:"page" binding:"required,gt=0"`
 		Limit      int      `form:"limit" binding:"required,gt=0"`
 		CourseName string   `form:"courseName"`
+		TeacherIDs []int64  `form:"teacherIds"`
 		Teachers   []string `form:"teachers"`
 		Location   string   `form:"location"`
+		RoomID     int64    `form:"roomId"`
 		Time       string   `form:"time"`
 	}
 	var params QueryParams
@@ -125,8 +137,12 @@ func (u *User) GetCoursesList(c *gin.Context) {
 	}
 	var times []model.CourseTime
 	type TimeForm struct {
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
 	}
 	if params.Time != "" {
 		var timeForms []TimeForm
@@ -136,13 +152,13 @@ func (u *User) GetCoursesList(c *gin.Context) {
 			return
 		}
 		for _, timeItem := range timeForms {
-			startTime, err := time.Parse("2006-01-02 15:04:05", timeItem.StartTime)
+			startTime, err := common.ParseTime(timeItem.StartTime)
 			if err != nil {
 				logrus.Errorf("开始时间格式错误: %v", timeItem.StartTime)
 				c.Error(common.ErrNew(err, common.ParamErr))
 				return
 			}
-			endTime, err := time.Parse("2006-01-02 15:04:05", timeItem.EndTime)
+			endTime, err := common.ParseTime(timeItem.EndTime)
 			if err != nil {
 				logrus.Errorf("结束时间格式错误: %v", timeItem.EndTime)
 				c.Error(common.ErrNew(err, common.ParamErr))
@@ -154,7 +170,7 @@ func (u *User) GetCoursesList(c *gin.Context) {
 			})
 		}
 	}
-	courses, total, err := srv.GetCourses(params.Page, params.Limit, params.CourseName, params.Teachers, times, params.Location)
+	courses, total, err := srv.GetCourses(params.Page, params.Limit, params.CourseName, params.TeacherIDs, params.Teachers, times, params.Location, params.RoomID)
 	if err != nil {
 		c.Error(common.ErrNew(err, common.OpErr))
 		return
@@ -162,18 +178,26 @@ func (u *User) GetCoursesList(c *gin.Context) {
 	type responseformat struct {
 		CourseID       int64      `json:"id"`
 		CourseName     string     `json:"courseName"`
+		CourseCode     string     `json:"courseCode"`
 		Capacity       int        `json:"capacity"`
 		CourseTeachers []string   `json:"teachers"`
 		Time           []TimeForm `json:"time"`
 		Location       string     `json:"location"`
+		RoomID         *int64     `json:"roomId"`
+		Credits        float64    `json:"credits"`
+		Category       string     `json:"category"`
 	}
 	var response []responseformat
 	for _, course := range courses {
 		var timeForms []TimeForm
 		for _, timeItem := range course.CourseTimes {
 			timeForms = append(timeForms, TimeForm{
-				StartTime: timeItem.StartTime.Format("2006-01-02 15:04:05"),
-				EndTime:   timeItem.EndTime.Format("2006-01-02 15:04:05"),
+				StartTime:   common.FormatTime(timeItem.StartTime),
+				EndTime:     common.FormatTime(timeItem.EndTime),
+				Location:    course.SessionLocation(timeItem),
+				RoomID:      course.SessionRoomID(timeItem),
+				Online:      timeItem.Online,
+				MeetingLink: timeItem.MeetingLink,
 			})
 		}
 		TeacherNames, err := srv.GetTeacherNamesByCourses(course.CourseID)
@@ -184,10 +208,14 @@ func (u *User) GetCoursesList(c *gin.Context) {
 		response = append(response, responseformat{
 			CourseID:       course.CourseID,
 			CourseName:     course.CourseName,
+			CourseCode:     course.CourseCode,
 			Capacity:       course.Capacity,
 			CourseTeachers: TeacherNames,
 			Time:           timeForms,
 			Location:       course.Location,
+			RoomID:         course.RoomID,
+			Credits:        course.Credits,
+			Category:       course.Category,
 		})
 	}
 	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"total": total, "courses": response}))
@@ -209,23 +237,35 @@ func (u *User) GetCourseDetail(c *gin.Context) {
 		return
 	}
 	type TimeForm struct {
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
 	}
 	type responseformat struct {
 		CourseID       int64      `json:"id"`
 		CourseName     string     `json:"courseName"`
+		CourseCode     string     `json:"courseCode"`
 		Capacity       int        `json:"capacity"`
 		CourseTeachers []string   `json:"teachers"`
 		Time           []TimeForm `json:"time"`
 		Location       string     `json:"location"`
+		RoomID         *int64     `json:"roomId"`
+		Credits        float64    `json:"credits"`
+		Category       string     `json:"category"`
 	}
 	var response responseformat
 	var timeForms []TimeForm
 	for _, timeItem := range course.CourseTimes {
 		timeForms = append(timeForms, TimeForm{
-			StartTime: timeItem.StartTime.Format("2006-01-02 15:04:05"),
-			EndTime:   timeItem.EndTime.Format("2006-01-02 15:04:05"),
+			StartTime:   common.FormatTime(timeItem.StartTime),
+			EndTime:     common.FormatTime(timeItem.EndTime),
+			Location:    course.SessionLocation(timeItem),
+			RoomID:      course.SessionRoomID(timeItem),
+			Online:      timeItem.Online,
+			MeetingLink: timeItem.MeetingLink,
 		})
 	}
 	TeacherNames, err := srv.GetTeacherNamesByCourses(course.CourseID)
@@ -236,10 +276,14 @@ func (u *User) GetCourseDetail(c *gin.Context) {
 	response = responseformat{
 		CourseID:       course.CourseID,
 		CourseName:     course.CourseName,
+		CourseCode:     course.CourseCode,
 		Capacity:       course.Capacity,
 		CourseTeachers: TeacherNames,
 		Time:           timeForms,
 		Location:       course.Location,
+		RoomID:         course.RoomID,
+		Credits:        course.Credits,
+		Category:       course.Category,
 	}
 	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"course": response}))
 }
@@ -274,8 +318,12 @@ func (u *User) ViewGrabbedCourses(c *gin.Context) {
 		return
 	}
 	type TimeForm struct {
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
+		StartTime   string `json:"startTime"`
+		EndTime     string `json:"endTime"`
+		Location    string `json:"location"`
+		RoomID      *int64 `json:"roomId"`
+		Online      bool   `json:"online"`
+		MeetingLink string `json:"meetingLink,omitempty"`
 	}
 	type responseformat struct {
 		CourseID       int64      `json:"id"`
@@ -284,14 +332,21 @@ func (u *User) ViewGrabbedCourses(c *gin.Context) {
 		CourseTeachers []string   `json:"teachers"`
 		Time           []TimeForm `json:"time"`
 		Location       string     `json:"location"`
+		RoomID         *int64     `json:"roomId"`
+		Credits        float64    `json:"credits"`
+		Category       string     `json:"category"`
 	}
 	var response []responseformat
 	for _, course := range courses {
 		var timeForms []TimeForm
 		for _, timeItem := range course.CourseTimes {
 			timeForms = append(timeForms, TimeForm{
-				StartTime: timeItem.StartTime.Format("2006-01-02 15:04:05"),
-				EndTime:   timeItem.EndTime.Format("2006-01-02 15:04:05"),
+				StartTime:   common.FormatTime(timeItem.StartTime),
+				EndTime:     common.FormatTime(timeItem.EndTime),
+				Location:    course.SessionLocation(timeItem),
+				RoomID:      course.SessionRoomID(timeItem),
+				Online:      timeItem.Online,
+				MeetingLink: timeItem.MeetingLink,
 			})
 		}
 		TeacherNames, err := srv.GetTeacherNamesByCourses(course.CourseID)
@@ -306,61 +361,167 @@ func (u *User) ViewGrabbedCourses(c *gin.Context) {
 			CourseTeachers: TeacherNames,
 			Time:           timeForms,
 			Location:       course.Location,
+			RoomID:         course.RoomID,
+			Credits:        course.Credits,
+			Category:       course.Category,
 		})
 	}
 	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"total": total, "courses": response}))
 }
 
-// GetSchedule - 获取用户当前已选课形成的课表
+// GetSchedule - 获取用户某一周的课表
 func (u *User) GetSchedule(c *gin.Context) {
+	start, end, ok := scheduleRange(c)
+	if !ok {
+		return
+	}
 	userSession := SessionGet(c, "user")
 	studentID := userSession.(UserSession).UserID
-	schedule, err := srv.GetUserSchedule(studentID)
+	schedule, err := srv.GetUserSchedule(studentID, start, end)
 	if err != nil {
 		logrus.Errorf("获取课表失败: %v", err)
-		c.Error(common.ErrNew(err, common.ParamErr))
+		c.Error(common.ErrNew(err, common.OpErr))
 		return
 	}
-	type timeForm struct {
-		StartTime time.Time `json:"startTime"`
-		EndTime   time.Time `json:"endTime"`
+	response, err := newScheduleResponse(schedule)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
 	}
-	type CourseTime struct {
-		ID         *int64     `json:"id,omitempty"`
-		CourseName *string    `json:"courseName,omitempty"`
-		Teachers   []string   `json:"teachers"`
-		Time       []timeForm `json:"time"`
-		Location   *string    `json:"location,omitempty"`
+	c.JSON(http.StatusOK, ResponseNew(c, response))
+}
+
+// scheduleRange 解析课表的周次或日期范围, 默认为本周; 参数错误时已写入c.Error
+func scheduleRange(c *gin.Context) (time.Time, time.Time, bool) {
+	type QueryParams struct {
+		Week      int    `form:"week" binding:"omitempty,min=1"`
+		StartDate string `form:"startDate"`
+		EndDate   string `form:"endDate"`
 	}
-	coursesByDay := make(map[string][]CourseTime)
-	daysOfWeek := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
-	for _, day := range daysOfWeek {
-		coursesByDay[day] = []CourseTime{} // 初始化每一天为一个空的课程列表
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return time.Time{}, time.Time{}, false
 	}
-	for _, course := range schedule {
-		teacherNames, err := srv.GetTeacherNamesByCourses(course.CourseID)
+	var start, end time.Time
+	switch {
+	case params.Week > 0:
+		var err error
+		start, end, err = service.TermWeekRange(params.Week)
 		if err != nil {
 			c.Error(common.ErrNew(err, common.OpErr))
-			return
+			return time.Time{}, time.Time{}, false
 		}
-		for _, timeItem := range course.CourseTimes {
-			courseTime := CourseTime{
-				ID:         &course.CourseID,
-				CourseName: &course.CourseName,
-				Teachers:   teacherNames,
-				Time: []timeForm{
-					{
-						StartTime: timeItem.StartTime,
-						EndTime:   timeItem.EndTime,
-					},
-				},
-				Location: &course.Location,
+	case params.StartDate != "":
+		var err error
+		start, err = common.ParseDate(params.StartDate)
+		if err != nil {
+			logrus.Errorf("开始日期格式错误: %v", params.StartDate)
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return time.Time{}, time.Time{}, false
+		}
+		end = start.AddDate(0, 0, 7)
+		if params.EndDate != "" {
+			endDate, err := common.ParseDate(params.EndDate)
+			if err != nil {
+				logrus.Errorf("结束日期格式错误: %v", params.EndDate)
+				c.Error(common.ErrNew(err, common.ParamErr))
+				return time.Time{}, time.Time{}, false
 			}
-			dayStr := timeItem.StartTime.Weekday().String()[:3]
-			coursesByDay[dayStr] = append(coursesByDay[dayStr], courseTime)
+			end = endDate.AddDate(0, 0, 1)
+		}
+		if !end.After(start) || end.After(start.AddDate(0, 0, 7)) {
+			c.Error(common.ErrNew(errors.New("日期范围须在一周以内"), common.ParamErr))
+			return time.Time{}, time.Time{}, false
 		}
+	default:
+		start, end = service.WeekOf(common.Now())
+	}
+	return start, end, true
+}
+
+// newScheduleResponse 把课表转换为接口返回的格式
+func newScheduleResponse(schedule *service.WeekSchedule) (gin.H, error) {
+	type blockForm struct {
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
 	}
-	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"courses": coursesByDay}))
+	type sessionForm struct {
+		ID          int64    `json:"id"`
+		CourseName  string   `json:"courseName"`
+		Teachers    []string `json:"teachers"`
+		Location    string   `json:"location"`
+		RoomID      *int64   `json:"roomId"`
+		Online      bool     `json:"online"`
+		MeetingLink string   `json:"meetingLink,omitempty"`
+		StartTime   string   `json:"startTime"`
+		EndTime     string   `json:"endTime"`
+	}
+	type dayForm struct {
+		Date       string        `json:"date"`
+		Weekday    string        `json:"weekday"`
+		Holiday    string        `json:"holiday,omitempty"`
+		MakeUp     bool          `json:"makeUp"`
+		Courses    []sessionForm `json:"courses"`
+		Hours      float64       `json:"hours"`
+		Earliest   string        `json:"earliest,omitempty"`
+		Latest     string        `json:"latest,omitempty"`
+		FreeBlocks []blockForm   `json:"freeBlocks"`
+	}
+	teachers := make(map[int64][]string)
+	var days []dayForm
+	for _, day := range schedule.Days {
+		form := dayForm{
+			Date:       common.FormatDate(day.Date),
+			Weekday:    day.Date.Weekday().String()[:3],
+			Holiday:    day.Holiday,
+			MakeUp:     day.MakeUp,
+			Courses:    []sessionForm{},
+			Hours:      day.Hours,
+			FreeBlocks: []blockForm{},
+		}
+		for _, session := range day.Sessions {
+			teacherNames, ok := teachers[session.CourseID]
+			if !ok {
+				var err error
+				teacherNames, err = srv.GetTeacherNamesByCourses(session.CourseID)
+				if err != nil {
+					return nil, err
+				}
+				teachers[session.CourseID] = teacherNames
+			}
+			form.Courses = append(form.Courses, sessionForm{
+				ID:          session.CourseID,
+				CourseName:  session.CourseName,
+				Teachers:    teacherNames,
+				Location:    session.Location,
+				RoomID:      session.RoomID,
+				Online:      session.Online,
+				MeetingLink: session.MeetingLink,
+				StartTime:   common.FormatTime(session.StartTime),
+				EndTime:     common.FormatTime(session.EndTime),
+			})
+		}
+		if day.Earliest != nil {
+			form.Earliest = common.FormatTime(*day.Earliest)
+			form.Latest = common.FormatTime(*day.Latest)
+		}
+		for _, block := range day.FreeBlocks {
+			form.FreeBlocks = append(form.FreeBlocks, blockForm{
+				StartTime: common.FormatTime(block.StartTime),
+				EndTime:   common.FormatTime(block.EndTime),
+			})
+		}
+		days = append(days, form)
+	}
+	return gin.H{
+		"week":       schedule.Week,
+		"startDate":  common.FormatDate(schedule.StartDate),
+		"endDate":    common.FormatDate(schedule.EndDate),
+		"totalHours": schedule.TotalHours,
+		"days":       days,
+	}, nil
 }
 
 // GiveUpCourse - 退课
@@ -376,3 +537,32 @@ func (u *User) GiveUpCourse(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, ResponseNew(c, nil))
 }
+
+// GetNotices - 获取自己的通知
+func (u *User) GetNotices(c *gin.Context) {
+	userSession := SessionGet(c, "user")
+	studentID := userSession.(UserSession).UserID
+	notices, err := srv.GetStudentNotices(studentID)
+	if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type noticeForm struct {
+		ID        int64  `json:"id"`
+		Kind      string `json:"kind"`
+		CourseID  int64  `json:"courseId"`
+		Message   string `json:"message"`
+		CreatedAt string `json:"createdAt"`
+	}
+	response := []noticeForm{}
+	for _, notice := range notices {
+		response = append(response, noticeForm{
+			ID:        notice.ID,
+			Kind:      notice.Kind,
+			CourseID:  notice.CourseID,
+			Message:   notice.Message,
+			CreatedAt: common.FormatTime(notice.CreatedAt),
+		})
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{"notices": response}))
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"encoding/csv"
+	"finaltenzor/common"
+	"finaltenzor/service"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// roundHours 课时保留两位小数
+func roundHours(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
+
+// GetTeacherWorkload 统计教师工作量, format=csv时导出CSV文件
+// 不传日期范围时默认统计当前学期; split指定合讲课程的课时分摊方式, 默认平均分摊
+func (t *Teacher) GetTeacherWorkload(c *gin.Context) {
+	type QueryParams struct {
+		FromDate   string `form:"fromDate"`
+		ToDate     string `form:"toDate"`
+		Department string `form:"department"`
+		Split      string `form:"split" binding:"omitempty,oneof=full equal share"`
+		Format     string `form:"format" binding:"omitempty,oneof=json csv"`
+	}
+	var params QueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	if params.Split == "" {
+		params.Split = service.WorkloadSplitEqual
+	}
+	start, end, err := srv.TermRange(common.Now())
+	if params.FromDate != "" || params.ToDate != "" {
+		start, err = common.ParseDate(params.FromDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		end, err = common.ParseDate(params.ToDate)
+		if err != nil {
+			c.Error(common.ErrNew(err, common.ParamErr))
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+	} else if err != nil {
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	workloads, weeks, err := srv.GetTeacherWorkload(start, end, params.Department, params.Split)
+	if err != nil {
+		logrus.Errorf("统计教师工作量失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	type weekForm struct {
+		StartDate string  `json:"startDate"`
+		Hours     float64 `json:"hours"`
+	}
+	type workloadForm struct {
+		ID           int64      `json:"id"`
+		Name         string     `json:"name"`
+		StaffID      *string    `json:"staffId"`
+		Department   string     `json:"department"`
+		CourseCount  int        `json:"courseCount"`
+		StudentCount int        `json:"studentCount"`
+		ContactHours float64    `json:"contactHours"`
+		Weeks        []weekForm `json:"weeks"`
+	}
+	forms := make([]workloadForm, 0, len(workloads))
+	for _, workload := range workloads {
+		form := workloadForm{
+			ID:           workload.Teacher.ID,
+			Name:         workload.Teacher.Name,
+			StaffID:      workload.Teacher.StaffID,
+			Department:   workload.Teacher.Department,
+			CourseCount:  workload.Courses,
+			StudentCount: workload.Students,
+			ContactHours: roundHours(workload.ContactHours),
+			Weeks:        make([]weekForm, 0, len(workload.Weeks)),
+		}
+		for _, week := range workload.Weeks {
+			form.Weeks = append(form.Weeks, weekForm{
+				StartDate: common.FormatDate(week.StartDate),
+				Hours:     roundHours(week.Hours),
+			})
+		}
+		forms = append(forms, form)
+	}
+
+	if params.Format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=workload-%s.csv", common.FormatDate(start)))
+		c.Status(http.StatusOK)
+		// 写入BOM, 便于Excel正确识别中文
+		c.Writer.WriteString("\xEF\xBB\xBF")
+		writer := csv.NewWriter(c.Writer)
+		header := []string{"教师ID", "姓名", "工号", "院系", "课程数", "学生人数", "总课时"}
+		for _, week := range weeks {
+			header = append(header, common.FormatDate(week))
+		}
+		writer.Write(header)
+		for _, form := range forms {
+			staffID := ""
+			if form.StaffID != nil {
+				staffID = *form.StaffID
+			}
+			record := []string{
+				strconv.FormatInt(form.ID, 10),
+				form.Name,
+				staffID,
+				form.Department,
+				strconv.Itoa(form.CourseCount),
+				strconv.Itoa(form.StudentCount),
+				strconv.FormatFloat(form.ContactHours, 'f', -1, 64),
+			}
+			for _, week := range form.Weeks {
+				record = append(record, strconv.FormatFloat(week.Hours, 'f', -1, 64))
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			logrus.Errorf("导出教师工作量失败: %v", err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, gin.H{
+		"fromDate":  common.FormatDate(start),
+		"toDate":    common.FormatDate(end.AddDate(0, 0, -1)),
+		"split":     params.Split,
+		"workloads": forms,
+	}))
+}
+
+// SetTeacherShares 设置合讲课程中各教师的课时分摊比例
+func (t *Teacher) SetTeacherShares(c *gin.Context) {
+	courseIDStr := c.Param("courseId")
+	courseID, err := strconv.ParseInt(courseIDStr, 10, 64)
+	if err != nil {
+		logrus.Errorf("无效的 courseId: %v", courseIDStr)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	var form struct {
+		Shares []struct {
+			TeacherID int64   `json:"teacherId" binding:"required"`
+			Share     float64 `json:"share" binding:"min=0,max=1"`
+		} `json:"shares" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		logrus.Errorf("参数错误: %v", err)
+		c.Error(common.ErrNew(err, common.ParamErr))
+		return
+	}
+	shares := make([]service.TeacherShare, 0, len(form.Shares))
+	for _, share := range form.Shares {
+		shares = append(shares, service.TeacherShare{TeacherID: share.TeacherID, Share: share.Share})
+	}
+	if err := srv.SetTeacherShares(courseID, shares); err != nil {
+		logrus.Errorf("设置课时分摊比例失败: %v", err)
+		c.Error(common.ErrNew(err, common.OpErr))
+		return
+	}
+	c.JSON(http.StatusOK, ResponseNew(c, nil))
+}
@@ -53,6 +53,8 @@ func errorHandle(c *gin.Context, err any) {
 		statusCode = http.StatusUnauthorized // 401
 	case common.LevelErr:
 		statusCode = http.StatusNotAcceptable // 406
+	case common.LimitErr:
+		statusCode = http.StatusTooManyRequests // 429
 	default:
 		statusCode = http.StatusInternalServerError // 500
 	}
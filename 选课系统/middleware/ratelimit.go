@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"finaltenzor/common"
+	"finaltenzor/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit 限制每个登录用户访问同一接口的频率, 两次请求至少间隔interval, 须放在CheckLogin之后
+func RateLimit(interval time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	last := make(map[string]time.Time)
+	return func(c *gin.Context) {
+		key := controller.SessionGet(c, "user").(controller.UserSession).UserID + " " + c.FullPath()
+		now := time.Now()
+		mu.Lock()
+		// 清理早已过期的记录, 避免长时间运行后占用过多内存
+		if len(last) > 10000 {
+			for k, t := range last {
+				if now.Sub(t) >= interval {
+					delete(last, k)
+				}
+			}
+		}
+		wait := interval - now.Sub(last[key])
+		if wait <= 0 {
+			last[key] = now
+		}
+		mu.Unlock()
+		if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.Error(common.ErrNew(errors.New("请求过于频繁, 请稍后再试"), common.LimitErr))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
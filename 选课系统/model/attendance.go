@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+)
+
+// 考勤状态
+const (
+	AttendancePresent = "present"
+	AttendanceLate    = "late"
+	AttendanceAbsent  = "absent"
+	AttendanceExcused = "excused" // 请假
+)
+
+// 考勤方式
+const (
+	AttendanceByCode   = "code"   // 学生扫码签到
+	AttendanceByManual = "manual" // 教师或管理员手动标记
+)
+
+// Attendance 学生某次上课的考勤, 上课时间按课程ID和开始时间确定
+type Attendance struct {
+	CourseID     int64     `gorm:"type:BIGINT NOT NULL;uniqueIndex:idx_attendance_session_student;comment:课程ID" json:"courseId"`
+	SessionStart time.Time `gorm:"type:DATETIME NOT NULL;uniqueIndex:idx_attendance_session_student;comment:上课开始时间" json:"sessionStart"`
+	StudentID    string    `gorm:"type:VARCHAR(20) NOT NULL;uniqueIndex:idx_attendance_session_student;index;comment:学生ID" json:"studentId"`
+	Status       string    `gorm:"type:VARCHAR(16) NOT NULL;comment:考勤状态" json:"status"`
+	Method       string    `gorm:"type:VARCHAR(16) NOT NULL;comment:考勤方式" json:"method"`
+	MarkedBy     string    `gorm:"type:VARCHAR(32) NOT NULL;comment:标记人的用户ID" json:"markedBy"`
+
+	BaseModel
+}
+
+func (Attendance) TableName() string {
+	return "attendance"
+}
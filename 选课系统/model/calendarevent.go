@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+// 校历事件类型
+const (
+	CalendarTerm    = "term"    // 学期起止
+	CalendarHoliday = "holiday" // 放假
+	CalendarMakeUp  = "makeup"  // 调休补课
+)
+
+type CalendarEvent struct {
+	UID       string    `gorm:"type:VARCHAR(255) NOT NULL;uniqueIndex;comment:ICS事件UID" json:"uid"`
+	Kind      string    `gorm:"type:VARCHAR(16) NOT NULL;index;comment:事件类型" json:"kind"`
+	Name      string    `gorm:"type:VARCHAR(255) NOT NULL;comment:事件名称" json:"name"`
+	StartDate time.Time `gorm:"type:DATE NOT NULL;comment:开始日期" json:"startDate"`
+	EndDate   time.Time `gorm:"type:DATE NOT NULL;comment:结束日期(含)" json:"endDate"`
+
+	BaseModel
+}
+
+func (CalendarEvent) TableName() string {
+	return "calendar_event"
+}
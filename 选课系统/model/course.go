@@ -9,8 +9,17 @@ import (
 type Course struct {
 	CourseID   int64  `gorm:"primaryKey;UNSIGNED;NOT NULL;comment:课程ID" json:"courseID"`
 	CourseName string `gorm:"type:VARCHAR(128) NOT NULL;comment:课程名称" json:"courseName"`
+	CourseCode string `gorm:"type:VARCHAR(32) NOT NULL;default:'';index;comment:课程代码, 同一门课历次开课共用, 培养方案按代码匹配" json:"courseCode"`
 	Capacity   int    `gorm:"type:INT NOT NULL;comment:课程容量" json:"capacity"`
 	Location   string `gorm:"type:VARCHAR(128) NOT NULL;comment:上课地点" json:"location"`
+	RoomID     *int64 `gorm:"type:BIGINT NULL;index;comment:教室ID" json:"roomId"`
+
+	Credits      float64 `gorm:"type:DECIMAL(4,1) NOT NULL;default:0;comment:学分" json:"credits"`
+	GradeScaleID *int64  `gorm:"type:BIGINT NULL;comment:成绩等级制ID, 为空时使用默认等级制" json:"gradeScaleId"`
+	Category     string  `gorm:"type:VARCHAR(64) NOT NULL;default:'';index;comment:课程类别, 如通识必修、专业选修" json:"category"`
+
+	MinEnrollment       int    `gorm:"type:INT NOT NULL;default:0;comment:最低开课人数" json:"minEnrollment"`
+	AlternativeCourseID *int64 `gorm:"type:BIGINT NULL;comment:停开时的替代课程ID" json:"alternativeCourseId"`
 
 	CreatedAt time.Time      `gorm:"type:DATETIME(3);NOT NULL;comment:创建时间" json:"createdAt"`
 	UpdatedAt time.Time      `gorm:"type:DATETIME(3);NOT NULL;comment:更新时间" json:"updatedAt"`
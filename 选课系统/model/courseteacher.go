@@ -1,8 +1,9 @@
 package model
 
 type CourseTeacher struct {
-	CourseID  int64 `gorm:"type:INT UNSIGNED NOT NULL;comment:课程ID" json:"courseId"`
-	TeacherID int64 `gorm:"type:INT UNSIGNED NOT NULL;comment:教师ID" json:"teacherId"`
+	CourseID  int64   `gorm:"type:INT UNSIGNED NOT NULL;comment:课程ID" json:"courseId"`
+	TeacherID int64   `gorm:"type:INT UNSIGNED NOT NULL;comment:教师ID" json:"teacherId"`
+	HourShare float64 `gorm:"type:DECIMAL(5,4) NOT NULL;default:0;comment:合讲课程的课时分摊比例, 0表示未设置" json:"hourShare"`
 }
 
 func (CourseTeacher) TableName() string {
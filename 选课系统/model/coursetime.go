@@ -8,8 +8,38 @@ type CourseTime struct {
 	CourseID  int64     `gorm:"type:INT UNSIGNED NOT NULL;comment:课程ID" json:"courseId"`
 	StartTime time.Time `gorm:"type:DATETIME NOT NULL;comment:开始时间" json:"startTime"`
 	EndTime   time.Time `gorm:"type:DATETIME NOT NULL;comment:结束时间" json:"endTime"`
+
+	RoomID      *int64 `gorm:"type:BIGINT NULL;index;comment:本次上课的教室ID, 为空时使用课程的教室" json:"roomId"`
+	Location    string `gorm:"type:VARCHAR(128) NOT NULL;default:'';comment:本次上课教室的名称" json:"location"`
+	Online      bool   `gorm:"NOT NULL;default:false;comment:是否线上上课" json:"online"`
+	MeetingLink string `gorm:"type:VARCHAR(512) NOT NULL;default:'';comment:线上上课的会议链接" json:"meetingLink"`
 }
 
 func (CourseTime) TableName() string {
 	return "course_time"
 }
+
+// 线上上课时显示的上课地点
+const OnlineLocation = "线上"
+
+// SessionRoomID 返回某次上课实际使用的教室, 线上上课时返回nil
+func (c Course) SessionRoomID(t CourseTime) *int64 {
+	if t.Online {
+		return nil
+	}
+	if t.RoomID != nil {
+		return t.RoomID
+	}
+	return c.RoomID
+}
+
+// SessionLocation 返回某次上课实际的上课地点
+func (c Course) SessionLocation(t CourseTime) string {
+	if t.Online {
+		return OnlineLocation
+	}
+	if t.RoomID != nil {
+		return t.Location
+	}
+	return c.Location
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+// CourseWatch 学生关注的课程, 课程有空余名额时提醒学生
+type CourseWatch struct {
+	StudentID  string     `gorm:"type:VARCHAR(20) NOT NULL;uniqueIndex:idx_course_watch_student_course;comment:学生ID" json:"studentId"`
+	CourseID   int64      `gorm:"type:BIGINT NOT NULL;uniqueIndex:idx_course_watch_student_course;index;comment:课程ID" json:"courseId"`
+	NotifiedAt *time.Time `gorm:"type:DATETIME(3) NULL;comment:最近一次提醒的时间" json:"notifiedAt"`
+
+	BaseModel
+}
+
+func (CourseWatch) TableName() string {
+	return "course_watch"
+}
@@ -0,0 +1,15 @@
+package model
+
+// DegreeProgram 某专业某一届学生的培养方案, 毕业要求以JSON保存
+type DegreeProgram struct {
+	Major        string `gorm:"type:VARCHAR(128) NOT NULL;uniqueIndex:idx_degree_program_major_year;comment:专业" json:"major"`
+	EntryYear    int    `gorm:"type:INT NOT NULL;uniqueIndex:idx_degree_program_major_year;comment:入学年份" json:"entryYear"`
+	Name         string `gorm:"type:VARCHAR(128) NOT NULL;default:'';comment:培养方案名称" json:"name"`
+	Requirements Fields `gorm:"comment:毕业要求" json:"requirements"`
+
+	BaseModel
+}
+
+func (DegreeProgram) TableName() string {
+	return "degree_program"
+}
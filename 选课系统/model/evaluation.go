@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+)
+
+// 评教问题类型
+const (
+	EvaluationRating = "rating" // 打分题
+	EvaluationText   = "text"   // 文字题
+)
+
+// EvaluationSurvey 评教问卷, 评教期间选课学生对每门课程各填写一次
+type EvaluationSurvey struct {
+	Title     string    `gorm:"type:VARCHAR(128) NOT NULL;comment:问卷标题" json:"title"`
+	Questions Fields    `gorm:"comment:问卷题目" json:"questions"`
+	StartTime time.Time `gorm:"type:DATETIME NOT NULL;comment:评教开始时间" json:"startTime"`
+	EndTime   time.Time `gorm:"type:DATETIME NOT NULL;comment:评教结束时间" json:"endTime"`
+
+	BaseModel
+}
+
+func (EvaluationSurvey) TableName() string {
+	return "evaluation_survey"
+}
+
+// EvaluationSubmission 记录学生已评价过哪门课程, 用于去重, 不保存答案
+type EvaluationSubmission struct {
+	SurveyID  int64  `gorm:"type:BIGINT NOT NULL;uniqueIndex:idx_evaluation_submission_student;comment:问卷ID" json:"surveyId"`
+	CourseID  int64  `gorm:"type:BIGINT NOT NULL;uniqueIndex:idx_evaluation_submission_student;comment:课程ID" json:"courseId"`
+	StudentID string `gorm:"type:VARCHAR(20) NOT NULL;uniqueIndex:idx_evaluation_submission_student;comment:学生ID" json:"studentId"`
+
+	BaseModel
+}
+
+func (EvaluationSubmission) TableName() string {
+	return "evaluation_submission"
+}
+
+// EvaluationResponse 一份匿名答卷, 与EvaluationSubmission分开保存, 不带学生ID和提交时间;
+// 主键是随机生成的, 不能按插入顺序与EvaluationSubmission对应
+type EvaluationResponse struct {
+	ID       string `gorm:"type:CHAR(32) NOT NULL;primaryKey;comment:随机主键" json:"id"`
+	SurveyID int64  `gorm:"type:BIGINT NOT NULL;index:idx_evaluation_response_course;comment:问卷ID" json:"surveyId"`
+	CourseID int64  `gorm:"type:BIGINT NOT NULL;index:idx_evaluation_response_course;comment:课程ID" json:"courseId"`
+	Answers  Fields `gorm:"comment:答案" json:"answers"`
+}
+
+func (EvaluationResponse) TableName() string {
+	return "evaluation_response"
+}
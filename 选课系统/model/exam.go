@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+type Exam struct {
+	PlanID    int64     `gorm:"type:BIGINT UNSIGNED NOT NULL;index;comment:考试安排方案ID" json:"planId"`
+	CourseID  int64     `gorm:"type:BIGINT UNSIGNED NOT NULL;index;comment:课程ID" json:"courseId"`
+	RoomID    *int64    `gorm:"type:BIGINT UNSIGNED;index;comment:考场教室ID" json:"roomId"`
+	Room      string    `gorm:"type:VARCHAR(128) NOT NULL;comment:考场" json:"room"`
+	StartTime time.Time `gorm:"type:DATETIME NOT NULL;comment:开始时间" json:"startTime"`
+	EndTime   time.Time `gorm:"type:DATETIME NOT NULL;comment:结束时间" json:"endTime"`
+	Students  int       `gorm:"type:INT NOT NULL;comment:应考人数" json:"students"`
+
+	BaseModel
+}
+
+func (Exam) TableName() string {
+	return "exam"
+}
@@ -0,0 +1,23 @@
+package model
+
+// 考试安排方案状态
+const (
+	ExamPlanDraft      = "draft"      // 待审核
+	ExamPlanApproved   = "approved"   // 已通过, 学生可见
+	ExamPlanRejected   = "rejected"   // 已驳回
+	ExamPlanSuperseded = "superseded" // 已被新的方案替代
+)
+
+type ExamPlan struct {
+	Name        string `gorm:"type:VARCHAR(128) NOT NULL;comment:方案名称" json:"name"`
+	Status      string `gorm:"type:VARCHAR(16) NOT NULL;index;comment:方案状态" json:"status"`
+	Unscheduled Fields `gorm:"comment:未能安排的课程及原因" json:"unscheduled"`
+
+	Exams []Exam `gorm:"foreignKey:PlanID" json:"exams"`
+
+	BaseModel
+}
+
+func (ExamPlan) TableName() string {
+	return "exam_plan"
+}
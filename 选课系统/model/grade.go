@@ -0,0 +1,17 @@
+package model
+
+type Grade struct {
+	CourseID   int64    `gorm:"type:BIGINT NOT NULL;uniqueIndex:idx_grade_course_student;comment:课程ID" json:"courseId"`
+	StudentID  string   `gorm:"type:VARCHAR(20) NOT NULL;uniqueIndex:idx_grade_course_student;index;comment:学生ID" json:"studentId"`
+	ScaleID    int64    `gorm:"type:BIGINT NOT NULL;comment:录入时使用的成绩等级制ID" json:"scaleId"`
+	Value      string   `gorm:"type:VARCHAR(16) NOT NULL;comment:成绩(分数或等级)" json:"value"`
+	Points     *float64 `gorm:"type:DECIMAL(4,2) NULL;comment:绩点, 通过/不通过制为空" json:"points"`
+	Passed     bool     `gorm:"NOT NULL;comment:是否通过" json:"passed"`
+	RecordedBy string   `gorm:"type:VARCHAR(32) NOT NULL;comment:录入人的用户ID" json:"recordedBy"`
+
+	BaseModel
+}
+
+func (Grade) TableName() string {
+	return "grade"
+}
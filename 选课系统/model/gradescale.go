@@ -0,0 +1,21 @@
+package model
+
+// 成绩等级制的类型
+const (
+	GradeScalePercent  = "percent"   // 百分制, 按分数段换算绩点
+	GradeScaleLetter   = "letter"    // 等级制, 如A、B+
+	GradeScalePassFail = "pass_fail" // 通过/不通过, 不计入绩点
+)
+
+type GradeScale struct {
+	Name      string `gorm:"type:VARCHAR(64) NOT NULL;uniqueIndex;comment:名称" json:"name"`
+	Kind      string `gorm:"type:VARCHAR(16) NOT NULL;comment:等级制类型" json:"kind"`
+	Rules     Fields `gorm:"comment:成绩与绩点的换算规则" json:"rules"`
+	IsDefault bool   `gorm:"NOT NULL;default:false;comment:是否为未指定等级制的课程使用的默认等级制" json:"isDefault"`
+
+	BaseModel
+}
+
+func (GradeScale) TableName() string {
+	return "grade_scale"
+}
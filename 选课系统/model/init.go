@@ -4,6 +4,7 @@ import (
 	dblog "finaltenzor/logger"
 	"fmt"
 	"log"
+	"net/url"
 	"time"
 
 	"finaltenzor/config"
@@ -16,12 +17,13 @@ import (
 var DB *gorm.DB
 
 func init() {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&collation=utf8mb4_unicode_ci&&parseTime=True&loc=Local",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&collation=utf8mb4_unicode_ci&&parseTime=True&loc=%s",
 		config.Config.MysqlUser,
 		config.Config.MysqlPass,
 		config.Config.MysqlHost,
 		config.Config.MysqlPort,
-		config.Config.MysqlName)
+		config.Config.MysqlName,
+		url.QueryEscape(config.Config.Timezone))
 	var dbLogger logger.Interface
 	if dblog.DatabaseLogger == nil {
 		dbLogger = logger.Default.LogMode(logger.Info)
@@ -63,7 +65,10 @@ func initModel() {
 
 	// example
 	// begin
-	DB.AutoMigrate(&Course{}, &CourseTime{}, &Teacher{}, &CourseTeacher{}, &CourseStudent{}, &User{})
+	DB.AutoMigrate(&Course{}, &CourseTime{}, &Teacher{}, &CourseTeacher{}, &CourseStudent{}, &User{}, &CalendarEvent{}, &ExamPlan{}, &Exam{}, &Notice{}, &Room{}, &TimetableDraft{}, &TimetableCourse{}, &TeacherAvailability{}, &RoomBooking{}, &RoomBookingTime{}, &GradeScale{}, &Grade{}, &Attendance{}, &EvaluationSurvey{}, &EvaluationSubmission{}, &EvaluationResponse{}, &DegreeProgram{}, &CourseWatch{}, &OfficeHour{}, &OfficeHourBooking{}, &SeatSwap{}, &SeatSwapMatch{}, &StudentProfile{}, &RelocationOffer{})
+	if err := migrateCourseLocations(DB); err != nil {
+		panic(err)
+	}
 	//end
 
 }
@@ -0,0 +1,22 @@
+package model
+
+// 学生通知类型
+const (
+	NoticeCourseCancelled = "course_cancelled" // 课程停开且未能调剂
+	NoticeRelocationOffer = "relocation_offer" // 课程停开并提供了替代课程名额
+	NoticeSeatAvailable   = "seat_available"   // 关注的课程有空余名额
+	NoticeSeatSwap        = "seat_swap"        // 换课申请的匹配和结果
+)
+
+type Notice struct {
+	StudentID string `gorm:"type:VARCHAR(20) NOT NULL;index;comment:学生ID" json:"studentId"`
+	Kind      string `gorm:"type:VARCHAR(32) NOT NULL;comment:通知类型" json:"kind"`
+	CourseID  int64  `gorm:"type:BIGINT UNSIGNED NOT NULL;comment:相关课程ID" json:"courseId"`
+	Message   string `gorm:"type:VARCHAR(512) NOT NULL;comment:通知内容" json:"message"`
+
+	BaseModel
+}
+
+func (Notice) TableName() string {
+	return "notice"
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+)
+
+// OfficeHour 教师每周固定的答疑时间段, 按SlotMinutes切分成可预约的时段
+type OfficeHour struct {
+	TeacherID   int64     `gorm:"type:BIGINT NOT NULL;index;comment:教师ID" json:"teacherId"`
+	FromDate    time.Time `gorm:"type:DATE NOT NULL;comment:生效开始日期" json:"fromDate"`
+	ToDate      time.Time `gorm:"type:DATE NOT NULL;comment:生效结束日期(含)" json:"toDate"`
+	Weekday     int       `gorm:"type:TINYINT NOT NULL;comment:星期几(1-7)" json:"weekday"`
+	StartClock  string    `gorm:"type:VARCHAR(5) NOT NULL;comment:开始时刻(HH:MM)" json:"startClock"`
+	EndClock    string    `gorm:"type:VARCHAR(5) NOT NULL;comment:结束时刻(HH:MM)" json:"endClock"`
+	SlotMinutes int       `gorm:"type:INT NOT NULL;comment:每个时段的分钟数" json:"slotMinutes"`
+	Location    string    `gorm:"type:VARCHAR(128) NOT NULL;default:'';comment:答疑地点" json:"location"`
+	Note        string    `gorm:"type:VARCHAR(255) NOT NULL;default:'';comment:说明" json:"note"`
+
+	BaseModel
+}
+
+func (OfficeHour) TableName() string {
+	return "office_hour"
+}
+
+// OfficeHourBooking 学生预约的一个答疑时段, 取消时直接删除以便他人重新预约
+type OfficeHourBooking struct {
+	OfficeHourID int64     `gorm:"type:BIGINT NOT NULL;uniqueIndex:idx_office_hour_booking_slot;comment:答疑时间段ID" json:"officeHourId"`
+	StartTime    time.Time `gorm:"type:DATETIME NOT NULL;uniqueIndex:idx_office_hour_booking_slot;comment:时段开始时间" json:"startTime"`
+	EndTime      time.Time `gorm:"type:DATETIME NOT NULL;comment:时段结束时间" json:"endTime"`
+	TeacherID    int64     `gorm:"type:BIGINT NOT NULL;index;comment:教师ID" json:"teacherId"`
+	StudentID    string    `gorm:"type:VARCHAR(20) NOT NULL;index;comment:学生ID" json:"studentId"`
+	Note         string    `gorm:"type:VARCHAR(255) NOT NULL;default:'';comment:预约说明, 如想讨论的问题" json:"note"`
+
+	BaseModel
+}
+
+func (OfficeHourBooking) TableName() string {
+	return "office_hour_booking"
+}
@@ -0,0 +1,24 @@
+package model
+
+// 调剂名额状态
+const (
+	RelocationOfferPending  = "pending"  // 等待学生接受或拒绝
+	RelocationOfferAccepted = "accepted" // 学生已接受并选入替代课程
+	RelocationOfferDeclined = "declined" // 学生已拒绝
+	RelocationOfferExpired  = "expired"  // 替代课程也已停开
+)
+
+// RelocationOffer 课程停开时为已选学生提供的替代课程名额, 学生接受后才选入替代课程
+type RelocationOffer struct {
+	StudentID           string `gorm:"type:VARCHAR(20) NOT NULL;index;comment:学生ID" json:"studentId"`
+	CancelledCourseID   int64  `gorm:"type:BIGINT NOT NULL;comment:停开的课程ID" json:"cancelledCourseId"`
+	CancelledCourseName string `gorm:"type:VARCHAR(128) NOT NULL;comment:停开的课程名称" json:"cancelledCourseName"`
+	CourseID            int64  `gorm:"type:BIGINT NOT NULL;index;comment:替代课程ID" json:"courseId"`
+	Status              string `gorm:"type:VARCHAR(16) NOT NULL;comment:状态" json:"status"`
+
+	BaseModel
+}
+
+func (RelocationOffer) TableName() string {
+	return "relocation_offer"
+}
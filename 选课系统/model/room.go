@@ -0,0 +1,67 @@
+package model
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type Room struct {
+	Building   string `gorm:"type:VARCHAR(64) NOT NULL;uniqueIndex:idx_room_building_name;comment:教学楼" json:"building"`
+	Name       string `gorm:"type:VARCHAR(128) NOT NULL;uniqueIndex:idx_room_building_name;comment:教室编号" json:"name"`
+	Seats      int    `gorm:"type:INT NOT NULL;comment:座位数" json:"seats"`
+	Projector  bool   `gorm:"NOT NULL;default:false;comment:是否有投影仪" json:"projector"`
+	Lab        bool   `gorm:"NOT NULL;default:false;comment:是否为实验室" json:"lab"`
+	Accessible bool   `gorm:"NOT NULL;default:false;comment:是否无障碍" json:"accessible"`
+
+	BaseModel
+}
+
+func (Room) TableName() string {
+	return "room"
+}
+
+// Label 返回教室的完整名称, 同时作为课程的上课地点
+func (r Room) Label() string {
+	if r.Building == "" {
+		return r.Name
+	}
+	return r.Building + " " + r.Name
+}
+
+// migrateCourseLocations 把还没有关联教室的课程的上课地点文本转换为教室,
+// 形如"教学楼 101"的地点按第一个空格拆成教学楼和教室编号, 座位数取该地点课程的最大容量
+func migrateCourseLocations(db *gorm.DB) error {
+	var courses []Course
+	if err := db.Where("room_id IS NULL AND location <> ''").Find(&courses).Error; err != nil {
+		return err
+	}
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	for _, course := range courses {
+		room := Room{Name: strings.TrimSpace(course.Location)}
+		if parts := strings.SplitN(room.Name, " ", 2); len(parts) == 2 {
+			room.Building, room.Name = parts[0], strings.TrimSpace(parts[1])
+		}
+		if err := tx.Where("building = ? AND name = ?", room.Building, room.Name).FirstOrCreate(&room).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if room.Seats < course.Capacity {
+			if err := tx.Model(&room).Update("seats", course.Capacity).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Model(&Course{}).Where("course_id = ?", course.CourseID).
+			Updates(map[string]any{"room_id": room.ID, "location": room.Label()}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+)
+
+// 教室预订的活动类型
+const (
+	BookingSeminar = "seminar" // 讲座、研讨会
+	BookingExam    = "exam"    // 考试
+	BookingClub    = "club"    // 社团活动
+	BookingOther   = "other"
+)
+
+type RoomBooking struct {
+	RoomID    int64  `gorm:"type:BIGINT NOT NULL;index;comment:教室ID" json:"roomId"`
+	Title     string `gorm:"type:VARCHAR(128) NOT NULL;comment:活动名称" json:"title"`
+	Kind      string `gorm:"type:VARCHAR(16) NOT NULL;comment:活动类型" json:"kind"`
+	BookedBy  string `gorm:"type:VARCHAR(32) NOT NULL;comment:预订人的用户ID" json:"bookedBy"`
+	TeacherID *int64 `gorm:"type:BIGINT NULL;index;comment:教师预订时的教师ID" json:"teacherId"`
+	Note      string `gorm:"type:VARCHAR(255) NOT NULL;default:'';comment:说明" json:"note"`
+
+	BaseModel
+
+	Times []RoomBookingTime `gorm:"foreignKey:BookingID" json:"times"`
+}
+
+func (RoomBooking) TableName() string {
+	return "room_booking"
+}
+
+// RoomBookingTime 预订的每一次使用时间, 重复预订按周展开为多条
+type RoomBookingTime struct {
+	BookingID int64     `gorm:"type:BIGINT NOT NULL;index;comment:预订ID" json:"bookingId"`
+	RoomID    int64     `gorm:"type:BIGINT NOT NULL;index;comment:教室ID, 与预订相同, 便于冲突查询" json:"roomId"`
+	StartTime time.Time `gorm:"type:DATETIME NOT NULL;comment:开始时间" json:"startTime"`
+	EndTime   time.Time `gorm:"type:DATETIME NOT NULL;comment:结束时间" json:"endTime"`
+}
+
+func (RoomBookingTime) TableName() string {
+	return "room_booking_time"
+}
@@ -0,0 +1,44 @@
+package model
+
+// 换课申请状态
+const (
+	SeatSwapOpen      = "open"      // 等待匹配
+	SeatSwapMatched   = "matched"   // 已匹配, 等待各方确认
+	SeatSwapCompleted = "completed" // 已完成交换
+	SeatSwapCancelled = "cancelled" // 已撤回或失效
+)
+
+// 换课匹配状态
+const (
+	SeatSwapMatchPending   = "pending"   // 等待各方确认
+	SeatSwapMatchCompleted = "completed" // 已完成交换
+	SeatSwapMatchCancelled = "cancelled" // 有人拒绝、超时或复核未通过
+)
+
+// SeatSwap 学生发布的换课申请: 让出GiveCourseID的名额, 换取WantCourseID的名额
+type SeatSwap struct {
+	StudentID    string `gorm:"type:VARCHAR(20) NOT NULL;index;comment:学生ID" json:"studentId"`
+	GiveCourseID int64  `gorm:"type:BIGINT NOT NULL;index;comment:让出的课程ID" json:"giveCourseId"`
+	WantCourseID int64  `gorm:"type:BIGINT NOT NULL;index;comment:想要的课程ID" json:"wantCourseId"`
+	Status       string `gorm:"type:VARCHAR(16) NOT NULL;index;comment:状态" json:"status"`
+	MatchID      *int64 `gorm:"type:BIGINT NULL;index;comment:所在的匹配ID" json:"matchId"`
+	Accepted     bool   `gorm:"NOT NULL;default:false;comment:是否已确认所在的匹配" json:"accepted"`
+
+	BaseModel
+}
+
+func (SeatSwap) TableName() string {
+	return "seat_swap"
+}
+
+// SeatSwapMatch 一组可以互换名额的换课申请, 两人互换或多人轮换, 各方都确认后一次完成
+type SeatSwapMatch struct {
+	Status string `gorm:"type:VARCHAR(16) NOT NULL;comment:状态" json:"status"`
+	Reason string `gorm:"type:VARCHAR(255) NOT NULL;default:'';comment:取消原因" json:"reason"`
+
+	BaseModel
+}
+
+func (SeatSwapMatch) TableName() string {
+	return "seat_swap_match"
+}
@@ -0,0 +1,26 @@
+package model
+
+// 学籍状态
+const (
+	StudentActive    = "active"    // 在读
+	StudentSuspended = "suspended" // 休学
+	StudentGraduated = "graduated" // 已毕业
+)
+
+// StudentProfile 学生的学籍信息, 没有学籍信息的学生视为在读; 删除时直接删除以便重新建立
+type StudentProfile struct {
+	StudentID  string `gorm:"type:VARCHAR(20) NOT NULL;uniqueIndex;comment:学生ID" json:"studentId"`
+	Department string `gorm:"type:VARCHAR(128) NOT NULL;default:'';index;comment:所属院系" json:"department"`
+	Major      string `gorm:"type:VARCHAR(128) NOT NULL;default:'';index;comment:专业" json:"major"`
+	EntryYear  int    `gorm:"type:INT NOT NULL;default:0;index;comment:入学年份" json:"entryYear"`
+	ClassName  string `gorm:"type:VARCHAR(64) NOT NULL;default:'';index;comment:行政班" json:"className"`
+	Status     string `gorm:"type:VARCHAR(16) NOT NULL;default:'active';index;comment:学籍状态" json:"status"`
+	Email      string `gorm:"type:VARCHAR(128) NOT NULL;default:'';comment:电子邮箱" json:"email"`
+	Phone      string `gorm:"type:VARCHAR(32) NOT NULL;default:'';comment:联系电话" json:"phone"`
+
+	BaseModel
+}
+
+func (StudentProfile) TableName() string {
+	return "student_profile"
+}
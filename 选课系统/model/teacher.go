@@ -1,7 +1,11 @@
 package model
 
 type Teacher struct {
-	Name string `gorm:"type:VARCHAR(128) NOT NULL;comment:教师姓名" json:"name"`
+	Name       string  `gorm:"type:VARCHAR(128) NOT NULL;comment:教师姓名" json:"name"`
+	StaffID    *string `gorm:"type:VARCHAR(32) NULL;uniqueIndex;comment:工号" json:"staffId"`
+	Department string  `gorm:"type:VARCHAR(128) NOT NULL;default:'';comment:所属院系" json:"department"`
+	Email      string  `gorm:"type:VARCHAR(128) NOT NULL;default:'';comment:电子邮箱" json:"email"`
+	Phone      string  `gorm:"type:VARCHAR(32) NOT NULL;default:'';comment:联系电话" json:"phone"`
 
 	BaseModel
 }
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// 教师时间段的类型
+const (
+	AvailabilityUnavailable = "unavailable" // 不能上课, 排课时必须避开
+	AvailabilityPreferred   = "preferred"   // 希望上课的时间, 不在其中时给出提醒
+)
+
+type TeacherAvailability struct {
+	TeacherID  int64     `gorm:"type:BIGINT UNSIGNED NOT NULL;index;comment:教师ID" json:"teacherId"`
+	Kind       string    `gorm:"type:VARCHAR(16) NOT NULL;comment:时间段类型" json:"kind"`
+	FromDate   time.Time `gorm:"type:DATE NOT NULL;comment:生效开始日期(一般为学期开始)" json:"fromDate"`
+	ToDate     time.Time `gorm:"type:DATE NOT NULL;comment:生效结束日期(含)" json:"toDate"`
+	Weekday    int       `gorm:"type:TINYINT NOT NULL;comment:星期几(1-7)" json:"weekday"`
+	StartClock string    `gorm:"type:VARCHAR(5) NOT NULL;comment:开始时刻(HH:MM)" json:"startClock"`
+	EndClock   string    `gorm:"type:VARCHAR(5) NOT NULL;comment:结束时刻(HH:MM)" json:"endClock"`
+	Note       string    `gorm:"type:VARCHAR(255) NOT NULL;default:'';comment:说明" json:"note"`
+
+	BaseModel
+}
+
+func (TeacherAvailability) TableName() string {
+	return "teacher_availability"
+}
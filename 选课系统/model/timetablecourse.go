@@ -0,0 +1,18 @@
+package model
+
+type TimetableCourse struct {
+	DraftID    int64  `gorm:"type:BIGINT UNSIGNED NOT NULL;index;comment:课表草案ID" json:"draftId"`
+	CourseName string `gorm:"type:VARCHAR(128) NOT NULL;comment:课程名称" json:"courseName"`
+	Capacity   int    `gorm:"type:INT NOT NULL;comment:课程容量" json:"capacity"`
+	TeacherIDs Fields `gorm:"comment:授课教师ID" json:"teacherIds"`
+	RoomID     *int64 `gorm:"type:BIGINT NULL;comment:安排的教室ID, 未能安排时为空" json:"roomId"`
+	Meetings   Fields `gorm:"comment:每周的上课节次" json:"meetings"`
+	Reason     string `gorm:"type:VARCHAR(255) NOT NULL;default:'';comment:未能安排的原因" json:"reason"`
+	CourseID   *int64 `gorm:"type:BIGINT NULL;comment:应用后创建的课程ID" json:"courseId"`
+
+	BaseModel
+}
+
+func (TimetableCourse) TableName() string {
+	return "timetable_course"
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// 课表草案状态
+const (
+	TimetableDraftPending   = "draft"     // 待审核
+	TimetableDraftApplied   = "applied"   // 已应用, 课程已创建
+	TimetableDraftDiscarded = "discarded" // 已放弃
+)
+
+type TimetableDraft struct {
+	Name     string    `gorm:"type:VARCHAR(128) NOT NULL;comment:草案名称" json:"name"`
+	Status   string    `gorm:"type:VARCHAR(16) NOT NULL;index;comment:草案状态" json:"status"`
+	FromDate time.Time `gorm:"type:DATE NOT NULL;comment:课表开始日期" json:"fromDate"`
+	ToDate   time.Time `gorm:"type:DATE NOT NULL;comment:课表结束日期(含)" json:"toDate"`
+	Weekdays Fields    `gorm:"comment:可排课的星期" json:"weekdays"`
+	Periods  Fields    `gorm:"comment:每天的节次及起止时刻" json:"periods"`
+	Cost     int       `gorm:"type:INT NOT NULL;default:0;comment:软约束总代价" json:"cost"`
+
+	Courses []TimetableCourse `gorm:"foreignKey:DraftID" json:"courses"`
+
+	BaseModel
+}
+
+func (TimetableDraft) TableName() string {
+	return "timetable_draft"
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"finaltenzor/middleware"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,13 +16,108 @@ func InitRouter(r *gin.Engine) {
 		{
 			adminRouter.Use(middleware.CheckRole(1))
 			{
-				adminRouter.POST("/courses", ctr.Admin.AddCourse)                   // 添加课程
-				adminRouter.DELETE("/courses/:courseId", ctr.Admin.DeleteCourse)    // 根据课程编号删除一门课程
-				adminRouter.PUT("/courses", ctr.Admin.UpdateCourse)                 // 更新课程信息
-				adminRouter.GET("/courses", ctr.Admin.GetCourses)                   // 获取所有的课程列表
-				adminRouter.GET("/courses/:courseId", ctr.Admin.GetCourseDetail)    // 获取一门课的详情
-				adminRouter.GET("/students", ctr.Admin.GetStudentsList)             // 获取学生列表
-				adminRouter.GET("/students/:studentId", ctr.Admin.GetStudentDetail) // 获取某个学生具体信息
+				adminRouter.POST("/courses", ctr.Admin.AddCourse)                                                         // 添加课程
+				adminRouter.DELETE("/courses/:courseId", ctr.Admin.DeleteCourse)                                          // 根据课程编号删除一门课程
+				adminRouter.PUT("/courses", ctr.Admin.UpdateCourse)                                                       // 更新课程信息
+				adminRouter.GET("/courses", ctr.Admin.GetCourses)                                                         // 获取所有的课程列表
+				adminRouter.GET("/courses/:courseId", ctr.Admin.GetCourseDetail)                                          // 获取一门课的详情
+				adminRouter.GET("/courses-underfilled", ctr.Admin.GetUnderfilledCourses)                                  // 结束选课轮次时获取人数不足的课程
+				adminRouter.POST("/courses/:courseId/cancel", ctr.Admin.CancelCourse)                                     // 停开课程并提供调剂名额
+				adminRouter.POST("/rooms", ctr.Room.AddRoom)                                                              // 添加教室
+				adminRouter.PUT("/rooms", ctr.Room.UpdateRoom)                                                            // 更新教室信息
+				adminRouter.DELETE("/rooms/:roomId", ctr.Room.DeleteRoom)                                                 // 删除教室
+				adminRouter.GET("/rooms", ctr.Room.GetRooms)                                                              // 获取教室列表
+				adminRouter.GET("/rooms-free", ctr.Room.FindFreeRooms)                                                    // 查找空闲教室
+				adminRouter.GET("/rooms/:roomId", ctr.Room.GetRoom)                                                       // 获取教室详情
+				adminRouter.GET("/rooms/:roomId/calendar", ctr.Room.GetRoomCalendar)                                      // 教室日历(课程和预订)
+				adminRouter.POST("/room-bookings", ctr.Room.BookRoom)                                                     // 预订教室(单次或按周重复)
+				adminRouter.GET("/room-bookings", ctr.Room.GetRoomBookings)                                               // 查询教室预订
+				adminRouter.DELETE("/room-bookings/:bookingId", ctr.Room.CancelRoomBooking)                               // 取消教室预订
+				adminRouter.GET("/audit/conflicts", ctr.Audit.GetConflictAudit)                                           // 学期冲突检查报告(可导出CSV)
+				adminRouter.POST("/teachers", ctr.Teacher.AddTeacher)                                                     // 添加教师
+				adminRouter.PUT("/teachers", ctr.Teacher.UpdateTeacher)                                                   // 更新教师信息
+				adminRouter.DELETE("/teachers/:teacherId", ctr.Teacher.DeleteTeacher)                                     // 删除教师
+				adminRouter.GET("/teachers", ctr.Teacher.GetTeachers)                                                     // 获取教师列表
+				adminRouter.GET("/teachers/:teacherId", ctr.Teacher.GetTeacher)                                           // 获取教师详情
+				adminRouter.GET("/teachers-duplicates", ctr.Teacher.FindTeacherDuplicates)                                // 查找疑似重复的教师
+				adminRouter.POST("/teachers/:teacherId/merge", ctr.Teacher.MergeTeachers)                                 // 把重复的教师合并到该教师
+				adminRouter.POST("/teachers/:teacherId/availability", ctr.Teacher.AddTeacherAvailability)                 // 添加教师不能上课或希望上课的时间段
+				adminRouter.GET("/teachers/:teacherId/availability", ctr.Teacher.GetTeacherAvailability)                  // 获取教师的时间段
+				adminRouter.DELETE("/teachers/:teacherId/availability/:blockId", ctr.Teacher.DeleteTeacherAvailability)   // 删除教师的时间段
+				adminRouter.POST("/teachers/:teacherId/account", ctr.Teacher.CreateTeacherAccount)                        // 为教师创建登录账号
+				adminRouter.GET("/teachers-workload", ctr.Teacher.GetTeacherWorkload)                                     // 教师工作量统计(可导出CSV)
+				adminRouter.PUT("/courses/:courseId/teacher-shares", ctr.Teacher.SetTeacherShares)                        // 设置合讲课程的课时分摊比例
+				adminRouter.GET("/students", ctr.Admin.GetStudentsList)                                                   // 获取学生列表
+				adminRouter.GET("/students/:studentId", ctr.Admin.GetStudentDetail)                                       // 获取某个学生具体信息
+				adminRouter.PUT("/students/:studentId/profile", ctr.Admin.SaveStudentProfile)                             // 建立或更新学生的学籍信息
+				adminRouter.DELETE("/students/:studentId/profile", ctr.Admin.DeleteStudentProfile)                        // 删除学生的学籍信息
+				adminRouter.PUT("/student-profiles", ctr.Admin.BulkUpdateStudentProfiles)                                 // 批量修改学籍信息
+				adminRouter.GET("/students/:studentId/transcript", ctr.Grade.GetTranscript)                               // 获取学生成绩单
+				adminRouter.GET("/students/:studentId/attendance", ctr.Attendance.GetStudentAttendanceSummary)            // 获取学生各课程的考勤统计
+				adminRouter.POST("/grade-scales", ctr.Grade.SaveGradeScale)                                               // 添加成绩等级制
+				adminRouter.PUT("/grade-scales", ctr.Grade.SaveGradeScale)                                                // 更新成绩等级制
+				adminRouter.GET("/grade-scales", ctr.Grade.GetGradeScales)                                                // 获取成绩等级制列表
+				adminRouter.DELETE("/grade-scales/:scaleId", ctr.Grade.DeleteGradeScale)                                  // 删除成绩等级制
+				adminRouter.PUT("/courses/:courseId/grades", ctr.Grade.RecordGrade)                                       // 录入学生成绩
+				adminRouter.GET("/courses/:courseId/grades", ctr.Grade.GetCourseGrades)                                   // 获取课程成绩
+				adminRouter.POST("/grades/import", ctr.Grade.ImportGrades)                                                // 从CSV批量导入成绩(默认仅预览)
+				adminRouter.GET("/courses/:courseId/attendance/code", ctr.Attendance.GetAttendanceCode)                   // 生成上课签到码
+				adminRouter.PUT("/courses/:courseId/attendance", ctr.Attendance.MarkAttendance)                           // 手动标记考勤
+				adminRouter.GET("/courses/:courseId/attendance", ctr.Attendance.GetSessionAttendance)                     // 获取某次上课的考勤
+				adminRouter.GET("/courses/:courseId/attendance-summary", ctr.Attendance.GetCourseAttendanceSummary)       // 课程考勤统计
+				adminRouter.POST("/evaluation-surveys", ctr.Evaluation.SaveEvaluationSurvey)                              // 添加评教问卷
+				adminRouter.PUT("/evaluation-surveys", ctr.Evaluation.SaveEvaluationSurvey)                               // 修改评教问卷
+				adminRouter.GET("/evaluation-surveys", ctr.Evaluation.GetEvaluationSurveys)                               // 获取评教问卷列表
+				adminRouter.DELETE("/evaluation-surveys/:surveyId", ctr.Evaluation.DeleteEvaluationSurvey)                // 删除评教问卷
+				adminRouter.GET("/evaluation-surveys/:surveyId/courses/:courseId", ctr.Evaluation.GetCourseEvaluation)    // 课程评教结果
+				adminRouter.GET("/evaluation-surveys/:surveyId/teachers/:teacherId", ctr.Evaluation.GetTeacherEvaluation) // 教师评教结果
+				adminRouter.POST("/degree-programs", ctr.Degree.SaveDegreeProgram)                                        // 添加培养方案
+				adminRouter.PUT("/degree-programs", ctr.Degree.SaveDegreeProgram)                                         // 修改培养方案
+				adminRouter.GET("/degree-programs", ctr.Degree.GetDegreePrograms)                                         // 获取培养方案列表
+				adminRouter.DELETE("/degree-programs/:programId", ctr.Degree.DeleteDegreeProgram)                         // 删除培养方案
+				adminRouter.GET("/students/:studentId/degree-audit", ctr.Degree.AuditDegree)                              // 学生毕业要求审核
+				adminRouter.POST("/calendar/import", ctr.Calendar.ImportCalendar)                                         // 导入ICS校历(默认仅预览)
+				adminRouter.GET("/calendar", ctr.Calendar.GetCalendar)                                                    // 获取校历事件
+				adminRouter.POST("/exams/plans", ctr.Exam.CreateExamPlan)                                                 // 生成考试安排方案
+				adminRouter.GET("/exams/plans", ctr.Exam.GetExamPlans)                                                    // 获取考试安排方案列表
+				adminRouter.GET("/exams/plans/:planId", ctr.Exam.GetExamPlan)                                             // 获取考试安排方案详情
+				adminRouter.PUT("/exams/plans/:planId/approve", ctr.Exam.ApproveExamPlan)                                 // 通过考试安排方案
+				adminRouter.PUT("/exams/plans/:planId/reject", ctr.Exam.RejectExamPlan)                                   // 驳回考试安排方案
+				adminRouter.POST("/timetables", ctr.Timetable.GenerateTimetable)                                          // 自动排课生成课表草案
+				adminRouter.GET("/timetables", ctr.Timetable.GetTimetableDrafts)                                          // 获取课表草案列表
+				adminRouter.GET("/timetables/:draftId", ctr.Timetable.GetTimetableDraft)                                  // 获取课表草案详情
+				adminRouter.PUT("/timetables/:draftId/apply", ctr.Timetable.ApplyTimetableDraft)                          // 应用课表草案, 批量创建课程
+				adminRouter.PUT("/timetables/:draftId/discard", ctr.Timetable.DiscardTimetableDraft)                      // 放弃课表草案
+			}
+		}
+		teacherRouter := apiRouter.Group("/teacher")
+		{
+			teacherRouter.Use(middleware.CheckRole(3))
+			{
+				teacherRouter.GET("/courses", ctr.Teacher.GetTeachingCourses)                                            // 获取自己讲授的课程
+				teacherRouter.GET("/courses/:courseId/students", ctr.Teacher.GetCourseRoster)                            // 分页获取自己课程的学生名单
+				teacherRouter.PUT("/courses/:courseId/grades", ctr.Grade.RecordGrade)                                    // 录入自己课程的学生成绩
+				teacherRouter.GET("/courses/:courseId/grades", ctr.Grade.GetCourseGrades)                                // 获取自己课程的成绩
+				teacherRouter.GET("/courses/:courseId/attendance/code", ctr.Attendance.GetAttendanceCode)                // 生成上课签到码
+				teacherRouter.PUT("/courses/:courseId/attendance", ctr.Attendance.MarkAttendance)                        // 手动标记考勤
+				teacherRouter.GET("/courses/:courseId/attendance", ctr.Attendance.GetSessionAttendance)                  // 获取某次上课的考勤
+				teacherRouter.GET("/courses/:courseId/attendance-summary", ctr.Attendance.GetCourseAttendanceSummary)    // 课程考勤统计
+				teacherRouter.GET("/evaluation-surveys", ctr.Evaluation.GetEvaluationSurveys)                            // 获取评教问卷列表
+				teacherRouter.GET("/evaluation-surveys/:surveyId/courses/:courseId", ctr.Evaluation.GetCourseEvaluation) // 课程评教结果
+				teacherRouter.GET("/evaluation-surveys/:surveyId", ctr.Evaluation.GetTeacherEvaluation)                  // 自己所授课程的评教结果
+				teacherRouter.POST("/office-hours", ctr.OfficeHour.AddOfficeHour)                                        // 发布答疑时间段
+				teacherRouter.GET("/office-hours", ctr.OfficeHour.GetOfficeHours)                                        // 查看自己的答疑时间段
+				teacherRouter.DELETE("/office-hours/:officeHourId", ctr.OfficeHour.DeleteOfficeHour)                     // 删除答疑时间段
+				teacherRouter.GET("/office-hour-bookings", ctr.OfficeHour.GetOfficeHourBookings)                         // 查看学生的答疑预约
+				teacherRouter.GET("/office-hour-bookings/ics", ctr.OfficeHour.ExportOfficeHourBookings)                  // 导出答疑预约日历
+				teacherRouter.GET("/schedule", ctr.Teacher.GetTeachingSchedule)                                          // 获取自己某一周的课表
+				teacherRouter.POST("/availability", ctr.Teacher.AddTeacherAvailability)                                  // 添加自己不能上课或希望上课的时间段
+				teacherRouter.GET("/availability", ctr.Teacher.GetTeacherAvailability)                                   // 获取自己的时间段
+				teacherRouter.DELETE("/availability/:blockId", ctr.Teacher.DeleteTeacherAvailability)                    // 删除自己的时间段
+				teacherRouter.GET("/rooms/:roomId/calendar", ctr.Room.GetRoomCalendar)                                   // 查看教室日历
+				teacherRouter.POST("/room-bookings", ctr.Room.BookRoom)                                                  // 预订教室(需开启教师预订)
+				teacherRouter.GET("/room-bookings", ctr.Room.GetRoomBookings)                                            // 查询自己的教室预订
+				teacherRouter.DELETE("/room-bookings/:bookingId", ctr.Room.CancelRoomBooking)                            // 取消自己的教室预订
 			}
 		}
 		userRouter := apiRouter.Group("/user")
@@ -35,10 +131,42 @@ func InitRouter(r *gin.Engine) {
 			}
 			userRouter.Use(middleware.CheckRole(2))
 			{
-				userRouter.POST("/courses", ctr.User.GrabCourse)                 // 抢课
-				userRouter.DELETE("/courses/:courseId", ctr.User.GiveUpCourse)   // 放弃选择这门课
-				userRouter.GET("/courses-selected", ctr.User.ViewGrabbedCourses) // 查看自己已经抢到的课
-				userRouter.GET("/schedule", ctr.User.GetSchedule)                // 获取用户当前已选课形成的课表
+				userRouter.POST("/courses", ctr.User.GrabCourse)                                              // 抢课
+				userRouter.DELETE("/courses/:courseId", ctr.User.GiveUpCourse)                                // 放弃选择这门课
+				userRouter.GET("/courses-selected", ctr.User.ViewGrabbedCourses)                              // 查看自己已经抢到的课
+				userRouter.GET("/schedule", ctr.User.GetSchedule)                                             // 获取用户当前已选课形成的课表
+				userRouter.GET("/notices", ctr.User.GetNotices)                                               // 获取自己的通知
+				userRouter.GET("/exams", ctr.Exam.GetExams)                                                   // 获取自己的考试安排
+				userRouter.GET("/transcript", ctr.Grade.GetTranscript)                                        // 获取自己的成绩单
+				userRouter.POST("/attendance", ctr.Attendance.CheckIn)                                        // 扫码签到
+				userRouter.GET("/attendance", ctr.Attendance.GetStudentAttendanceSummary)                     // 获取自己的考勤统计
+				userRouter.GET("/evaluations", ctr.Evaluation.GetStudentEvaluations)                          // 获取待评教的课程
+				userRouter.POST("/evaluations/:surveyId", ctr.Evaluation.SubmitEvaluation)                    // 提交课程评价
+				userRouter.GET("/degree-audit", ctr.Degree.AuditDegree)                                       // 毕业要求审核
+				userRouter.GET("/recommendations", ctr.User.RecommendCourses)                                 // 课程推荐
+				userRouter.POST("/schedule-builder", ctr.User.BuildSchedules)                                 // 排课助手, 计算不冲突的选课组合
+				userRouter.POST("/schedule-builder/submit", ctr.User.GrabCourses)                             // 一次提交选课组合
+				userRouter.POST("/watchlist", ctr.User.WatchCourse)                                           // 关注课程
+				userRouter.DELETE("/watchlist/:courseId", ctr.User.UnwatchCourse)                             // 取消关注课程
+				userRouter.GET("/watchlist", ctr.User.GetCourseWatches)                                       // 获取关注列表
+				userRouter.GET("/seat-alerts", middleware.RateLimit(10*time.Second), ctr.User.GetSeatAlerts)  // 获取空余名额提醒
+				userRouter.GET("/office-hour-teachers", ctr.OfficeHour.GetOfficeHourTeachers)                 // 可以预约答疑的教师
+				userRouter.GET("/teachers/:teacherId/office-hours", ctr.OfficeHour.GetOfficeHourSlots)        // 查看教师的答疑时段
+				userRouter.POST("/office-hour-bookings", ctr.OfficeHour.BookOfficeHour)                       // 预约答疑
+				userRouter.DELETE("/office-hour-bookings/:bookingId", ctr.OfficeHour.CancelOfficeHourBooking) // 取消答疑预约
+				userRouter.GET("/office-hour-bookings", ctr.OfficeHour.GetOfficeHourBookings)                 // 查看自己的答疑预约
+				userRouter.GET("/office-hour-bookings/ics", ctr.OfficeHour.ExportOfficeHourBookings)          // 导出答疑预约日历
+				userRouter.POST("/seat-swaps", ctr.User.PostSeatSwap)                                         // 发布换课申请
+				userRouter.GET("/seat-swaps", ctr.User.GetSeatSwapBoard)                                      // 换课看板
+				userRouter.GET("/seat-swaps/mine", ctr.User.GetSeatSwaps)                                     // 查看自己的换课申请
+				userRouter.POST("/seat-swaps/:swapId/accept", ctr.User.AcceptSeatSwap)                        // 确认换课匹配
+				userRouter.POST("/seat-swaps/:swapId/decline", ctr.User.DeclineSeatSwap)                      // 拒绝换课匹配
+				userRouter.DELETE("/seat-swaps/:swapId", ctr.User.CancelSeatSwap)                             // 撤回换课申请
+				userRouter.GET("/relocation-offers", ctr.User.GetRelocationOffers)                            // 查看课程停开后的调剂名额
+				userRouter.POST("/relocation-offers/:offerId/accept", ctr.User.AcceptRelocationOffer)         // 接受调剂名额, 选入替代课程
+				userRouter.POST("/relocation-offers/:offerId/decline", ctr.User.DeclineRelocationOffer)       // 拒绝调剂名额
+				userRouter.GET("/profile", ctr.User.GetOwnProfile)                                            // 查看自己的学籍信息
+				userRouter.PUT("/profile", ctr.User.UpdateOwnProfile)                                         // 修改自己的联系方式
 			}
 			userRouter.GET("/courses", ctr.User.GetCoursesList)            // 获取课程列表
 			userRouter.GET("/courses/:courseId", ctr.User.GetCourseDetail) // 根据课程编号获取某个课程详情
@@ -3,16 +3,38 @@ package service
 import (
 	"errors"
 	"finaltenzor/model"
+	"fmt"
 	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Admin struct{}
 
-// 添加课程
-func (a *Admin) AddCourse(CourseName string, Capacity int, CourseTeachers []string, Time []model.CourseTime, Location string) (int64, error) {
-	TeacherService := TeacherService{}
+// CourseInput 是添加和更新课程时传入的课程信息
+type CourseInput struct {
+	CourseName          string
+	CourseCode          *string // 为nil时更新课程保持原值
+	Capacity            int
+	Teachers            []string
+	TeacherIDs          []int64
+	Times               []model.CourseTime
+	RoomID              int64
+	MinEnrollment       int
+	AlternativeCourseID int64
+	Credits             *float64 // 学分、成绩等级制和类别为nil时更新课程保持原值
+	GradeScaleID        *int64   // 为0表示使用默认的成绩等级制
+	Category            *string
+}
+
+// normalizeCourseCode 课程代码不区分大小写, 统一保存为大写
+func normalizeCourseCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// 添加课程, 返回课程ID和违反教师偏好的提醒
+func (a *Admin) AddCourse(input CourseInput) (int64, []string, error) {
 	var course model.Course
 	tx := model.DB.Begin()
 	defer func() {
@@ -20,65 +42,110 @@ func (a *Admin) AddCourse(CourseName string, Capacity int, CourseTeachers []stri
 			tx.Rollback()
 		}
 	}()
-	if err := tx.Where("course_name = ?", CourseName).First(&course).Error; err == nil {
-		return 0, errors.New("该课程已存在且未被删除")
+	if err := tx.Where("course_name = ?", input.CourseName).First(&course).Error; err == nil {
+		return 0, nil, errors.New("该课程已存在且未被删除")
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		tx.Rollback()
-		return 0, err
+		return 0, nil, err
 	}
-	for _, newTime := range Time {
-		var count int64
-		if err := tx.Model(&model.Course{}).
-			Where("location = ? AND course_name != ? AND EXISTS (SELECT 1 FROM course_time WHERE course_time.course_id = course.course_id AND start_time < ? AND end_time > ?)",
-				Location, CourseName, newTime.EndTime, newTime.StartTime).
-			Count(&count).Error; err != nil {
-			tx.Rollback()
-			return 0, err
-		}
-		if count > 0 {
+	if err := checkCourseCalendar(tx, input.Times); err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	if err := checkAlternativeCourse(tx, 0, input.AlternativeCourseID); err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	if err := prepareSessions(tx, input.Times, input.Capacity); err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	// 每次上课都单独指定了教室或在线上进行时, 课程可以不指定教室
+	var room *model.Room
+	if input.RoomID > 0 {
+		var err error
+		room, err = findCourseRoom(tx, input.RoomID, input.Capacity)
+		if err != nil {
 			tx.Rollback()
-			return 0, errors.New("该地点在指定时间内已被占用")
+			return 0, nil, err
 		}
+	} else if needsCourseRoom(input.Times) {
+		tx.Rollback()
+		return 0, nil, errors.New("请为课程指定教室")
 	}
-	for _, teacherName := range CourseTeachers {
-		teacherID, err := TeacherService.FindOrRegisterTeacher(teacherName)
+	var roomID int64
+	if room != nil {
+		roomID = room.ID
+	}
+	conflict, err := roomConflict(tx, roomID, 0, input.Times)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	if conflict {
+		tx.Rollback()
+		return 0, nil, errors.New("该地点在指定时间内已被占用")
+	}
+	teacherIDs, err := resolveCourseTeachers(tx, input.TeacherIDs, input.Teachers)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	if len(teacherIDs) == 0 {
+		tx.Rollback()
+		return 0, nil, errors.New("请为课程指定教师")
+	}
+	for _, teacherID := range teacherIDs {
+		conflict, err := teacherConflict(tx, teacherID, 0, input.Times)
 		if err != nil {
 			tx.Rollback()
-			return 0, err
+			return 0, nil, err
 		}
-		for _, newTime := range Time {
-			var count int64
-			if err := tx.Model(&model.Course{}).
-				Joins("JOIN course_teacher ON course_teacher.course_id = course.course_id").
-				Where("course_teacher.teacher_id = ? AND EXISTS (SELECT 1 FROM course_time WHERE course_time.course_id = course.course_id AND start_time < ? AND end_time > ?)",
-					teacherID, newTime.EndTime, newTime.StartTime).
-				Count(&count).Error; err != nil {
-				tx.Rollback()
-				return 0, err
-			}
-			if count > 0 {
-				tx.Rollback()
-				return 0, errors.New("教师在指定时间内已被安排其他课程")
-			}
+		if conflict {
+			tx.Rollback()
+			return 0, nil, errors.New("教师在指定时间内已被安排其他课程")
 		}
 	}
+	warnings, err := checkTeacherAvailability(tx, teacherIDs, input.Times)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
 	course = model.Course{
-		CourseName:  CourseName,
-		Capacity:    Capacity,
-		CourseTimes: Time,
-		Location:    Location,
+		CourseName:    input.CourseName,
+		Capacity:      input.Capacity,
+		CourseTimes:   input.Times,
+		MinEnrollment: input.MinEnrollment,
+	}
+	if input.CourseCode != nil {
+		course.CourseCode = normalizeCourseCode(*input.CourseCode)
+	}
+	if input.Credits != nil {
+		course.Credits = *input.Credits
+	}
+	if input.Category != nil {
+		course.Category = *input.Category
+	}
+	if input.GradeScaleID != nil && *input.GradeScaleID > 0 {
+		if err := checkGradeScale(tx, *input.GradeScaleID); err != nil {
+			tx.Rollback()
+			return 0, nil, err
+		}
+		course.GradeScaleID = input.GradeScaleID
+	}
+	if room != nil {
+		course.Location = room.Label()
+		course.RoomID = &room.ID
+	}
+	if input.AlternativeCourseID > 0 {
+		course.AlternativeCourseID = &input.AlternativeCourseID
 	}
 	if err := tx.Create(&course).Error; err != nil {
 		tx.Rollback()
-		return 0, err
+		return 0, nil, err
 	}
 	var courseTeachers []model.CourseTeacher
-	for _, teacherName := range CourseTeachers {
-		teacherID, err := TeacherService.FindOrRegisterTeacher(teacherName)
-		if err != nil {
-			tx.Rollback()
-			return 0, err
-		}
+	for _, teacherID := range teacherIDs {
 		courseTeachers = append(courseTeachers, model.CourseTeacher{
 			CourseID:  course.CourseID,
 			TeacherID: teacherID,
@@ -87,13 +154,13 @@ func (a *Admin) AddCourse(CourseName string, Capacity int, CourseTeachers []stri
 	if len(courseTeachers) > 0 {
 		if err := tx.Create(&courseTeachers).Error; err != nil {
 			tx.Rollback()
-			return 0, err
+			return 0, nil, err
 		}
 	}
 	if err := tx.Commit().Error; err != nil {
-		return 0, err
+		return 0, nil, err
 	}
-	return course.CourseID, nil
+	return course.CourseID, warnings, nil
 }
 
 // 删除课程
@@ -113,31 +180,32 @@ func (a *Admin) DeleteCourse(courseID int64) error {
 		tx.Rollback()
 		return err
 	}
-	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseTime{}).Error; err != nil {
+	if err := deleteCourseRecords(tx, courseID); err != nil {
 		tx.Rollback()
 		return err
 	}
-	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseStudent{}).Error; err != nil {
-		tx.Rollback()
+	if err := tx.Commit().Error; err != nil {
 		return err
 	}
-	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseTeacher{}).Error; err != nil {
-		tx.Rollback()
+	return nil
+}
+
+// deleteCourseRecords 删除课程及其上课时间、选课和授课记录
+func deleteCourseRecords(tx *gorm.DB, courseID int64) error {
+	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseTime{}).Error; err != nil {
 		return err
 	}
-	if err := tx.Delete(&model.Course{}, courseID).Error; err != nil {
-		tx.Rollback()
+	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseStudent{}).Error; err != nil {
 		return err
 	}
-	if err := tx.Commit().Error; err != nil {
+	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseTeacher{}).Error; err != nil {
 		return err
 	}
-	return nil
+	return tx.Delete(&model.Course{}, courseID).Error
 }
 
-// 更新课程
-func (a *Admin) UpdateCourse(courseID int64, CourseName string, Capacity int, CourseTeachers []string, Time []model.CourseTime, Location string) error {
-	TeacherService := TeacherService{}
+// 更新课程, 返回违反教师偏好的提醒
+func (a *Admin) UpdateCourse(courseID int64, input CourseInput) ([]string, error) {
 	var course model.Course
 	tx := model.DB.Begin()
 	defer func() {
@@ -148,111 +216,224 @@ func (a *Admin) UpdateCourse(courseID int64, CourseName string, Capacity int, Co
 	if err := tx.First(&course, courseID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			tx.Rollback()
-			return errors.New("课程不存在")
+			return nil, errors.New("课程不存在")
 		}
 		tx.Rollback()
-		return err
+		return nil, err
 	}
-	course.CourseName = CourseName
-	course.Capacity = Capacity
-	course.Location = Location
-	if len(Time) > 0 {
-		for _, timeItem := range Time {
-			var conflictingCourses []model.Course
-			if err := tx.Model(&model.Course{}).
-				Joins("JOIN course_time ON course_time.course_id = course.course_id").
-				Where("location = ? AND course.course_id != ? AND ((course_time.end_time > ?) AND (course_time.start_time < ?))",
-					Location, courseID, timeItem.StartTime, timeItem.EndTime).Find(&conflictingCourses).Error; err != nil {
-				tx.Rollback()
-				return err
-			}
-			if len(conflictingCourses) > 0 {
-				tx.Rollback()
-				return errors.New("教室冲突，请检查课程时间安排")
-			}
+	course.CourseName = input.CourseName
+	capacityIncreased := input.Capacity > course.Capacity
+	course.Capacity = input.Capacity
+	roomID := input.RoomID
+	if roomID == 0 && course.RoomID != nil {
+		roomID = *course.RoomID
+	}
+	var room *model.Room
+	var err error
+	if roomID > 0 {
+		room, err = findCourseRoom(tx, roomID, input.Capacity)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
 		}
-		for i := range Time {
-			Time[i].CourseID = course.CourseID
+	}
+	// 按更新后的上课时间检查每次上课的教室容量, 没有单独指定教室的线下上课需要课程的教室
+	sessions := input.Times
+	if len(sessions) == 0 {
+		if err := tx.Where("course_id = ?", courseID).Find(&sessions).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := prepareSessions(tx, sessions, input.Capacity); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if room == nil && needsCourseRoom(sessions) {
+		tx.Rollback()
+		return nil, errors.New("请为课程指定教室")
+	}
+	roomChanged := room != nil && (course.RoomID == nil || *course.RoomID != room.ID)
+	if room != nil {
+		course.Location = room.Label()
+		course.RoomID = &room.ID
+	}
+	course.MinEnrollment = input.MinEnrollment
+	if input.CourseCode != nil {
+		course.CourseCode = normalizeCourseCode(*input.CourseCode)
+	}
+	if input.Credits != nil {
+		course.Credits = *input.Credits
+	}
+	if input.Category != nil {
+		course.Category = *input.Category
+	}
+	if input.GradeScaleID != nil {
+		if err := checkGradeScale(tx, *input.GradeScaleID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		course.GradeScaleID = nil
+		if *input.GradeScaleID > 0 {
+			course.GradeScaleID = input.GradeScaleID
+		}
+	}
+	course.AlternativeCourseID = nil
+	if input.AlternativeCourseID > 0 {
+		if err := checkAlternativeCourse(tx, courseID, input.AlternativeCourseID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		course.AlternativeCourseID = &input.AlternativeCourseID
+	}
+	if len(input.Times) > 0 {
+		if err := checkCourseCalendar(tx, input.Times); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		conflict, err := roomConflict(tx, roomID, courseID, input.Times)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if conflict {
+			tx.Rollback()
+			return nil, errors.New("教室冲突，请检查课程时间安排")
+		}
+		for i := range input.Times {
+			input.Times[i].CourseID = course.CourseID
 		}
 		if err := tx.Where("course_id = ?", course.CourseID).Delete(&model.CourseTime{}).Error; err != nil {
 			tx.Rollback()
-			return err
+			return nil, err
+		}
+		if err := tx.Create(&input.Times).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	} else if roomChanged {
+		conflict, err := roomConflict(tx, roomID, courseID, sessions)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
 		}
-		if err := tx.Create(&Time).Error; err != nil {
+		if conflict {
 			tx.Rollback()
-			return err
+			return nil, errors.New("教室冲突，请检查课程时间安排")
 		}
 	}
-	if len(CourseTeachers) > 0 {
-		var teacherIDs []int64
-		for _, teacherName := range CourseTeachers {
-			teacherID, err := TeacherService.FindOrRegisterTeacher(teacherName)
-			if err != nil {
-				tx.Rollback()
-				return err
-			}
-			teacherIDs = append(teacherIDs, teacherID)
-		}
-		if len(teacherIDs) > 0 && len(Time) > 0 {
-			var conflicts []model.CourseTeacher
-			if err := tx.Model(&model.CourseTeacher{}).
-				Joins("JOIN course_time ON course_time.course_id = course_teacher.course_id").
-				Where("teacher_id IN (?) AND course_teacher.course_id != ? AND ((course_time.start_time < ? AND course_time.end_time > ?) OR (course_time.start_time < ? AND course_time.end_time > ?))",
-					teacherIDs, courseID, Time[0].EndTime, Time[0].StartTime, Time[0].StartTime, Time[0].EndTime).Find(&conflicts).Error; err != nil {
-				tx.Rollback()
-				return err
-			}
-			if len(conflicts) > 0 {
-				tx.Rollback()
-				return errors.New("教师冲突，请检查课程时间安排")
-			}
+	if len(input.Teachers) > 0 || len(input.TeacherIDs) > 0 {
+		teacherIDs, err := resolveCourseTeachers(tx, input.TeacherIDs, input.Teachers)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		// 保留仍在授课的教师已设置的课时分摊比例
+		var oldTeachers []model.CourseTeacher
+		if err := tx.Where("course_id = ?", course.CourseID).Find(&oldTeachers).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		shares := make(map[int64]float64, len(oldTeachers))
+		for _, oldTeacher := range oldTeachers {
+			shares[oldTeacher.TeacherID] = oldTeacher.HourShare
+		}
+		if err := tx.Where("course_id = ?", course.CourseID).Delete(&model.CourseTeacher{}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
 		}
 		for _, teacherID := range teacherIDs {
 			courseTeacher := model.CourseTeacher{
 				CourseID:  course.CourseID,
 				TeacherID: teacherID,
+				HourShare: shares[teacherID],
 			}
 			if err := tx.Create(&courseTeacher).Error; err != nil {
 				tx.Rollback()
-				return err
+				return nil, err
 			}
 		}
 	}
+	// 教师或时间变化后, 按更新后的教师和全部上课时间检查冲突和教师时间段
+	var warnings []string
+	if len(input.Times) > 0 || len(input.Teachers) > 0 || len(input.TeacherIDs) > 0 {
+		var teacherIDs []int64
+		if err := tx.Model(&model.CourseTeacher{}).Where("course_id = ?", courseID).
+			Pluck("teacher_id", &teacherIDs).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		var times []model.CourseTime
+		if err := tx.Where("course_id = ?", courseID).Find(&times).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		for _, teacherID := range teacherIDs {
+			conflict, err := teacherConflict(tx, teacherID, courseID, times)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if conflict {
+				tx.Rollback()
+				return nil, errors.New("教师冲突，请检查课程时间安排")
+			}
+		}
+		if warnings, err = checkTeacherAvailability(tx, teacherIDs, times); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
 	if err := tx.Save(&course).Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
+	}
+	if capacityIncreased {
+		if err := notifyWatchers(tx, courseID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// checkAlternativeCourse 检查停开时的替代课程是否存在且不是课程本身
+func checkAlternativeCourse(tx *gorm.DB, courseID int64, alternativeID int64) error {
+	if alternativeID == 0 {
+		return nil
+	}
+	if alternativeID == courseID {
+		return errors.New("替代课程不能是课程本身")
+	}
+	var count int64
+	if err := tx.Model(&model.Course{}).Where("course_id = ?", alternativeID).Count(&count).Error; err != nil {
 		return err
 	}
+	if count == 0 {
+		return errors.New("替代课程不存在")
+	}
 	return nil
 }
 
 // 查询课程
-func (a *Admin) GetCourses(page int, limit int, courseName string, teachers []string, times []model.CourseTime, location string) ([]model.Course, int, error) {
-	TeacherService := TeacherService{}
+func (a *Admin) GetCourses(page int, limit int, courseName string, teacherIDs []int64, teachers []string, times []model.CourseTime, location string, roomID int64) ([]model.Course, int, error) {
 	var courses []model.Course
 	var total int64
 	query := model.DB.Model(&model.Course{}).Preload("CourseTimes")
 	if courseName != "" {
 		query = query.Where("course_name LIKE ?", "%"+courseName+"%")
 	}
-	if len(teachers) > 0 {
-		var teacherIDs []int64
-		for _, teacherName := range teachers {
-			teacher, err := TeacherService.FindTeacherByName(teacherName)
-			if err != nil {
-				return nil, 0, err
-			}
-			if teacher == nil {
-				return nil, 0, errors.New("教师未找到: " + teacherName)
-			}
-			teacherIDs = append(teacherIDs, teacher.ID)
-		}
-		if len(teacherIDs) > 0 {
-			query = query.Joins("JOIN course_teacher ON course_teacher.course_id = course.course_id").
-				Where("course_teacher.teacher_id IN (?)", teacherIDs)
+	if len(teacherIDs) > 0 || len(teachers) > 0 {
+		// 同名教师有多位或姓名不存在时报错, 与保存课程时的规则相同
+		resolved, err := resolveCourseTeachers(model.DB, teacherIDs, teachers)
+		if err != nil {
+			return nil, 0, err
 		}
+		query = query.Where("course.course_id IN (?)",
+			model.DB.Model(&model.CourseTeacher{}).Select("course_id").Where("teacher_id IN ?", resolved))
 	}
 	if len(times) > 0 {
 		var timeConditions []string
@@ -264,7 +445,11 @@ func (a *Admin) GetCourses(page int, limit int, courseName string, teachers []st
 		query = query.Where(strings.Join(timeConditions, " OR "), timeArgs...)
 	}
 	if location != "" {
-		query = query.Where("location LIKE ?", "%"+location+"%")
+		query = query.Where("course.location LIKE ? OR course.course_id IN (SELECT course_id FROM course_time WHERE course_time.location LIKE ?)",
+			"%"+location+"%", "%"+location+"%")
+	}
+	if roomID > 0 {
+		query = query.Where("course.room_id = ? OR course.course_id IN (SELECT course_id FROM course_time WHERE course_time.room_id = ?)", roomID, roomID)
 	}
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -285,8 +470,8 @@ func (a *Admin) GetCourseDetail(courseID int64) (*model.Course, error) {
 	return &course, nil
 }
 
-// 获取学生列表
-func (a *Admin) GetStudentsList(page int, limit int, studentName string, studentID string) ([]model.User, map[string]int, error) {
+// 获取学生列表, 按学籍信息筛选时只包括已建立学籍信息的学生
+func (a *Admin) GetStudentsList(page int, limit int, studentName string, studentID string, filter StudentFilter) ([]model.User, map[string]int, error) {
 	var students []model.User
 	courseCounts := make(map[string]int)
 	query := model.DB.Model(&model.User{})
@@ -296,6 +481,25 @@ func (a *Admin) GetStudentsList(page int, limit int, studentName string, student
 	if studentID != "" {
 		query = query.Where("user_id = ?", studentID)
 	}
+	if filter != (StudentFilter{}) {
+		// 没有建立学籍信息的学生视为在读
+		query = query.Joins("LEFT JOIN student_profile ON student_profile.student_id = `user`.user_id AND student_profile.deleted_at IS NULL")
+		if filter.Department != "" {
+			query = query.Where("student_profile.department = ?", filter.Department)
+		}
+		if filter.Major != "" {
+			query = query.Where("student_profile.major = ?", filter.Major)
+		}
+		if filter.EntryYear != 0 {
+			query = query.Where("student_profile.entry_year = ?", filter.EntryYear)
+		}
+		if filter.ClassName != "" {
+			query = query.Where("student_profile.class_name = ?", filter.ClassName)
+		}
+		if filter.Status != "" {
+			query = query.Where("COALESCE(student_profile.status, ?) = ?", model.StudentActive, filter.Status)
+		}
+	}
 	if err := query.Limit(limit).Offset((page - 1) * limit).Find(&students).Error; err != nil {
 		return nil, nil, err
 	}
@@ -330,3 +534,171 @@ func (a *Admin) GetStudentDetail(studentID string) (*model.User, *[]model.Course
 	}
 	return &student, &courses, nil
 }
+
+type UnderfilledCourse struct {
+	Course   model.Course
+	Enrolled int
+}
+
+type CancellationOutcome struct {
+	StudentID string
+	OfferedTo int64
+	Reason    string
+}
+
+// GetUnderfilledCourses 获取选课人数未达到最低开课人数的课程
+func (a *Admin) GetUnderfilledCourses() ([]UnderfilledCourse, error) {
+	var courses []model.Course
+	if err := model.DB.Where("min_enrollment > 0").Order("course_id").Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	var courseIDs []int64
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.CourseID)
+	}
+	counts, err := enrollmentCounts(model.DB, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	underfilled := []UnderfilledCourse{}
+	for _, course := range courses {
+		if counts[course.CourseID] < course.MinEnrollment {
+			underfilled = append(underfilled, UnderfilledCourse{Course: course, Enrolled: counts[course.CourseID]})
+		}
+	}
+	return underfilled, nil
+}
+
+// CancelCourse 停开选课人数不足的课程, force为true时不检查人数; 尽可能为已选学生提供替代课程的名额,
+// 学生接受后才选入替代课程, 并给每位学生发送处理结果通知
+func (a *Admin) CancelCourse(courseID int64, force bool) ([]CancellationOutcome, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var course model.Course
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&course, courseID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("课程不存在")
+		}
+		return nil, err
+	}
+	if !force {
+		counts, err := enrollmentCounts(tx, []int64{courseID})
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if counts[courseID] >= course.MinEnrollment {
+			tx.Rollback()
+			return nil, fmt.Errorf("课程选课人数%d已达到最低开课人数%d, 如确需停开请指定force", counts[courseID], course.MinEnrollment)
+		}
+	}
+	var alternative *model.Course
+	var alternativeCount int
+	if course.AlternativeCourseID != nil {
+		var found model.Course
+		err := tx.Preload("CourseTimes").First(&found, *course.AlternativeCourseID).Error
+		switch {
+		case err == nil:
+			alternative = &found
+			counts, err := enrollmentCounts(tx, []int64{found.CourseID})
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			alternativeCount = counts[found.CourseID]
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	// 以本课程为替代课程、尚未处理的名额随之失效
+	if err := tx.Model(&model.RelocationOffer{}).Where("course_id = ? AND status = ?", courseID, model.RelocationOfferPending).
+		Update("status", model.RelocationOfferExpired).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	var studentIDs []string
+	if err := tx.Model(&model.CourseStudent{}).Where("course_id = ?", courseID).
+		Order("student_id").Pluck("student_id", &studentIDs).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	outcomes := []CancellationOutcome{}
+	for _, studentID := range studentIDs {
+		outcome := CancellationOutcome{StudentID: studentID, Reason: "课程未配置替代课程"}
+		if alternative != nil {
+			reason, err := relocationReason(tx, studentID, courseID, alternative, alternativeCount)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			outcome.Reason = reason
+			if reason == "" {
+				offer := model.RelocationOffer{
+					StudentID:           studentID,
+					CancelledCourseID:   courseID,
+					CancelledCourseName: course.CourseName,
+					CourseID:            alternative.CourseID,
+					Status:              model.RelocationOfferPending,
+				}
+				if err := tx.Create(&offer).Error; err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+				outcome.OfferedTo = alternative.CourseID
+			}
+		}
+		notice := model.Notice{
+			StudentID: studentID,
+			Kind:      model.NoticeCourseCancelled,
+			CourseID:  courseID,
+			Message:   fmt.Sprintf("课程《%s》因选课人数不足停开, 未能调剂: %s", course.CourseName, outcome.Reason),
+		}
+		if outcome.OfferedTo != 0 {
+			notice.Kind = model.NoticeRelocationOffer
+			notice.Message = fmt.Sprintf("课程《%s》因选课人数不足停开, 可以调剂到《%s》, 请在调剂名额中接受或拒绝", course.CourseName, alternative.CourseName)
+		}
+		if err := tx.Create(&notice).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	if err := deleteCourseRecords(tx, courseID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// relocationReason 检查能否为学生提供替代课程的名额, 不能提供时返回原因; 名额不预留, 学生接受时再按抢课规则检查
+func relocationReason(tx *gorm.DB, studentID string, courseID int64, alternative *model.Course, alternativeCount int) (string, error) {
+	var enrolled int64
+	if err := tx.Model(&model.CourseStudent{}).
+		Where("course_id = ? AND student_id = ?", alternative.CourseID, studentID).
+		Count(&enrolled).Error; err != nil {
+		return "", err
+	}
+	if enrolled > 0 {
+		return "已选替代课程", nil
+	}
+	if alternativeCount >= alternative.Capacity {
+		return "替代课程容量已满", nil
+	}
+	conflict, err := studentTimeConflict(tx, studentID, alternative.CourseTimes, courseID)
+	if err != nil {
+		return "", err
+	}
+	if conflict {
+		return "替代课程与已选课程时间冲突", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"finaltenzor/config"
+	"finaltenzor/model"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Attendance struct{}
+
+const (
+	attendanceCodePeriod = 30 * time.Second // 签到码每隔多久更换一次
+	attendanceCodeGrace  = 1                // 额外接受之前几个周期的签到码, 容忍扫码和网络延迟
+	attendanceOpenBefore = 15 * time.Minute // 上课前多久开始可以签到
+	attendanceLateAfter  = 10 * time.Minute // 上课开始多久之后签到记为迟到
+)
+
+// findSession 按课程ID和开始时间查找一次上课
+func findSession(db *gorm.DB, courseID int64, start time.Time) (*model.CourseTime, error) {
+	var session model.CourseTime
+	err := db.Joins("JOIN course ON course.course_id = course_time.course_id AND course.deleted_at IS NULL").
+		Where("course_time.course_id = ? AND course_time.start_time = ?", courseID, start).
+		First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("该课程在此时间没有上课")
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// errNoAttendanceSecret 未配置签到码密钥时不能生成和校验签到码
+var errNoAttendanceSecret = errors.New("系统未配置签到码密钥(APP_ATTENDANCE_SECRET), 暂不能使用扫码签到")
+
+// attendanceSignature 对课程、上课时间和签到码周期签名
+func attendanceSignature(courseID int64, start int64, window int64) string {
+	mac := hmac.New(sha256.New, config.Config.AttendanceSecret)
+	fmt.Fprintf(mac, "attendance:%d:%d:%d", courseID, start, window)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// sessionOpen 判断此刻是否在这次上课的签到时间内
+func sessionOpen(session *model.CourseTime, now time.Time) bool {
+	return !now.Before(session.StartTime.Add(-attendanceOpenBefore)) && now.Before(session.EndTime)
+}
+
+// AttendanceCode 生成当前周期的签到码, 签到码在expiresAt之后失效, 前端应在此之前刷新二维码
+func (a *Attendance) AttendanceCode(courseID int64, start time.Time, now time.Time) (string, time.Time, error) {
+	if len(config.Config.AttendanceSecret) == 0 {
+		return "", time.Time{}, errNoAttendanceSecret
+	}
+	session, err := findSession(model.DB, courseID, start)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !sessionOpen(session, now) {
+		return "", time.Time{}, errors.New("不在签到时间内")
+	}
+	window := now.Unix() / int64(attendanceCodePeriod/time.Second)
+	startUnix := session.StartTime.Unix()
+	code := fmt.Sprintf("%d.%d.%d.%s", courseID, startUnix, window, attendanceSignature(courseID, startUnix, window))
+	expiresAt := time.Unix((window+1+attendanceCodeGrace)*int64(attendanceCodePeriod/time.Second), 0)
+	return code, expiresAt, nil
+}
+
+// parseAttendanceCode 校验签到码的签名和有效期, 返回课程ID和上课开始时间
+func parseAttendanceCode(code string, now time.Time) (int64, time.Time, error) {
+	if len(config.Config.AttendanceSecret) == 0 {
+		return 0, time.Time{}, errNoAttendanceSecret
+	}
+	invalid := errors.New("无效的签到码")
+	parts := strings.Split(strings.TrimSpace(code), ".")
+	if len(parts) != 4 {
+		return 0, time.Time{}, invalid
+	}
+	var numbers [3]int64
+	for i := range numbers {
+		number, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil {
+			return 0, time.Time{}, invalid
+		}
+		numbers[i] = number
+	}
+	courseID, startUnix, window := numbers[0], numbers[1], numbers[2]
+	if !hmac.Equal([]byte(parts[3]), []byte(attendanceSignature(courseID, startUnix, window))) {
+		return 0, time.Time{}, invalid
+	}
+	current := now.Unix() / int64(attendanceCodePeriod/time.Second)
+	if window > current || window < current-attendanceCodeGrace {
+		return 0, time.Time{}, errors.New("签到码已过期, 请扫描最新的二维码")
+	}
+	return courseID, time.Unix(startUnix, 0).In(config.Config.Location), nil
+}
+
+// checkEnrolled 检查学生是否选修了课程
+func checkEnrolled(db *gorm.DB, courseID int64, studentID string) error {
+	var count int64
+	if err := db.Model(&model.CourseStudent{}).Where("course_id = ? AND student_id = ?", courseID, studentID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("您没有选修该课程")
+	}
+	return nil
+}
+
+// saveAttendance 记录一名学生一次上课的考勤, 已有记录时覆盖
+func saveAttendance(db *gorm.DB, record model.Attendance) (*model.Attendance, error) {
+	var existing model.Attendance
+	err := db.Where("course_id = ? AND session_start = ? AND student_id = ?", record.CourseID, record.SessionStart, record.StudentID).
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	record.BaseModel = existing.BaseModel
+	if err := db.Save(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CheckIn 学生提交签到码签到, 上课开始一段时间后签到记为迟到; 已签到的不重复记录
+func (a *Attendance) CheckIn(studentID string, code string, now time.Time) (*model.Attendance, error) {
+	courseID, start, err := parseAttendanceCode(code, now)
+	if err != nil {
+		return nil, err
+	}
+	session, err := findSession(model.DB, courseID, start)
+	if err != nil {
+		return nil, err
+	}
+	if !sessionOpen(session, now) {
+		return nil, errors.New("不在签到时间内")
+	}
+	if err := checkEnrolled(model.DB, courseID, studentID); err != nil {
+		return nil, err
+	}
+	var existing model.Attendance
+	err = model.DB.Where("course_id = ? AND session_start = ? AND student_id = ?", courseID, session.StartTime, studentID).
+		First(&existing).Error
+	if err == nil && (existing.Status == model.AttendancePresent || existing.Status == model.AttendanceLate) {
+		return &existing, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	status := model.AttendancePresent
+	if now.After(session.StartTime.Add(attendanceLateAfter)) {
+		status = model.AttendanceLate
+	}
+	return saveAttendance(model.DB, model.Attendance{
+		CourseID:     courseID,
+		SessionStart: session.StartTime,
+		StudentID:    studentID,
+		Status:       status,
+		Method:       model.AttendanceByCode,
+		MarkedBy:     studentID,
+	})
+}
+
+// MarkAttendance 教师或管理员手动标记学生的考勤
+func (a *Attendance) MarkAttendance(courseID int64, start time.Time, studentID string, status string, markedBy string) (*model.Attendance, error) {
+	switch status {
+	case model.AttendancePresent, model.AttendanceLate, model.AttendanceAbsent, model.AttendanceExcused:
+	default:
+		return nil, errors.New("无效的考勤状态")
+	}
+	session, err := findSession(model.DB, courseID, start)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEnrolled(model.DB, courseID, studentID); err != nil {
+		return nil, fmt.Errorf("学生%s没有选修该课程", studentID)
+	}
+	return saveAttendance(model.DB, model.Attendance{
+		CourseID:     courseID,
+		SessionStart: session.StartTime,
+		StudentID:    studentID,
+		Status:       status,
+		Method:       model.AttendanceByManual,
+		MarkedBy:     markedBy,
+	})
+}
+
+type SessionAttendance struct {
+	Student model.User
+	Record  *model.Attendance // 没有记录时为nil
+}
+
+// GetSessionAttendance 获取一次上课所有选课学生的考勤
+func (a *Attendance) GetSessionAttendance(courseID int64, start time.Time) (*model.CourseTime, []SessionAttendance, error) {
+	session, err := findSession(model.DB, courseID, start)
+	if err != nil {
+		return nil, nil, err
+	}
+	var students []model.User
+	if err := model.DB.Joins("JOIN course_student ON course_student.student_id = user.user_id").
+		Where("course_student.course_id = ?", courseID).Order("user.user_id").Find(&students).Error; err != nil {
+		return nil, nil, err
+	}
+	var records []model.Attendance
+	if err := model.DB.Where("course_id = ? AND session_start = ?", courseID, session.StartTime).
+		Find(&records).Error; err != nil {
+		return nil, nil, err
+	}
+	byStudent := make(map[string]*model.Attendance, len(records))
+	for i := range records {
+		byStudent[records[i].StudentID] = &records[i]
+	}
+	result := make([]SessionAttendance, 0, len(students))
+	for _, student := range students {
+		result = append(result, SessionAttendance{Student: student, Record: byStudent[student.UserID]})
+	}
+	return session, result, nil
+}
+
+type AttendanceSummary struct {
+	CourseID  int64
+	StudentID string
+	Sessions  int // 已经开始的上课次数
+	Present   int
+	Late      int
+	Excused   int
+	Absent    int // 没有签到或被标记缺勤的次数
+}
+
+// Rate 出勤率, 迟到算出勤, 请假不计入
+func (s AttendanceSummary) Rate() float64 {
+	if s.Sessions-s.Excused <= 0 {
+		return 1
+	}
+	return float64(s.Present+s.Late) / float64(s.Sessions-s.Excused)
+}
+
+// attendanceSummaries 统计选课记录pairs在now之前已开始的上课中的考勤
+func attendanceSummaries(pairs []model.CourseStudent, now time.Time) ([]AttendanceSummary, error) {
+	summaries := make([]AttendanceSummary, 0, len(pairs))
+	if len(pairs) == 0 {
+		return summaries, nil
+	}
+	courseSet := make(map[int64]bool)
+	var courseIDs []int64
+	for _, pair := range pairs {
+		if !courseSet[pair.CourseID] {
+			courseSet[pair.CourseID] = true
+			courseIDs = append(courseIDs, pair.CourseID)
+		}
+	}
+	var sessionCounts []struct {
+		CourseID int64
+		Count    int
+	}
+	if err := model.DB.Model(&model.CourseTime{}).Select("course_id, COUNT(*) AS count").
+		Where("course_id IN ? AND start_time <= ?", courseIDs, now).
+		Group("course_id").Scan(&sessionCounts).Error; err != nil {
+		return nil, err
+	}
+	sessions := make(map[int64]int, len(sessionCounts))
+	for _, item := range sessionCounts {
+		sessions[item.CourseID] = item.Count
+	}
+	var statusCounts []struct {
+		CourseID  int64
+		StudentID string
+		Status    string
+		Count     int
+	}
+	if err := model.DB.Model(&model.Attendance{}).Select("course_id, student_id, status, COUNT(*) AS count").
+		Where("course_id IN ? AND session_start <= ?", courseIDs, now).
+		Group("course_id, student_id, status").Scan(&statusCounts).Error; err != nil {
+		return nil, err
+	}
+	type key struct {
+		courseID  int64
+		studentID string
+	}
+	index := make(map[key]int, len(pairs))
+	for _, pair := range pairs {
+		index[key{pair.CourseID, pair.StudentID}] = len(summaries)
+		summaries = append(summaries, AttendanceSummary{
+			CourseID:  pair.CourseID,
+			StudentID: pair.StudentID,
+			Sessions:  sessions[pair.CourseID],
+		})
+	}
+	for _, item := range statusCounts {
+		i, ok := index[key{item.CourseID, item.StudentID}]
+		if !ok {
+			continue
+		}
+		switch item.Status {
+		case model.AttendancePresent:
+			summaries[i].Present += item.Count
+		case model.AttendanceLate:
+			summaries[i].Late += item.Count
+		case model.AttendanceExcused:
+			summaries[i].Excused += item.Count
+		}
+	}
+	for i := range summaries {
+		summary := &summaries[i]
+		summary.Absent = max(summary.Sessions-summary.Present-summary.Late-summary.Excused, 0)
+	}
+	return summaries, nil
+}
+
+// CourseAttendanceSummary 统计课程每名选课学生的考勤
+func (a *Attendance) CourseAttendanceSummary(courseID int64, now time.Time) ([]AttendanceSummary, error) {
+	var pairs []model.CourseStudent
+	if err := model.DB.Where("course_id = ?", courseID).Order("student_id").Find(&pairs).Error; err != nil {
+		return nil, err
+	}
+	return attendanceSummaries(pairs, now)
+}
+
+// StudentAttendanceSummary 统计学生所选每门课程的考勤
+func (a *Attendance) StudentAttendanceSummary(studentID string, now time.Time) ([]AttendanceSummary, error) {
+	var pairs []model.CourseStudent
+	if err := model.DB.Joins("JOIN course ON course.course_id = course_student.course_id AND course.deleted_at IS NULL").
+		Where("course_student.student_id = ?", studentID).Order("course_student.course_id").
+		Find(&pairs).Error; err != nil {
+		return nil, err
+	}
+	return attendanceSummaries(pairs, now)
+}
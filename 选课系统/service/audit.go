@@ -0,0 +1,271 @@
+package service
+
+import (
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Audit struct{}
+
+// 冲突类型
+const (
+	AuditRoomDoubleBooking = "room_double_booking" // 同一教室同时安排了两门课
+	AuditTeacherOverlap    = "teacher_overlap"     // 同一教师同时教两门课
+	AuditStudentOverlap    = "student_overlap"     // 学生选了时间重叠的两门课
+	AuditOverCapacity      = "over_capacity"       // 选课人数超过课程容量
+	AuditBookingCourse     = "booking_course"      // 教室预订与在该教室上课的课程时间重叠
+	AuditBookingOverlap    = "booking_overlap"     // 同一教室的两个预订时间重叠
+)
+
+// AuditBooking 是冲突涉及的教室预订
+type AuditBooking struct {
+	ID    int64
+	Title string
+}
+
+type AuditConflict struct {
+	Kind        string
+	Subject     string         // 冲突涉及的教室、教师或学生
+	CourseIDs   []int64        // 受影响的课程
+	Bookings    []AuditBooking // 受影响的教室预订
+	FirstTime   time.Time      // 第一次冲突的上课时间, 容量超限时为空
+	Occurrences int            // 冲突的上课次数
+	Detail      string
+}
+
+type AuditReport struct {
+	Start     time.Time
+	End       time.Time
+	Conflicts []AuditConflict
+	Summary   map[string]int
+}
+
+// overlapRow 是两门课上课时间重叠的汇总结果
+type overlapRow struct {
+	Subject     string
+	CourseA     int64
+	CourseB     int64
+	FirstTime   time.Time
+	Occurrences int
+}
+
+// ConflictAudit 扫描[start, end)内所有课程和教室预订, 列出教室、教师、学生的时间冲突以及超过容量的课程
+func (a *Audit) ConflictAudit(start time.Time, end time.Time) (*AuditReport, error) {
+	report := &AuditReport{Start: start, End: end, Conflicts: []AuditConflict{}, Summary: make(map[string]int)}
+
+	// 线上上课不占用教室, 单独指定了教室的上课时间按该教室计算
+	rooms, err := auditOverlaps(start, end,
+		"CAST(COALESCE(a.room_id, ca.room_id) AS CHAR)",
+		"JOIN course cb ON cb.course_id = b.course_id AND cb.deleted_at IS NULL AND b.online = false "+
+			"AND COALESCE(b.room_id, cb.room_id) = COALESCE(a.room_id, ca.room_id)",
+		"a.online = false AND COALESCE(a.room_id, ca.room_id) IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	var roomList []model.Room
+	if err := model.DB.Find(&roomList).Error; err != nil {
+		return nil, err
+	}
+	roomLabels := make(map[string]string, len(roomList))
+	for _, room := range roomList {
+		roomLabels[fmt.Sprint(room.ID)] = room.Label()
+	}
+	for _, row := range rooms {
+		report.add(AuditRoomDoubleBooking, roomLabels[row.Subject], row, "教室被两门课程同时占用")
+	}
+
+	teachers, err := auditOverlaps(start, end,
+		"CAST(ta.teacher_id AS CHAR)",
+		"JOIN course cb ON cb.course_id = b.course_id AND cb.deleted_at IS NULL "+
+			"JOIN course_teacher ta ON ta.course_id = a.course_id "+
+			"JOIN course_teacher tb ON tb.course_id = b.course_id AND tb.teacher_id = ta.teacher_id", "")
+	if err != nil {
+		return nil, err
+	}
+	var teacherList []model.Teacher
+	if err := model.DB.Find(&teacherList).Error; err != nil {
+		return nil, err
+	}
+	teacherNames := make(map[string]string, len(teacherList))
+	for _, teacher := range teacherList {
+		teacherNames[fmt.Sprint(teacher.ID)] = teacher.Name
+	}
+	for _, row := range teachers {
+		report.add(AuditTeacherOverlap, teacherNames[row.Subject], row, "教师同时教授两门课程")
+	}
+
+	students, err := auditOverlaps(start, end,
+		"sa.student_id",
+		"JOIN course cb ON cb.course_id = b.course_id AND cb.deleted_at IS NULL "+
+			"JOIN course_student sa ON sa.course_id = a.course_id "+
+			"JOIN course_student sb ON sb.course_id = b.course_id AND sb.student_id = sa.student_id", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range students {
+		report.add(AuditStudentOverlap, row.Subject, row, "学生所选的两门课程时间重叠")
+	}
+
+	if err := report.addBookingConflicts(start, end, roomLabels); err != nil {
+		return nil, err
+	}
+
+	var overfull []struct {
+		CourseID int64
+		Capacity int
+		Enrolled int
+	}
+	if err := model.DB.Table("course").
+		Select("course.course_id, course.capacity, COUNT(*) AS enrolled").
+		Joins("JOIN course_student ON course_student.course_id = course.course_id").
+		Where("course.deleted_at IS NULL AND EXISTS (SELECT 1 FROM course_time WHERE course_time.course_id = course.course_id AND start_time < ? AND end_time > ?)", end, start).
+		Group("course.course_id, course.capacity").
+		Having("COUNT(*) > course.capacity").
+		Order("course.course_id").
+		Scan(&overfull).Error; err != nil {
+		return nil, err
+	}
+	for _, course := range overfull {
+		report.Conflicts = append(report.Conflicts, AuditConflict{
+			Kind:      AuditOverCapacity,
+			CourseIDs: []int64{course.CourseID},
+			Detail:    fmt.Sprintf("选课人数%d超过容量%d", course.Enrolled, course.Capacity),
+		})
+		report.Summary[AuditOverCapacity]++
+	}
+	return report, nil
+}
+
+// auditOverlaps 查找[start, end)内上课时间重叠的课程对, 按subject和课程对汇总;
+// joins须关联别名为cb的第二门课程, 并通过subject限定冲突的对象
+func auditOverlaps(start time.Time, end time.Time, subject string, joins string, where string) ([]overlapRow, error) {
+	var rows []overlapRow
+	query := model.DB.Table("course_time AS a").
+		Select(subject+" AS subject, a.course_id AS course_a, b.course_id AS course_b, MIN(a.start_time) AS first_time, COUNT(*) AS occurrences").
+		Joins("JOIN course ca ON ca.course_id = a.course_id AND ca.deleted_at IS NULL").
+		Joins("JOIN course_time b ON b.course_id > a.course_id AND b.start_time < a.end_time AND b.end_time > a.start_time").
+		Joins(joins).
+		Where("a.start_time < ? AND a.end_time > ?", end, start)
+	if where != "" {
+		query = query.Where(where)
+	}
+	if err := query.Group(subject + ", a.course_id, b.course_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].FirstTime.Equal(rows[j].FirstTime) {
+			return rows[i].FirstTime.Before(rows[j].FirstTime)
+		}
+		if rows[i].CourseA != rows[j].CourseA {
+			return rows[i].CourseA < rows[j].CourseA
+		}
+		return rows[i].CourseB < rows[j].CourseB
+	})
+	return rows, nil
+}
+
+// bookingOverlapRow 是教室预订与课程或另一个预订时间重叠的汇总结果, Other为课程ID或预订ID
+type bookingOverlapRow struct {
+	RoomID      int64
+	BookingID   int64
+	Other       int64
+	FirstTime   time.Time
+	Occurrences int
+}
+
+// addBookingConflicts 检查[start, end)内的教室预订是否与线下课程或同一教室的其他预订冲突
+func (r *AuditReport) addBookingConflicts(start time.Time, end time.Time, roomLabels map[string]string) error {
+	var withCourses []bookingOverlapRow
+	if err := bookingOccupancy(model.DB, start, end).
+		Select("room_booking_time.room_id, room_booking_time.booking_id, a.course_id AS other, " +
+			"MIN(GREATEST(a.start_time, room_booking_time.start_time)) AS first_time, COUNT(*) AS occurrences").
+		Joins("JOIN course_time a ON a.online = false AND a.start_time < room_booking_time.end_time AND a.end_time > room_booking_time.start_time").
+		Joins("JOIN course ca ON ca.course_id = a.course_id AND ca.deleted_at IS NULL AND COALESCE(a.room_id, ca.room_id) = room_booking_time.room_id").
+		Group("room_booking_time.room_id, room_booking_time.booking_id, a.course_id").
+		Scan(&withCourses).Error; err != nil {
+		return err
+	}
+	var withBookings []bookingOverlapRow
+	if err := bookingOccupancy(model.DB, start, end).
+		Select("room_booking_time.room_id, room_booking_time.booking_id, other.booking_id AS other, " +
+			"MIN(GREATEST(other.start_time, room_booking_time.start_time)) AS first_time, COUNT(*) AS occurrences").
+		Joins("JOIN room_booking_time other ON other.booking_id > room_booking_time.booking_id AND other.room_id = room_booking_time.room_id " +
+			"AND other.start_time < room_booking_time.end_time AND other.end_time > room_booking_time.start_time").
+		Joins("JOIN room_booking other_booking ON other_booking.id = other.booking_id AND other_booking.deleted_at IS NULL").
+		Group("room_booking_time.room_id, room_booking_time.booking_id, other.booking_id").
+		Scan(&withBookings).Error; err != nil {
+		return err
+	}
+	if len(withCourses) == 0 && len(withBookings) == 0 {
+		return nil
+	}
+
+	var bookingIDs []int64
+	for _, row := range withCourses {
+		bookingIDs = append(bookingIDs, row.BookingID)
+	}
+	for _, row := range withBookings {
+		bookingIDs = append(bookingIDs, row.BookingID, row.Other)
+	}
+	var bookings []model.RoomBooking
+	if err := model.DB.Unscoped().Where("id IN ?", bookingIDs).Find(&bookings).Error; err != nil {
+		return err
+	}
+	titles := make(map[int64]string, len(bookings))
+	for _, booking := range bookings {
+		titles[booking.ID] = booking.Title
+	}
+
+	for _, rows := range [][]bookingOverlapRow{withCourses, withBookings} {
+		sort.Slice(rows, func(i, j int) bool {
+			if !rows[i].FirstTime.Equal(rows[j].FirstTime) {
+				return rows[i].FirstTime.Before(rows[j].FirstTime)
+			}
+			if rows[i].BookingID != rows[j].BookingID {
+				return rows[i].BookingID < rows[j].BookingID
+			}
+			return rows[i].Other < rows[j].Other
+		})
+	}
+	for _, row := range withCourses {
+		r.Conflicts = append(r.Conflicts, AuditConflict{
+			Kind:        AuditBookingCourse,
+			Subject:     roomLabels[fmt.Sprint(row.RoomID)],
+			CourseIDs:   []int64{row.Other},
+			Bookings:    []AuditBooking{{ID: row.BookingID, Title: titles[row.BookingID]}},
+			FirstTime:   row.FirstTime,
+			Occurrences: row.Occurrences,
+			Detail:      "教室预订与在该教室上课的课程时间重叠",
+		})
+		r.Summary[AuditBookingCourse]++
+	}
+	for _, row := range withBookings {
+		r.Conflicts = append(r.Conflicts, AuditConflict{
+			Kind:    AuditBookingOverlap,
+			Subject: roomLabels[fmt.Sprint(row.RoomID)],
+			Bookings: []AuditBooking{
+				{ID: row.BookingID, Title: titles[row.BookingID]},
+				{ID: row.Other, Title: titles[row.Other]},
+			},
+			FirstTime:   row.FirstTime,
+			Occurrences: row.Occurrences,
+			Detail:      "同一教室的两个预订时间重叠",
+		})
+		r.Summary[AuditBookingOverlap]++
+	}
+	return nil
+}
+
+func (r *AuditReport) add(kind string, subject string, row overlapRow, detail string) {
+	r.Conflicts = append(r.Conflicts, AuditConflict{
+		Kind:        kind,
+		Subject:     subject,
+		CourseIDs:   []int64{row.CourseA, row.CourseB},
+		FirstTime:   row.FirstTime,
+		Occurrences: row.Occurrences,
+		Detail:      detail,
+	})
+	r.Summary[kind]++
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var weekdayNames = [...]string{"", "周一", "周二", "周三", "周四", "周五", "周六", "周日"}
+
+// isoWeekday 返回1-7表示周一至周日
+func isoWeekday(t time.Time) int {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		return 7
+	}
+	return weekday
+}
+
+// AddTeacherAvailability 为教师添加不能上课或希望上课的时间段
+func (t *TeacherService) AddTeacherAvailability(block *model.TeacherAvailability) error {
+	if block.Kind != model.AvailabilityUnavailable && block.Kind != model.AvailabilityPreferred {
+		return errors.New("无效的时间段类型")
+	}
+	if block.Weekday < 1 || block.Weekday > 7 {
+		return errors.New("无效的星期")
+	}
+	startClock, err := common.ParseClock(block.StartClock)
+	if err != nil {
+		return err
+	}
+	endClock, err := common.ParseClock(block.EndClock)
+	if err != nil {
+		return err
+	}
+	if endClock <= startClock {
+		return errors.New("结束时刻必须晚于开始时刻")
+	}
+	if block.ToDate.Before(block.FromDate) {
+		return errors.New("结束日期不能早于开始日期")
+	}
+	teacher, err := t.FindTeacherByID(block.TeacherID)
+	if err != nil {
+		return err
+	}
+	if teacher == nil {
+		return errors.New("教师不存在")
+	}
+	return model.DB.Create(block).Error
+}
+
+// GetTeacherAvailability 获取教师的时间段, from和to不为零时只返回与该日期范围重叠的时间段
+func (t *TeacherService) GetTeacherAvailability(teacherID int64, from time.Time, to time.Time) ([]model.TeacherAvailability, error) {
+	var blocks []model.TeacherAvailability
+	query := model.DB.Where("teacher_id = ?", teacherID)
+	if !from.IsZero() && !to.IsZero() {
+		query = query.Where("from_date <= ? AND to_date >= ?", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	if err := query.Order("from_date, weekday, start_clock").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// DeleteTeacherAvailability 删除教师的一个时间段
+func (t *TeacherService) DeleteTeacherAvailability(teacherID int64, blockID int64) error {
+	result := model.DB.Where("teacher_id = ?", teacherID).Delete(&model.TeacherAvailability{}, blockID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("时间段不存在")
+	}
+	return nil
+}
+
+// loadTeacherAvailability 读取教师在[from, to]日期内生效的时间段
+func loadTeacherAvailability(db *gorm.DB, teacherIDs []int64, from time.Time, to time.Time) (map[int64][]model.TeacherAvailability, error) {
+	byTeacher := make(map[int64][]model.TeacherAvailability)
+	if len(teacherIDs) == 0 {
+		return byTeacher, nil
+	}
+	var blocks []model.TeacherAvailability
+	if err := db.Where("teacher_id IN ? AND from_date <= ? AND to_date >= ?",
+		teacherIDs, to.Format("2006-01-02"), from.Format("2006-01-02")).
+		Order("id").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		byTeacher[block.TeacherID] = append(byTeacher[block.TeacherID], block)
+	}
+	return byTeacher, nil
+}
+
+// availabilityInRange 判断date是否在时间段的生效日期范围内
+func availabilityInRange(block model.TeacherAvailability, date time.Time) bool {
+	day := date.Format("2006-01-02")
+	return day >= block.FromDate.Format("2006-01-02") && day <= block.ToDate.Format("2006-01-02")
+}
+
+// availabilityClocks 返回时间段在date这一天的起止时间
+func availabilityClocks(block model.TeacherAvailability, date time.Time) (time.Time, time.Time) {
+	startClock, _ := common.ParseClock(block.StartClock)
+	endClock, _ := common.ParseClock(block.EndClock)
+	day := dateOf(date)
+	return day.Add(startClock), day.Add(endClock)
+}
+
+// checkTeacherAvailability 检查课程时间是否落在教师不能上课的时间段内, 是则返回错误;
+// 教师设置了希望上课的时间段时, 落在这些时间段之外的课程时间作为提醒返回
+func checkTeacherAvailability(db *gorm.DB, teacherIDs []int64, times []model.CourseTime) ([]string, error) {
+	if len(times) == 0 || len(teacherIDs) == 0 {
+		return nil, nil
+	}
+	from, to := times[0].StartTime, times[0].EndTime
+	for _, courseTime := range times {
+		if courseTime.StartTime.Before(from) {
+			from = courseTime.StartTime
+		}
+		if courseTime.EndTime.After(to) {
+			to = courseTime.EndTime
+		}
+	}
+	availability, err := loadTeacherAvailability(db, teacherIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var teachers []model.Teacher
+	if err := db.Where("id IN ?", teacherIDs).Find(&teachers).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(teachers))
+	for _, teacher := range teachers {
+		names[teacher.ID] = teacher.Name
+	}
+	warned := make(map[string]bool)
+	var warnings []string
+	for _, teacherID := range teacherIDs {
+		blocks := availability[teacherID]
+		for _, courseTime := range times {
+			date := dateOf(courseTime.StartTime)
+			hasPreferred, preferred := false, false
+			for _, block := range blocks {
+				if !availabilityInRange(block, date) {
+					continue
+				}
+				// 教师在这段日期内设置过希望上课的时间段, 即使不是同一天也需要检查
+				if block.Kind == model.AvailabilityPreferred {
+					hasPreferred = true
+				}
+				if block.Weekday != isoWeekday(date) {
+					continue
+				}
+				start, end := availabilityClocks(block, date)
+				switch block.Kind {
+				case model.AvailabilityUnavailable:
+					if courseTime.StartTime.Before(end) && courseTime.EndTime.After(start) {
+						return nil, fmt.Errorf("教师%s在%s %s-%s不能上课, 与课程时间%s冲突",
+							names[teacherID], weekdayNames[block.Weekday], block.StartClock, block.EndClock,
+							courseTime.StartTime.Format("2006-01-02 15:04"))
+					}
+				case model.AvailabilityPreferred:
+					if !courseTime.StartTime.Before(start) && !courseTime.EndTime.After(end) {
+						preferred = true
+					}
+				}
+			}
+			if !hasPreferred || preferred {
+				continue
+			}
+			// 每周重复的课程只提醒一次
+			key := fmt.Sprintf("%d %d %s %s", teacherID, isoWeekday(date),
+				courseTime.StartTime.Format("15:04"), courseTime.EndTime.Format("15:04"))
+			if warned[key] {
+				continue
+			}
+			warned[key] = true
+			warnings = append(warnings, fmt.Sprintf("教师%s希望在设定的时间段上课, %s %s-%s不在其中",
+				names[teacherID], weekdayNames[isoWeekday(date)],
+				courseTime.StartTime.Format("15:04"), courseTime.EndTime.Format("15:04")))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings, nil
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"finaltenzor/config"
+	"finaltenzor/model"
+	"finaltenzor/service/ics"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+type Calendar struct{}
+
+type CalendarChange struct {
+	Action string
+	Reason string
+	Event  model.CalendarEvent
+}
+
+type CalendarImportResult struct {
+	Committed bool
+	Created   int
+	Updated   int
+	Unchanged int
+	Skipped   int
+	Deleted   int
+	Changes   []CalendarChange
+}
+
+// 校历导入结果中每个事件的处理方式
+const (
+	calendarCreate    = "create"
+	calendarUpdate    = "update"
+	calendarUnchanged = "unchanged"
+	calendarSkip      = "skip"
+	calendarDelete    = "delete"
+)
+
+// 学期事件至少要跨越一周, 避免把"开学典礼"之类的单日事件当成学期
+const minTermDays = 7
+
+// ImportCalendar 导入ICS校历, commit为false时只预览变更不写入数据库;
+// 文件中有效事件覆盖的日期范围内已有但文件中不再出现的事件会被删除, 范围之外的事件保持不变
+func (cal *Calendar) ImportCalendar(file io.Reader, commit bool) (*CalendarImportResult, error) {
+	events, err := ics.Parse(file, config.Config.Location)
+	if err != nil {
+		return nil, err
+	}
+	result := &CalendarImportResult{Committed: commit}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	seen := make(map[string]bool)
+	var rangeStart, rangeEnd time.Time
+	for _, event := range events {
+		calendarEvent, reason := toCalendarEvent(event)
+		if reason != "" {
+			result.Skipped++
+			result.Changes = append(result.Changes, CalendarChange{Action: calendarSkip, Reason: reason, Event: calendarEvent})
+			continue
+		}
+		if seen[calendarEvent.UID] {
+			result.Skipped++
+			result.Changes = append(result.Changes, CalendarChange{Action: calendarSkip, Reason: "文件中存在重复的UID", Event: calendarEvent})
+			continue
+		}
+		seen[calendarEvent.UID] = true
+		// 只按接受的事件计算校历范围, 跳过的事件日期可能无效, 不能据此删除范围内的其他事件
+		if rangeStart.IsZero() || calendarEvent.StartDate.Before(rangeStart) {
+			rangeStart = calendarEvent.StartDate
+		}
+		if calendarEvent.EndDate.After(rangeEnd) {
+			rangeEnd = calendarEvent.EndDate
+		}
+		var existing model.CalendarEvent
+		err := tx.Where("uid = ?", calendarEvent.UID).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			result.Created++
+			result.Changes = append(result.Changes, CalendarChange{Action: calendarCreate, Event: calendarEvent})
+			if commit {
+				if err := tx.Create(&calendarEvent).Error; err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+			}
+		case err != nil:
+			tx.Rollback()
+			return nil, err
+		case sameCalendarEvent(existing, calendarEvent):
+			result.Unchanged++
+			result.Changes = append(result.Changes, CalendarChange{Action: calendarUnchanged, Event: existing})
+		default:
+			calendarEvent.BaseModel = existing.BaseModel
+			result.Updated++
+			result.Changes = append(result.Changes, CalendarChange{
+				Action: calendarUpdate,
+				Reason: fmt.Sprintf("原为 %s %s ~ %s", existing.Name, existing.StartDate.Format("2006-01-02"), existing.EndDate.Format("2006-01-02")),
+				Event:  calendarEvent,
+			})
+			if commit {
+				if err := tx.Save(&calendarEvent).Error; err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+			}
+		}
+	}
+	if len(seen) > 0 {
+		uids := make([]string, 0, len(seen))
+		for uid := range seen {
+			uids = append(uids, uid)
+		}
+		query := tx.Where("start_date <= ? AND end_date >= ?", rangeEnd.Format("2006-01-02"), rangeStart.Format("2006-01-02")).
+			Where("uid NOT IN ?", uids)
+		var removed []model.CalendarEvent
+		if err := query.Order("start_date").Find(&removed).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		for _, event := range removed {
+			result.Deleted++
+			result.Changes = append(result.Changes, CalendarChange{Action: calendarDelete, Reason: "新校历中已没有该事件", Event: event})
+			if commit {
+				// UID有唯一索引, 直接删除以便之后重新导入同一事件
+				if err := tx.Unscoped().Delete(&event).Error; err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+			}
+		}
+	}
+	if !commit {
+		tx.Rollback()
+		return result, nil
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetCalendarEvents 获取与[start, end]日期范围有交集的校历事件, kind为空时返回全部类型
+func (cal *Calendar) GetCalendarEvents(start time.Time, end time.Time, kind string) ([]model.CalendarEvent, error) {
+	var events []model.CalendarEvent
+	query := model.DB.Where("start_date <= ? AND end_date >= ?", end.Format("2006-01-02"), start.Format("2006-01-02"))
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if err := query.Order("start_date").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// toCalendarEvent 把ICS事件转换为校历事件, 无法识别时返回跳过原因
+func toCalendarEvent(event ics.Event) (model.CalendarEvent, string) {
+	start := dateOf(event.Start)
+	end := dateOf(event.End)
+	// DTEND不包含在事件内, 全天事件或恰好在零点结束的事件要往前退一天
+	if end.After(start) && event.End.Equal(end) {
+		end = end.AddDate(0, 0, -1)
+	}
+	if end.Before(start) {
+		end = start
+	}
+	calendarEvent := model.CalendarEvent{
+		UID:       event.UID,
+		Kind:      classifyEvent(event),
+		Name:      event.Summary,
+		StartDate: start,
+		EndDate:   end,
+	}
+	if calendarEvent.UID == "" {
+		sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s", event.Summary, start.Format("20060102"), end.Format("20060102"))))
+		calendarEvent.UID = hex.EncodeToString(sum[:])
+	}
+	switch {
+	case calendarEvent.Kind == "":
+		return calendarEvent, "无法识别的事件类型"
+	case calendarEvent.Kind == model.CalendarTerm && end.Sub(start).Hours()/24+1 < minTermDays:
+		return calendarEvent, "学期事件跨度过短"
+	}
+	return calendarEvent, ""
+}
+
+// classifyEvent 根据CATEGORIES或标题关键字判断事件类型
+func classifyEvent(event ics.Event) string {
+	for _, category := range event.Categories {
+		switch strings.ToUpper(category) {
+		case "TERM", "SEMESTER", "学期":
+			return model.CalendarTerm
+		case "HOLIDAY", "VACATION", "假期", "放假":
+			return model.CalendarHoliday
+		case "MAKEUP", "MAKE-UP", "WORKDAY", "补课", "调休":
+			return model.CalendarMakeUp
+		}
+	}
+	// 先判断假期, "学期中的假期"应按假期处理; 英文关键字按整词匹配, 避免"midterm"被当成学期
+	summary := strings.ToLower(event.Summary)
+	switch {
+	case containsAny(summary, "补课", "补班", "上班") || containsWord(summary, "make-up", "makeup", "working day"):
+		return model.CalendarMakeUp
+	case containsAny(summary, "假") || containsWord(summary, "holiday", "holidays", "vacation", "break"):
+		return model.CalendarHoliday
+	case containsAny(summary, "学期") || containsWord(summary, "semester", "term"):
+		return model.CalendarTerm
+	}
+	return ""
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord 判断s中是否含有整词words之一, word可以是用空格分隔的多个词
+func containsWord(s string, words ...string) bool {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	normalized := " " + strings.Join(fields, " ") + " "
+	for _, word := range words {
+		if strings.Contains(normalized, " "+word+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func sameCalendarEvent(a model.CalendarEvent, b model.CalendarEvent) bool {
+	return a.Kind == b.Kind && a.Name == b.Name &&
+		a.StartDate.Format("2006-01-02") == b.StartDate.Format("2006-01-02") &&
+		a.EndDate.Format("2006-01-02") == b.EndDate.Format("2006-01-02")
+}
+
+// calendarDay 是校历中某一天的状态
+type calendarDay struct {
+	InTerm  bool
+	Holiday string
+	MakeUp  bool
+}
+
+// calendarView 缓存一段时间内的校历事件, 用于逐日判断是否放假
+type calendarView struct {
+	events   []model.CalendarEvent
+	hasTerms bool
+}
+
+// loadCalendar 加载与[start, end)有交集的校历事件
+func loadCalendar(db *gorm.DB, start time.Time, end time.Time) (*calendarView, error) {
+	view := &calendarView{}
+	if err := db.Where("start_date < ? AND end_date >= ?", end.Format("2006-01-02"), start.Format("2006-01-02")).
+		Find(&view.events).Error; err != nil {
+		return nil, err
+	}
+	var termCount int64
+	if err := db.Model(&model.CalendarEvent{}).Where("kind = ?", model.CalendarTerm).Count(&termCount).Error; err != nil {
+		return nil, err
+	}
+	view.hasTerms = termCount > 0
+	return view, nil
+}
+
+func (v *calendarView) day(t time.Time) calendarDay {
+	date := t.Format("2006-01-02")
+	day := calendarDay{InTerm: !v.hasTerms}
+	for _, event := range v.events {
+		if date < event.StartDate.Format("2006-01-02") || date > event.EndDate.Format("2006-01-02") {
+			continue
+		}
+		switch event.Kind {
+		case model.CalendarTerm:
+			day.InTerm = true
+		case model.CalendarHoliday:
+			day.Holiday = event.Name
+		case model.CalendarMakeUp:
+			day.MakeUp = true
+		}
+	}
+	return day
+}
+
+// checkCourseCalendar 检查课程时间是否落在学期内且不在假期中, 调休补课日视为上课日
+func checkCourseCalendar(db *gorm.DB, times []model.CourseTime) error {
+	if len(times) == 0 {
+		return nil
+	}
+	start, end := times[0].StartTime, times[0].EndTime
+	for _, courseTime := range times {
+		if courseTime.StartTime.Before(start) {
+			start = courseTime.StartTime
+		}
+		if courseTime.EndTime.After(end) {
+			end = courseTime.EndTime
+		}
+	}
+	view, err := loadCalendar(db, dateOf(start), dateOf(end).AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	for _, courseTime := range times {
+		day := view.day(courseTime.StartTime)
+		if !day.InTerm {
+			return fmt.Errorf("课程时间 %s 不在任何学期范围内", courseTime.StartTime.Format("2006-01-02"))
+		}
+		if day.Holiday != "" && !day.MakeUp {
+			return fmt.Errorf("课程时间 %s 与校历假期冲突: %s", courseTime.StartTime.Format("2006-01-02"), day.Holiday)
+		}
+	}
+	return nil
+}
+
+// termStartFor 返回date所在学期的开始日期, 校历中没有对应学期时退回到APP_TERM_START配置
+func termStartFor(date time.Time) (time.Time, error) {
+	var term model.CalendarEvent
+	day := date.Format("2006-01-02")
+	err := model.DB.Where("kind = ? AND start_date <= ? AND end_date >= ?", model.CalendarTerm, day, day).
+		Order("start_date DESC").First(&term).Error
+	if err == nil {
+		return dateOf(term.StartDate.In(config.Config.Location)), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, err
+	}
+	// 假期中按下一个学期计算周次
+	err = model.DB.Where("kind = ? AND start_date > ?", model.CalendarTerm, day).
+		Order("start_date").First(&term).Error
+	if err == nil {
+		return dateOf(term.StartDate.In(config.Config.Location)), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, err
+	}
+	if config.Config.TermStart == "" {
+		return time.Time{}, errors.New("未配置学期开始日期")
+	}
+	termStart, err := time.ParseInLocation("2006-01-02", config.Config.TermStart, config.Config.Location)
+	if err != nil {
+		return time.Time{}, errors.New("学期开始日期配置错误")
+	}
+	return termStart, nil
+}
+
+// TermRange 返回包含date的学期的日期范围[start, end), 不在学期中时返回下一个学期
+func (cal *Calendar) TermRange(date time.Time) (time.Time, time.Time, error) {
+	var term model.CalendarEvent
+	day := date.Format("2006-01-02")
+	err := model.DB.Where("kind = ? AND end_date >= ?", model.CalendarTerm, day).
+		Order("start_date").First(&term).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, time.Time{}, errors.New("校历中没有当前学期, 请指定日期范围")
+		}
+		return time.Time{}, time.Time{}, err
+	}
+	start := dateOf(term.StartDate.In(config.Config.Location))
+	end := dateOf(term.EndDate.In(config.Config.Location)).AddDate(0, 0, 1)
+	return start, end, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	seatAlertFanout   = 3                // 每个空余名额最多提醒的关注者数, 避免所有人同时抢一个名额
+	seatAlertCooldown = 10 * time.Minute // 同一关注者两次提醒的最小间隔
+	maxCourseWatches  = 20               // 每名学生最多关注的课程数
+)
+
+// WatchCourse 关注课程, 课程有空余名额时会收到提醒
+func (us *User) WatchCourse(studentID string, courseID int64) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var course model.Course
+	if err := tx.Where("course_id = ?", courseID).First(&course).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("课程未找到")
+		}
+		return err
+	}
+	var count int64
+	if err := tx.Model(&model.CourseStudent{}).Where("student_id = ? AND course_id = ?", studentID, courseID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("你已经选了这门课程")
+	}
+	if err := tx.Model(&model.CourseWatch{}).Where("student_id = ? AND course_id = ?", studentID, courseID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("你已经关注了这门课程")
+	}
+	if err := tx.Model(&model.CourseWatch{}).Where("student_id = ?", studentID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count >= maxCourseWatches {
+		tx.Rollback()
+		return fmt.Errorf("最多只能关注%d门课程", maxCourseWatches)
+	}
+	if err := tx.Create(&model.CourseWatch{StudentID: studentID, CourseID: courseID}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// UnwatchCourse 取消关注课程, 直接删除以便之后重新关注
+func (us *User) UnwatchCourse(studentID string, courseID int64) error {
+	result := model.DB.Unscoped().Where("student_id = ? AND course_id = ?", studentID, courseID).Delete(&model.CourseWatch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("你没有关注这门课程")
+	}
+	return nil
+}
+
+// WatchedCourse 关注列表中的一门课程及其当前人数
+type WatchedCourse struct {
+	Watch    model.CourseWatch
+	Course   model.Course
+	Enrolled int
+}
+
+// GetCourseWatches 获取学生的关注列表, 已删除的课程不再显示
+func (us *User) GetCourseWatches(studentID string) ([]WatchedCourse, error) {
+	var watches []model.CourseWatch
+	if err := model.DB.Where("student_id = ?", studentID).Order("id").Find(&watches).Error; err != nil {
+		return nil, err
+	}
+	courseIDs := make([]int64, 0, len(watches))
+	for _, watch := range watches {
+		courseIDs = append(courseIDs, watch.CourseID)
+	}
+	watched := []WatchedCourse{}
+	if len(courseIDs) == 0 {
+		return watched, nil
+	}
+	var courses []model.Course
+	if err := model.DB.Where("course_id IN ?", courseIDs).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	courseMap := make(map[int64]model.Course, len(courses))
+	for _, course := range courses {
+		courseMap[course.CourseID] = course
+	}
+	counts, err := enrollmentCounts(model.DB, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, watch := range watches {
+		course, ok := courseMap[watch.CourseID]
+		if !ok {
+			continue
+		}
+		watched = append(watched, WatchedCourse{Watch: watch, Course: course, Enrolled: counts[watch.CourseID]})
+	}
+	return watched, nil
+}
+
+// notifyWatchers 课程有空余名额时提醒关注者, 应在退课或扩容的事务中调用
+// 每个名额最多提醒seatAlertFanout人, 从未提醒过的和最久没有提醒的关注者优先, 使提醒在关注者之间轮换;
+// 最近已提醒过的关注者在冷却时间内不再提醒
+func notifyWatchers(tx *gorm.DB, courseID int64) error {
+	var course model.Course
+	if err := tx.Where("course_id = ?", courseID).First(&course).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	var enrolled int64
+	if err := tx.Model(&model.CourseStudent{}).Where("course_id = ?", courseID).Count(&enrolled).Error; err != nil {
+		return err
+	}
+	free := course.Capacity - int(enrolled)
+	if free <= 0 {
+		return nil
+	}
+	now := time.Now()
+	var watches []model.CourseWatch
+	if err := tx.Where("course_id = ? AND (notified_at IS NULL OR notified_at < ?)", courseID, now.Add(-seatAlertCooldown)).
+		Where("student_id NOT IN (?)", tx.Model(&model.CourseStudent{}).Select("student_id").Where("course_id = ?", courseID)).
+		Order("notified_at IS NOT NULL, notified_at, id").Limit(free * seatAlertFanout).
+		Find(&watches).Error; err != nil {
+		return err
+	}
+	for _, watch := range watches {
+		notice := model.Notice{
+			StudentID: watch.StudentID,
+			Kind:      model.NoticeSeatAvailable,
+			CourseID:  courseID,
+			Message:   fmt.Sprintf("你关注的课程《%s》现在有%d个空余名额", course.CourseName, free),
+		}
+		if err := tx.Create(&notice).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.CourseWatch{}).Where("id = ?", watch.ID).Update("notified_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSeatAlerts 获取学生的空余名额提醒中ID大于afterID的部分, 最早的在前, 客户端以最后一条的ID作为下次的afterID
+func (n *Notice) GetSeatAlerts(studentID string, afterID int64) ([]model.Notice, error) {
+	var notices []model.Notice
+	if err := model.DB.Where("student_id = ? AND kind = ? AND id > ?", studentID, model.NoticeSeatAvailable, afterID).
+		Order("id").Limit(50).Find(&notices).Error; err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
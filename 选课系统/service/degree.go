@@ -0,0 +1,433 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type Degree struct{}
+
+// 毕业要求的类型
+const (
+	RequirementCourses = "courses" // 必修课程, 须全部修完
+	RequirementChoose  = "choose"  // 从一组课程中选修N门
+	RequirementCredits = "credits" // 某类别的课程须修满的学分
+)
+
+// 毕业要求的完成情况
+const (
+	AuditSatisfied  = "satisfied"   // 已完成
+	AuditInProgress = "in_progress" // 在读课程结课及格后可完成
+	AuditMissing    = "missing"     // 尚有缺口
+)
+
+const degreeSuggestionLimit = 10 // 每项学分要求最多推荐的课程数
+
+// Requirement 培养方案中的一项毕业要求, 必修和选修组按课程代码指定, 同一门课任何一次开课都可以满足要求
+type Requirement struct {
+	Kind        string   `json:"kind"`
+	Title       string   `json:"title"`
+	CourseCodes []string `json:"courseCodes,omitempty"`
+	Count       int      `json:"count,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	MinCredits  float64  `json:"minCredits,omitempty"`
+}
+
+// validateRequirements 检查毕业要求, 引用的课程代码必须有开过课(包括已删除的历史课程)
+func validateRequirements(tx *gorm.DB, requirements []Requirement) error {
+	if len(requirements) == 0 {
+		return errors.New("请设置毕业要求")
+	}
+	courseCodes := make(map[string]bool)
+	for i := range requirements {
+		requirement := &requirements[i]
+		requirement.Title = strings.TrimSpace(requirement.Title)
+		requirement.Category = strings.TrimSpace(requirement.Category)
+		if requirement.Title == "" {
+			return fmt.Errorf("第%d项要求的名称不能为空", i+1)
+		}
+		switch requirement.Kind {
+		case RequirementCourses, RequirementChoose:
+			if len(requirement.CourseCodes) == 0 {
+				return fmt.Errorf("%s: 请指定课程", requirement.Title)
+			}
+			seen := make(map[string]bool, len(requirement.CourseCodes))
+			for j, code := range requirement.CourseCodes {
+				code = normalizeCourseCode(code)
+				if code == "" {
+					return fmt.Errorf("%s: 课程代码不能为空", requirement.Title)
+				}
+				if seen[code] {
+					return fmt.Errorf("%s: 课程%s重复", requirement.Title, code)
+				}
+				seen[code] = true
+				courseCodes[code] = true
+				requirement.CourseCodes[j] = code
+			}
+			if requirement.Kind == RequirementChoose && (requirement.Count < 1 || requirement.Count > len(requirement.CourseCodes)) {
+				return fmt.Errorf("%s: 选修门数必须在1到%d之间", requirement.Title, len(requirement.CourseCodes))
+			}
+			if requirement.Kind == RequirementCourses {
+				requirement.Count = 0
+			}
+			requirement.Category = ""
+			requirement.MinCredits = 0
+		case RequirementCredits:
+			if requirement.Category == "" {
+				return fmt.Errorf("%s: 请指定课程类别", requirement.Title)
+			}
+			if requirement.MinCredits <= 0 {
+				return fmt.Errorf("%s: 最低学分必须大于0", requirement.Title)
+			}
+			requirement.CourseCodes = nil
+			requirement.Count = 0
+		default:
+			return fmt.Errorf("第%d项要求的类型无效", i+1)
+		}
+	}
+	codes := make([]string, 0, len(courseCodes))
+	for code := range courseCodes {
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	var found []string
+	if err := tx.Unscoped().Model(&model.Course{}).Where("course_code IN ?", codes).
+		Distinct().Pluck("course_code", &found).Error; err != nil {
+		return err
+	}
+	for _, code := range found {
+		delete(courseCodes, strings.ToUpper(code))
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if courseCodes[code] {
+			return fmt.Errorf("课程代码%s不存在", code)
+		}
+	}
+	return nil
+}
+
+// DegreeRequirements 解析培养方案的毕业要求
+func DegreeRequirements(program model.DegreeProgram) ([]Requirement, error) {
+	var requirements []Requirement
+	if err := json.Unmarshal(program.Requirements, &requirements); err != nil {
+		return nil, err
+	}
+	return requirements, nil
+}
+
+// SaveDegreeProgram 添加或更新培养方案, 每个专业每一届只能有一个培养方案
+func (d *Degree) SaveDegreeProgram(program *model.DegreeProgram, requirements []Requirement) error {
+	program.Major = strings.TrimSpace(program.Major)
+	if program.Major == "" {
+		return errors.New("专业不能为空")
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := validateRequirements(tx, requirements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	requirementsJSON, err := json.Marshal(requirements)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	program.Requirements = requirementsJSON
+	var count int64
+	if err := tx.Model(&model.DegreeProgram{}).
+		Where("major = ? AND entry_year = ? AND id != ?", program.Major, program.EntryYear, program.ID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("该专业该届的培养方案已存在")
+	}
+	if program.ID > 0 {
+		var existing model.DegreeProgram
+		if err := tx.First(&existing, program.ID).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("培养方案不存在")
+			}
+			return err
+		}
+		program.BaseModel = existing.BaseModel
+	}
+	if err := tx.Save(program).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetDegreePrograms 获取培养方案, major不为空时只返回该专业的
+func (d *Degree) GetDegreePrograms(major string) ([]model.DegreeProgram, error) {
+	var programs []model.DegreeProgram
+	query := model.DB.Order("major, entry_year DESC")
+	if major != "" {
+		query = query.Where("major = ?", major)
+	}
+	if err := query.Find(&programs).Error; err != nil {
+		return nil, err
+	}
+	return programs, nil
+}
+
+// DeleteDegreeProgram 删除培养方案, 直接删除以便重新创建同专业同届的方案
+func (d *Degree) DeleteDegreeProgram(programID int64) error {
+	result := model.DB.Unscoped().Delete(&model.DegreeProgram{}, programID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("培养方案不存在")
+	}
+	return nil
+}
+
+// RequirementAudit 一项毕业要求的审核结果
+// Missing为必修或选修组中尚未修读的课程(取该代码最近一次开课), Suggestions为当前仍有余量、可以用来弥补缺口的开课
+type RequirementAudit struct {
+	Requirement    Requirement
+	Status         string
+	Completed      []model.Course
+	InProgress     []model.Course
+	Missing        []model.Course
+	Credits        float64
+	PlannedCredits float64
+	Suggestions    []model.Course
+}
+
+// DegreeAudit 学生对照培养方案的毕业要求审核结果
+type DegreeAudit struct {
+	Program      model.DegreeProgram
+	Status       string
+	Requirements []RequirementAudit
+}
+
+// openCourses 返回学生可以选的课程: 未删除、仍有余量、学生未修过也未在读
+func openCourses(db *gorm.DB, taken map[int64]bool) (map[int64]model.Course, error) {
+	var courses []model.Course
+	if err := db.Preload("CourseTimes").Order("course_id").Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	courseIDs := make([]int64, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.CourseID)
+	}
+	counts, err := enrollmentCounts(db, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	open := make(map[int64]model.Course)
+	for _, course := range courses {
+		if !taken[course.CourseID] && counts[course.CourseID] < course.Capacity {
+			open[course.CourseID] = course
+		}
+	}
+	return open, nil
+}
+
+// auditStatus 按已完成和计入在读课程后是否满足要求确定状态
+func auditStatus(done bool, planned bool) string {
+	switch {
+	case done:
+		return AuditSatisfied
+	case planned:
+		return AuditInProgress
+	default:
+		return AuditMissing
+	}
+}
+
+// ErrNoDegreeProgram 专业和入学年份没有对应的培养方案
+var ErrNoDegreeProgram = errors.New("该专业该届没有培养方案")
+
+// AuditDegree 对照专业和入学年份对应的培养方案, 检查学生已修(及格)和在读(尚无成绩)的课程
+func (d *Degree) AuditDegree(studentID string, major string, entryYear int) (*DegreeAudit, error) {
+	var program model.DegreeProgram
+	if err := model.DB.Where("major = ? AND entry_year = ?", major, entryYear).First(&program).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoDegreeProgram
+		}
+		return nil, err
+	}
+	requirements, err := DegreeRequirements(program)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := completedCourseIDs(model.DB, studentID)
+	if err != nil {
+		return nil, err
+	}
+	var gradedIDs, enrolledIDs []int64
+	if err := model.DB.Model(&model.Grade{}).Where("student_id = ?", studentID).Pluck("course_id", &gradedIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := model.DB.Model(&model.CourseStudent{}).Where("student_id = ?", studentID).Pluck("course_id", &enrolledIDs).Error; err != nil {
+		return nil, err
+	}
+	graded := make(map[int64]bool, len(gradedIDs))
+	taken := make(map[int64]bool, len(gradedIDs)+len(enrolledIDs))
+	for _, courseID := range gradedIDs {
+		graded[courseID] = true
+		taken[courseID] = true
+	}
+	inProgress := make(map[int64]bool)
+	for _, courseID := range enrolledIDs {
+		taken[courseID] = true
+		if !graded[courseID] {
+			inProgress[courseID] = true
+		}
+	}
+	// 已删除的课程仍按修读记录计入
+	courseIDs := make([]int64, 0, len(taken))
+	for courseID := range taken {
+		courseIDs = append(courseIDs, courseID)
+	}
+	courseMap := make(map[int64]model.Course)
+	if len(courseIDs) > 0 {
+		var courses []model.Course
+		if err := model.DB.Unscoped().Where("course_id IN ?", courseIDs).Find(&courses).Error; err != nil {
+			return nil, err
+		}
+		for _, course := range courses {
+			courseMap[course.CourseID] = course
+		}
+	}
+	// 培养方案引用的课程代码各取最近一次开课, 用于显示尚未修读的课程
+	var requiredCodes []string
+	for _, requirement := range requirements {
+		requiredCodes = append(requiredCodes, requirement.CourseCodes...)
+	}
+	catalog := make(map[string]model.Course)
+	if len(requiredCodes) > 0 {
+		var offerings []model.Course
+		if err := model.DB.Unscoped().Where("course_code IN ?", requiredCodes).Order("course_id").
+			Find(&offerings).Error; err != nil {
+			return nil, err
+		}
+		for _, course := range offerings {
+			catalog[course.CourseCode] = course
+		}
+	}
+	open, err := openCourses(model.DB, taken)
+	if err != nil {
+		return nil, err
+	}
+	// 学生修读过的课程按ID排序, 使结果稳定
+	studentCourseIDs := make([]int64, 0, len(taken))
+	for courseID := range taken {
+		studentCourseIDs = append(studentCourseIDs, courseID)
+	}
+	sort.Slice(studentCourseIDs, func(i, j int) bool { return studentCourseIDs[i] < studentCourseIDs[j] })
+	openIDs := make([]int64, 0, len(open))
+	for courseID := range open {
+		openIDs = append(openIDs, courseID)
+	}
+	sort.Slice(openIDs, func(i, j int) bool { return openIDs[i] < openIDs[j] })
+
+	// 按课程代码汇总修读情况和当前的开课, 重修或换学期修读同一门课都按同一代码计
+	completedCodes := make(map[string]model.Course)
+	inProgressCodes := make(map[string]model.Course)
+	for _, courseID := range studentCourseIDs {
+		course := courseMap[courseID]
+		if course.CourseCode == "" {
+			continue
+		}
+		switch {
+		case completed[courseID]:
+			completedCodes[course.CourseCode] = course
+		case inProgress[courseID]:
+			inProgressCodes[course.CourseCode] = course
+		}
+	}
+	openByCode := make(map[string][]model.Course)
+	for _, courseID := range openIDs {
+		if course := open[courseID]; course.CourseCode != "" {
+			openByCode[course.CourseCode] = append(openByCode[course.CourseCode], course)
+		}
+	}
+
+	audit := &DegreeAudit{Program: program, Status: AuditSatisfied}
+	for _, requirement := range requirements {
+		item := RequirementAudit{
+			Requirement: requirement,
+			Completed:   []model.Course{},
+			InProgress:  []model.Course{},
+			Missing:     []model.Course{},
+			Suggestions: []model.Course{},
+		}
+		switch requirement.Kind {
+		case RequirementCourses, RequirementChoose:
+			for _, code := range requirement.CourseCodes {
+				if course, ok := completedCodes[code]; ok {
+					item.Completed = append(item.Completed, course)
+				} else if course, ok := inProgressCodes[code]; ok {
+					item.InProgress = append(item.InProgress, course)
+				} else {
+					item.Missing = append(item.Missing, catalog[code])
+					item.Suggestions = append(item.Suggestions, openByCode[code]...)
+				}
+			}
+			needed := len(requirement.CourseCodes)
+			if requirement.Kind == RequirementChoose {
+				needed = requirement.Count
+			}
+			item.Status = auditStatus(len(item.Completed) >= needed, len(item.Completed)+len(item.InProgress) >= needed)
+		case RequirementCredits:
+			for _, courseID := range studentCourseIDs {
+				course, ok := courseMap[courseID]
+				if !ok || course.Category != requirement.Category {
+					continue
+				}
+				switch {
+				case completed[courseID]:
+					item.Completed = append(item.Completed, course)
+					item.Credits += course.Credits
+				case inProgress[courseID]:
+					item.InProgress = append(item.InProgress, course)
+				}
+			}
+			item.PlannedCredits = item.Credits
+			for _, course := range item.InProgress {
+				item.PlannedCredits += course.Credits
+			}
+			item.Status = auditStatus(item.Credits >= requirement.MinCredits, item.PlannedCredits >= requirement.MinCredits)
+			for _, courseID := range openIDs {
+				if len(item.Suggestions) >= degreeSuggestionLimit {
+					break
+				}
+				if course := open[courseID]; course.Category == requirement.Category {
+					item.Suggestions = append(item.Suggestions, course)
+				}
+			}
+		}
+		if item.Status != AuditMissing {
+			item.Suggestions = []model.Course{}
+		}
+		switch {
+		case item.Status == AuditMissing:
+			audit.Status = AuditMissing
+		case item.Status == AuditInProgress && audit.Status == AuditSatisfied:
+			audit.Status = AuditInProgress
+		}
+		audit.Requirements = append(audit.Requirements, item)
+	}
+	return audit, nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+
+	"gorm.io/gorm"
+)
+
+// enrollmentCounts 统计每门课程的已选人数
+func enrollmentCounts(db *gorm.DB, courseIDs []int64) (map[int64]int, error) {
+	counts := make(map[int64]int, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		CourseID int64
+		Total    int
+	}
+	if err := db.Model(&model.CourseStudent{}).
+		Select("course_id, COUNT(*) AS total").
+		Where("course_id IN ?", courseIDs).
+		Group("course_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.CourseID] = row.Total
+	}
+	return counts, nil
+}
+
+// studentTimeConflict 检查times是否与学生已选课程的上课时间重叠, excludeCourseIDs中的课程不参与比较
+func studentTimeConflict(db *gorm.DB, studentID string, times []model.CourseTime, excludeCourseIDs ...int64) (bool, error) {
+	var schedule []model.Course
+	query := db.Preload("CourseTimes").
+		Joins("JOIN course_student ON course_student.course_id = course.course_id").
+		Where("course_student.student_id = ?", studentID)
+	if len(excludeCourseIDs) > 0 {
+		query = query.Where("course.course_id NOT IN ?", excludeCourseIDs)
+	}
+	if err := query.Find(&schedule).Error; err != nil {
+		return false, err
+	}
+	for _, existingCourse := range schedule {
+		if timesOverlap(existingCourse.CourseTimes, times) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkGrab 按抢课的规则检查学生能否选入课程: 没有选过、容量未满、与已选课程和picked中的课程时间不冲突;
+// course须已在事务中加锁并预加载上课时间, enrolled为加锁后统计的已选人数
+func checkGrab(tx *gorm.DB, studentID string, course model.Course, enrolled int, picked []model.Course) error {
+	var count int64
+	if err := tx.Model(&model.CourseStudent{}).Where("student_id = ? AND course_id = ?", studentID, course.CourseID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("学生已经抢过该课程")
+	}
+	if enrolled >= course.Capacity {
+		return errors.New("课程容量已满，无法选择该课程")
+	}
+	conflict, err := studentTimeConflict(tx, studentID, course.CourseTimes)
+	if err != nil {
+		return err
+	}
+	for _, other := range picked {
+		if timesOverlap(other.CourseTimes, course.CourseTimes) {
+			conflict = true
+			break
+		}
+	}
+	if conflict {
+		return errors.New("学生课程时间冲突，无法选择该课程")
+	}
+	return nil
+}
+
+// timesOverlap 判断两组上课时间是否有重叠, 与抢课时的判断一致: 首尾相接不算冲突
+func timesOverlap(a []model.CourseTime, b []model.CourseTime) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.StartTime.Before(y.EndTime) && y.StartTime.Before(x.EndTime) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,489 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Evaluation struct{}
+
+const (
+	evaluationMaxScale     = 10   // 打分题的最高分上限
+	evaluationMaxText      = 2000 // 文字题答案的最大字数
+	evaluationMinResponses = 3    // 教师查看结果所需的最少答卷数, 过少时容易推断出填写人
+)
+
+// EvaluationQuestion 问卷中的一道题, 打分题从1分打到Scale分
+type EvaluationQuestion struct {
+	Kind     string `json:"kind"`
+	Title    string `json:"title"`
+	Required bool   `json:"required"`
+	Scale    int    `json:"scale,omitempty"`
+}
+
+// EvaluationAnswer 对一道题的回答, Question为题目序号
+type EvaluationAnswer struct {
+	Question int    `json:"question"`
+	Rating   int    `json:"rating,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+// validateEvaluationQuestions 检查问卷题目, 打分题未设置分制时按5分制
+func validateEvaluationQuestions(questions []EvaluationQuestion) error {
+	if len(questions) == 0 {
+		return errors.New("问卷至少需要一道题")
+	}
+	for i := range questions {
+		question := &questions[i]
+		question.Title = strings.TrimSpace(question.Title)
+		if question.Title == "" {
+			return fmt.Errorf("第%d题的题目不能为空", i+1)
+		}
+		switch question.Kind {
+		case model.EvaluationRating:
+			if question.Scale == 0 {
+				question.Scale = 5
+			}
+			if question.Scale < 2 || question.Scale > evaluationMaxScale {
+				return fmt.Errorf("第%d题的分制必须在2到%d之间", i+1, evaluationMaxScale)
+			}
+		case model.EvaluationText:
+			question.Scale = 0
+		default:
+			return fmt.Errorf("第%d题的类型无效", i+1)
+		}
+	}
+	return nil
+}
+
+// EvaluationQuestions 解析问卷的题目
+func EvaluationQuestions(survey model.EvaluationSurvey) ([]EvaluationQuestion, error) {
+	var questions []EvaluationQuestion
+	if err := json.Unmarshal(survey.Questions, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// SaveEvaluationSurvey 添加或更新评教问卷, 已有学生提交后不能再修改题目
+func (e *Evaluation) SaveEvaluationSurvey(survey *model.EvaluationSurvey, questions []EvaluationQuestion) error {
+	if !survey.EndTime.After(survey.StartTime) {
+		return errors.New("评教结束时间必须晚于开始时间")
+	}
+	if err := validateEvaluationQuestions(questions); err != nil {
+		return err
+	}
+	questionsJSON, err := json.Marshal(questions)
+	if err != nil {
+		return err
+	}
+	survey.Questions = questionsJSON
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if survey.ID == 0 {
+		if err := tx.Create(survey).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit().Error
+	}
+	var existing model.EvaluationSurvey
+	if err := tx.First(&existing, survey.ID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("评教问卷不存在")
+		}
+		return err
+	}
+	if string(existing.Questions) != string(survey.Questions) {
+		var count int64
+		if err := tx.Model(&model.EvaluationResponse{}).Where("survey_id = ?", survey.ID).
+			Count(&count).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if count > 0 {
+			tx.Rollback()
+			return errors.New("已有学生提交该问卷, 不能修改题目")
+		}
+	}
+	survey.BaseModel = existing.BaseModel
+	if err := tx.Save(survey).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetEvaluationSurveys 获取全部评教问卷, 最近的在前
+func (e *Evaluation) GetEvaluationSurveys() ([]model.EvaluationSurvey, error) {
+	var surveys []model.EvaluationSurvey
+	if err := model.DB.Order("start_time DESC, id DESC").Find(&surveys).Error; err != nil {
+		return nil, err
+	}
+	return surveys, nil
+}
+
+// DeleteEvaluationSurvey 删除评教问卷, 已有学生提交的不能删除
+func (e *Evaluation) DeleteEvaluationSurvey(surveyID int64) error {
+	var count int64
+	if err := model.DB.Model(&model.EvaluationResponse{}).Where("survey_id = ?", surveyID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("已有学生提交该问卷, 不能删除")
+	}
+	result := model.DB.Delete(&model.EvaluationSurvey{}, surveyID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("评教问卷不存在")
+	}
+	return nil
+}
+
+// findEvaluationSurvey 按ID获取评教问卷
+func findEvaluationSurvey(db *gorm.DB, surveyID int64) (*model.EvaluationSurvey, error) {
+	var survey model.EvaluationSurvey
+	if err := db.First(&survey, surveyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("评教问卷不存在")
+		}
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// PendingEvaluation 学生在一份进行中的问卷里需要评价的课程
+type PendingEvaluation struct {
+	Survey    model.EvaluationSurvey
+	Courses   []model.Course
+	Submitted map[int64]bool
+}
+
+// GetStudentEvaluations 获取正在进行的评教问卷和学生所选的课程, 并标出已评价的课程
+func (e *Evaluation) GetStudentEvaluations(studentID string, now time.Time) ([]PendingEvaluation, error) {
+	var surveys []model.EvaluationSurvey
+	if err := model.DB.Where("start_time <= ? AND end_time > ?", now, now).
+		Order("end_time").Find(&surveys).Error; err != nil {
+		return nil, err
+	}
+	if len(surveys) == 0 {
+		return nil, nil
+	}
+	var courses []model.Course
+	if err := model.DB.Joins("JOIN course_student ON course_student.course_id = course.course_id").
+		Where("course_student.student_id = ?", studentID).
+		Order("course.course_id").Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	var submissions []model.EvaluationSubmission
+	surveyIDs := make([]int64, 0, len(surveys))
+	for _, survey := range surveys {
+		surveyIDs = append(surveyIDs, survey.ID)
+	}
+	if err := model.DB.Where("survey_id IN ? AND student_id = ?", surveyIDs, studentID).
+		Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	pending := make([]PendingEvaluation, 0, len(surveys))
+	for _, survey := range surveys {
+		submitted := make(map[int64]bool)
+		for _, submission := range submissions {
+			if submission.SurveyID == survey.ID {
+				submitted[submission.CourseID] = true
+			}
+		}
+		pending = append(pending, PendingEvaluation{Survey: survey, Courses: courses, Submitted: submitted})
+	}
+	return pending, nil
+}
+
+// validateEvaluationAnswers 按题目检查答案, 返回按题目顺序整理后的答案
+func validateEvaluationAnswers(questions []EvaluationQuestion, answers []EvaluationAnswer) ([]EvaluationAnswer, error) {
+	byQuestion := make(map[int]EvaluationAnswer, len(answers))
+	for _, answer := range answers {
+		if answer.Question < 0 || answer.Question >= len(questions) {
+			return nil, fmt.Errorf("题目序号%d无效", answer.Question)
+		}
+		if _, ok := byQuestion[answer.Question]; ok {
+			return nil, fmt.Errorf("第%d题重复作答", answer.Question+1)
+		}
+		byQuestion[answer.Question] = answer
+	}
+	cleaned := make([]EvaluationAnswer, 0, len(answers))
+	for i, question := range questions {
+		answer, ok := byQuestion[i]
+		answer.Text = strings.TrimSpace(answer.Text)
+		switch question.Kind {
+		case model.EvaluationRating:
+			answered := ok && answer.Rating != 0
+			if answered && (answer.Rating < 1 || answer.Rating > question.Scale) {
+				return nil, fmt.Errorf("第%d题的评分必须在1到%d之间", i+1, question.Scale)
+			}
+			if !answered {
+				if question.Required {
+					return nil, fmt.Errorf("第%d题为必答题", i+1)
+				}
+				continue
+			}
+			cleaned = append(cleaned, EvaluationAnswer{Question: i, Rating: answer.Rating})
+		case model.EvaluationText:
+			if answer.Text == "" {
+				if question.Required {
+					return nil, fmt.Errorf("第%d题为必答题", i+1)
+				}
+				continue
+			}
+			if len([]rune(answer.Text)) > evaluationMaxText {
+				return nil, fmt.Errorf("第%d题的回答不能超过%d字", i+1, evaluationMaxText)
+			}
+			cleaned = append(cleaned, EvaluationAnswer{Question: i, Text: answer.Text})
+		}
+	}
+	return cleaned, nil
+}
+
+// SubmitEvaluation 学生提交对一门课程的评价, 每份问卷每门课程只能提交一次
+// 提交记录和答卷分两张表保存, 答卷中不含学生信息
+func (e *Evaluation) SubmitEvaluation(studentID string, surveyID int64, courseID int64, answers []EvaluationAnswer, now time.Time) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	survey, err := findEvaluationSurvey(tx, surveyID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if now.Before(survey.StartTime) {
+		tx.Rollback()
+		return errors.New("评教尚未开始")
+	}
+	if !now.Before(survey.EndTime) {
+		tx.Rollback()
+		return errors.New("评教已结束")
+	}
+	if err := checkEnrolled(tx, courseID, studentID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	questions, err := EvaluationQuestions(*survey)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	cleaned, err := validateEvaluationAnswers(questions, answers)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	var count int64
+	if err := tx.Model(&model.EvaluationSubmission{}).
+		Where("survey_id = ? AND course_id = ? AND student_id = ?", surveyID, courseID, studentID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("您已评价过该课程")
+	}
+	// 并发提交时由唯一索引保证只有一份生效
+	submission := model.EvaluationSubmission{SurveyID: surveyID, CourseID: courseID, StudentID: studentID}
+	if err := tx.Create(&submission).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	answersJSON, err := json.Marshal(cleaned)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	responseID, err := newEvaluationResponseID()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	response := model.EvaluationResponse{ID: responseID, SurveyID: surveyID, CourseID: courseID, Answers: answersJSON}
+	if err := tx.Create(&response).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// newEvaluationResponseID 生成答卷的随机主键, 自增主键会与EvaluationSubmission的插入顺序一一对应, 从而暴露答卷是谁提交的
+func newEvaluationResponseID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// EvaluationQuestionResult 一道题的汇总结果, 打分题统计平均分和各分值人数, 文字题列出全部回答
+type EvaluationQuestionResult struct {
+	Question     EvaluationQuestion
+	Answered     int
+	Average      *float64
+	Distribution []int
+	Comments     []string
+}
+
+// EvaluationResult 一门或多门课程的评教汇总结果
+type EvaluationResult struct {
+	Survey    model.EvaluationSurvey
+	CourseIDs []int64
+	Enrolled  int
+	Responses int
+	Questions []EvaluationQuestionResult
+}
+
+// aggregateEvaluation 汇总课程的答卷, 文字回答排序后返回以免按提交顺序对应到学生
+func aggregateEvaluation(db *gorm.DB, survey model.EvaluationSurvey, courseIDs []int64) (*EvaluationResult, error) {
+	questions, err := EvaluationQuestions(survey)
+	if err != nil {
+		return nil, err
+	}
+	result := &EvaluationResult{Survey: survey, CourseIDs: courseIDs}
+	for _, question := range questions {
+		item := EvaluationQuestionResult{Question: question, Comments: []string{}}
+		if question.Kind == model.EvaluationRating {
+			item.Distribution = make([]int, question.Scale)
+		}
+		result.Questions = append(result.Questions, item)
+	}
+	if len(courseIDs) == 0 {
+		return result, nil
+	}
+	var enrolled int64
+	if err := db.Model(&model.CourseStudent{}).Where("course_id IN ?", courseIDs).Count(&enrolled).Error; err != nil {
+		return nil, err
+	}
+	result.Enrolled = int(enrolled)
+	var responses []model.EvaluationResponse
+	if err := db.Where("survey_id = ? AND course_id IN ?", survey.ID, courseIDs).Find(&responses).Error; err != nil {
+		return nil, err
+	}
+	result.Responses = len(responses)
+	sums := make([]int, len(questions))
+	for _, response := range responses {
+		var answers []EvaluationAnswer
+		if err := json.Unmarshal(response.Answers, &answers); err != nil {
+			return nil, err
+		}
+		for _, answer := range answers {
+			if answer.Question < 0 || answer.Question >= len(questions) {
+				continue
+			}
+			item := &result.Questions[answer.Question]
+			switch item.Question.Kind {
+			case model.EvaluationRating:
+				if answer.Rating < 1 || answer.Rating > item.Question.Scale {
+					continue
+				}
+				item.Answered++
+				item.Distribution[answer.Rating-1]++
+				sums[answer.Question] += answer.Rating
+			case model.EvaluationText:
+				item.Answered++
+				item.Comments = append(item.Comments, answer.Text)
+			}
+		}
+	}
+	for i := range result.Questions {
+		item := &result.Questions[i]
+		if item.Question.Kind == model.EvaluationRating && item.Answered > 0 {
+			average := float64(sums[i]) / float64(item.Answered)
+			item.Average = &average
+		}
+		sort.Strings(item.Comments)
+	}
+	return result, nil
+}
+
+// checkEvaluationVisible 教师只能在评教结束后查看结果, 且答卷数不能太少
+func checkEvaluationVisible(survey model.EvaluationSurvey, responses int, now time.Time) error {
+	if now.Before(survey.EndTime) {
+		return errors.New("评教结束后才能查看结果")
+	}
+	if responses < evaluationMinResponses {
+		return fmt.Errorf("答卷少于%d份, 为保护学生匿名不显示结果", evaluationMinResponses)
+	}
+	return nil
+}
+
+// GetCourseEvaluation 汇总一门课程的评教结果, forTeacher为true时按教师的查看限制检查
+func (e *Evaluation) GetCourseEvaluation(surveyID int64, courseID int64, forTeacher bool, now time.Time) (*EvaluationResult, error) {
+	survey, err := findEvaluationSurvey(model.DB, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	if err := model.DB.Model(&model.Course{}).Where("course_id = ?", courseID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("课程不存在")
+	}
+	result, err := aggregateEvaluation(model.DB, *survey, []int64{courseID})
+	if err != nil {
+		return nil, err
+	}
+	if forTeacher {
+		if err := checkEvaluationVisible(*survey, result.Responses, now); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// GetTeacherEvaluation 汇总教师所授全部课程的评教结果
+func (e *Evaluation) GetTeacherEvaluation(surveyID int64, teacherID int64, forTeacher bool, now time.Time) (*EvaluationResult, error) {
+	survey, err := findEvaluationSurvey(model.DB, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	if err := model.DB.Model(&model.Teacher{}).Where("id = ?", teacherID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("教师不存在")
+	}
+	var courseIDs []int64
+	if err := model.DB.Model(&model.CourseTeacher{}).
+		Joins("JOIN course ON course.course_id = course_teacher.course_id AND course.deleted_at IS NULL").
+		Where("course_teacher.teacher_id = ?", teacherID).
+		Order("course_teacher.course_id").
+		Pluck("course_teacher.course_id", &courseIDs).Error; err != nil {
+		return nil, err
+	}
+	result, err := aggregateEvaluation(model.DB, *survey, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	if forTeacher {
+		if err := checkEvaluationVisible(*survey, result.Responses, now); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"finaltenzor/model"
+	"finaltenzor/service/examplan"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Exam struct{}
+
+type ExamWindow = examplan.Window
+
+type ExamRequest struct {
+	Name            string
+	CourseIDs       []int64
+	Windows         []ExamWindow
+	RoomIDs         []int64
+	Durations       map[int64]time.Duration
+	DefaultDuration time.Duration
+}
+
+type UnscheduledExam = examplan.Unscheduled
+
+// CreateExamPlan 根据选课数据计算考试安排并保存为待审核方案
+func (e *Exam) CreateExamPlan(req ExamRequest) (*model.ExamPlan, error) {
+	if len(req.Windows) == 0 || len(req.RoomIDs) == 0 {
+		return nil, errors.New("考试时段和考场不能为空")
+	}
+	windows := append([]ExamWindow(nil), req.Windows...)
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].StartTime.Before(windows[j].StartTime)
+	})
+	for i := range windows {
+		if !windows[i].EndTime.After(windows[i].StartTime) {
+			return nil, errors.New("考试时段的结束时间必须晚于开始时间")
+		}
+		if i > 0 && windows[i].StartTime.Before(windows[i-1].EndTime) {
+			return nil, errors.New("考试时段不能重叠")
+		}
+	}
+	rooms, err := loadExamRooms(req.RoomIDs, windows)
+	if err != nil {
+		return nil, err
+	}
+	courses, err := loadExamCourses(req)
+	if err != nil {
+		return nil, err
+	}
+	assigned, unscheduled := examplan.Assign(courses, windows, rooms)
+	exams := make([]model.Exam, 0, len(assigned))
+	for _, exam := range assigned {
+		roomID := exam.RoomID
+		exams = append(exams, model.Exam{
+			CourseID:  exam.CourseID,
+			RoomID:    &roomID,
+			Room:      exam.Room,
+			StartTime: exam.StartTime,
+			EndTime:   exam.EndTime,
+			Students:  exam.Students,
+		})
+	}
+	unscheduledJSON, err := json.Marshal(unscheduled)
+	if err != nil {
+		return nil, err
+	}
+	plan := model.ExamPlan{
+		Name:        req.Name,
+		Status:      model.ExamPlanDraft,
+		Unscheduled: unscheduledJSON,
+		Exams:       exams,
+	}
+	if err := model.DB.Create(&plan).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// loadExamRooms 读取考场教室, 并标出每个考试时段内已被课程、教室预订或已通过方案的考试占用的教室
+func loadExamRooms(roomIDs []int64, windows []ExamWindow) ([]examplan.Room, error) {
+	var rooms []model.Room
+	if err := model.DB.Where("id IN ?", roomIDs).Order("id").Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[int64]bool, len(rooms))
+	for _, room := range rooms {
+		found[room.ID] = true
+	}
+	for _, roomID := range roomIDs {
+		if !found[roomID] {
+			return nil, fmt.Errorf("考场教室%d不存在", roomID)
+		}
+	}
+	examRooms := make([]examplan.Room, len(rooms))
+	for i, room := range rooms {
+		examRooms[i] = examplan.Room{ID: room.ID, Name: room.Label(), Capacity: room.Seats, Busy: make([]bool, len(windows))}
+	}
+	for w, window := range windows {
+		busy, err := busyRoomIDs(model.DB, []model.CourseTime{{StartTime: window.StartTime, EndTime: window.EndTime}})
+		if err != nil {
+			return nil, err
+		}
+		var examRoomIDs []int64
+		if err := model.DB.Model(&model.Exam{}).
+			Joins("JOIN exam_plan ON exam_plan.id = exam.plan_id AND exam_plan.deleted_at IS NULL").
+			Where("exam_plan.status = ? AND exam.room_id IS NOT NULL", model.ExamPlanApproved).
+			Where("exam.start_time < ? AND exam.end_time > ?", window.EndTime, window.StartTime).
+			Distinct().Pluck("exam.room_id", &examRoomIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, roomID := range examRoomIDs {
+			busy[roomID] = true
+		}
+		for i := range examRooms {
+			examRooms[i].Busy[w] = busy[examRooms[i].ID]
+		}
+	}
+	return examRooms, nil
+}
+
+// loadExamCourses 读取课程的选课学生并找出有共同学生的课程
+func loadExamCourses(req ExamRequest) ([]examplan.Course, error) {
+	var courseIDs []int64
+	query := model.DB.Model(&model.Course{})
+	if len(req.CourseIDs) > 0 {
+		query = query.Where("course_id IN ?", req.CourseIDs)
+	}
+	if err := query.Order("course_id").Pluck("course_id", &courseIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(courseIDs) == 0 {
+		return nil, errors.New("没有需要安排考试的课程")
+	}
+	var enrollments []model.CourseStudent
+	if err := model.DB.Where("course_id IN ?", courseIDs).Find(&enrollments).Error; err != nil {
+		return nil, err
+	}
+	courses := make([]examplan.Course, len(courseIDs))
+	index := make(map[int64]int, len(courseIDs))
+	for i, courseID := range courseIDs {
+		duration, ok := req.Durations[courseID]
+		if !ok {
+			duration = req.DefaultDuration
+		}
+		courses[i] = examplan.Course{ID: courseID, Duration: duration}
+		index[courseID] = i
+	}
+	coursesByStudent := make(map[string][]int64)
+	for _, enrollment := range enrollments {
+		courses[index[enrollment.CourseID]].Students++
+		coursesByStudent[enrollment.StudentID] = append(coursesByStudent[enrollment.StudentID], enrollment.CourseID)
+	}
+	linked := make(map[[2]int64]bool)
+	for _, studentCourses := range coursesByStudent {
+		for i := 0; i < len(studentCourses); i++ {
+			for j := i + 1; j < len(studentCourses); j++ {
+				a, b := studentCourses[i], studentCourses[j]
+				if a > b {
+					a, b = b, a
+				}
+				if a == b || linked[[2]int64{a, b}] {
+					continue
+				}
+				linked[[2]int64{a, b}] = true
+				courses[index[a]].Conflicts = append(courses[index[a]].Conflicts, b)
+				courses[index[b]].Conflicts = append(courses[index[b]].Conflicts, a)
+			}
+		}
+	}
+	return courses, nil
+}
+
+// GetExamPlans 获取考试安排方案列表
+func (e *Exam) GetExamPlans() ([]model.ExamPlan, error) {
+	var plans []model.ExamPlan
+	if err := model.DB.Order("id DESC").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// GetExamPlan 获取考试安排方案详情
+func (e *Exam) GetExamPlan(planID int64) (*model.ExamPlan, error) {
+	var plan model.ExamPlan
+	err := model.DB.Preload("Exams", func(db *gorm.DB) *gorm.DB {
+		return db.Order("start_time, room")
+	}).First(&plan, planID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("考试安排方案不存在")
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ReviewExamPlan 审核考试安排方案, 通过后与其安排了相同课程的已通过方案被替代;
+// 已通过方案中只有部分课程被新方案重新安排时不能通过, 以免其余课程的考试随之消失
+func (e *Exam) ReviewExamPlan(planID int64, approve bool) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var plan model.ExamPlan
+	if err := tx.First(&plan, planID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("考试安排方案不存在")
+		}
+		return err
+	}
+	if plan.Status != model.ExamPlanDraft {
+		tx.Rollback()
+		return fmt.Errorf("该方案状态为%s, 不能再审核", plan.Status)
+	}
+	status := model.ExamPlanRejected
+	if approve {
+		status = model.ExamPlanApproved
+		superseded, err := supersededExamPlans(tx, plan.ID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if len(superseded) > 0 {
+			if err := tx.Model(&model.ExamPlan{}).Where("id IN ?", superseded).
+				Update("status", model.ExamPlanSuperseded).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	if err := tx.Model(&plan).Update("status", status).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// supersededExamPlans 返回通过planID后应被替代的已通过方案, 即与其有相同课程的方案;
+// 这些方案的课程必须全部包含在planID中
+func supersededExamPlans(tx *gorm.DB, planID int64) ([]int64, error) {
+	var courseIDs []int64
+	if err := tx.Model(&model.Exam{}).Where("plan_id = ?", planID).Pluck("course_id", &courseIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(courseIDs) == 0 {
+		return nil, nil
+	}
+	covered := make(map[int64]bool, len(courseIDs))
+	for _, courseID := range courseIDs {
+		covered[courseID] = true
+	}
+	var exams []model.Exam
+	if err := tx.Joins("JOIN exam_plan ON exam_plan.id = exam.plan_id AND exam_plan.deleted_at IS NULL").
+		Where("exam_plan.status = ? AND exam.plan_id IN (?)", model.ExamPlanApproved,
+			tx.Model(&model.Exam{}).Select("plan_id").Where("course_id IN ?", courseIDs)).
+		Find(&exams).Error; err != nil {
+		return nil, err
+	}
+	var planIDs []int64
+	seen := make(map[int64]bool)
+	for _, exam := range exams {
+		if !covered[exam.CourseID] {
+			var other model.ExamPlan
+			if err := tx.Select("id, name").First(&other, exam.PlanID).Error; err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("已通过的方案《%s》中还有本方案未包含的课程, 请生成包含其全部课程的方案后再审核", other.Name)
+		}
+		if !seen[exam.PlanID] {
+			seen[exam.PlanID] = true
+			planIDs = append(planIDs, exam.PlanID)
+		}
+	}
+	return planIDs, nil
+}
+
+// GetStudentExams 获取学生在已通过方案中的考试安排
+func (e *Exam) GetStudentExams(studentID string) ([]model.Exam, error) {
+	var exams []model.Exam
+	err := model.DB.Model(&model.Exam{}).
+		Joins("JOIN exam_plan ON exam_plan.id = exam.plan_id AND exam_plan.deleted_at IS NULL").
+		Joins("JOIN course_student ON course_student.course_id = exam.course_id").
+		Where("exam_plan.status = ? AND course_student.student_id = ?", model.ExamPlanApproved, studentID).
+		Order("exam.start_time").
+		Find(&exams).Error
+	if err != nil {
+		return nil, err
+	}
+	return exams, nil
+}
+
+// GetCourseNames 批量获取课程名称
+func (e *Exam) GetCourseNames(courseIDs []int64) (map[int64]string, error) {
+	names := make(map[int64]string, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return names, nil
+	}
+	var courses []model.Course
+	if err := model.DB.Select("course_id, course_name").Where("course_id IN ?", courseIDs).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	for _, course := range courses {
+		names[course.CourseID] = course.CourseName
+	}
+	return names, nil
+}
@@ -0,0 +1,165 @@
+package examplan
+
+import (
+	"sort"
+	"time"
+)
+
+type Window struct {
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// Room 考场, Busy的下标为考试时段, 为true表示该时段教室已被课程、教室预订或其他考试占用
+type Room struct {
+	ID       int64
+	Name     string
+	Capacity int
+	Busy     []bool
+}
+
+// Course 需要安排考试的课程, Conflicts为与其有共同学生的课程
+type Course struct {
+	ID        int64
+	Students  int
+	Duration  time.Duration
+	Conflicts []int64
+}
+
+type Exam struct {
+	CourseID  int64
+	RoomID    int64
+	Room      string
+	StartTime time.Time
+	EndTime   time.Time
+	Students  int
+}
+
+type Unscheduled struct {
+	CourseID int64  `json:"courseId"`
+	Reason   string `json:"reason"`
+}
+
+// node 是冲突图中的一个课程, 有共同学生的两门课之间连边
+type node struct {
+	Course
+	neighbors []*node
+	window    int
+}
+
+// Assign 用DSatur顺序的贪心着色为每门课选择考试时段, 时段即颜色;
+// 同一时段内每个考场只安排一门考试, 选择容量足够的最小考场
+func Assign(courses []Course, windows []Window, rooms []Room) ([]Exam, []Unscheduled) {
+	nodes := make([]*node, 0, len(courses))
+	byCourse := make(map[int64]*node, len(courses))
+	for _, course := range courses {
+		n := &node{Course: course, window: -1}
+		nodes = append(nodes, n)
+		byCourse[course.ID] = n
+	}
+	for _, n := range nodes {
+		for _, courseID := range n.Conflicts {
+			if neighbor, ok := byCourse[courseID]; ok && neighbor != n {
+				n.neighbors = append(n.neighbors, neighbor)
+			}
+		}
+	}
+	rooms = append([]Room(nil), rooms...)
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].Capacity != rooms[j].Capacity {
+			return rooms[i].Capacity < rooms[j].Capacity
+		}
+		if rooms[i].Name != rooms[j].Name {
+			return rooms[i].Name < rooms[j].Name
+		}
+		return rooms[i].ID < rooms[j].ID
+	})
+	// 已被占用的教室在该时段不再使用
+	roomUsed := make([][]bool, len(windows))
+	for w := range roomUsed {
+		roomUsed[w] = make([]bool, len(rooms))
+		for r, room := range rooms {
+			roomUsed[w][r] = w < len(room.Busy) && room.Busy[w]
+		}
+	}
+	var exams []Exam
+	unscheduled := []Unscheduled{}
+	done := make(map[int64]bool, len(nodes))
+	for range nodes {
+		n := next(nodes, done)
+		done[n.ID] = true
+		blocked := make(map[int]bool)
+		for _, neighbor := range n.neighbors {
+			if neighbor.window >= 0 {
+				blocked[neighbor.window] = true
+			}
+		}
+		reason := "与已安排考试的学生冲突, 没有可用的考试时段"
+		for w, window := range windows {
+			if blocked[w] {
+				continue
+			}
+			if window.StartTime.Add(n.Duration).After(window.EndTime) {
+				reason = "考试时长超过了可用的考试时段"
+				continue
+			}
+			room := -1
+			for r := range rooms {
+				if !roomUsed[w][r] && rooms[r].Capacity >= n.Students {
+					room = r
+					break
+				}
+			}
+			if room < 0 {
+				reason = "没有容量足够的空闲考场"
+				continue
+			}
+			roomUsed[w][room] = true
+			n.window = w
+			exams = append(exams, Exam{
+				CourseID:  n.ID,
+				RoomID:    rooms[room].ID,
+				Room:      rooms[room].Name,
+				StartTime: window.StartTime,
+				EndTime:   window.StartTime.Add(n.Duration),
+				Students:  n.Students,
+			})
+			break
+		}
+		if n.window < 0 {
+			unscheduled = append(unscheduled, Unscheduled{CourseID: n.ID, Reason: reason})
+		}
+	}
+	return exams, unscheduled
+}
+
+// next 选出饱和度(相邻课程已占用的不同时段数)最高的课程, 依次以冲突数、人数、课程顺序打破平局
+func next(nodes []*node, done map[int64]bool) *node {
+	var best *node
+	bestSaturation := -1
+	for _, n := range nodes {
+		if done[n.ID] {
+			continue
+		}
+		used := make(map[int]bool)
+		for _, neighbor := range n.neighbors {
+			if neighbor.window >= 0 {
+				used[neighbor.window] = true
+			}
+		}
+		saturation := len(used)
+		switch {
+		case best == nil, saturation > bestSaturation:
+		case saturation < bestSaturation:
+			continue
+		case len(n.neighbors) > len(best.neighbors):
+		case len(n.neighbors) < len(best.neighbors):
+			continue
+		case n.Students > best.Students:
+		default:
+			continue
+		}
+		best, bestSaturation = n, saturation
+	}
+	return best
+}
@@ -0,0 +1,231 @@
+package examplan
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+// windows 生成n个互不重叠的两小时考试时段
+func windows(n int) []Window {
+	result := make([]Window, n)
+	for i := range result {
+		start := day.Add(time.Duration(i) * 3 * time.Hour)
+		result[i] = Window{StartTime: start, EndTime: start.Add(2 * time.Hour)}
+	}
+	return result
+}
+
+// conflicts 由无向边生成每门课程的冲突列表
+func conflicts(courses []Course, edges ...[2]int64) []Course {
+	index := make(map[int64]int, len(courses))
+	for i, course := range courses {
+		index[course.ID] = i
+	}
+	for _, edge := range edges {
+		courses[index[edge[0]]].Conflicts = append(courses[index[edge[0]]].Conflicts, edge[1])
+		courses[index[edge[1]]].Conflicts = append(courses[index[edge[1]]].Conflicts, edge[0])
+	}
+	return courses
+}
+
+func course(id int64, students int) Course {
+	return Course{ID: id, Students: students, Duration: 2 * time.Hour}
+}
+
+// checkAssignment 检查有冲突的课程不在同一时段, 同一时段内考场不重复, 考场容量足够
+func checkAssignment(t *testing.T, courses []Course, rooms []Room, exams []Exam) {
+	t.Helper()
+	capacity := make(map[string]int, len(rooms))
+	for _, room := range rooms {
+		capacity[room.Name] = room.Capacity
+	}
+	byCourse := make(map[int64]Exam, len(exams))
+	used := make(map[string]bool)
+	for _, exam := range exams {
+		if _, ok := byCourse[exam.CourseID]; ok {
+			t.Errorf("课程 %d 安排了多场考试", exam.CourseID)
+		}
+		byCourse[exam.CourseID] = exam
+		key := exam.StartTime.String() + "/" + exam.Room
+		if exam.RoomID == 0 {
+			t.Errorf("课程 %d 的考场没有教室ID", exam.CourseID)
+		}
+		if used[key] {
+			t.Errorf("考场 %s 在 %v 安排了多场考试", exam.Room, exam.StartTime)
+		}
+		used[key] = true
+		if exam.Students > capacity[exam.Room] {
+			t.Errorf("课程 %d 有 %d 人, 考场 %s 只能容纳 %d 人", exam.CourseID, exam.Students, exam.Room, capacity[exam.Room])
+		}
+	}
+	for _, course := range courses {
+		exam, ok := byCourse[course.ID]
+		if !ok {
+			continue
+		}
+		for _, other := range course.Conflicts {
+			if otherExam, ok := byCourse[other]; ok && otherExam.StartTime.Equal(exam.StartTime) {
+				t.Errorf("有共同学生的课程 %d 和 %d 安排在同一时段", course.ID, other)
+			}
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	rooms := []Room{{ID: 1, Name: "A101", Capacity: 100}, {ID: 2, Name: "B201", Capacity: 30}, {ID: 3, Name: "C301", Capacity: 30}}
+	tests := []struct {
+		name        string
+		courses     []Course
+		windows     int
+		rooms       []Room
+		scheduled   int
+		unscheduled map[int64]string
+	}{
+		{
+			name:      "没有冲突的课程共用一个时段",
+			courses:   []Course{course(1, 20), course(2, 20), course(3, 20)},
+			windows:   1,
+			rooms:     rooms,
+			scheduled: 3,
+		},
+		{
+			name:      "三角形需要三个时段",
+			courses:   conflicts([]Course{course(1, 10), course(2, 10), course(3, 10)}, [2]int64{1, 2}, [2]int64{2, 3}, [2]int64{1, 3}),
+			windows:   3,
+			rooms:     rooms,
+			scheduled: 3,
+		},
+		{
+			name:        "三角形只有两个时段时有一门课排不下",
+			courses:     conflicts([]Course{course(1, 10), course(2, 10), course(3, 10)}, [2]int64{1, 2}, [2]int64{2, 3}, [2]int64{1, 3}),
+			windows:     2,
+			rooms:       rooms,
+			scheduled:   2,
+			unscheduled: map[int64]string{3: "与已安排考试的学生冲突, 没有可用的考试时段"},
+		},
+		{
+			// 1-4-5-2-3-6-1 构成偶数环, 按编号顺序贪心会用三个时段, DSatur只需要两个
+			name: "偶数环两个时段即可",
+			courses: conflicts([]Course{course(1, 5), course(2, 5), course(3, 5), course(4, 5), course(5, 5), course(6, 5)},
+				[2]int64{1, 4}, [2]int64{1, 6}, [2]int64{2, 3}, [2]int64{2, 5}, [2]int64{3, 6}, [2]int64{5, 4}),
+			windows:   2,
+			rooms:     rooms,
+			scheduled: 6,
+		},
+		{
+			name:      "奇数环需要三个时段",
+			courses:   conflicts([]Course{course(1, 5), course(2, 5), course(3, 5), course(4, 5), course(5, 5)}, [2]int64{1, 2}, [2]int64{2, 3}, [2]int64{3, 4}, [2]int64{4, 5}, [2]int64{5, 1}),
+			windows:   3,
+			rooms:     rooms,
+			scheduled: 5,
+		},
+		{
+			name:        "人数超过所有考场的容量",
+			courses:     []Course{course(1, 120)},
+			windows:     2,
+			rooms:       rooms,
+			unscheduled: map[int64]string{1: "没有容量足够的空闲考场"},
+		},
+		{
+			name:        "大考场在每个时段只能用一次",
+			courses:     []Course{course(1, 80), course(2, 90), course(3, 70)},
+			windows:     2,
+			rooms:       rooms,
+			scheduled:   2,
+			unscheduled: map[int64]string{3: "没有容量足够的空闲考场"},
+		},
+		{
+			name:      "教室已被占用的时段不安排考试",
+			courses:   []Course{course(1, 80), course(2, 20)},
+			windows:   2,
+			rooms:     []Room{{ID: 1, Name: "A101", Capacity: 100, Busy: []bool{true, false}}, {ID: 2, Name: "B201", Capacity: 30, Busy: []bool{false, true}}},
+			scheduled: 2,
+		},
+		{
+			name:        "教室在所有时段都被占用",
+			courses:     []Course{course(1, 80)},
+			windows:     2,
+			rooms:       []Room{{ID: 1, Name: "A101", Capacity: 100, Busy: []bool{true, true}}, {ID: 2, Name: "B201", Capacity: 30}},
+			unscheduled: map[int64]string{1: "没有容量足够的空闲考场"},
+		},
+		{
+			name:        "考试时长超过时段",
+			courses:     []Course{{ID: 1, Students: 10, Duration: 3 * time.Hour}},
+			windows:     1,
+			rooms:       rooms,
+			unscheduled: map[int64]string{1: "考试时长超过了可用的考试时段"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exams, unscheduled := Assign(tt.courses, windows(tt.windows), tt.rooms)
+			checkAssignment(t, tt.courses, tt.rooms, exams)
+			if len(exams) != tt.scheduled {
+				t.Errorf("安排了 %d 场考试, 期望 %d 场", len(exams), tt.scheduled)
+			}
+			got := make(map[int64]string, len(unscheduled))
+			for _, item := range unscheduled {
+				got[item.CourseID] = item.Reason
+			}
+			if len(got) == 0 && len(tt.unscheduled) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.unscheduled) {
+				t.Errorf("未安排的课程为 %v, 期望 %v", got, tt.unscheduled)
+			}
+		})
+	}
+}
+
+func TestAssignRoomChoice(t *testing.T) {
+	rooms := []Room{{ID: 1, Name: "大教室", Capacity: 200}, {ID: 2, Name: "小教室乙", Capacity: 40}, {ID: 3, Name: "小教室甲", Capacity: 40}, {ID: 4, Name: "中教室", Capacity: 80}}
+	courses := []Course{course(1, 150), course(2, 60), course(3, 30), course(4, 35)}
+	exams, unscheduled := Assign(courses, windows(1), rooms)
+	if len(unscheduled) != 0 {
+		t.Fatalf("未安排的课程: %v", unscheduled)
+	}
+	got := make(map[int64]string, len(exams))
+	for _, exam := range exams {
+		got[exam.CourseID] = exam.Room
+	}
+	// 人数多的课程先安排, 每门课选容量足够的最小考场, 容量相同的考场按名称排序
+	want := map[int64]string{1: "大教室", 2: "中教室", 3: "小教室甲", 4: "小教室乙"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("考场分配为 %v, 期望 %v", got, want)
+	}
+}
+
+func TestNextSaturation(t *testing.T) {
+	// 只有一个考场时每个时段只能安排一门考试, 安排顺序即时段顺序;
+	// 3的冲突最多先安排, 之后4和5的饱和度为1, 应先于人数更多但饱和度为0的1
+	courses := conflicts([]Course{course(1, 90), course(2, 10), course(3, 10), course(4, 10), course(5, 10)},
+		[2]int64{1, 2}, [2]int64{3, 4}, [2]int64{3, 5})
+	exams, unscheduled := Assign(courses, windows(5), []Room{{ID: 1, Name: "A", Capacity: 100}})
+	if len(unscheduled) != 0 {
+		t.Fatalf("未安排的课程: %v", unscheduled)
+	}
+	sort.Slice(exams, func(i, j int) bool { return exams[i].StartTime.Before(exams[j].StartTime) })
+	var order []int64
+	for _, exam := range exams {
+		order = append(order, exam.CourseID)
+	}
+	want := []int64{3, 4, 5, 1, 2}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("安排顺序为 %v, 期望 %v", order, want)
+	}
+}
+
+func TestAssignSkipsBusyWindows(t *testing.T) {
+	rooms := []Room{{ID: 7, Name: "A101", Capacity: 100, Busy: []bool{true, false, false}}}
+	exams, unscheduled := Assign([]Course{course(1, 50)}, windows(3), rooms)
+	if len(unscheduled) != 0 || len(exams) != 1 {
+		t.Fatalf("安排结果为 %v, 未安排 %v", exams, unscheduled)
+	}
+	if want := windows(3)[1].StartTime; !exams[0].StartTime.Equal(want) || exams[0].RoomID != 7 {
+		t.Errorf("考试安排在 %v 的教室 %d, 期望 %v 的教室 7", exams[0].StartTime, exams[0].RoomID, want)
+	}
+}
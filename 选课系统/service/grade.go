@@ -0,0 +1,528 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"finaltenzor/config"
+	"finaltenzor/model"
+	"finaltenzor/service/grading"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Grade struct{}
+
+// 成绩录入结果中每行的处理方式
+const (
+	gradeCreate    = "create"
+	gradeUpdate    = "update"
+	gradeUnchanged = "unchanged"
+)
+
+// GradeRule 成绩到绩点的一条换算规则
+type GradeRule = grading.Rule
+
+// SaveGradeScale 添加或更新成绩等级制, 设为默认时取消其他等级制的默认标记
+func (g *Grade) SaveGradeScale(scale *model.GradeScale, rules []GradeRule) error {
+	if err := grading.Validate(scale.Kind, rules); err != nil {
+		return err
+	}
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	scale.Rules = rulesJSON
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var count int64
+	if err := tx.Model(&model.GradeScale{}).Where("name = ? AND id != ?", scale.Name, scale.ID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("该成绩等级制已存在")
+	}
+	if scale.ID > 0 {
+		var existing model.GradeScale
+		if err := tx.First(&existing, scale.ID).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("成绩等级制不存在")
+			}
+			return err
+		}
+		// 已录入的成绩按录入时的换算结果保存, 修改类型会使其无法解释
+		if existing.Kind != scale.Kind {
+			if err := tx.Model(&model.Grade{}).Where("scale_id = ?", scale.ID).Count(&count).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			if count > 0 {
+				tx.Rollback()
+				return errors.New("已有成绩使用该等级制, 不能修改类型")
+			}
+		}
+		scale.BaseModel = existing.BaseModel
+	}
+	if scale.IsDefault {
+		if err := tx.Model(&model.GradeScale{}).Where("id != ?", scale.ID).
+			Update("is_default", false).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Save(scale).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetGradeScales 获取全部成绩等级制
+func (g *Grade) GetGradeScales() ([]model.GradeScale, error) {
+	var scales []model.GradeScale
+	if err := model.DB.Order("id").Find(&scales).Error; err != nil {
+		return nil, err
+	}
+	return scales, nil
+}
+
+// DeleteGradeScale 删除成绩等级制, 仍被课程或成绩使用的不能删除
+func (g *Grade) DeleteGradeScale(scaleID int64) error {
+	var count int64
+	if err := model.DB.Model(&model.Course{}).Where("grade_scale_id = ?", scaleID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("仍有课程使用该等级制, 不能删除")
+	}
+	if err := model.DB.Model(&model.Grade{}).Where("scale_id = ?", scaleID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("已有成绩使用该等级制, 不能删除")
+	}
+	result := model.DB.Unscoped().Delete(&model.GradeScale{}, scaleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("成绩等级制不存在")
+	}
+	return nil
+}
+
+// GradeRules 解析等级制的换算规则
+func GradeRules(scale model.GradeScale) ([]GradeRule, error) {
+	var rules []GradeRule
+	if err := json.Unmarshal(scale.Rules, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// checkGradeScale 检查课程指定的成绩等级制是否存在, 0表示使用默认等级制
+func checkGradeScale(tx *gorm.DB, scaleID int64) error {
+	if scaleID == 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&model.GradeScale{}).Where("id = ?", scaleID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("成绩等级制不存在")
+	}
+	return nil
+}
+
+// courseGradeScale 返回课程使用的等级制, 课程未指定时使用默认等级制
+func courseGradeScale(db *gorm.DB, course model.Course) (*model.GradeScale, error) {
+	var scale model.GradeScale
+	query := db.Where("is_default = ?", true)
+	if course.GradeScaleID != nil {
+		query = db.Where("id = ?", *course.GradeScaleID)
+	}
+	if err := query.First(&scale).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("课程没有可用的成绩等级制, 请先设置默认等级制")
+		}
+		return nil, err
+	}
+	return &scale, nil
+}
+
+// evaluateGrade 按等级制解释成绩, 返回规范化的成绩、绩点和是否通过
+func evaluateGrade(scale model.GradeScale, value string) (string, *float64, bool, error) {
+	rules, err := GradeRules(scale)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return grading.Evaluate(scale.Kind, scale.Name, rules, value)
+}
+
+// saveGrade 录入或更新一条成绩, 学生必须已选该课程; 返回create、update或unchanged
+func saveGrade(tx *gorm.DB, course model.Course, scale model.GradeScale, studentID string, value string, recordedBy string, commit bool) (*model.Grade, string, error) {
+	var count int64
+	if err := tx.Model(&model.CourseStudent{}).Where("course_id = ? AND student_id = ?", course.CourseID, studentID).
+		Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+	if count == 0 {
+		return nil, "", fmt.Errorf("学生%s没有选修课程%s", studentID, course.CourseName)
+	}
+	normalized, points, passed, err := evaluateGrade(scale, value)
+	if err != nil {
+		return nil, "", err
+	}
+	var grade model.Grade
+	action := gradeUpdate
+	err = tx.Where("course_id = ? AND student_id = ?", course.CourseID, studentID).First(&grade).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		action = gradeCreate
+		grade = model.Grade{CourseID: course.CourseID, StudentID: studentID}
+	} else if err != nil {
+		return nil, "", err
+	} else if grade.ScaleID == scale.ID && grade.Value == normalized {
+		return &grade, gradeUnchanged, nil
+	}
+	grade.ScaleID = scale.ID
+	grade.Value = normalized
+	grade.Points = points
+	grade.Passed = passed
+	grade.RecordedBy = recordedBy
+	if commit {
+		if err := tx.Save(&grade).Error; err != nil {
+			return nil, "", err
+		}
+	}
+	return &grade, action, nil
+}
+
+// RecordGrade 录入或更新学生在某门课程的成绩
+func (g *Grade) RecordGrade(courseID int64, studentID string, value string, recordedBy string) (*model.Grade, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var course model.Course
+	if err := tx.First(&course, courseID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("课程不存在")
+		}
+		return nil, err
+	}
+	scale, err := courseGradeScale(tx, course)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	grade, _, err := saveGrade(tx, course, *scale, studentID, value, recordedBy, true)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return grade, nil
+}
+
+// GetCourseGrades 获取课程的全部成绩
+func (g *Grade) GetCourseGrades(courseID int64) ([]model.Grade, error) {
+	var grades []model.Grade
+	if err := model.DB.Where("course_id = ?", courseID).Order("student_id").Find(&grades).Error; err != nil {
+		return nil, err
+	}
+	return grades, nil
+}
+
+type GradeImportRow struct {
+	Line      int
+	CourseID  int64
+	StudentID string
+	Value     string
+	Points    *float64
+	Passed    bool
+	Action    string
+	Error     string
+}
+
+type GradeImportResult struct {
+	Committed bool
+	Created   int
+	Updated   int
+	Unchanged int
+	Failed    int
+	Rows      []GradeImportRow
+}
+
+// ImportGrades 从CSV批量导入成绩, 每行为课程ID、学号、成绩, 第一行可以是表头;
+// commit为false时只预览, 有任何一行出错时不导入
+func (g *Grade) ImportGrades(r io.Reader, commit bool, recordedBy string) (*GradeImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV格式错误: %v", err)
+	}
+	result := &GradeImportResult{Rows: []GradeImportRow{}}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	courses := make(map[int64]*model.Course)
+	scales := make(map[int64]*model.GradeScale)
+	seen := make(map[string]int)
+	for i, record := range records {
+		parsed, skip, parseErr := grading.ParseRecord(i, record)
+		if skip {
+			continue
+		}
+		row := GradeImportRow{Line: parsed.Line, CourseID: parsed.CourseID, StudentID: parsed.StudentID, Value: parsed.Value}
+		courseID := row.CourseID
+		rowErr := func() error {
+			if parseErr != nil {
+				return parseErr
+			}
+			key := fmt.Sprintf("%d/%s", row.CourseID, row.StudentID)
+			if line, ok := seen[key]; ok {
+				return fmt.Errorf("与第%d行重复", line)
+			}
+			seen[key] = row.Line
+			course, ok := courses[courseID]
+			if !ok {
+				course = &model.Course{}
+				if err := tx.First(course, courseID).Error; err != nil {
+					if !errors.Is(err, gorm.ErrRecordNotFound) {
+						return err
+					}
+					course = nil
+				}
+				courses[courseID] = course
+				if course != nil {
+					scale, err := courseGradeScale(tx, *course)
+					if err != nil {
+						return err
+					}
+					scales[courseID] = scale
+				}
+			}
+			if course == nil {
+				return fmt.Errorf("课程%d不存在", courseID)
+			}
+			if scales[courseID] == nil {
+				return errors.New("课程没有可用的成绩等级制")
+			}
+			grade, action, err := saveGrade(tx, *course, *scales[courseID], row.StudentID, row.Value, recordedBy, commit)
+			if err != nil {
+				return err
+			}
+			row.Value, row.Points, row.Passed, row.Action = grade.Value, grade.Points, grade.Passed, action
+			return nil
+		}()
+		if rowErr != nil {
+			row.Error = rowErr.Error()
+			result.Failed++
+		} else {
+			switch row.Action {
+			case gradeCreate:
+				result.Created++
+			case gradeUpdate:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if !commit || result.Failed > 0 {
+		tx.Rollback()
+		return result, nil
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	result.Committed = true
+	return result, nil
+}
+
+type TranscriptCourse struct {
+	Course model.Course
+	Grade  model.Grade
+}
+
+type TranscriptTerm struct {
+	Name          string
+	StartDate     time.Time
+	Courses       []TranscriptCourse
+	Credits       float64
+	EarnedCredits float64
+	GPA           *float64
+}
+
+type Transcript struct {
+	Terms         []TranscriptTerm
+	Credits       float64
+	EarnedCredits float64
+	GPA           *float64
+}
+
+// gpaAccumulator 按学分加权计算平均绩点, 学分为0的课程按1学分计; 通过/不通过的课程不计入
+type gpaAccumulator struct {
+	points float64
+	weight float64
+}
+
+func (a *gpaAccumulator) add(course model.Course, grade model.Grade) {
+	if grade.Points == nil {
+		return
+	}
+	weight := course.Credits
+	if weight <= 0 {
+		weight = 1
+	}
+	a.points += *grade.Points * weight
+	a.weight += weight
+}
+
+func (a *gpaAccumulator) gpa() *float64 {
+	if a.weight == 0 {
+		return nil
+	}
+	gpa := a.points / a.weight
+	return &gpa
+}
+
+// termOf 返回某日期所在学期的名称和开始日期, 校历中没有对应学期时按春季(2-7月)、秋季学期命名
+func termOf(date time.Time, terms []model.CalendarEvent) (string, time.Time) {
+	day := dateOf(date.In(config.Config.Location))
+	for _, term := range terms {
+		start := dateOf(term.StartDate.In(config.Config.Location))
+		end := dateOf(term.EndDate.In(config.Config.Location))
+		if !day.Before(start) && !day.After(end) {
+			return term.Name, start
+		}
+	}
+	year, month := day.Year(), day.Month()
+	switch {
+	case month >= time.February && month <= time.July:
+		return fmt.Sprintf("%d年春季学期", year), time.Date(year, time.February, 1, 0, 0, 0, 0, day.Location())
+	case month == time.January:
+		year--
+	}
+	return fmt.Sprintf("%d年秋季学期", year), time.Date(year, time.August, 1, 0, 0, 0, 0, day.Location())
+}
+
+// GetTranscript 生成学生的成绩单, 按课程第一次上课所在的学期分组, 给出每学期和累计的平均绩点
+func (g *Grade) GetTranscript(studentID string) (*Transcript, error) {
+	var grades []model.Grade
+	if err := model.DB.Where("student_id = ?", studentID).Find(&grades).Error; err != nil {
+		return nil, err
+	}
+	transcript := &Transcript{Terms: []TranscriptTerm{}}
+	if len(grades) == 0 {
+		return transcript, nil
+	}
+	courseIDs := make([]int64, 0, len(grades))
+	for _, grade := range grades {
+		courseIDs = append(courseIDs, grade.CourseID)
+	}
+	// 已删除的课程仍然保留在成绩单中
+	var courses []model.Course
+	if err := model.DB.Unscoped().Where("course_id IN ?", courseIDs).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	courseMap := make(map[int64]model.Course, len(courses))
+	for _, course := range courses {
+		courseMap[course.CourseID] = course
+	}
+	var firstTimes []struct {
+		CourseID  int64
+		FirstTime time.Time
+	}
+	if err := model.DB.Model(&model.CourseTime{}).Select("course_id, MIN(start_time) AS first_time").
+		Where("course_id IN ?", courseIDs).Group("course_id").Scan(&firstTimes).Error; err != nil {
+		return nil, err
+	}
+	firstTime := make(map[int64]time.Time, len(firstTimes))
+	for _, item := range firstTimes {
+		firstTime[item.CourseID] = item.FirstTime
+	}
+	var terms []model.CalendarEvent
+	if err := model.DB.Where("kind = ?", model.CalendarTerm).Order("start_date").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+
+	termIndex := make(map[string]int)
+	accumulators := make(map[string]*gpaAccumulator)
+	var total gpaAccumulator
+	for _, grade := range grades {
+		course := courseMap[grade.CourseID]
+		date, ok := firstTime[grade.CourseID]
+		if !ok {
+			date = grade.CreatedAt
+		}
+		name, start := termOf(date, terms)
+		index, ok := termIndex[name]
+		if !ok {
+			index = len(transcript.Terms)
+			termIndex[name] = index
+			accumulators[name] = &gpaAccumulator{}
+			transcript.Terms = append(transcript.Terms, TranscriptTerm{Name: name, StartDate: start})
+		}
+		term := &transcript.Terms[index]
+		term.Courses = append(term.Courses, TranscriptCourse{Course: course, Grade: grade})
+		term.Credits += course.Credits
+		transcript.Credits += course.Credits
+		if grade.Passed {
+			term.EarnedCredits += course.Credits
+			transcript.EarnedCredits += course.Credits
+		}
+		accumulators[name].add(course, grade)
+		total.add(course, grade)
+	}
+	for i := range transcript.Terms {
+		term := &transcript.Terms[i]
+		term.GPA = accumulators[term.Name].gpa()
+		sort.Slice(term.Courses, func(a, b int) bool {
+			return term.Courses[a].Course.CourseID < term.Courses[b].Course.CourseID
+		})
+	}
+	sort.SliceStable(transcript.Terms, func(i, j int) bool {
+		return transcript.Terms[i].StartDate.Before(transcript.Terms[j].StartDate)
+	})
+	transcript.GPA = total.gpa()
+	return transcript, nil
+}
+
+// completedCourseIDs 返回学生已取得及格成绩的课程, 先修课程和毕业要求检查都以此为准
+func completedCourseIDs(db *gorm.DB, studentID string) (map[int64]bool, error) {
+	var courseIDs []int64
+	if err := db.Model(&model.Grade{}).Where("student_id = ? AND passed = ?", studentID, true).
+		Pluck("course_id", &courseIDs).Error; err != nil {
+		return nil, err
+	}
+	completed := make(map[int64]bool, len(courseIDs))
+	for _, courseID := range courseIDs {
+		completed[courseID] = true
+	}
+	return completed, nil
+}
@@ -0,0 +1,128 @@
+package grading
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 等级制类型, 与model.GradeScale.Kind的取值一致
+const (
+	Percent  = "percent"
+	Letter   = "letter"
+	PassFail = "pass_fail"
+)
+
+// Rule 成绩到绩点的一条换算规则: 百分制按MinScore分数段匹配, 其他等级制按Grade匹配
+type Rule struct {
+	Grade    string   `json:"grade"`
+	MinScore float64  `json:"minScore"`
+	Points   *float64 `json:"points"`
+	Passing  bool     `json:"passing"`
+}
+
+// Record 成绩CSV中的一行: 课程ID、学号、成绩
+type Record struct {
+	Line      int
+	CourseID  int64
+	StudentID string
+	Value     string
+}
+
+// Validate 检查换算规则, 百分制须覆盖0分, 等级不能重复, 通过/不通过制不设绩点
+func Validate(kind string, rules []Rule) error {
+	if len(rules) == 0 {
+		return errors.New("请设置成绩换算规则")
+	}
+	seen := make(map[string]bool)
+	coversZero := false
+	for _, rule := range rules {
+		if rule.Points != nil && (*rule.Points < 0 || *rule.Points > 5) {
+			return errors.New("绩点必须在0到5之间")
+		}
+		switch kind {
+		case Percent:
+			if rule.MinScore < 0 || rule.MinScore > 100 {
+				return errors.New("分数段下限必须在0到100之间")
+			}
+			key := strconv.FormatFloat(rule.MinScore, 'f', -1, 64)
+			if seen[key] {
+				return fmt.Errorf("分数段下限%s重复", key)
+			}
+			seen[key] = true
+			if rule.MinScore == 0 {
+				coversZero = true
+			}
+		case Letter, PassFail:
+			grade := strings.ToUpper(strings.TrimSpace(rule.Grade))
+			if grade == "" {
+				return errors.New("等级不能为空")
+			}
+			if seen[grade] {
+				return fmt.Errorf("等级%s重复", grade)
+			}
+			seen[grade] = true
+			if kind == PassFail && rule.Points != nil {
+				return errors.New("通过/不通过制不计绩点")
+			}
+		default:
+			return errors.New("无效的成绩等级制类型")
+		}
+	}
+	if kind == Percent && !coversZero {
+		return errors.New("百分制须包含下限为0的分数段")
+	}
+	return nil
+}
+
+// Evaluate 按名为name的等级制解释成绩, 返回规范化的成绩、绩点和是否通过
+func Evaluate(kind string, name string, rules []Rule, value string) (string, *float64, bool, error) {
+	value = strings.TrimSpace(value)
+	if kind == Percent {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 100 {
+			return "", nil, false, fmt.Errorf("成绩%q不是0到100之间的分数", value)
+		}
+		rules = append([]Rule(nil), rules...)
+		sort.Slice(rules, func(i, j int) bool { return rules[i].MinScore > rules[j].MinScore })
+		for _, rule := range rules {
+			if score >= rule.MinScore {
+				return strconv.FormatFloat(score, 'f', -1, 64), rule.Points, rule.Passing, nil
+			}
+		}
+		return "", nil, false, fmt.Errorf("分数%s没有对应的分数段", value)
+	}
+	for _, rule := range rules {
+		if strings.EqualFold(strings.TrimSpace(rule.Grade), value) {
+			return strings.ToUpper(value), rule.Points, rule.Passing, nil
+		}
+	}
+	return "", nil, false, fmt.Errorf("成绩%q不属于等级制%s", value, name)
+}
+
+// ParseRecord 解析成绩CSV的第i行(从0开始), 空行和第一行的表头返回skip
+func ParseRecord(i int, record []string) (Record, bool, error) {
+	row := Record{Line: i + 1}
+	if i == 0 && len(record) > 0 {
+		record[0] = strings.TrimPrefix(record[0], "\xEF\xBB\xBF")
+	}
+	if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+		return row, true, nil
+	}
+	courseID, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+	if i == 0 && err != nil {
+		return row, true, nil // 表头
+	}
+	if len(record) < 3 {
+		return row, false, errors.New("每行须包含课程ID、学号和成绩")
+	}
+	if err != nil {
+		return row, false, fmt.Errorf("无效的课程ID: %s", record[0])
+	}
+	row.CourseID = courseID
+	row.StudentID = strings.TrimSpace(record[1])
+	row.Value = strings.TrimSpace(record[2])
+	return row, false, nil
+}
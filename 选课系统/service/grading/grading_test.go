@@ -0,0 +1,160 @@
+package grading
+
+import "testing"
+
+type scale struct {
+	kind  string
+	rules []Rule
+}
+
+func points(value float64) *float64 {
+	return &value
+}
+
+func TestEvaluate(t *testing.T) {
+	percent := scale{Percent, []Rule{
+		{MinScore: 0, Points: points(0)},
+		{MinScore: 60, Points: points(1), Passing: true},
+		{MinScore: 89.5, Points: points(4), Passing: true},
+		{MinScore: 75, Points: points(2.5), Passing: true},
+	}}
+	letter := scale{Letter, []Rule{
+		{Grade: "A", Points: points(4), Passing: true},
+		{Grade: " B+ ", Points: points(3.3), Passing: true},
+		{Grade: "F", Points: points(0)},
+	}}
+	passFail := scale{PassFail, []Rule{
+		{Grade: "P", Passing: true},
+		{Grade: "F"},
+	}}
+	tests := []struct {
+		name    string
+		scale   scale
+		value   string
+		want    string
+		points  *float64
+		passing bool
+		wantErr bool
+	}{
+		{name: "0分", scale: percent, value: "0", want: "0", points: points(0)},
+		{name: "刚好不及格", scale: percent, value: "59.99", want: "59.99", points: points(0)},
+		{name: "及格线", scale: percent, value: "60", want: "60", points: points(1), passing: true},
+		{name: "分数段下限之前", scale: percent, value: "74.9", want: "74.9", points: points(1), passing: true},
+		{name: "分数段下限", scale: percent, value: "75", want: "75", points: points(2.5), passing: true},
+		{name: "小数下限", scale: percent, value: "89.5", want: "89.5", points: points(4), passing: true},
+		{name: "满分", scale: percent, value: "100", want: "100", points: points(4), passing: true},
+		{name: "去掉空格和多余的零", scale: percent, value: " 85.50 ", want: "85.5", points: points(2.5), passing: true},
+		{name: "超过100分", scale: percent, value: "100.5", wantErr: true},
+		{name: "负分", scale: percent, value: "-1", wantErr: true},
+		{name: "百分制不接受等级", scale: percent, value: "A", wantErr: true},
+		{name: "等级不区分大小写", scale: letter, value: "b+", want: "B+", points: points(3.3), passing: true},
+		{name: "不及格等级", scale: letter, value: "F", want: "F", points: points(0)},
+		{name: "不存在的等级", scale: letter, value: "C", wantErr: true},
+		{name: "等级制不接受分数", scale: letter, value: "90", wantErr: true},
+		{name: "通过", scale: passFail, value: "p", want: "P", passing: true},
+		{name: "不通过", scale: passFail, value: "F", want: "F"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, points, passing, err := Evaluate(tt.scale.kind, "测试", tt.scale.rules, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("期望出错, 实际得到 %q", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.want {
+				t.Errorf("成绩为 %q, 期望 %q", value, tt.want)
+			}
+			if (points == nil) != (tt.points == nil) || (points != nil && *points != *tt.points) {
+				t.Errorf("绩点为 %v, 期望 %v", points, tt.points)
+			}
+			if passing != tt.passing {
+				t.Errorf("通过为 %v, 期望 %v", passing, tt.passing)
+			}
+		})
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		rules   []Rule
+		wantErr bool
+	}{
+		{name: "百分制", kind: Percent, rules: []Rule{{MinScore: 0}, {MinScore: 60, Passing: true}}},
+		{name: "百分制缺少0分段", kind: Percent, rules: []Rule{{MinScore: 60}}, wantErr: true},
+		{name: "分数段下限重复", kind: Percent, rules: []Rule{{MinScore: 0}, {MinScore: 0}}, wantErr: true},
+		{name: "分数段下限超过100", kind: Percent, rules: []Rule{{MinScore: 0}, {MinScore: 101}}, wantErr: true},
+		{name: "绩点超过5", kind: Percent, rules: []Rule{{MinScore: 0, Points: points(5.1)}}, wantErr: true},
+		{name: "等级制", kind: Letter, rules: []Rule{{Grade: "A", Points: points(4)}, {Grade: "F"}}},
+		{name: "等级重复", kind: Letter, rules: []Rule{{Grade: "a"}, {Grade: " A"}}, wantErr: true},
+		{name: "等级为空", kind: Letter, rules: []Rule{{Grade: " "}}, wantErr: true},
+		{name: "通过制不计绩点", kind: PassFail, rules: []Rule{{Grade: "P", Points: points(4)}}, wantErr: true},
+		{name: "没有规则", kind: Letter, wantErr: true},
+		{name: "未知类型", kind: "gpa", rules: []Rule{{Grade: "A"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.kind, tt.rules); (err != nil) != tt.wantErr {
+				t.Errorf("错误为 %v, 期望出错 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    int
+		record  []string
+		skip    bool
+		want    Record
+		wantErr bool
+	}{
+		{name: "表头", line: 0, record: []string{"课程ID", "学号", "成绩"}, skip: true},
+		{name: "带BOM的表头", line: 0, record: []string{"\xEF\xBB\xBFcourse_id", "student_id", "grade"}, skip: true},
+		{
+			name:   "带BOM的第一行数据",
+			line:   0,
+			record: []string{"\xEF\xBB\xBF12", "2021001", "90"},
+			want:   Record{Line: 1, CourseID: 12, StudentID: "2021001", Value: "90"},
+		},
+		{
+			name:   "去掉首尾空格",
+			line:   3,
+			record: []string{" 12 ", " 2021001 ", " B+ "},
+			want:   Record{Line: 4, CourseID: 12, StudentID: "2021001", Value: "B+"},
+		},
+		{
+			name:   "多余的列忽略",
+			line:   1,
+			record: []string{"12", "2021001", "A", "备注"},
+			want:   Record{Line: 2, CourseID: 12, StudentID: "2021001", Value: "A"},
+		},
+		{name: "空行", line: 2, record: []string{" "}, skip: true},
+		{name: "非第一行的课程ID无效", line: 1, record: []string{"课程ID", "2021001", "90"}, want: Record{Line: 2}, wantErr: true},
+		{name: "列数不够", line: 1, record: []string{"12", "2021001"}, want: Record{Line: 2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, skip, err := ParseRecord(tt.line, tt.record)
+			if skip != tt.skip {
+				t.Fatalf("跳过为 %v, 期望 %v", skip, tt.skip)
+			}
+			if skip {
+				return
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("错误为 %v, 期望出错 %v", err, tt.wantErr)
+			}
+			if row.Line != tt.want.Line || row.CourseID != tt.want.CourseID || row.StudentID != tt.want.StudentID || row.Value != tt.want.Value {
+				t.Errorf("解析为 %+v, 期望 %+v", row, tt.want)
+			}
+		})
+	}
+}
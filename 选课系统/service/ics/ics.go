@@ -0,0 +1,229 @@
+package ics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Categories  []string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+}
+
+// contentLine 是展开折行之后的一行内容, 例如 DTSTART;VALUE=DATE:20240901
+type contentLine struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Parse 解析ICS文件中的所有VEVENT, 不带时区的时间按loc解释
+func Parse(r io.Reader, loc *time.Location) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	var current *Event
+	for i, raw := range lines {
+		line, err := parseLine(raw)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %w", i+1, err)
+		}
+		switch {
+		case line.Name == "BEGIN" && strings.EqualFold(line.Value, "VEVENT"):
+			current = &Event{}
+		case line.Name == "END" && strings.EqualFold(line.Value, "VEVENT"):
+			if current == nil {
+				return nil, fmt.Errorf("第%d行: END:VEVENT 没有对应的 BEGIN", i+1)
+			}
+			if current.Start.IsZero() {
+				return nil, fmt.Errorf("事件 %s 缺少 DTSTART", current.Summary)
+			}
+			if current.End.IsZero() {
+				current.End = current.Start
+				if current.AllDay {
+					current.End = current.Start.AddDate(0, 0, 1)
+				}
+			}
+			events = append(events, *current)
+			current = nil
+		case current != nil:
+			if err := current.set(line, loc); err != nil {
+				return nil, fmt.Errorf("第%d行: %w", i+1, err)
+			}
+		}
+	}
+	if current != nil {
+		return nil, errors.New("文件在 VEVENT 结束之前截断")
+	}
+	return events, nil
+}
+
+func (e *Event) set(line contentLine, loc *time.Location) error {
+	switch line.Name {
+	case "UID":
+		e.UID = line.Value
+	case "SUMMARY":
+		e.Summary = unescape(line.Value)
+	case "DESCRIPTION":
+		e.Description = unescape(line.Value)
+	case "LOCATION":
+		e.Location = unescape(line.Value)
+	case "CATEGORIES":
+		for _, category := range strings.Split(line.Value, ",") {
+			if category = strings.TrimSpace(unescape(category)); category != "" {
+				e.Categories = append(e.Categories, category)
+			}
+		}
+	case "DTSTART":
+		t, allDay, err := parseTime(line, loc)
+		if err != nil {
+			return err
+		}
+		e.Start, e.AllDay = t, allDay
+	case "DTEND":
+		t, _, err := parseTime(line, loc)
+		if err != nil {
+			return err
+		}
+		e.End = t
+	}
+	return nil
+}
+
+// unfold 读取所有行并把以空格或制表符开头的续行拼接回上一行
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		lines = append(lines, text)
+	}
+	return lines, scanner.Err()
+}
+
+func parseLine(raw string) (contentLine, error) {
+	colon := strings.IndexByte(raw, ':')
+	if colon < 0 {
+		return contentLine{}, fmt.Errorf("无法识别的内容行 %q", raw)
+	}
+	parts := strings.Split(raw[:colon], ";")
+	line := contentLine{
+		Name:   strings.ToUpper(parts[0]),
+		Params: make(map[string]string),
+		Value:  raw[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		if eq := strings.IndexByte(param, '='); eq > 0 {
+			line.Params[strings.ToUpper(param[:eq])] = strings.Trim(param[eq+1:], `"`)
+		}
+	}
+	return line, nil
+}
+
+func parseTime(line contentLine, loc *time.Location) (time.Time, bool, error) {
+	if line.Params["VALUE"] == "DATE" || len(line.Value) == 8 {
+		t, err := time.ParseInLocation("20060102", line.Value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(line.Value, "Z") {
+		t, err := time.Parse("20060102T150405Z", line.Value)
+		return t.In(loc), false, err
+	}
+	if tzid := line.Params["TZID"]; tzid != "" {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			t, err := time.ParseInLocation("20060102T150405", line.Value, zone)
+			return t.In(loc), false, err
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", line.Value, loc)
+	return t, false, err
+}
+
+func unescape(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+func escape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+// fold 按RFC 5545把超过75字节的内容行折成多行, 不在UTF-8字符中间断开
+func fold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// Write 把事件写成一个ICS日历, 时间统一以UTC输出
+func Write(w io.Writer, name string, events []Event) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//finaltenzor//course-selection//CN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:" + escape(name),
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, event := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+event.UID,
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+event.Start.UTC().Format("20060102T150405Z"),
+			"DTEND:"+event.End.UTC().Format("20060102T150405Z"),
+			"SUMMARY:"+escape(event.Summary),
+		)
+		if event.Location != "" {
+			lines = append(lines, "LOCATION:"+escape(event.Location))
+		}
+		if event.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escape(event.Description))
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, 0, len(event.Categories))
+			for _, category := range event.Categories {
+				categories = append(categories, escape(category))
+			}
+			lines = append(lines, "CATEGORIES:"+strings.Join(categories, ","))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for _, line := range lines {
+		if _, err := io.WriteString(w, fold(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package ics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // 测试环境可能没有系统时区数据
+	"unicode/utf8"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		lines int
+	}{
+		{name: "短行不折", line: "SUMMARY:高等数学", lines: 1},
+		{name: "正好75字节不折", line: strings.Repeat("a", 75), lines: 1},
+		{name: "76字节折成两行", line: strings.Repeat("a", 76), lines: 2},
+		{name: "长ASCII行", line: "DESCRIPTION:" + strings.Repeat("x", 200), lines: 3},
+		{name: "中文不在字符中间断开", line: "SUMMARY:" + strings.Repeat("线性代数", 20), lines: 4},
+		{name: "混合宽度字符", line: "LOCATION:" + strings.Repeat("a教", 40), lines: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := fold(tt.line)
+			physical := strings.Split(folded, "\r\n")
+			if len(physical) != tt.lines {
+				t.Errorf("折成 %d 行, 期望 %d 行", len(physical), tt.lines)
+			}
+			for i, line := range physical {
+				if len(line) > 75 {
+					t.Errorf("第%d行长 %d 字节, 超过75字节", i+1, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("第%d行在UTF-8字符中间断开: %q", i+1, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("续行 %q 没有以空格开头", line)
+				}
+			}
+			lines, err := unfold(strings.NewReader(folded + "\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.line {
+				t.Errorf("展开后为 %q, 期望 %q", lines, tt.line)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		line   string
+		want   time.Time
+		allDay bool
+	}{
+		{
+			name:   "全天日期按本地时区解释",
+			line:   "DTSTART;VALUE=DATE:20240901",
+			want:   time.Date(2024, 9, 1, 0, 0, 0, 0, shanghai),
+			allDay: true,
+		},
+		{
+			name: "UTC时间",
+			line: "DTSTART:20240901T000000Z",
+			want: time.Date(2024, 9, 1, 8, 0, 0, 0, shanghai),
+		},
+		{
+			name: "不带时区的时间按本地时区解释",
+			line: "DTSTART:20240901T080000",
+			want: time.Date(2024, 9, 1, 8, 0, 0, 0, shanghai),
+		},
+		{
+			name: "TZID指定的时区",
+			line: "DTSTART;TZID=America/New_York:20240901T080000",
+			want: time.Date(2024, 9, 1, 20, 0, 0, 0, shanghai),
+		},
+		{
+			name: "TZID带引号",
+			line: `DTSTART;TZID="Europe/London":20240115T090000`,
+			want: time.Date(2024, 1, 15, 17, 0, 0, 0, shanghai),
+		},
+		{
+			name: "无法识别的TZID按本地时区解释",
+			line: "DTSTART;TZID=Custom/Unknown:20240901T080000",
+			want: time.Date(2024, 9, 1, 8, 0, 0, 0, shanghai),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := parseLine(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, allDay, err := parseTime(line, shanghai)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("解析为 %v, 期望 %v", got, tt.want)
+			}
+			if got.Location() != shanghai {
+				t.Errorf("时区为 %v, 期望转换到 %v", got.Location(), shanghai)
+			}
+			if allDay != tt.allDay {
+				t.Errorf("全天为 %v, 期望 %v", allDay, tt.allDay)
+			}
+		})
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{{
+		UID:         "course-1-20240902@finaltenzor",
+		Summary:     "数据结构; 第1讲, 绪论",
+		Description: strings.Repeat("课程介绍\n", 20),
+		Location:    "教学楼 101",
+		Categories:  []string{"课程", "必修"},
+		Start:       time.Date(2024, 9, 2, 8, 0, 0, 0, shanghai),
+		End:         time.Date(2024, 9, 2, 9, 40, 0, 0, shanghai),
+	}}
+	var buf bytes.Buffer
+	if err := Write(&buf, "我的课表", events); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(&buf, shanghai)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 1 {
+		t.Fatalf("解析出 %d 个事件, 期望 1 个", len(parsed))
+	}
+	got, want := parsed[0], events[0]
+	if got.UID != want.UID || got.Summary != want.Summary || got.Description != want.Description || got.Location != want.Location {
+		t.Errorf("解析结果 %+v 与写入的 %+v 不一致", got, want)
+	}
+	if strings.Join(got.Categories, ",") != strings.Join(want.Categories, ",") {
+		t.Errorf("类别为 %v, 期望 %v", got.Categories, want.Categories)
+	}
+	if !got.Start.Equal(want.Start) || !got.End.Equal(want.End) {
+		t.Errorf("时间为 %v - %v, 期望 %v - %v", got.Start, got.End, want.Start, want.End)
+	}
+}
@@ -0,0 +1,36 @@
+package namematch
+
+// Distance 计算两个姓名之间的编辑距离(按字符)
+func Distance(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Similar 判断两个规范化后的姓名是否相同或相近, 返回编辑距离;
+// 姓名越长允许的差异越大, 两三个字的中文姓名只允许相差一个字
+func Similar(a []rune, b []rune) (int, bool) {
+	limit := 1
+	if max(len(a), len(b)) > 6 {
+		limit = 2
+	}
+	if len(a)-len(b) > limit || len(b)-len(a) > limit {
+		return 0, false
+	}
+	distance := Distance(a, b)
+	return distance, distance == 0 || (distance <= limit && len(a) > 1 && len(b) > 1)
+}
@@ -0,0 +1,64 @@
+package namematch
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"张三", "", 2},
+		{"", "李四", 2},
+		{"张三", "张三", 0},
+		{"张三", "张山", 1},
+		{"张三", "张三丰", 1},
+		{"欧阳修", "欧修", 1},
+		{"王小明", "明小王", 2},
+		{"zhangsan", "zhangshan", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := Distance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+				t.Errorf("Distance(%q, %q) = %d, 期望 %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := Distance([]rune(tt.b), []rune(tt.a)); got != tt.want {
+				t.Errorf("Distance(%q, %q) = %d, 期望 %d", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		distance int
+		similar  bool
+	}{
+		{name: "姓名相同", a: "张三", b: "张三", distance: 0, similar: true},
+		{name: "两个字相差一个字", a: "张三", b: "张山", distance: 1, similar: true},
+		{name: "多一个字", a: "张三", b: "张三丰", distance: 1, similar: true},
+		{name: "三个字相差两个字", a: "王小明", b: "王大名", similar: false},
+		{name: "单字姓名不算相近", a: "张", b: "王", similar: false},
+		{name: "单字与两字姓名不算相近", a: "张", b: "张三", similar: false},
+		{name: "长度相差太多", a: "张三", b: "张三丰四", similar: false},
+		{name: "长姓名允许相差两个字", a: "zhangsanfeng", b: "zhangsanfang", distance: 1, similar: true},
+		{name: "长姓名相差两个字", a: "zhangsanfeng", b: "zhangshanfen", distance: 2, similar: true},
+		{name: "长姓名相差三个字", a: "zhangsanfeng", b: "chongsanfing", similar: false},
+		{name: "长度相差两个字", a: "zhangsanfeng", b: "zhangsanfe", distance: 2, similar: true},
+		{name: "较短的在前时同样相近", a: "zhangsanfe", b: "zhangsanfeng", distance: 2, similar: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance, similar := Similar([]rune(tt.a), []rune(tt.b))
+			if similar != tt.similar {
+				t.Errorf("Similar(%q, %q) = %v, 期望 %v", tt.a, tt.b, similar, tt.similar)
+			}
+			if similar && distance != tt.distance {
+				t.Errorf("Similar(%q, %q) 的编辑距离为 %d, 期望 %d", tt.a, tt.b, distance, tt.distance)
+			}
+		})
+	}
+}
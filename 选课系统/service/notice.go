@@ -0,0 +1,16 @@
+package service
+
+import (
+	"finaltenzor/model"
+)
+
+type Notice struct{}
+
+// GetStudentNotices 获取学生的通知, 最新的在前
+func (n *Notice) GetStudentNotices(studentID string) ([]model.Notice, error) {
+	var notices []model.Notice
+	if err := model.DB.Where("student_id = ?", studentID).Order("id DESC").Find(&notices).Error; err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OfficeHour struct{}
+
+const (
+	minOfficeHourSlot = 5   // 答疑时段最短分钟数
+	maxOfficeHourSlot = 240 // 答疑时段最长分钟数
+
+	maxOfficeHourRange = 62 * 24 * time.Hour // 一次查询答疑时段的最长范围
+)
+
+// officeHourSlots 展开答疑时间段在[from, to)内的全部时段, 时间段末尾不足一个时段的部分不开放预约
+func officeHourSlots(block model.OfficeHour, from time.Time, to time.Time) []model.CourseTime {
+	startClock, _ := common.ParseClock(block.StartClock)
+	endClock, _ := common.ParseClock(block.EndClock)
+	slot := time.Duration(block.SlotMinutes) * time.Minute
+	day := dateOf(from)
+	if first := dateOf(block.FromDate); day.Before(first) {
+		day = first
+	}
+	last := dateOf(block.ToDate)
+	var slots []model.CourseTime
+	for ; !day.After(last) && day.Before(to); day = day.AddDate(0, 0, 1) {
+		if isoWeekday(day) != block.Weekday {
+			continue
+		}
+		for start := startClock; start+slot <= endClock; start += slot {
+			slotStart := day.Add(start)
+			if slotStart.Before(from) || !slotStart.Before(to) {
+				continue
+			}
+			slots = append(slots, model.CourseTime{StartTime: slotStart, EndTime: slotStart.Add(slot)})
+		}
+	}
+	return slots
+}
+
+// AddOfficeHour 教师发布每周固定的答疑时间段, 同一天内不能与自己的其他答疑时间段重叠
+func (o *OfficeHour) AddOfficeHour(block *model.OfficeHour) error {
+	if block.Weekday < 1 || block.Weekday > 7 {
+		return errors.New("无效的星期")
+	}
+	startClock, err := common.ParseClock(block.StartClock)
+	if err != nil {
+		return err
+	}
+	endClock, err := common.ParseClock(block.EndClock)
+	if err != nil {
+		return err
+	}
+	if block.SlotMinutes < minOfficeHourSlot || block.SlotMinutes > maxOfficeHourSlot {
+		return fmt.Errorf("每个时段必须在%d到%d分钟之间", minOfficeHourSlot, maxOfficeHourSlot)
+	}
+	if endClock-startClock < time.Duration(block.SlotMinutes)*time.Minute {
+		return errors.New("答疑时间段至少要能容纳一个时段")
+	}
+	if block.ToDate.Before(block.FromDate) {
+		return errors.New("结束日期不能早于开始日期")
+	}
+	// 限制重复次数, 与教室预订的周期规则一致
+	if _, err := WeeklyTimes(block.FromDate, block.ToDate, time.Weekday(block.Weekday%7), startClock, endClock); err != nil {
+		return err
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var count int64
+	if err := tx.Model(&model.Teacher{}).Where("id = ?", block.TeacherID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count == 0 {
+		tx.Rollback()
+		return errors.New("教师不存在")
+	}
+	if err := tx.Model(&model.OfficeHour{}).
+		Where("teacher_id = ? AND weekday = ? AND from_date <= ? AND to_date >= ?",
+			block.TeacherID, block.Weekday, block.ToDate.Format("2006-01-02"), block.FromDate.Format("2006-01-02")).
+		Where("start_clock < ? AND end_clock > ?", block.EndClock, block.StartClock).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("与已有的答疑时间重叠")
+	}
+	if err := tx.Create(block).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetOfficeHours 获取教师的答疑时间段
+func (o *OfficeHour) GetOfficeHours(teacherID int64) ([]model.OfficeHour, error) {
+	var blocks []model.OfficeHour
+	if err := model.DB.Where("teacher_id = ?", teacherID).
+		Order("from_date, weekday, start_clock").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// DeleteOfficeHour 删除答疑时间段, 还有未到时间的预约时不能删除
+func (o *OfficeHour) DeleteOfficeHour(teacherID int64, blockID int64, now time.Time) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var count int64
+	if err := tx.Model(&model.OfficeHourBooking{}).Where("office_hour_id = ? AND start_time > ?", blockID, now).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("该答疑时间段还有学生预约, 不能删除")
+	}
+	result := tx.Where("teacher_id = ?", teacherID).Delete(&model.OfficeHour{}, blockID)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("答疑时间段不存在")
+	}
+	return tx.Commit().Error
+}
+
+// checkStudentOfTeacher 检查学生是否选修了该教师讲授的课程
+func checkStudentOfTeacher(db *gorm.DB, studentID string, teacherID int64) error {
+	var count int64
+	if err := db.Model(&model.CourseStudent{}).
+		Joins("JOIN course_teacher ON course_teacher.course_id = course_student.course_id").
+		Joins("JOIN course ON course.course_id = course_student.course_id AND course.deleted_at IS NULL").
+		Where("course_student.student_id = ? AND course_teacher.teacher_id = ?", studentID, teacherID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("你没有选修该教师的课程, 不能预约答疑")
+	}
+	return nil
+}
+
+// GetStudentTeachers 获取学生所选课程的授课教师, 即学生可以预约答疑的教师
+func (o *OfficeHour) GetStudentTeachers(studentID string) ([]model.Teacher, error) {
+	var teachers []model.Teacher
+	if err := model.DB.Distinct("teacher.*").
+		Joins("JOIN course_teacher ON course_teacher.teacher_id = teacher.id").
+		Joins("JOIN course_student ON course_student.course_id = course_teacher.course_id").
+		Joins("JOIN course ON course.course_id = course_teacher.course_id AND course.deleted_at IS NULL").
+		Where("course_student.student_id = ?", studentID).
+		Order("teacher.id").Find(&teachers).Error; err != nil {
+		return nil, err
+	}
+	return teachers, nil
+}
+
+// OfficeHourSlot 一个答疑时段及其预约情况, 不向学生透露其他预约者
+type OfficeHourSlot struct {
+	Block  model.OfficeHour
+	Start  time.Time
+	End    time.Time
+	Booked bool
+	Mine   bool
+}
+
+// GetOfficeHourSlots 获取教师在[from, to)内尚未开始的答疑时段
+func (o *OfficeHour) GetOfficeHourSlots(studentID string, teacherID int64, from time.Time, to time.Time, now time.Time) ([]OfficeHourSlot, error) {
+	if err := checkStudentOfTeacher(model.DB, studentID, teacherID); err != nil {
+		return nil, err
+	}
+	if from.Before(now) {
+		from = now
+	}
+	if !to.After(from) {
+		return []OfficeHourSlot{}, nil
+	}
+	if to.Sub(from) > maxOfficeHourRange {
+		return nil, errors.New("一次最多查询62天的答疑时段")
+	}
+	var blocks []model.OfficeHour
+	if err := model.DB.Where("teacher_id = ? AND from_date <= ? AND to_date >= ?",
+		teacherID, to.Format("2006-01-02"), from.Format("2006-01-02")).
+		Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	var bookings []model.OfficeHourBooking
+	if err := model.DB.Where("teacher_id = ? AND start_time >= ? AND start_time < ?", teacherID, from, to).
+		Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	type slotKey struct {
+		blockID int64
+		start   int64
+	}
+	booked := make(map[slotKey]string, len(bookings))
+	for _, booking := range bookings {
+		booked[slotKey{booking.OfficeHourID, booking.StartTime.Unix()}] = booking.StudentID
+	}
+	slots := []OfficeHourSlot{}
+	for _, block := range blocks {
+		for _, slot := range officeHourSlots(block, from, to) {
+			bookedBy, ok := booked[slotKey{block.ID, slot.StartTime.Unix()}]
+			slots = append(slots, OfficeHourSlot{
+				Block:  block,
+				Start:  slot.StartTime,
+				End:    slot.EndTime,
+				Booked: ok,
+				Mine:   ok && bookedBy == studentID,
+			})
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+	return slots, nil
+}
+
+// BookOfficeHour 学生预约一个答疑时段, 时段不能与学生的课程或其他答疑预约冲突
+func (o *OfficeHour) BookOfficeHour(studentID string, blockID int64, start time.Time, note string, now time.Time) (*model.OfficeHourBooking, error) {
+	var booking *model.OfficeHourBooking
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var block model.OfficeHour
+	if err := tx.First(&block, blockID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("答疑时间段不存在")
+		}
+		return nil, err
+	}
+	slots := officeHourSlots(block, start, start.Add(time.Minute))
+	if len(slots) == 0 || !slots[0].StartTime.Equal(start) {
+		tx.Rollback()
+		return nil, errors.New("该时间不是可预约的答疑时段")
+	}
+	slot := slots[0]
+	if !slot.StartTime.After(now) {
+		tx.Rollback()
+		return nil, errors.New("该答疑时段已经开始")
+	}
+	if err := checkStudentOfTeacher(tx, studentID, block.TeacherID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	var count int64
+	if err := tx.Model(&model.OfficeHourBooking{}).Where("office_hour_id = ? AND start_time = ?", blockID, start).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return nil, errors.New("该答疑时段已被预约")
+	}
+	conflict, err := studentTimeConflict(tx, studentID, []model.CourseTime{slot})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if conflict {
+		tx.Rollback()
+		return nil, errors.New("该答疑时段与你的课程时间冲突")
+	}
+	if err := tx.Model(&model.OfficeHourBooking{}).
+		Where("student_id = ? AND start_time < ? AND end_time > ?", studentID, slot.EndTime, slot.StartTime).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return nil, errors.New("该答疑时段与你的其他答疑预约冲突")
+	}
+	// 并发预约同一时段时由唯一索引保证只有一人成功
+	booking = &model.OfficeHourBooking{
+		OfficeHourID: blockID,
+		StartTime:    slot.StartTime,
+		EndTime:      slot.EndTime,
+		TeacherID:    block.TeacherID,
+		StudentID:    studentID,
+		Note:         note,
+	}
+	if err := tx.Create(booking).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+// CancelOfficeHourBooking 学生取消自己尚未开始的答疑预约
+func (o *OfficeHour) CancelOfficeHourBooking(studentID string, bookingID int64, now time.Time) error {
+	var booking model.OfficeHourBooking
+	if err := model.DB.Where("student_id = ?", studentID).First(&booking, bookingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("预约不存在")
+		}
+		return err
+	}
+	if !booking.StartTime.After(now) {
+		return errors.New("答疑已经开始, 不能取消")
+	}
+	return model.DB.Unscoped().Delete(&booking).Error
+}
+
+// OfficeHourBookingDetail 答疑预约及其时间段、教师和学生信息
+type OfficeHourBookingDetail struct {
+	Booking     model.OfficeHourBooking
+	Block       model.OfficeHour
+	TeacherName string
+	StudentName string
+}
+
+// GetOfficeHourBookings 获取从from开始的答疑预约, teacherID不为0时按教师查询, 否则按学生查询
+func (o *OfficeHour) GetOfficeHourBookings(teacherID int64, studentID string, from time.Time) ([]OfficeHourBookingDetail, error) {
+	query := model.DB.Where("end_time > ?", from)
+	if teacherID != 0 {
+		query = query.Where("teacher_id = ?", teacherID)
+	} else {
+		query = query.Where("student_id = ?", studentID)
+	}
+	var bookings []model.OfficeHourBooking
+	if err := query.Order("start_time").Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	details := []OfficeHourBookingDetail{}
+	if len(bookings) == 0 {
+		return details, nil
+	}
+	var blockIDs, teacherIDs []int64
+	var studentIDs []string
+	for _, booking := range bookings {
+		blockIDs = append(blockIDs, booking.OfficeHourID)
+		teacherIDs = append(teacherIDs, booking.TeacherID)
+		studentIDs = append(studentIDs, booking.StudentID)
+	}
+	var blocks []model.OfficeHour
+	if err := model.DB.Unscoped().Where("id IN ?", blockIDs).Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	blockMap := make(map[int64]model.OfficeHour, len(blocks))
+	for _, block := range blocks {
+		blockMap[block.ID] = block
+	}
+	var teachers []model.Teacher
+	if err := model.DB.Unscoped().Where("id IN ?", teacherIDs).Find(&teachers).Error; err != nil {
+		return nil, err
+	}
+	teacherNames := make(map[int64]string, len(teachers))
+	for _, teacher := range teachers {
+		teacherNames[teacher.ID] = teacher.Name
+	}
+	var students []model.User
+	if err := model.DB.Where("user_id IN ?", studentIDs).Find(&students).Error; err != nil {
+		return nil, err
+	}
+	studentNames := make(map[string]string, len(students))
+	for _, student := range students {
+		studentNames[student.UserID] = student.UserName
+	}
+	for _, booking := range bookings {
+		details = append(details, OfficeHourBookingDetail{
+			Booking:     booking,
+			Block:       blockMap[booking.OfficeHourID],
+			TeacherName: teacherNames[booking.TeacherID],
+			StudentName: studentNames[booking.StudentID],
+		})
+	}
+	return details, nil
+}
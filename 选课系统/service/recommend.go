@@ -0,0 +1,189 @@
+package service
+
+import (
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 推荐排序中各项因素的权重
+const (
+	recommendRequirementWeight = 3.0 // 可以弥补培养方案缺口
+	recommendPopularityWeight  = 2.0 // 相似同学中选这门课的比例
+	recommendSeatWeight        = 1.0 // 剩余名额占容量的比例
+)
+
+// Recommendation 一门推荐课程及推荐理由
+type Recommendation struct {
+	Course    model.Course
+	Score     float64
+	Remaining int
+	Reasons   []string
+}
+
+// RecommendCourses 为学生推荐课程, 只考虑与现有课表不冲突、仍有余量且还有未上课次的课程
+// 按培养方案缺口、相似同学(与该学生同选过课程的同学)中的选课比例和剩余名额排序; major为空时不考虑培养方案
+func (us *User) RecommendCourses(studentID string, major string, entryYear int, limit int, now time.Time) ([]Recommendation, error) {
+	var gradedIDs, enrolledIDs []int64
+	if err := model.DB.Model(&model.Grade{}).Where("student_id = ?", studentID).Pluck("course_id", &gradedIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := model.DB.Model(&model.CourseStudent{}).Where("student_id = ?", studentID).Pluck("course_id", &enrolledIDs).Error; err != nil {
+		return nil, err
+	}
+	taken := make(map[int64]bool, len(gradedIDs)+len(enrolledIDs))
+	for _, courseID := range gradedIDs {
+		taken[courseID] = true
+	}
+	for _, courseID := range enrolledIDs {
+		taken[courseID] = true
+	}
+	open, err := openCourses(model.DB, taken)
+	if err != nil {
+		return nil, err
+	}
+	// 已及格或正在修读的课程, 其他学期或班级的开课也不再推荐
+	completed, err := completedCourseIDs(model.DB, studentID)
+	if err != nil {
+		return nil, err
+	}
+	heldIDs := append([]int64{}, enrolledIDs...)
+	for courseID := range completed {
+		heldIDs = append(heldIDs, courseID)
+	}
+	if len(heldIDs) > 0 {
+		var heldCodes []string
+		if err := model.DB.Unscoped().Model(&model.Course{}).Where("course_id IN ? AND course_code != ''", heldIDs).
+			Distinct().Pluck("course_code", &heldCodes).Error; err != nil {
+			return nil, err
+		}
+		held := make(map[string]bool, len(heldCodes))
+		for _, code := range heldCodes {
+			held[code] = true
+		}
+		for courseID, course := range open {
+			if held[course.CourseCode] {
+				delete(open, courseID)
+			}
+		}
+	}
+	var schedule []model.Course
+	if err := model.DB.Preload("CourseTimes").
+		Joins("JOIN course_student ON course_student.course_id = course.course_id").
+		Where("course_student.student_id = ?", studentID).
+		Find(&schedule).Error; err != nil {
+		return nil, err
+	}
+	var busy []model.CourseTime
+	for _, course := range schedule {
+		busy = append(busy, course.CourseTimes...)
+	}
+
+	// 培养方案中尚有缺口的要求: 必修和选修组按课程代码匹配当前的开课, 学分要求按类别匹配
+	gapCourses := make(map[int64][]string)
+	gapCategories := make(map[string][]string)
+	if major != "" {
+		degree := Degree{}
+		audit, err := degree.AuditDegree(studentID, major, entryYear)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range audit.Requirements {
+			if item.Status != AuditMissing {
+				continue
+			}
+			if item.Requirement.Kind == RequirementCredits {
+				gapCategories[item.Requirement.Category] = append(gapCategories[item.Requirement.Category], item.Requirement.Title)
+				continue
+			}
+			for _, course := range item.Suggestions {
+				gapCourses[course.CourseID] = append(gapCourses[course.CourseID], item.Requirement.Title)
+			}
+		}
+	}
+
+	// 相似同学在各门课程中的人数
+	takenIDs := make([]int64, 0, len(taken))
+	for courseID := range taken {
+		takenIDs = append(takenIDs, courseID)
+	}
+	var peers []string
+	if len(takenIDs) > 0 {
+		if err := model.DB.Model(&model.CourseStudent{}).
+			Where("course_id IN ? AND student_id != ?", takenIDs, studentID).
+			Distinct().Pluck("student_id", &peers).Error; err != nil {
+			return nil, err
+		}
+	}
+	peerCounts := make(map[int64]int)
+	if len(peers) > 0 {
+		var rows []struct {
+			CourseID int64
+			Total    int
+		}
+		if err := model.DB.Model(&model.CourseStudent{}).
+			Select("course_id, COUNT(DISTINCT student_id) AS total").
+			Where("student_id IN ?", peers).
+			Group("course_id").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			peerCounts[row.CourseID] = row.Total
+		}
+	}
+	courseIDs := make([]int64, 0, len(open))
+	for courseID := range open {
+		courseIDs = append(courseIDs, courseID)
+	}
+	counts, err := enrollmentCounts(model.DB, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := []Recommendation{}
+	for _, course := range open {
+		upcoming := false
+		for _, courseTime := range course.CourseTimes {
+			if courseTime.EndTime.After(now) {
+				upcoming = true
+				break
+			}
+		}
+		if !upcoming || timesOverlap(busy, course.CourseTimes) {
+			continue
+		}
+		remaining := course.Capacity - counts[course.CourseID]
+		item := Recommendation{Course: course, Remaining: remaining}
+		titles := gapCourses[course.CourseID]
+		if course.Category != "" {
+			titles = append(titles, gapCategories[course.Category]...)
+		}
+		if len(titles) > 0 {
+			item.Score += recommendRequirementWeight
+			for _, title := range titles {
+				item.Reasons = append(item.Reasons, fmt.Sprintf("可满足培养方案要求「%s」", title))
+			}
+		}
+		if peerCount := peerCounts[course.CourseID]; peerCount > 0 {
+			item.Score += recommendPopularityWeight * float64(peerCount) / float64(len(peers))
+			item.Reasons = append(item.Reasons, fmt.Sprintf("和你选过同一门课的%d名同学中有%d人选了这门课", len(peers), peerCount))
+		}
+		if course.Capacity > 0 {
+			item.Score += recommendSeatWeight * float64(remaining) / float64(course.Capacity)
+		}
+		item.Reasons = append(item.Reasons, fmt.Sprintf("与你现有课表没有时间冲突, 剩余名额%d/%d", remaining, course.Capacity))
+		recommendations = append(recommendations, item)
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Course.CourseID < recommendations[j].Course.CourseID
+	})
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations, nil
+}
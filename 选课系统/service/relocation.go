@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RelocationOfferDetail 调剂名额及替代课程的当前信息
+type RelocationOfferDetail struct {
+	Offer    model.RelocationOffer
+	Course   model.Course
+	Enrolled int
+}
+
+// GetRelocationOffers 获取学生的调剂名额, 最新的在前
+func (us *User) GetRelocationOffers(studentID string) ([]RelocationOfferDetail, error) {
+	var offers []model.RelocationOffer
+	if err := model.DB.Where("student_id = ?", studentID).Order("id DESC").Find(&offers).Error; err != nil {
+		return nil, err
+	}
+	details := []RelocationOfferDetail{}
+	if len(offers) == 0 {
+		return details, nil
+	}
+	courseIDs := make([]int64, 0, len(offers))
+	for _, offer := range offers {
+		courseIDs = append(courseIDs, offer.CourseID)
+	}
+	var courses []model.Course
+	if err := model.DB.Unscoped().Preload("CourseTimes").Where("course_id IN ?", courseIDs).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	courseMap := make(map[int64]model.Course, len(courses))
+	for _, course := range courses {
+		courseMap[course.CourseID] = course
+	}
+	counts, err := enrollmentCounts(model.DB, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, offer := range offers {
+		details = append(details, RelocationOfferDetail{Offer: offer, Course: courseMap[offer.CourseID], Enrolled: counts[offer.CourseID]})
+	}
+	return details, nil
+}
+
+// RespondRelocationOffer 接受或拒绝调剂名额, 接受时锁住替代课程并按抢课的规则检查容量和时间冲突后选入
+func (us *User) RespondRelocationOffer(studentID string, offerID int64, accept bool) (*model.RelocationOffer, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var offer model.RelocationOffer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND student_id = ?", offerID, studentID).First(&offer).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("调剂名额不存在")
+		}
+		return nil, err
+	}
+	if offer.Status != model.RelocationOfferPending {
+		tx.Rollback()
+		return nil, errors.New("该调剂名额已处理或已失效")
+	}
+	status := model.RelocationOfferDeclined
+	if accept {
+		var course model.Course
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("CourseTimes").
+			Where("course_id = ?", offer.CourseID).First(&course).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("替代课程已停开")
+			}
+			return nil, err
+		}
+		counts, err := enrollmentCounts(tx, []int64{course.CourseID})
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := checkGrab(tx, studentID, course, counts[course.CourseID], nil); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Create(&model.CourseStudent{StudentID: studentID, CourseID: course.CourseID}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		status = model.RelocationOfferAccepted
+	}
+	if err := tx.Model(&offer).Update("status", status).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
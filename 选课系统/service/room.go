@@ -0,0 +1,364 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Room struct{}
+
+// AddRoom 添加教室
+func (r *Room) AddRoom(room *model.Room) error {
+	var count int64
+	if err := model.DB.Model(&model.Room{}).Where("building = ? AND name = ?", room.Building, room.Name).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该教室已存在")
+	}
+	return model.DB.Create(room).Error
+}
+
+// UpdateRoom 更新教室信息, 座位数不能少于在该教室上课的课程容量
+func (r *Room) UpdateRoom(room *model.Room) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var existing model.Room
+	if err := tx.First(&existing, room.ID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("教室不存在")
+		}
+		return err
+	}
+	var count int64
+	if err := tx.Model(&model.Room{}).Where("building = ? AND name = ? AND id != ?", room.Building, room.Name, room.ID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return errors.New("该教室已存在")
+	}
+	var maxCapacity int
+	if err := tx.Model(&model.Course{}).
+		Where("room_id = ? OR course_id IN (SELECT course_id FROM course_time WHERE course_time.room_id = ?)", room.ID, room.ID).
+		Select("COALESCE(MAX(capacity), 0)").Scan(&maxCapacity).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if room.Seats < maxCapacity {
+		tx.Rollback()
+		return errors.New("座位数少于在该教室上课的课程容量")
+	}
+	room.BaseModel = existing.BaseModel
+	if err := tx.Save(room).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&model.Course{}).Where("room_id = ?", room.ID).Update("location", room.Label()).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&model.CourseTime{}).Where("room_id = ?", room.ID).Update("location", room.Label()).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// DeleteRoom 删除教室, 仍有课程使用的教室不能删除
+func (r *Room) DeleteRoom(roomID int64) error {
+	var count int64
+	if err := model.DB.Model(&model.Course{}).
+		Where("room_id = ? OR course_id IN (SELECT course_id FROM course_time WHERE course_time.room_id = ?)", roomID, roomID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("仍有课程在该教室上课, 不能删除")
+	}
+	if err := model.DB.Model(&model.RoomBookingTime{}).
+		Joins("JOIN room_booking ON room_booking.id = room_booking_time.booking_id AND room_booking.deleted_at IS NULL").
+		Where("room_booking_time.room_id = ? AND room_booking_time.end_time > ?", roomID, time.Now()).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该教室还有未结束的预订, 不能删除")
+	}
+	result := model.DB.Unscoped().Delete(&model.Room{}, roomID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("教室不存在")
+	}
+	return nil
+}
+
+// GetRooms 查询教室
+func (r *Room) GetRooms(page int, limit int, building string, minSeats int) ([]model.Room, int, error) {
+	var rooms []model.Room
+	var total int64
+	query := model.DB.Model(&model.Room{})
+	if building != "" {
+		query = query.Where("building LIKE ?", "%"+building+"%")
+	}
+	if minSeats > 0 {
+		query = query.Where("seats >= ?", minSeats)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("building, name").Limit(limit).Offset((page - 1) * limit).Find(&rooms).Error; err != nil {
+		return nil, 0, err
+	}
+	return rooms, int(total), nil
+}
+
+// GetRoom 获取教室详情
+func (r *Room) GetRoom(roomID int64) (*model.Room, error) {
+	var room model.Room
+	if err := model.DB.First(&room, roomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("教室不存在")
+		}
+		return nil, err
+	}
+	return &room, nil
+}
+
+// findCourseRoom 获取课程使用的教室并检查座位数是否容纳课程容量
+func findCourseRoom(tx *gorm.DB, roomID int64, capacity int) (*model.Room, error) {
+	var room model.Room
+	if err := tx.First(&room, roomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("教室不存在")
+		}
+		return nil, err
+	}
+	if capacity > room.Seats {
+		return nil, errors.New("课程容量超过了教室座位数")
+	}
+	return &room, nil
+}
+
+// prepareSessions 检查每次上课单独指定的教室或线上会议链接, 并填入教室名称;
+// 线上上课不能同时指定教室, 线下上课会清空会议链接
+func prepareSessions(tx *gorm.DB, times []model.CourseTime, capacity int) error {
+	for i := range times {
+		session := &times[i]
+		if session.Online {
+			if session.RoomID != nil {
+				return errors.New("线上上课不能同时指定教室")
+			}
+			if session.MeetingLink == "" {
+				return errors.New("线上上课须提供会议链接")
+			}
+			session.Location = ""
+			continue
+		}
+		session.MeetingLink = ""
+		session.Location = ""
+		if session.RoomID == nil {
+			continue
+		}
+		room, err := findCourseRoom(tx, *session.RoomID, capacity)
+		if err != nil {
+			return err
+		}
+		session.Location = room.Label()
+	}
+	return nil
+}
+
+// needsCourseRoom 判断是否有线下上课没有单独指定教室, 需要使用课程的教室
+func needsCourseRoom(times []model.CourseTime) bool {
+	for _, courseTime := range times {
+		if !courseTime.Online && courseTime.RoomID == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionRoomColumn 每次上课实际使用的教室: 单独指定了教室的用该教室, 否则用课程的教室
+const sessionRoomColumn = "COALESCE(course_time.room_id, course.room_id)"
+
+// roomOccupancy 查询与[start, end)重叠的线下上课时间, 教室冲突检查和空闲教室查询共用这份占用数据
+func roomOccupancy(db *gorm.DB, start time.Time, end time.Time) *gorm.DB {
+	return db.Model(&model.CourseTime{}).
+		Joins("JOIN course ON course.course_id = course_time.course_id AND course.deleted_at IS NULL").
+		Where("course_time.online = ? AND "+sessionRoomColumn+" IS NOT NULL", false).
+		Where("course_time.start_time < ? AND course_time.end_time > ?", end, start)
+}
+
+// bookingOccupancy 查询与[start, end)重叠的教室预订时间
+func bookingOccupancy(db *gorm.DB, start time.Time, end time.Time) *gorm.DB {
+	return db.Model(&model.RoomBookingTime{}).
+		Joins("JOIN room_booking ON room_booking.id = room_booking_time.booking_id AND room_booking.deleted_at IS NULL").
+		Where("room_booking_time.start_time < ? AND room_booking_time.end_time > ?", end, start)
+}
+
+// roomConflict 检查times中每次线下上课使用的教室是否已被其他课程或教室预订占用,
+// 没有单独指定教室的上课时间使用课程的教室roomID
+func roomConflict(tx *gorm.DB, roomID int64, excludeCourseID int64, times []model.CourseTime) (bool, error) {
+	for _, courseTime := range times {
+		if courseTime.Online {
+			continue
+		}
+		sessionRoomID := roomID
+		if courseTime.RoomID != nil {
+			sessionRoomID = *courseTime.RoomID
+		}
+		var count int64
+		if err := roomOccupancy(tx, courseTime.StartTime, courseTime.EndTime).
+			Where(sessionRoomColumn+" = ? AND course.course_id != ?", sessionRoomID, excludeCourseID).
+			Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+		if err := bookingOccupancy(tx, courseTime.StartTime, courseTime.EndTime).
+			Where("room_booking_time.room_id = ?", sessionRoomID).
+			Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// busyRoomIDs 返回在times中任一时间段被课程或教室预订占用的教室
+func busyRoomIDs(db *gorm.DB, times []model.CourseTime) (map[int64]bool, error) {
+	busy := make(map[int64]bool)
+	for _, courseTime := range times {
+		var roomIDs, bookedIDs []int64
+		if err := roomOccupancy(db, courseTime.StartTime, courseTime.EndTime).
+			Distinct().Pluck(sessionRoomColumn, &roomIDs).Error; err != nil {
+			return nil, err
+		}
+		if err := bookingOccupancy(db, courseTime.StartTime, courseTime.EndTime).
+			Distinct().Pluck("room_booking_time.room_id", &bookedIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, roomID := range append(roomIDs, bookedIDs...) {
+			busy[roomID] = true
+		}
+	}
+	return busy, nil
+}
+
+type RoomRequirement struct {
+	MinSeats   int
+	Projector  bool
+	Lab        bool
+	Accessible bool
+}
+
+type FreeRoom struct {
+	Room    model.Room
+	Surplus int
+}
+
+// 按周重复查询时最多展开的次数, 避免一次查询过大的时间范围
+const maxRecurrence = 60
+
+// WeeklyTimes 展开[from, to]日期范围内每周weekday的startClock至endClock时间段
+func WeeklyTimes(from time.Time, to time.Time, weekday time.Weekday, startClock time.Duration, endClock time.Duration) ([]model.CourseTime, error) {
+	if endClock <= startClock {
+		return nil, errors.New("结束时刻必须晚于开始时刻")
+	}
+	var times []model.CourseTime
+	day := dateOf(from)
+	day = day.AddDate(0, 0, (int(weekday)-int(day.Weekday())+7)%7)
+	for ; !day.After(to); day = day.AddDate(0, 0, 7) {
+		if len(times) >= maxRecurrence {
+			return nil, fmt.Errorf("重复次数不能超过%d次", maxRecurrence)
+		}
+		times = append(times, model.CourseTime{StartTime: day.Add(startClock), EndTime: day.Add(endClock)})
+	}
+	if len(times) == 0 {
+		return nil, errors.New("日期范围内没有符合条件的时间段")
+	}
+	return times, nil
+}
+
+// FindFreeRooms 查找在所有时间段内都空闲且满足座位数和设施要求的教室, 排序规则见rankRooms
+func (r *Room) FindFreeRooms(times []model.CourseTime, req RoomRequirement) ([]FreeRoom, error) {
+	query := model.DB.Where("seats >= ?", req.MinSeats)
+	if req.Projector {
+		query = query.Where("projector = ?", true)
+	}
+	if req.Lab {
+		query = query.Where("lab = ?", true)
+	}
+	if req.Accessible {
+		query = query.Where("accessible = ?", true)
+	}
+	var rooms []model.Room
+	if err := query.Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	busy, err := busyRoomIDs(model.DB, times)
+	if err != nil {
+		return nil, err
+	}
+	var free []model.Room
+	for _, room := range rooms {
+		if !busy[room.ID] {
+			free = append(free, room)
+		}
+	}
+	return rankRooms(free, req), nil
+}
+
+// rankRooms 筛选满足要求的教室, 按多余座位数从少到多排序, 其次优先没有多余特殊设施的教室
+func rankRooms(rooms []model.Room, req RoomRequirement) []FreeRoom {
+	ranked := []FreeRoom{}
+	for _, room := range rooms {
+		if room.Seats < req.MinSeats || (req.Projector && !room.Projector) ||
+			(req.Lab && !room.Lab) || (req.Accessible && !room.Accessible) {
+			continue
+		}
+		ranked = append(ranked, FreeRoom{Room: room, Surplus: room.Seats - req.MinSeats})
+	}
+	extras := func(room model.Room) int {
+		count := 0
+		for _, extra := range []bool{room.Projector && !req.Projector, room.Lab && !req.Lab, room.Accessible && !req.Accessible} {
+			if extra {
+				count++
+			}
+		}
+		return count
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Surplus != b.Surplus {
+			return a.Surplus < b.Surplus
+		}
+		if extras(a.Room) != extras(b.Room) {
+			return extras(a.Room) < extras(b.Room)
+		}
+		if a.Room.Building != b.Room.Building {
+			return a.Room.Building < b.Room.Building
+		}
+		return a.Room.Name < b.Room.Name
+	})
+	return ranked
+}
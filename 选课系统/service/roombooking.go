@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BookRoom 预订教室, booking.Times为每一次使用的时间, 与课程或其他预订冲突时预订失败
+func (r *Room) BookRoom(booking *model.RoomBooking) error {
+	switch booking.Kind {
+	case model.BookingSeminar, model.BookingExam, model.BookingClub, model.BookingOther:
+	default:
+		return errors.New("无效的活动类型")
+	}
+	if len(booking.Times) == 0 {
+		return errors.New("请指定预订时间")
+	}
+	sort.Slice(booking.Times, func(i, j int) bool {
+		return booking.Times[i].StartTime.Before(booking.Times[j].StartTime)
+	})
+	times := make([]model.CourseTime, 0, len(booking.Times))
+	for i := range booking.Times {
+		bookingTime := &booking.Times[i]
+		if !bookingTime.EndTime.After(bookingTime.StartTime) {
+			return errors.New("结束时间必须晚于开始时间")
+		}
+		if i > 0 && bookingTime.StartTime.Before(booking.Times[i-1].EndTime) {
+			return errors.New("预订的时间段之间有重叠")
+		}
+		bookingTime.RoomID = booking.RoomID
+		times = append(times, model.CourseTime{StartTime: bookingTime.StartTime, EndTime: bookingTime.EndTime})
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var room model.Room
+	if err := tx.First(&room, booking.RoomID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("教室不存在")
+		}
+		return err
+	}
+	conflict, err := roomConflict(tx, room.ID, 0, times)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if conflict {
+		tx.Rollback()
+		return errors.New("该教室在指定时间内已被课程或其他活动占用")
+	}
+	if err := tx.Create(booking).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetRoomBookings 查询教室预订, roomID和teacherID为0时不限; from和to不为零时只返回有使用时间落在[from, to)内的预订
+func (r *Room) GetRoomBookings(roomID int64, teacherID int64, from time.Time, to time.Time) ([]model.RoomBooking, error) {
+	var bookings []model.RoomBooking
+	query := model.DB.Model(&model.RoomBooking{})
+	if roomID > 0 {
+		query = query.Where("room_id = ?", roomID)
+	}
+	if teacherID > 0 {
+		query = query.Where("teacher_id = ?", teacherID)
+	}
+	if !from.IsZero() && !to.IsZero() {
+		query = query.Where("id IN (SELECT booking_id FROM room_booking_time WHERE start_time < ? AND end_time > ?)", to, from)
+	}
+	err := query.Preload("Times", func(db *gorm.DB) *gorm.DB {
+		return db.Order("start_time")
+	}).Order("id DESC").Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+// CancelRoomBooking 取消教室预订, teacherID不为0时只能取消该教师自己的预订
+func (r *Room) CancelRoomBooking(bookingID int64, teacherID int64) error {
+	query := model.DB.Where("id = ?", bookingID)
+	if teacherID > 0 {
+		query = query.Where("teacher_id = ?", teacherID)
+	}
+	result := query.Delete(&model.RoomBooking{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("预订不存在")
+	}
+	return nil
+}
+
+// 教室日历中的占用类型
+const (
+	RoomUseCourse  = "course"
+	RoomUseBooking = "booking"
+)
+
+type RoomCalendarEntry struct {
+	Kind        string // RoomUseCourse或RoomUseBooking
+	ID          int64  // 课程ID或预订ID
+	Title       string
+	BookingKind string
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// GetRoomCalendar 合并教室在[start, end)内的课程和预订, 按开始时间排序
+func (r *Room) GetRoomCalendar(roomID int64, start time.Time, end time.Time) (*model.Room, []RoomCalendarEntry, error) {
+	room, err := r.GetRoom(roomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := []RoomCalendarEntry{}
+	var sessions []struct {
+		CourseID   int64
+		CourseName string
+		StartTime  time.Time
+		EndTime    time.Time
+	}
+	if err := roomOccupancy(model.DB, start, end).
+		Select("course.course_id, course.course_name, course_time.start_time, course_time.end_time").
+		Where(sessionRoomColumn+" = ?", roomID).
+		Scan(&sessions).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, session := range sessions {
+		entries = append(entries, RoomCalendarEntry{
+			Kind:      RoomUseCourse,
+			ID:        session.CourseID,
+			Title:     session.CourseName,
+			StartTime: session.StartTime,
+			EndTime:   session.EndTime,
+		})
+	}
+	var bookings []struct {
+		BookingID int64
+		Title     string
+		Kind      string
+		StartTime time.Time
+		EndTime   time.Time
+	}
+	if err := bookingOccupancy(model.DB, start, end).
+		Select("room_booking_time.booking_id, room_booking.title, room_booking.kind, room_booking_time.start_time, room_booking_time.end_time").
+		Where("room_booking_time.room_id = ?", roomID).
+		Scan(&bookings).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, booking := range bookings {
+		entries = append(entries, RoomCalendarEntry{
+			Kind:        RoomUseBooking,
+			ID:          booking.BookingID,
+			Title:       booking.Title,
+			BookingKind: booking.Kind,
+			StartTime:   booking.StartTime,
+			EndTime:     booking.EndTime,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartTime.Before(entries[j].StartTime)
+	})
+	return room, entries, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"finaltenzor/config"
+	"finaltenzor/model"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 课表网格每天的起止时刻，空闲时段只在这个范围内计算
+const (
+	scheduleDayBegin = 8 * time.Hour
+	scheduleDayEnd   = 22 * time.Hour
+)
+
+type ScheduleSession struct {
+	CourseID    int64
+	CourseName  string
+	Location    string
+	RoomID      *int64
+	Online      bool
+	MeetingLink string
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+type ScheduleBlock struct {
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+type ScheduleDay struct {
+	Date       time.Time
+	Holiday    string
+	MakeUp     bool
+	Sessions   []ScheduleSession
+	Hours      float64
+	Earliest   *time.Time
+	Latest     *time.Time
+	FreeBlocks []ScheduleBlock
+}
+
+type WeekSchedule struct {
+	Week       int
+	StartDate  time.Time
+	EndDate    time.Time
+	TotalHours float64
+	Days       []ScheduleDay
+}
+
+// dateOf 取某一时刻所在日期的零点
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// WeekOf 返回某日期所在周的周一零点与下周一零点
+func WeekOf(date time.Time) (time.Time, time.Time) {
+	day := dateOf(date)
+	offset := (int(day.Weekday()) + 6) % 7
+	start := day.AddDate(0, 0, -offset)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// TermWeekRange 计算当前学期第week周的起止时间
+func TermWeekRange(week int) (time.Time, time.Time, error) {
+	termStart, err := termStartFor(time.Now().In(config.Config.Location))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start, _ := WeekOf(termStart)
+	start = start.AddDate(0, 0, (week-1)*7)
+	return start, start.AddDate(0, 0, 7), nil
+}
+
+// termWeekOf 计算某日期是学期的第几周，找不到学期或在学期开始之前时返回0
+func termWeekOf(date time.Time) int {
+	termStart, err := termStartFor(date)
+	if err != nil {
+		return 0
+	}
+	first, _ := WeekOf(termStart)
+	if date.Before(first) {
+		return 0
+	}
+	return int(dateOf(date).Sub(first).Hours()/24)/7 + 1
+}
+
+// buildWeekSchedule 把时间段内的课程按天排成网格并计算每日统计
+func buildWeekSchedule(sessions []ScheduleSession, calendar *calendarView, start time.Time, end time.Time) *WeekSchedule {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.Before(sessions[j].StartTime)
+	})
+	schedule := &WeekSchedule{
+		Week:      termWeekOf(start),
+		StartDate: start,
+		EndDate:   end.AddDate(0, 0, -1),
+	}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		calendarDay := calendar.day(day)
+		scheduleDay := ScheduleDay{
+			Date:       day,
+			Holiday:    calendarDay.Holiday,
+			MakeUp:     calendarDay.MakeUp,
+			Sessions:   []ScheduleSession{},
+			FreeBlocks: []ScheduleBlock{},
+		}
+		for _, session := range sessions {
+			if session.StartTime.Before(day) || !session.StartTime.Before(next) {
+				continue
+			}
+			scheduleDay.Sessions = append(scheduleDay.Sessions, session)
+			scheduleDay.Hours += session.EndTime.Sub(session.StartTime).Hours()
+		}
+		if len(scheduleDay.Sessions) > 0 {
+			earliest := scheduleDay.Sessions[0].StartTime
+			latest := scheduleDay.Sessions[0].EndTime
+			for _, session := range scheduleDay.Sessions {
+				if session.EndTime.After(latest) {
+					latest = session.EndTime
+				}
+			}
+			scheduleDay.Earliest = &earliest
+			scheduleDay.Latest = &latest
+		}
+		scheduleDay.FreeBlocks = freeBlocks(day, scheduleDay.Sessions)
+		schedule.TotalHours += scheduleDay.Hours
+		schedule.Days = append(schedule.Days, scheduleDay)
+	}
+	return schedule
+}
+
+// freeBlocks 计算一天内课表网格范围里没有课程的时间段，sessions须已按开始时间排序
+func freeBlocks(day time.Time, sessions []ScheduleSession) []ScheduleBlock {
+	blocks := []ScheduleBlock{}
+	cursor := day.Add(scheduleDayBegin)
+	dayEnd := day.Add(scheduleDayEnd)
+	for _, session := range sessions {
+		if !cursor.Before(dayEnd) {
+			break
+		}
+		if session.StartTime.After(cursor) {
+			blockEnd := session.StartTime
+			if blockEnd.After(dayEnd) {
+				blockEnd = dayEnd
+			}
+			blocks = append(blocks, ScheduleBlock{StartTime: cursor, EndTime: blockEnd})
+		}
+		if session.EndTime.After(cursor) {
+			cursor = session.EndTime
+		}
+	}
+	if cursor.Before(dayEnd) {
+		blocks = append(blocks, ScheduleBlock{StartTime: cursor, EndTime: dayEnd})
+	}
+	return blocks
+}
+
+// loadWeekSchedule 读取query选出的课程在[start, end)内的上课时间并生成课表
+func loadWeekSchedule(query *gorm.DB, start time.Time, end time.Time) (*WeekSchedule, error) {
+	var courses []model.Course
+	err := query.Preload("CourseTimes", func(db *gorm.DB) *gorm.DB {
+		return db.Where("start_time >= ? AND start_time < ?", start, end).Order("start_time")
+	}).Find(&courses).Error
+	if err != nil {
+		return nil, err
+	}
+	var sessions []ScheduleSession
+	for _, course := range courses {
+		for _, courseTime := range course.CourseTimes {
+			sessions = append(sessions, ScheduleSession{
+				CourseID:    course.CourseID,
+				CourseName:  course.CourseName,
+				Location:    course.SessionLocation(courseTime),
+				RoomID:      course.SessionRoomID(courseTime),
+				Online:      courseTime.Online,
+				MeetingLink: courseTime.MeetingLink,
+				StartTime:   courseTime.StartTime,
+				EndTime:     courseTime.EndTime,
+			})
+		}
+	}
+	calendar, err := loadCalendar(model.DB, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return buildWeekSchedule(sessions, calendar, start, end), nil
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	scheduleBuilderMaxCandidates = 12 // 候选课程上限, 组合数随候选数指数增长
+	scheduleBuilderMaxOptions    = 20 // 最多返回的组合数
+)
+
+// ScheduleCandidate 排课助手的一门候选课程, MustHave为true时每个组合都必须包含
+type ScheduleCandidate struct {
+	CourseID int64
+	MustHave bool
+}
+
+// SchedulePreferences 排课偏好, 不满足偏好的组合仍会返回但排名靠后
+type SchedulePreferences struct {
+	EarliestStart string // 不早于该时刻上课, HH:MM, 为空表示不限
+	FreeWeekdays  []int  // 希望空出的星期, 1-7
+}
+
+// ScheduleExclusion 无法加入任何组合的候选课程及原因
+type ScheduleExclusion struct {
+	CourseID int64
+	Reason   string
+}
+
+// ScheduleOption 一个互不冲突的选课组合, Notes说明其中不满足偏好的课程
+type ScheduleOption struct {
+	Courses []model.Course
+	Score   float64
+	Credits float64
+	Notes   []string
+}
+
+// preferenceNotes 返回课程不满足偏好的说明, 为空表示满足全部偏好
+func preferenceNotes(course model.Course, earliest time.Duration, freeWeekdays map[int]bool) []string {
+	early, onFreeDay := 0, make(map[int]int)
+	for _, courseTime := range course.CourseTimes {
+		start := courseTime.StartTime
+		if earliest > 0 && start.Sub(dateOf(start)) < earliest {
+			early++
+		}
+		if weekday := isoWeekday(start); freeWeekdays[weekday] {
+			onFreeDay[weekday]++
+		}
+	}
+	var notes []string
+	if early > 0 {
+		notes = append(notes, fmt.Sprintf("%s有%d次课开始得太早", course.CourseName, early))
+	}
+	for weekday := 1; weekday <= 7; weekday++ {
+		if onFreeDay[weekday] > 0 {
+			notes = append(notes, fmt.Sprintf("%s有%d次课在%s", course.CourseName, onFreeDay[weekday], weekdayNames[weekday]))
+		}
+	}
+	return notes
+}
+
+// BuildSchedules 从候选课程中找出与学生现有课表和彼此之间都不冲突、且仍有余量的选课组合
+// 冲突判断与抢课相同; 如果一门课程可以加入组合且不违反偏好, 则不返回缺少它的组合
+// 每门满足偏好的课程计1分, 不满足的计0.5分, 按得分和学分从高到低排序
+func (us *User) BuildSchedules(studentID string, candidates []ScheduleCandidate, preferences SchedulePreferences, limit int) ([]ScheduleOption, []ScheduleExclusion, error) {
+	if len(candidates) == 0 {
+		return nil, nil, errors.New("请选择候选课程")
+	}
+	if len(candidates) > scheduleBuilderMaxCandidates {
+		return nil, nil, fmt.Errorf("候选课程不能超过%d门", scheduleBuilderMaxCandidates)
+	}
+	var earliest time.Duration
+	if preferences.EarliestStart != "" {
+		var err error
+		if earliest, err = common.ParseClock(preferences.EarliestStart); err != nil {
+			return nil, nil, err
+		}
+	}
+	freeWeekdays := make(map[int]bool, len(preferences.FreeWeekdays))
+	for _, weekday := range preferences.FreeWeekdays {
+		if weekday < 1 || weekday > 7 {
+			return nil, nil, errors.New("无效的星期")
+		}
+		freeWeekdays[weekday] = true
+	}
+	mustHave := make(map[int64]bool)
+	courseIDs := make([]int64, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.MustHave {
+			mustHave[candidate.CourseID] = true
+		}
+		courseIDs = append(courseIDs, candidate.CourseID)
+	}
+	var courses []model.Course
+	if err := model.DB.Preload("CourseTimes").Where("course_id IN ?", courseIDs).
+		Order("course_id").Find(&courses).Error; err != nil {
+		return nil, nil, err
+	}
+	found := make(map[int64]bool, len(courses))
+	for _, course := range courses {
+		found[course.CourseID] = true
+	}
+	for _, courseID := range courseIDs {
+		if !found[courseID] {
+			return nil, nil, fmt.Errorf("课程%d未找到", courseID)
+		}
+	}
+	var enrolledIDs []int64
+	if err := model.DB.Model(&model.CourseStudent{}).Where("student_id = ?", studentID).
+		Pluck("course_id", &enrolledIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	enrolled := make(map[int64]bool, len(enrolledIDs))
+	for _, courseID := range enrolledIDs {
+		enrolled[courseID] = true
+	}
+	var schedule []model.Course
+	if err := model.DB.Preload("CourseTimes").
+		Joins("JOIN course_student ON course_student.course_id = course.course_id").
+		Where("course_student.student_id = ?", studentID).
+		Find(&schedule).Error; err != nil {
+		return nil, nil, err
+	}
+	var busy []model.CourseTime
+	for _, course := range schedule {
+		busy = append(busy, course.CourseTimes...)
+	}
+	counts, err := enrollmentCounts(model.DB, courseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 先排除单独就无法选择的课程, 必选课程无法选择时直接报错
+	exclusions := []ScheduleExclusion{}
+	var eligible []model.Course
+	for _, course := range courses {
+		reason := ""
+		switch {
+		case enrolled[course.CourseID]:
+			reason = "已经选了这门课程"
+		case counts[course.CourseID] >= course.Capacity:
+			reason = "课程容量已满"
+		case timesOverlap(busy, course.CourseTimes):
+			reason = "与已选课程时间冲突"
+		}
+		if reason == "" {
+			eligible = append(eligible, course)
+			continue
+		}
+		if mustHave[course.CourseID] {
+			return nil, nil, fmt.Errorf("必选课程%s无法选择: %s", course.CourseName, reason)
+		}
+		exclusions = append(exclusions, ScheduleExclusion{CourseID: course.CourseID, Reason: reason})
+	}
+	// 必选课程排在前面, 便于尽早剪枝
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return mustHave[eligible[i].CourseID] && !mustHave[eligible[j].CourseID]
+	})
+	n := len(eligible)
+	conflicts := make([][]bool, n)
+	notes := make([][]string, n)
+	for i := range eligible {
+		conflicts[i] = make([]bool, n)
+		for j := range eligible {
+			conflicts[i][j] = i != j && timesOverlap(eligible[i].CourseTimes, eligible[j].CourseTimes)
+		}
+		notes[i] = preferenceNotes(eligible[i], earliest, freeWeekdays)
+	}
+	for i := range eligible {
+		for j := i + 1; j < n; j++ {
+			if conflicts[i][j] && mustHave[eligible[i].CourseID] && mustHave[eligible[j].CourseID] {
+				return nil, nil, fmt.Errorf("必选课程%s和%s时间冲突", eligible[i].CourseName, eligible[j].CourseName)
+			}
+		}
+	}
+
+	options := []ScheduleOption{}
+	chosen := make([]bool, n)
+	compatible := func(k int) bool {
+		for i := range eligible {
+			if chosen[i] && conflicts[i][k] {
+				return false
+			}
+		}
+		return true
+	}
+	var search func(k int)
+	search = func(k int) {
+		if k == n {
+			option := ScheduleOption{Courses: []model.Course{}, Notes: []string{}}
+			for i := range eligible {
+				// 还能无代价地加入一门课程时, 这个组合不值得推荐
+				if !chosen[i] && compatible(i) && len(notes[i]) == 0 {
+					return
+				}
+			}
+			for i, course := range eligible {
+				if !chosen[i] {
+					continue
+				}
+				option.Courses = append(option.Courses, course)
+				option.Credits += course.Credits
+				if len(notes[i]) == 0 {
+					option.Score++
+				} else {
+					option.Score += 0.5
+					option.Notes = append(option.Notes, notes[i]...)
+				}
+			}
+			if len(option.Courses) > 0 {
+				options = append(options, option)
+			}
+			return
+		}
+		if compatible(k) {
+			chosen[k] = true
+			search(k + 1)
+			chosen[k] = false
+		}
+		if !mustHave[eligible[k].CourseID] {
+			search(k + 1)
+		}
+	}
+	search(0)
+	sort.SliceStable(options, func(i, j int) bool {
+		if options[i].Score != options[j].Score {
+			return options[i].Score > options[j].Score
+		}
+		return options[i].Credits > options[j].Credits
+	})
+	if limit <= 0 || limit > scheduleBuilderMaxOptions {
+		limit = scheduleBuilderMaxOptions
+	}
+	if len(options) > limit {
+		options = options[:limit]
+	}
+	return options, exclusions, nil
+}
+
+// GrabCourses 一次选择多门课程, 按抢课的规则逐门检查, 任何一门不能选时全部不选
+func (us *User) GrabCourses(studentID string, courseIDs []int64) error {
+	if len(courseIDs) == 0 {
+		return errors.New("请选择课程")
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var student model.User
+	if err := tx.Where("user_id = ?", studentID).First(&student).Error; err != nil {
+		tx.Rollback()
+		return errors.New("学生未找到")
+	}
+	// 锁住这些课程, 防止同时选课超出容量
+	var courses []model.Course
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("CourseTimes").
+		Where("course_id IN ?", courseIDs).Order("course_id").Find(&courses).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	courseMap := make(map[int64]model.Course, len(courses))
+	for _, course := range courses {
+		courseMap[course.CourseID] = course
+	}
+	counts, err := enrollmentCounts(tx, courseIDs)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	var picked []model.Course
+	seen := make(map[int64]bool, len(courseIDs))
+	for _, courseID := range courseIDs {
+		if seen[courseID] {
+			continue
+		}
+		seen[courseID] = true
+		course, ok := courseMap[courseID]
+		if !ok {
+			tx.Rollback()
+			return fmt.Errorf("课程%d未找到", courseID)
+		}
+		if err := checkGrab(tx, student.UserID, course, counts[courseID], picked); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", course.CourseName, err)
+		}
+		picked = append(picked, course)
+	}
+	rows := make([]model.CourseStudent, 0, len(picked))
+	for _, course := range picked {
+		rows = append(rows, model.CourseStudent{StudentID: student.UserID, CourseID: course.CourseID})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,621 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+	"finaltenzor/service/swapcycle"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxSeatSwapCycle      = 4              // 多人轮换最多涉及的人数
+	maxActiveSeatSwaps    = 5              // 每名学生同时进行中的换课申请数
+	seatSwapConsentWindow = 48 * time.Hour // 匹配后等待各方确认的时间
+)
+
+// seatSwapCourseNames 获取换课申请涉及的课程名称, 已删除的课程也保留名称
+func seatSwapCourseNames(tx *gorm.DB, swaps []model.SeatSwap) (map[int64]string, error) {
+	courseIDs := make([]int64, 0, len(swaps)*2)
+	for _, swap := range swaps {
+		courseIDs = append(courseIDs, swap.GiveCourseID, swap.WantCourseID)
+	}
+	names := make(map[int64]string, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return names, nil
+	}
+	var courses []model.Course
+	if err := tx.Unscoped().Select("course_id, course_name").Where("course_id IN ?", courseIDs).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	for _, course := range courses {
+		names[course.CourseID] = course.CourseName
+	}
+	return names, nil
+}
+
+// notifySeatSwaps 给每个换课申请的发布者发送通知, message接收让出和换入的课程名称
+func notifySeatSwaps(tx *gorm.DB, swaps []model.SeatSwap, message func(swap model.SeatSwap, give string, want string) string) error {
+	names, err := seatSwapCourseNames(tx, swaps)
+	if err != nil {
+		return err
+	}
+	for _, swap := range swaps {
+		notice := model.Notice{
+			StudentID: swap.StudentID,
+			Kind:      model.NoticeSeatSwap,
+			CourseID:  swap.WantCourseID,
+			Message:   message(swap, names[swap.GiveCourseID], names[swap.WantCourseID]),
+		}
+		if err := tx.Create(&notice).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seatSwapEligible 检查学生此刻能否按申请交换, 返回不能交换的原因, 为空表示可以交换
+// 要求学生仍持有让出的课程且还没有成绩、没有选想要的课程、想要的课程未删除, 且换入后与其余课程时间不冲突
+func seatSwapEligible(tx *gorm.DB, swap model.SeatSwap) (string, error) {
+	var count int64
+	if err := tx.Model(&model.CourseStudent{}).Where("student_id = ? AND course_id = ?", swap.StudentID, swap.GiveCourseID).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "没有选让出的课程", nil
+	}
+	if err := tx.Model(&model.Grade{}).Where("student_id = ? AND course_id = ?", swap.StudentID, swap.GiveCourseID).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "让出的课程已经有成绩", nil
+	}
+	if err := tx.Model(&model.CourseStudent{}).Where("student_id = ? AND course_id = ?", swap.StudentID, swap.WantCourseID).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "已经选了想要的课程", nil
+	}
+	var want model.Course
+	if err := tx.Preload("CourseTimes").Where("course_id = ?", swap.WantCourseID).First(&want).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "想要的课程不存在", nil
+		}
+		return "", err
+	}
+	conflict, err := studentTimeConflict(tx, swap.StudentID, want.CourseTimes, swap.GiveCourseID)
+	if err != nil {
+		return "", err
+	}
+	if conflict {
+		return fmt.Sprintf("换入《%s》后与其他课程时间冲突", want.CourseName), nil
+	}
+	return "", nil
+}
+
+// seatSwapRequest 换课申请在交换环搜索中的表示
+func seatSwapRequest(swap model.SeatSwap) swapcycle.Request {
+	return swapcycle.Request{ID: swap.ID, StudentID: swap.StudentID, Give: swap.GiveCourseID, Want: swap.WantCourseID}
+}
+
+// matchSeatSwap 为一个等待匹配的换课申请寻找交换环, 人数少的环优先, 人数相同时优先较早发布的申请;
+// 找到后各方进入等待确认状态, 返回的匹配为空表示暂无匹配
+func matchSeatSwap(tx *gorm.DB, start model.SeatSwap) (*model.SeatSwapMatch, error) {
+	if reason, err := seatSwapEligible(tx, start); err != nil || reason != "" {
+		return nil, err
+	}
+	// 锁住所有等待匹配的申请, 避免同一申请被同时放进两个匹配
+	var open []model.SeatSwap
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("status = ?", model.SeatSwapOpen).
+		Order("id").Find(&open).Error; err != nil {
+		return nil, err
+	}
+	requests := make([]swapcycle.Request, 0, len(open))
+	byID := make(map[int64]model.SeatSwap, len(open))
+	for _, swap := range open {
+		requests = append(requests, seatSwapRequest(swap))
+		byID[swap.ID] = swap
+	}
+	found, err := swapcycle.Find(seatSwapRequest(start), requests, maxSeatSwapCycle, func(request swapcycle.Request) (bool, error) {
+		reason, err := seatSwapEligible(tx, byID[request.ID])
+		return reason == "", err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, nil
+	}
+	cycle := []model.SeatSwap{start}
+	for _, request := range found[1:] {
+		cycle = append(cycle, byID[request.ID])
+	}
+
+	match := model.SeatSwapMatch{Status: model.SeatSwapMatchPending}
+	if err := tx.Create(&match).Error; err != nil {
+		return nil, err
+	}
+	swapIDs := make([]int64, 0, len(cycle))
+	for _, swap := range cycle {
+		swapIDs = append(swapIDs, swap.ID)
+	}
+	if err := tx.Model(&model.SeatSwap{}).Where("id IN ?", swapIDs).Updates(map[string]interface{}{
+		"status":   model.SeatSwapMatched,
+		"match_id": match.ID,
+		"accepted": false,
+	}).Error; err != nil {
+		return nil, err
+	}
+	err = notifySeatSwaps(tx, cycle, func(swap model.SeatSwap, give string, want string) string {
+		return fmt.Sprintf("你让出《%s》换《%s》的申请已与其他%d位同学匹配, 请在%d小时内确认",
+			give, want, len(cycle)-1, int(seatSwapConsentWindow.Hours()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// cancelSeatSwapMatch 取消匹配, drop返回非空原因的申请随之取消, 其余申请重新等待匹配并立即尝试匹配
+func cancelSeatSwapMatch(tx *gorm.DB, match *model.SeatSwapMatch, reason string, drop func(swap model.SeatSwap) string) error {
+	var swaps []model.SeatSwap
+	if err := tx.Where("match_id = ?", match.ID).Order("id").Find(&swaps).Error; err != nil {
+		return err
+	}
+	match.Status = model.SeatSwapMatchCancelled
+	match.Reason = reason
+	if err := tx.Model(match).Updates(map[string]interface{}{"status": match.Status, "reason": match.Reason}).Error; err != nil {
+		return err
+	}
+	var dropped, reopened []model.SeatSwap
+	dropReasons := make(map[int64]string)
+	for _, swap := range swaps {
+		if dropReason := drop(swap); dropReason != "" {
+			dropReasons[swap.ID] = dropReason
+			dropped = append(dropped, swap)
+		} else {
+			reopened = append(reopened, swap)
+		}
+	}
+	for _, swap := range dropped {
+		if err := tx.Model(&model.SeatSwap{}).Where("id = ?", swap.ID).Updates(map[string]interface{}{
+			"status":   model.SeatSwapCancelled,
+			"accepted": false,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	for _, swap := range reopened {
+		if err := tx.Model(&model.SeatSwap{}).Where("id = ?", swap.ID).Updates(map[string]interface{}{
+			"status":   model.SeatSwapOpen,
+			"match_id": nil,
+			"accepted": false,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	if err := notifySeatSwaps(tx, dropped, func(swap model.SeatSwap, give string, want string) string {
+		return fmt.Sprintf("你让出《%s》换《%s》的申请已取消: %s", give, want, dropReasons[swap.ID])
+	}); err != nil {
+		return err
+	}
+	if err := notifySeatSwaps(tx, reopened, func(swap model.SeatSwap, give string, want string) string {
+		return fmt.Sprintf("换课匹配已取消(%s), 你让出《%s》换《%s》的申请将重新等待匹配", reason, give, want)
+	}); err != nil {
+		return err
+	}
+	for _, swap := range reopened {
+		// 前面的申请重新匹配时可能已经带走了这一个
+		var current model.SeatSwap
+		if err := tx.First(&current, swap.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != model.SeatSwapOpen {
+			continue
+		}
+		if _, err := matchSeatSwap(tx, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireSeatSwapMatches 取消超过确认期限的匹配, 未确认的申请随之取消, 已确认的重新等待匹配
+func expireSeatSwapMatches(tx *gorm.DB, now time.Time) error {
+	var matches []model.SeatSwapMatch
+	if err := tx.Where("status = ? AND created_at < ?", model.SeatSwapMatchPending, now.Add(-seatSwapConsentWindow)).
+		Order("id").Find(&matches).Error; err != nil {
+		return err
+	}
+	for i := range matches {
+		if err := cancelSeatSwapMatch(tx, &matches[i], "有同学未在期限内确认", func(swap model.SeatSwap) string {
+			if swap.Accepted {
+				return ""
+			}
+			return "未在期限内确认"
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeSeatSwapMatch 各方都确认后完成交换: 锁住相关课程和各方的选课记录, 逐一复核后在同一事务中原地修改选课记录
+// 每门课程让出和换入的名额相等, 交换过程中不会有空余名额被其他人抢走; 复核未通过时取消该申请, 其余申请重新等待匹配
+func executeSeatSwapMatch(tx *gorm.DB, match *model.SeatSwapMatch) error {
+	var swaps []model.SeatSwap
+	if err := tx.Where("match_id = ?", match.ID).Order("id").Find(&swaps).Error; err != nil {
+		return err
+	}
+	courseIDs := make([]int64, 0, len(swaps)*2)
+	studentIDs := make([]string, 0, len(swaps))
+	for _, swap := range swaps {
+		courseIDs = append(courseIDs, swap.GiveCourseID, swap.WantCourseID)
+		studentIDs = append(studentIDs, swap.StudentID)
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("course_id IN ?", courseIDs).
+		Order("course_id").Find(&[]model.Course{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("student_id IN ?", studentIDs).
+		Find(&[]model.CourseStudent{}).Error; err != nil {
+		return err
+	}
+	for _, swap := range swaps {
+		reason, err := seatSwapEligible(tx, swap)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			continue
+		}
+		failedID := swap.ID
+		return cancelSeatSwapMatch(tx, match, "有同学已不满足交换条件", func(swap model.SeatSwap) string {
+			if swap.ID == failedID {
+				return "复核未通过, " + reason
+			}
+			return ""
+		})
+	}
+	for _, swap := range swaps {
+		if err := tx.Model(&model.CourseStudent{}).Where("student_id = ? AND course_id = ?", swap.StudentID, swap.GiveCourseID).
+			Update("course_id", swap.WantCourseID).Error; err != nil {
+			return err
+		}
+		// 同一学生其他已失效的申请一并取消
+		if err := tx.Model(&model.SeatSwap{}).
+			Where("student_id = ? AND status = ? AND (give_course_id = ? OR want_course_id = ?)",
+				swap.StudentID, model.SeatSwapOpen, swap.GiveCourseID, swap.WantCourseID).
+			Update("status", model.SeatSwapCancelled).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&model.SeatSwap{}).Where("match_id = ?", match.ID).
+		Update("status", model.SeatSwapCompleted).Error; err != nil {
+		return err
+	}
+	match.Status = model.SeatSwapMatchCompleted
+	if err := tx.Model(match).Update("status", match.Status).Error; err != nil {
+		return err
+	}
+	return notifySeatSwaps(tx, swaps, func(swap model.SeatSwap, give string, want string) string {
+		return fmt.Sprintf("换课已完成: 你已从《%s》换到《%s》", give, want)
+	})
+}
+
+// PostSeatSwap 发布换课申请并立即尝试匹配, 每门课程同时只能有一个进行中的申请
+func (us *User) PostSeatSwap(studentID string, giveCourseID int64, wantCourseID int64, now time.Time) (*model.SeatSwap, error) {
+	if giveCourseID == wantCourseID {
+		return nil, errors.New("让出的课程和想要的课程不能相同")
+	}
+	swap := model.SeatSwap{
+		StudentID:    studentID,
+		GiveCourseID: giveCourseID,
+		WantCourseID: wantCourseID,
+		Status:       model.SeatSwapOpen,
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := expireSeatSwapMatches(tx, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	activeStatuses := []string{model.SeatSwapOpen, model.SeatSwapMatched}
+	var count int64
+	if err := tx.Model(&model.SeatSwap{}).Where("student_id = ? AND status IN ?", studentID, activeStatuses).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if count >= maxActiveSeatSwaps {
+		tx.Rollback()
+		return nil, fmt.Errorf("最多同时发布%d个换课申请", maxActiveSeatSwaps)
+	}
+	if err := tx.Model(&model.SeatSwap{}).Where("student_id = ? AND status IN ? AND give_course_id = ?", studentID, activeStatuses, giveCourseID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return nil, errors.New("这门课程已经有进行中的换课申请")
+	}
+	reason, err := seatSwapEligible(tx, swap)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if reason != "" {
+		tx.Rollback()
+		return nil, errors.New(reason)
+	}
+	if err := tx.Create(&swap).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := matchSeatSwap(tx, swap); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.First(&swap, swap.ID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &swap, nil
+}
+
+// loadOwnSeatSwap 锁住并获取学生自己的换课申请
+func loadOwnSeatSwap(tx *gorm.DB, studentID string, swapID int64) (*model.SeatSwap, error) {
+	var swap model.SeatSwap
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("student_id = ?", studentID).
+		First(&swap, swapID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("换课申请不存在")
+		}
+		return nil, err
+	}
+	return &swap, nil
+}
+
+// RespondSeatSwap 确认或拒绝自己的申请所在的匹配; 拒绝时自己的申请取消, 其他人的申请重新等待匹配
+// 最后一人确认时立即执行交换, 返回的匹配状态说明交换是否完成
+func (us *User) RespondSeatSwap(studentID string, swapID int64, accept bool, now time.Time) (*model.SeatSwapMatch, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := expireSeatSwapMatches(tx, now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	swap, err := loadOwnSeatSwap(tx, studentID, swapID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if swap.Status != model.SeatSwapMatched || swap.MatchID == nil {
+		tx.Rollback()
+		return nil, errors.New("该换课申请没有等待确认的匹配")
+	}
+	var match model.SeatSwapMatch
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, *swap.MatchID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if accept {
+		if err := acceptSeatSwapMatch(tx, swap, &match); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	} else if err := cancelSeatSwapMatch(tx, &match, "有同学拒绝了交换", func(other model.SeatSwap) string {
+		if other.ID == swap.ID {
+			return "你拒绝了交换"
+		}
+		return ""
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// acceptSeatSwapMatch 记录学生确认匹配, 所有人都确认后执行交换
+func acceptSeatSwapMatch(tx *gorm.DB, swap *model.SeatSwap, match *model.SeatSwapMatch) error {
+	if err := tx.Model(swap).Update("accepted", true).Error; err != nil {
+		return err
+	}
+	var pending int64
+	if err := tx.Model(&model.SeatSwap{}).Where("match_id = ? AND accepted = ?", match.ID, false).
+		Count(&pending).Error; err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+	return executeSeatSwapMatch(tx, match)
+}
+
+// CancelSeatSwap 撤回自己的换课申请, 已匹配时等同于拒绝该匹配
+func (us *User) CancelSeatSwap(studentID string, swapID int64) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	swap, err := loadOwnSeatSwap(tx, studentID, swapID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	switch swap.Status {
+	case model.SeatSwapOpen:
+		err = tx.Model(swap).Update("status", model.SeatSwapCancelled).Error
+	case model.SeatSwapMatched:
+		var match model.SeatSwapMatch
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, *swap.MatchID).Error; err == nil {
+			err = cancelSeatSwapMatch(tx, &match, "有同学撤回了换课申请", func(other model.SeatSwap) string {
+				if other.ID == swap.ID {
+					return "你撤回了申请"
+				}
+				return ""
+			})
+		}
+	default:
+		err = errors.New("该换课申请已经结束")
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// SeatSwapListing 换课看板上的一类申请, 不显示发布者
+type SeatSwapListing struct {
+	GiveCourseID int64
+	GiveCourse   string
+	WantCourseID int64
+	WantCourse   string
+	Count        int
+}
+
+// GetSeatSwapBoard 获取换课看板: 按让出和想要的课程汇总等待匹配的申请, 申请多的在前
+func (us *User) GetSeatSwapBoard() ([]SeatSwapListing, error) {
+	var rows []struct {
+		GiveCourseID int64
+		WantCourseID int64
+		Total        int
+	}
+	if err := model.DB.Model(&model.SeatSwap{}).
+		Select("give_course_id, want_course_id, COUNT(*) AS total").
+		Where("status = ?", model.SeatSwapOpen).
+		Group("give_course_id, want_course_id").
+		Order("total DESC, give_course_id, want_course_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	swaps := make([]model.SeatSwap, 0, len(rows))
+	for _, row := range rows {
+		swaps = append(swaps, model.SeatSwap{GiveCourseID: row.GiveCourseID, WantCourseID: row.WantCourseID})
+	}
+	names, err := seatSwapCourseNames(model.DB, swaps)
+	if err != nil {
+		return nil, err
+	}
+	listings := make([]SeatSwapListing, 0, len(rows))
+	for _, row := range rows {
+		listings = append(listings, SeatSwapListing{
+			GiveCourseID: row.GiveCourseID,
+			GiveCourse:   names[row.GiveCourseID],
+			WantCourseID: row.WantCourseID,
+			WantCourse:   names[row.WantCourseID],
+			Count:        row.Total,
+		})
+	}
+	return listings, nil
+}
+
+// SeatSwapLink 匹配中一方的让出和换入课程, 不显示是哪位同学
+type SeatSwapLink struct {
+	GiveCourseID int64
+	GiveCourse   string
+	WantCourseID int64
+	WantCourse   string
+	Accepted     bool
+	Mine         bool
+}
+
+// SeatSwapDetail 学生自己的一个换课申请, 已匹配时附带匹配中的各方和确认期限
+type SeatSwapDetail struct {
+	Swap       model.SeatSwap
+	GiveCourse string
+	WantCourse string
+	Match      *model.SeatSwapMatch
+	Links      []SeatSwapLink
+	ExpiresAt  time.Time
+}
+
+// GetSeatSwaps 获取学生自己最近的换课申请
+func (us *User) GetSeatSwaps(studentID string) ([]SeatSwapDetail, error) {
+	var swaps []model.SeatSwap
+	if err := model.DB.Where("student_id = ?", studentID).Order("id DESC").Limit(50).Find(&swaps).Error; err != nil {
+		return nil, err
+	}
+	var matchIDs []int64
+	for _, swap := range swaps {
+		if swap.MatchID != nil {
+			matchIDs = append(matchIDs, *swap.MatchID)
+		}
+	}
+	matches := make(map[int64]model.SeatSwapMatch)
+	members := make(map[int64][]model.SeatSwap)
+	related := swaps
+	if len(matchIDs) > 0 {
+		var matchRows []model.SeatSwapMatch
+		if err := model.DB.Where("id IN ?", matchIDs).Find(&matchRows).Error; err != nil {
+			return nil, err
+		}
+		for _, match := range matchRows {
+			matches[match.ID] = match
+		}
+		var memberRows []model.SeatSwap
+		if err := model.DB.Where("match_id IN ?", matchIDs).Order("id").Find(&memberRows).Error; err != nil {
+			return nil, err
+		}
+		for _, member := range memberRows {
+			members[*member.MatchID] = append(members[*member.MatchID], member)
+		}
+		related = append(append([]model.SeatSwap{}, swaps...), memberRows...)
+	}
+	names, err := seatSwapCourseNames(model.DB, related)
+	if err != nil {
+		return nil, err
+	}
+	details := make([]SeatSwapDetail, 0, len(swaps))
+	for _, swap := range swaps {
+		detail := SeatSwapDetail{
+			Swap:       swap,
+			GiveCourse: names[swap.GiveCourseID],
+			WantCourse: names[swap.WantCourseID],
+			Links:      []SeatSwapLink{},
+		}
+		// 被取消的匹配中的申请已经回到等待匹配状态, 只显示当前所在的匹配
+		if swap.MatchID != nil && swap.Status != model.SeatSwapOpen {
+			if match, ok := matches[*swap.MatchID]; ok {
+				detail.Match = &match
+				detail.ExpiresAt = match.CreatedAt.Add(seatSwapConsentWindow)
+				for _, member := range members[match.ID] {
+					detail.Links = append(detail.Links, SeatSwapLink{
+						GiveCourseID: member.GiveCourseID,
+						GiveCourse:   names[member.GiveCourseID],
+						WantCourseID: member.WantCourseID,
+						WantCourse:   names[member.WantCourseID],
+						Accepted:     member.Accepted,
+						Mine:         member.ID == swap.ID,
+					})
+				}
+			}
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
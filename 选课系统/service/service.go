@@ -5,6 +5,17 @@ type Service struct {
 	Admin
 	TeacherService
 	StudentService
+	Calendar
+	Exam
+	Notice
+	Room
+	Timetable
+	Audit
+	Grade
+	Attendance
+	Evaluation
+	Degree
+	OfficeHour
 }
 
 func New() *Service {
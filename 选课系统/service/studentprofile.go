@@ -0,0 +1,258 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const maxBulkStudentProfiles = 500 // 一次批量更新的学生数上限
+
+// StudentFilter 按学籍信息筛选学生, 为空的条件不参与筛选
+type StudentFilter struct {
+	Department string
+	Major      string
+	EntryYear  int
+	ClassName  string
+	Status     string
+}
+
+// StudentProfileUpdate 批量更新学籍信息时要修改的字段, 为nil的字段保持不变
+type StudentProfileUpdate struct {
+	Department *string
+	Major      *string
+	EntryYear  *int
+	ClassName  *string
+	Status     *string
+}
+
+func validStudentStatus(status string) bool {
+	return status == model.StudentActive || status == model.StudentSuspended || status == model.StudentGraduated
+}
+
+// checkStudentExists 检查学生账号是否存在
+func checkStudentExists(db *gorm.DB, studentIDs ...string) error {
+	var found []string
+	if err := db.Model(&model.User{}).Where("user_id IN ? AND auth = ?", studentIDs, 2).
+		Pluck("user_id", &found).Error; err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(found))
+	for _, studentID := range found {
+		exists[studentID] = true
+	}
+	for _, studentID := range studentIDs {
+		if !exists[studentID] {
+			return fmt.Errorf("学生%s不存在", studentID)
+		}
+	}
+	return nil
+}
+
+// GetStudentProfile 获取学生的学籍信息, 没有建立学籍信息时返回nil
+func (a *Admin) GetStudentProfile(studentID string) (*model.StudentProfile, error) {
+	var profile model.StudentProfile
+	if err := model.DB.Where("student_id = ?", studentID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetStudentProfiles 批量获取学籍信息, 按学生ID索引
+func (a *Admin) GetStudentProfiles(studentIDs []string) (map[string]model.StudentProfile, error) {
+	profiles := make(map[string]model.StudentProfile, len(studentIDs))
+	if len(studentIDs) == 0 {
+		return profiles, nil
+	}
+	var rows []model.StudentProfile
+	if err := model.DB.Where("student_id IN ?", studentIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, profile := range rows {
+		profiles[profile.StudentID] = profile
+	}
+	return profiles, nil
+}
+
+// SaveStudentProfile 建立或更新学生的学籍信息
+func (a *Admin) SaveStudentProfile(profile *model.StudentProfile) error {
+	profile.Department = strings.TrimSpace(profile.Department)
+	profile.Major = strings.TrimSpace(profile.Major)
+	profile.ClassName = strings.TrimSpace(profile.ClassName)
+	if profile.Status == "" {
+		profile.Status = model.StudentActive
+	}
+	if !validStudentStatus(profile.Status) {
+		return errors.New("无效的学籍状态")
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := checkStudentExists(tx, profile.StudentID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	var existing model.StudentProfile
+	err := tx.Where("student_id = ?", profile.StudentID).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = tx.Create(profile).Error
+	case err == nil:
+		profile.ID = existing.ID
+		profile.CreatedAt = existing.CreatedAt
+		err = tx.Select("department", "major", "entry_year", "class_name", "status", "email", "phone", "updated_at").
+			Updates(profile).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// DeleteStudentProfile 删除学生的学籍信息
+func (a *Admin) DeleteStudentProfile(studentID string) error {
+	result := model.DB.Unscoped().Where("student_id = ?", studentID).Delete(&model.StudentProfile{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("学籍信息不存在")
+	}
+	return nil
+}
+
+// BulkUpdateStudentProfiles 批量修改学生的学籍信息, 如整班毕业或调整行政班; 还没有学籍信息的学生随之建立
+func (a *Admin) BulkUpdateStudentProfiles(studentIDs []string, update StudentProfileUpdate) (int, error) {
+	if len(studentIDs) == 0 {
+		return 0, errors.New("请选择学生")
+	}
+	if len(studentIDs) > maxBulkStudentProfiles {
+		return 0, fmt.Errorf("一次最多更新%d名学生", maxBulkStudentProfiles)
+	}
+	columns := make(map[string]interface{})
+	if update.Department != nil {
+		columns["department"] = strings.TrimSpace(*update.Department)
+	}
+	if update.Major != nil {
+		columns["major"] = strings.TrimSpace(*update.Major)
+	}
+	if update.EntryYear != nil {
+		columns["entry_year"] = *update.EntryYear
+	}
+	if update.ClassName != nil {
+		columns["class_name"] = strings.TrimSpace(*update.ClassName)
+	}
+	if update.Status != nil {
+		if !validStudentStatus(*update.Status) {
+			return 0, errors.New("无效的学籍状态")
+		}
+		columns["status"] = *update.Status
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("请指定要修改的字段")
+	}
+	seen := make(map[string]bool, len(studentIDs))
+	unique := make([]string, 0, len(studentIDs))
+	for _, studentID := range studentIDs {
+		if !seen[studentID] {
+			seen[studentID] = true
+			unique = append(unique, studentID)
+		}
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := checkStudentExists(tx, unique...); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	var existing []string
+	if err := tx.Model(&model.StudentProfile{}).Where("student_id IN ?", unique).
+		Pluck("student_id", &existing).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	hasProfile := make(map[string]bool, len(existing))
+	for _, studentID := range existing {
+		hasProfile[studentID] = true
+	}
+	var created []model.StudentProfile
+	for _, studentID := range unique {
+		if !hasProfile[studentID] {
+			created = append(created, model.StudentProfile{StudentID: studentID, Status: model.StudentActive})
+		}
+	}
+	if len(created) > 0 {
+		if err := tx.Create(&created).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Model(&model.StudentProfile{}).Where("student_id IN ?", unique).Updates(columns).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return len(unique), nil
+}
+
+// UpdateOwnProfile 学生修改自己的联系方式, 只修改传入的字段, 为nil的字段保持不变;
+// 院系、专业、行政班和学籍状态只能由管理员修改
+func (us *User) UpdateOwnProfile(studentID string, email *string, phone *string) (*model.StudentProfile, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var profile model.StudentProfile
+	err := tx.Where("student_id = ?", studentID).First(&profile).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		profile = model.StudentProfile{StudentID: studentID, Status: model.StudentActive}
+		setContact(&profile, email, phone)
+		err = tx.Create(&profile).Error
+	case err == nil:
+		updates := setContact(&profile, email, phone)
+		if len(updates) > 0 {
+			err = tx.Model(&profile).Updates(updates).Error
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// setContact 把传入的联系方式写入profile, 返回需要更新的列
+func setContact(profile *model.StudentProfile, email *string, phone *string) map[string]interface{} {
+	updates := make(map[string]interface{})
+	if email != nil {
+		profile.Email = *email
+		updates["email"] = *email
+	}
+	if phone != nil {
+		profile.Phone = *phone
+		updates["phone"] = *phone
+	}
+	return updates
+}
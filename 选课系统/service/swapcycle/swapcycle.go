@@ -0,0 +1,82 @@
+package swapcycle
+
+// Request 一个等待匹配的换课申请: 让出Give课程, 想要Want课程
+type Request struct {
+	ID        int64
+	StudentID string
+	Give      int64
+	Want      int64
+}
+
+// Find 为start寻找交换环: 每人让出的课程正是上一人想要的, 最后一人想要的是start让出的课程;
+// 同一学生和同一门让出的课程在环中只出现一次, 环最多maxSize人
+// 人数少的环优先, 人数相同时优先open中靠前(较早发布)的申请; eligible检查候选申请是否仍可参与匹配
+// 返回的环以start开头, 找不到时返回nil
+func Find(start Request, open []Request, maxSize int, eligible func(Request) (bool, error)) ([]Request, error) {
+	byGive := make(map[int64][]Request)
+	for _, request := range open {
+		if request.ID != start.ID && request.StudentID != start.StudentID {
+			byGive[request.Give] = append(byGive[request.Give], request)
+		}
+	}
+	checked := make(map[int64]bool)
+	check := func(request Request) (bool, error) {
+		if ok, done := checked[request.ID]; done {
+			return ok, nil
+		}
+		ok, err := eligible(request)
+		if err != nil {
+			return false, err
+		}
+		checked[request.ID] = ok
+		return ok, nil
+	}
+
+	for size := 2; size <= maxSize; size++ {
+		path := []Request{start}
+		students := map[string]bool{start.StudentID: true}
+		gives := map[int64]bool{start.Give: true}
+		var search func() (bool, error)
+		search = func() (bool, error) {
+			last := path[len(path)-1]
+			for _, next := range byGive[last.Want] {
+				if students[next.StudentID] || gives[next.Give] {
+					continue
+				}
+				// 最后一人必须想要start让出的课程, 中间的人不能提前闭环
+				if closes := next.Want == start.Give; closes != (len(path)+1 == size) {
+					continue
+				}
+				ok, err := check(next)
+				if err != nil {
+					return false, err
+				}
+				if !ok {
+					continue
+				}
+				path = append(path, next)
+				if len(path) == size {
+					return true, nil
+				}
+				students[next.StudentID] = true
+				gives[next.Give] = true
+				found, err := search()
+				if err != nil || found {
+					return found, err
+				}
+				delete(students, next.StudentID)
+				delete(gives, next.Give)
+				path = path[:len(path)-1]
+			}
+			return false, nil
+		}
+		found, err := search()
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return path, nil
+		}
+	}
+	return nil, nil
+}
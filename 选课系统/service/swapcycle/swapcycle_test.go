@@ -0,0 +1,162 @@
+package swapcycle
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func request(id int64, student string, give int64, want int64) Request {
+	return Request{ID: id, StudentID: student, Give: give, Want: want}
+}
+
+func allEligible(Request) (bool, error) {
+	return true, nil
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name       string
+		start      Request
+		open       []Request
+		maxSize    int
+		ineligible []int64
+		want       []int64
+	}{
+		{
+			name:    "两人互换",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(1, "甲", 100, 200), request(2, "乙", 200, 100)},
+			maxSize: 3,
+			want:    []int64{1, 2},
+		},
+		{
+			name:    "三人轮换",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 200, 300), request(3, "丙", 300, 100)},
+			maxSize: 3,
+			want:    []int64{1, 2, 3},
+		},
+		{
+			name:    "两人互换优先于三人轮换",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 200, 300), request(3, "丙", 300, 100), request(4, "丁", 200, 100)},
+			maxSize: 3,
+			want:    []int64{1, 4},
+		},
+		{
+			name:    "人数相同时优先较早的申请",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 200, 100), request(3, "丙", 200, 100)},
+			maxSize: 3,
+			want:    []int64{1, 2},
+		},
+		{
+			name:    "超过人数上限的环不匹配",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 200, 300), request(3, "丙", 300, 100)},
+			maxSize: 2,
+		},
+		{
+			name:    "不与自己的其他申请成环",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "甲", 200, 100)},
+			maxSize: 3,
+		},
+		{
+			name:    "同一学生在环中只出现一次",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 200, 300), request(3, "乙", 300, 100)},
+			maxSize: 3,
+		},
+		{
+			name:    "中间的人不能提前闭环",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 200, 100), request(3, "丙", 100, 300)},
+			maxSize: 3,
+			want:    []int64{1, 2},
+		},
+		{
+			name:    "同一门让出的课程在环中只出现一次",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 200, 200), request(3, "丙", 200, 100)},
+			maxSize: 3,
+			want:    []int64{1, 3},
+		},
+		{
+			name:       "跳过已不符合条件的申请",
+			start:      request(1, "甲", 100, 200),
+			open:       []Request{request(2, "乙", 200, 100), request(3, "丙", 200, 300), request(4, "丁", 300, 100)},
+			maxSize:    3,
+			ineligible: []int64{2},
+			want:       []int64{1, 3, 4},
+		},
+		{
+			name:    "没有想要的课程",
+			start:   request(1, "甲", 100, 200),
+			open:    []Request{request(2, "乙", 300, 100)},
+			maxSize: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ineligible := make(map[int64]bool)
+			for _, id := range tt.ineligible {
+				ineligible[id] = true
+			}
+			cycle, err := Find(tt.start, tt.open, tt.maxSize, func(request Request) (bool, error) {
+				return !ineligible[request.ID], nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, request := range cycle {
+				got = append(got, request.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("交换环为 %v, 期望 %v", got, tt.want)
+			}
+			for i, request := range cycle {
+				next := cycle[(i+1)%len(cycle)]
+				if request.Want != next.Give {
+					t.Errorf("申请 %d 想要课程 %d, 但下一人 %d 让出的是课程 %d", request.ID, request.Want, next.ID, next.Give)
+				}
+			}
+		})
+	}
+}
+
+func TestFindChecksEligibilityOnce(t *testing.T) {
+	start := request(1, "甲", 100, 200)
+	open := []Request{request(2, "乙", 200, 300), request(3, "丙", 300, 400), request(4, "丁", 400, 100)}
+	calls := make(map[int64]int)
+	cycle, err := Find(start, open, 4, func(request Request) (bool, error) {
+		calls[request.ID]++
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cycle) != 4 {
+		t.Fatalf("交换环有 %d 人, 期望 4 人", len(cycle))
+	}
+	for id, count := range calls {
+		if count != 1 {
+			t.Errorf("申请 %d 检查了 %d 次", id, count)
+		}
+	}
+}
+
+func TestFindEligibilityError(t *testing.T) {
+	errCheck := errors.New("check failed")
+	_, err := Find(request(1, "甲", 100, 200), []Request{request(2, "乙", 200, 100)}, 3, func(Request) (bool, error) {
+		return false, errCheck
+	})
+	if !errors.Is(err, errCheck) {
+		t.Errorf("错误为 %v, 期望 %v", err, errCheck)
+	}
+	if cycle, err := Find(request(1, "甲", 100, 200), nil, 3, allEligible); err != nil || cycle != nil {
+		t.Errorf("没有其他申请时得到 %v, %v", cycle, err)
+	}
+}
@@ -1,25 +1,21 @@
 package service
 
 import (
+	"errors"
 	"finaltenzor/model"
+	"finaltenzor/service/namematch"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type TeacherService struct {
 }
 
-func (t *TeacherService) FindTeacherByName(name string) (*model.Teacher, error) {
-	var teacher model.Teacher
-	if err := model.DB.Where("name = ?", name).First(&teacher).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return &teacher, nil
-}
-
 func (t *TeacherService) FindTeacherByID(id int64) (*model.Teacher, error) {
 	var teacher model.Teacher
 	if err := model.DB.Where("id = ?", id).First(&teacher).Error; err != nil {
@@ -31,25 +27,6 @@ func (t *TeacherService) FindTeacherByID(id int64) (*model.Teacher, error) {
 	return &teacher, nil
 }
 
-func (t *TeacherService) RegisterTeacher(name string) (int64, error) {
-	newTeacher := model.Teacher{Name: name}
-	if err := model.DB.Create(&newTeacher).Error; err != nil {
-		return 0, err
-	}
-	return newTeacher.ID, nil
-}
-
-func (t *TeacherService) FindOrRegisterTeacher(name string) (int64, error) {
-	teacher, err := t.FindTeacherByName(name)
-	if err != nil {
-		return 0, err
-	}
-	if teacher != nil {
-		return teacher.ID, nil
-	}
-	return t.RegisterTeacher(name)
-}
-
 func (t *TeacherService) GetTeacherNamesByCourses(CourseID int64) ([]string, error) {
 	var teacherNames []string
 	var teacherIDs []int64
@@ -69,3 +46,504 @@ func (t *TeacherService) GetTeacherNamesByCourses(CourseID int64) ([]string, err
 	}
 	return teacherNames, nil
 }
+
+// teacherConflict 检查教师在times中的任一时间段是否已有其他课程
+func teacherConflict(tx *gorm.DB, teacherID int64, excludeCourseID int64, times []model.CourseTime) (bool, error) {
+	for _, courseTime := range times {
+		var count int64
+		if err := tx.Model(&model.Course{}).
+			Joins("JOIN course_teacher ON course_teacher.course_id = course.course_id").
+			Where("course_teacher.teacher_id = ? AND course.course_id != ? AND EXISTS (SELECT 1 FROM course_time WHERE course_time.course_id = course.course_id AND start_time < ? AND end_time > ?)",
+				teacherID, excludeCourseID, courseTime.EndTime, courseTime.StartTime).
+			Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateTeacherAccount 为教师创建登录账号, 每位教师只能有一个账号
+func (t *TeacherService) CreateTeacherAccount(teacherID int64, userID string, password string) error {
+	teacher, err := t.FindTeacherByID(teacherID)
+	if err != nil {
+		return err
+	}
+	if teacher == nil {
+		return errors.New("教师不存在")
+	}
+	var count int64
+	if err := model.DB.Model(&model.User{}).Where("user_id = ? OR teacher_id = ?", userID, teacherID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("账号已存在或该教师已有账号")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user := model.User{
+		UserID:    userID,
+		UserName:  teacher.Name,
+		Password:  string(hashedPassword),
+		Auth:      3,
+		TeacherID: &teacherID,
+	}
+	return model.DB.Create(&user).Error
+}
+
+// GetTeacherByUser 获取教师账号关联的教师
+func (t *TeacherService) GetTeacherByUser(userID string) (*model.Teacher, error) {
+	var user model.User
+	if err := model.DB.Where("user_id = ? AND auth = ?", userID, 3).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	if user.TeacherID == nil {
+		return nil, errors.New("该账号没有关联教师")
+	}
+	teacher, err := t.FindTeacherByID(*user.TeacherID)
+	if err != nil {
+		return nil, err
+	}
+	if teacher == nil {
+		return nil, errors.New("该账号关联的教师不存在")
+	}
+	return teacher, nil
+}
+
+// GetTeachingCourses 获取教师讲授的课程及每门课的选课人数
+func (t *TeacherService) GetTeachingCourses(teacherID int64) ([]model.Course, map[int64]int, error) {
+	var courses []model.Course
+	if err := model.DB.Preload("CourseTimes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("start_time")
+	}).
+		Joins("JOIN course_teacher ON course_teacher.course_id = course.course_id").
+		Where("course_teacher.teacher_id = ?", teacherID).
+		Order("course.course_id").
+		Find(&courses).Error; err != nil {
+		return nil, nil, err
+	}
+	courseIDs := make([]int64, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.CourseID)
+	}
+	counts, err := enrollmentCounts(model.DB, courseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return courses, counts, nil
+}
+
+// CheckCourseTeacher 检查教师是否讲授该课程, 教师只能访问自己的课程
+func (t *TeacherService) CheckCourseTeacher(teacherID int64, courseID int64) error {
+	var count int64
+	if err := model.DB.Model(&model.CourseTeacher{}).
+		Joins("JOIN course ON course.course_id = course_teacher.course_id AND course.deleted_at IS NULL").
+		Where("course_teacher.teacher_id = ? AND course_teacher.course_id = ?", teacherID, courseID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("您不是该课程的授课教师")
+	}
+	return nil
+}
+
+// GetCourseRoster 分页获取课程的选课学生名单
+func (t *TeacherService) GetCourseRoster(courseID int64, page int, limit int) ([]model.User, int, error) {
+	var students []model.User
+	var total int64
+	query := model.DB.Model(&model.User{}).
+		Joins("JOIN course_student ON course_student.student_id = user.user_id").
+		Where("course_student.course_id = ?", courseID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("user.user_id").Limit(limit).Offset((page - 1) * limit).Find(&students).Error; err != nil {
+		return nil, 0, err
+	}
+	return students, int(total), nil
+}
+
+// GetTeachingSchedule 获取教师在[start, end)内的课表
+func (t *TeacherService) GetTeachingSchedule(teacherID int64, start time.Time, end time.Time) (*WeekSchedule, error) {
+	return loadWeekSchedule(model.DB.Joins("JOIN course_teacher ON course_teacher.course_id = course.course_id").
+		Where("course_teacher.teacher_id = ?", teacherID), start, end)
+}
+
+// checkStaffID 检查工号是否已被其他教师使用
+func checkStaffID(tx *gorm.DB, staffID *string, excludeID int64) error {
+	if staffID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&model.Teacher{}).Where("staff_id = ? AND id != ?", *staffID, excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("工号已被其他教师使用")
+	}
+	return nil
+}
+
+// AddTeacher 添加教师, 同名教师可以通过工号区分
+func (t *TeacherService) AddTeacher(teacher *model.Teacher) error {
+	if err := checkStaffID(model.DB, teacher.StaffID, 0); err != nil {
+		return err
+	}
+	return model.DB.Create(teacher).Error
+}
+
+// UpdateTeacher 更新教师信息, 教师账号的用户名随之更新
+func (t *TeacherService) UpdateTeacher(teacher *model.Teacher) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var existing model.Teacher
+	if err := tx.First(&existing, teacher.ID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("教师不存在")
+		}
+		return err
+	}
+	if err := checkStaffID(tx, teacher.StaffID, teacher.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	teacher.BaseModel = existing.BaseModel
+	if err := tx.Save(teacher).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&model.User{}).Where("teacher_id = ?", teacher.ID).Update("user_name", teacher.Name).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// DeleteTeacher 删除教师, 仍有课程或登录账号的教师不能删除
+func (t *TeacherService) DeleteTeacher(teacherID int64) error {
+	var count int64
+	if err := model.DB.Model(&model.CourseTeacher{}).
+		Joins("JOIN course ON course.course_id = course_teacher.course_id AND course.deleted_at IS NULL").
+		Where("course_teacher.teacher_id = ?", teacherID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该教师仍有课程, 不能删除")
+	}
+	if err := model.DB.Model(&model.User{}).Where("teacher_id = ?", teacherID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该教师有登录账号, 不能删除")
+	}
+	// 答疑、可用时间和教室预订都属于该教师, 须先处理或合并到其他教师
+	owned := []struct {
+		model  interface{}
+		reason string
+	}{
+		{&model.OfficeHour{}, "该教师仍有答疑时间, 不能删除"},
+		{&model.OfficeHourBooking{}, "该教师仍有学生的答疑预约, 不能删除"},
+		{&model.TeacherAvailability{}, "该教师仍有可用时间设置, 不能删除"},
+		{&model.RoomBooking{}, "该教师仍有教室预订, 不能删除"},
+	}
+	for _, item := range owned {
+		if err := model.DB.Model(item.model).Where("teacher_id = ?", teacherID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New(item.reason)
+		}
+	}
+	result := model.DB.Unscoped().Delete(&model.Teacher{}, teacherID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("教师不存在")
+	}
+	return nil
+}
+
+// GetTeachers 查询教师
+func (t *TeacherService) GetTeachers(page int, limit int, name string, department string) ([]model.Teacher, int, error) {
+	var teachers []model.Teacher
+	var total int64
+	query := model.DB.Model(&model.Teacher{})
+	if name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id").Limit(limit).Offset((page - 1) * limit).Find(&teachers).Error; err != nil {
+		return nil, 0, err
+	}
+	return teachers, int(total), nil
+}
+
+// GetTeacher 获取教师详情及其讲授的课程ID
+func (t *TeacherService) GetTeacher(teacherID int64) (*model.Teacher, []int64, error) {
+	teacher, err := t.FindTeacherByID(teacherID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if teacher == nil {
+		return nil, nil, errors.New("教师不存在")
+	}
+	var courseIDs []int64
+	if err := model.DB.Model(&model.CourseTeacher{}).
+		Joins("JOIN course ON course.course_id = course_teacher.course_id AND course.deleted_at IS NULL").
+		Where("course_teacher.teacher_id = ?", teacherID).
+		Order("course_teacher.course_id").
+		Pluck("course_teacher.course_id", &courseIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	return teacher, courseIDs, nil
+}
+
+type TeacherDuplicate struct {
+	Teachers [2]model.Teacher
+	Reason   string
+}
+
+// normalizeTeacherName 去掉空白和间隔号并统一大小写, 用于比较姓名
+func normalizeTeacherName(name string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsSpace(r) || r == '·' || r == '.' || r == '•' {
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// FindTeacherDuplicates 找出姓名相同或相近、可能是同一人的教师;
+// 工号不同的两位教师被视为不同的人, 不会被列出
+func (t *TeacherService) FindTeacherDuplicates() ([]TeacherDuplicate, error) {
+	var teachers []model.Teacher
+	if err := model.DB.Order("id").Find(&teachers).Error; err != nil {
+		return nil, err
+	}
+	names := make([][]rune, len(teachers))
+	for i, teacher := range teachers {
+		names[i] = []rune(normalizeTeacherName(teacher.Name))
+	}
+	duplicates := []TeacherDuplicate{}
+	for i := range teachers {
+		for j := i + 1; j < len(teachers); j++ {
+			a, b := teachers[i], teachers[j]
+			if a.StaffID != nil && b.StaffID != nil && *a.StaffID != *b.StaffID {
+				continue
+			}
+			distance, ok := namematch.Similar(names[i], names[j])
+			if !ok {
+				continue
+			}
+			reason := "姓名相近"
+			switch {
+			case a.Name == b.Name:
+				reason = "姓名相同"
+			case distance == 0:
+				reason = "姓名仅空格、间隔号或大小写不同"
+			}
+			duplicates = append(duplicates, TeacherDuplicate{Teachers: [2]model.Teacher{a, b}, Reason: reason})
+		}
+	}
+	return duplicates, nil
+}
+
+// MergeTeachers 把sourceIDs中的教师合并到targetID: 课程、答疑时间和预约、可用时间以及教室预订改由目标教师所有,
+// 合讲同一门课时课时分摊比例相加; 目标教师缺少的工号、院系和联系方式以及登录账号从被合并的教师转移过来, 之后删除被合并的教师
+func (t *TeacherService) MergeTeachers(targetID int64, sourceIDs []int64) (int, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var target model.Teacher
+	if err := tx.First(&target, targetID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("教师不存在")
+		}
+		return 0, err
+	}
+	var sources []model.Teacher
+	if err := tx.Where("id IN ? AND id != ?", sourceIDs, targetID).Order("id").Find(&sources).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if len(sources) == 0 {
+		tx.Rollback()
+		return 0, errors.New("没有需要合并的教师")
+	}
+	var accounts int64
+	if err := tx.Model(&model.User{}).Where("teacher_id IN ?", append([]int64{targetID}, sourceIDs...)).Count(&accounts).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if accounts > 1 {
+		tx.Rollback()
+		return 0, errors.New("多位教师都有登录账号, 请先删除多余的账号")
+	}
+	moved := 0
+	for _, source := range sources {
+		var courseIDs []int64
+		if err := tx.Model(&model.CourseTeacher{}).Where("teacher_id = ?", source.ID).
+			Pluck("course_id", &courseIDs).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		for _, courseID := range courseIDs {
+			var shares []model.CourseTeacher
+			if err := tx.Where("course_id = ? AND teacher_id IN ?", courseID, []int64{targetID, source.ID}).
+				Find(&shares).Error; err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			if len(shares) > 1 {
+				// 两位教师原本合讲同一门课, 合并后只保留一条, 课时分摊比例相加
+				total := 0.0
+				for _, share := range shares {
+					total += share.HourShare
+				}
+				if total > 1 {
+					total = 1
+				}
+				if err := tx.Model(&model.CourseTeacher{}).Where("course_id = ? AND teacher_id = ?", courseID, targetID).
+					Update("hour_share", total).Error; err != nil {
+					tx.Rollback()
+					return 0, err
+				}
+				if err := tx.Where("course_id = ? AND teacher_id = ?", courseID, source.ID).
+					Delete(&model.CourseTeacher{}).Error; err != nil {
+					tx.Rollback()
+					return 0, err
+				}
+				continue
+			}
+			if err := tx.Model(&model.CourseTeacher{}).Where("course_id = ? AND teacher_id = ?", courseID, source.ID).
+				Update("teacher_id", targetID).Error; err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			moved++
+		}
+		if err := tx.Model(&model.User{}).Where("teacher_id = ?", source.ID).
+			Updates(map[string]any{"teacher_id": targetID, "user_name": target.Name}).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		for _, owned := range []interface{}{&model.OfficeHour{}, &model.OfficeHourBooking{}, &model.TeacherAvailability{}, &model.RoomBooking{}} {
+			if err := tx.Unscoped().Model(owned).Where("teacher_id = ?", source.ID).
+				Update("teacher_id", targetID).Error; err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		}
+		if target.StaffID == nil {
+			target.StaffID = source.StaffID
+		}
+		if target.Department == "" {
+			target.Department = source.Department
+		}
+		if target.Email == "" {
+			target.Email = source.Email
+		}
+		if target.Phone == "" {
+			target.Phone = source.Phone
+		}
+		if err := tx.Unscoped().Delete(&model.Teacher{}, source.ID).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Save(&target).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// resolveCourseTeachers 把课程的教师ID和教师姓名统一转换为教师ID:
+// 教师ID必须存在; 姓名只匹配到一位教师时直接使用, 匹配到多位同名教师时要求改用教师ID;
+// 没有匹配时报错并提示相近的姓名, 新教师需要先通过教师管理添加, 避免录错姓名时登记出不存在的教师
+func resolveCourseTeachers(tx *gorm.DB, teacherIDs []int64, names []string) ([]int64, error) {
+	seen := make(map[int64]bool)
+	var resolved []int64
+	for _, teacherID := range teacherIDs {
+		var count int64
+		if err := tx.Model(&model.Teacher{}).Where("id = ?", teacherID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("教师%d不存在", teacherID)
+		}
+		if !seen[teacherID] {
+			seen[teacherID] = true
+			resolved = append(resolved, teacherID)
+		}
+	}
+	for _, name := range names {
+		var matches []model.Teacher
+		if err := tx.Where("name = ?", name).Limit(2).Find(&matches).Error; err != nil {
+			return nil, err
+		}
+		var teacherID int64
+		switch len(matches) {
+		case 0:
+			return nil, unknownTeacherError(tx, name)
+		case 1:
+			teacherID = matches[0].ID
+		default:
+			return nil, fmt.Errorf("存在多位名为%s的教师, 请使用教师ID指定", name)
+		}
+		if !seen[teacherID] {
+			seen[teacherID] = true
+			resolved = append(resolved, teacherID)
+		}
+	}
+	return resolved, nil
+}
+
+// unknownTeacherError 姓名没有匹配到教师时的错误, 列出姓名相近的教师供选择
+func unknownTeacherError(tx *gorm.DB, name string) error {
+	var teachers []model.Teacher
+	if err := tx.Order("id").Find(&teachers).Error; err != nil {
+		return err
+	}
+	target := []rune(normalizeTeacherName(name))
+	var suggestions []string
+	for _, teacher := range teachers {
+		if _, ok := namematch.Similar(target, []rune(normalizeTeacherName(teacher.Name))); ok {
+			suggestions = append(suggestions, fmt.Sprintf("%s(ID %d)", teacher.Name, teacher.ID))
+		}
+	}
+	if len(suggestions) == 0 {
+		return fmt.Errorf("教师%s不存在, 请先添加该教师或使用教师ID指定", name)
+	}
+	return fmt.Errorf("教师%s不存在, 是否是: %s? 请使用教师ID指定, 或先添加该教师", name, strings.Join(suggestions, "、"))
+}
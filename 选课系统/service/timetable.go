@@ -0,0 +1,599 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"finaltenzor/common"
+	"finaltenzor/model"
+	"finaltenzor/service/timetable"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Timetable struct{}
+
+// 每门课最多尝试的候选教室数, 控制几千门课时的计算量
+const maxRoomCandidates = 10
+
+// TimetablePeriod 是一天中的一个节次, 起止时刻为HH:MM格式
+type TimetablePeriod struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// TimetableMeeting 是课程每周的一次上课, Weekday取值1-7表示周一至周日, Period为开始节次(从0开始)
+type TimetableMeeting struct {
+	Weekday int `json:"weekday"`
+	Period  int `json:"period"`
+	Length  int `json:"length"`
+}
+
+type TimetableCourseRequest struct {
+	CourseName   string
+	Capacity     int
+	TeacherIDs   []int64
+	WeeklyHours  int // 周学时, 即每周上课的节数
+	BlockPeriods int // 每次连续上课的节数
+	Room         RoomRequirement
+}
+
+// TeacherPreference 教师对某个节次的偏好, Weight大于0表示希望避开, 小于0表示希望安排
+type TeacherPreference struct {
+	TeacherID int64
+	Weekday   int
+	Period    int
+	Weight    int
+}
+
+type TimetableRequest struct {
+	Name        string
+	FromDate    time.Time
+	ToDate      time.Time
+	Weekdays    []int
+	Periods     []TimetablePeriod
+	Courses     []TimetableCourseRequest
+	Preferences []TeacherPreference
+}
+
+// timetableGrid 把草案的星期和节次换算为具体的上课时间
+type timetableGrid struct {
+	weekdays []int
+	starts   []time.Duration
+	ends     []time.Duration
+}
+
+func newTimetableGrid(weekdays []int, periods []TimetablePeriod) (*timetableGrid, error) {
+	if len(weekdays) == 0 || len(periods) == 0 {
+		return nil, errors.New("可排课的星期和节次不能为空")
+	}
+	grid := &timetableGrid{}
+	seen := make(map[int]bool)
+	for _, weekday := range weekdays {
+		if weekday < 1 || weekday > 7 || seen[weekday] {
+			return nil, fmt.Errorf("无效的星期: %d", weekday)
+		}
+		seen[weekday] = true
+		grid.weekdays = append(grid.weekdays, weekday)
+	}
+	sort.Ints(grid.weekdays)
+	for i, period := range periods {
+		startClock, err := common.ParseClock(period.Start)
+		if err != nil {
+			return nil, fmt.Errorf("第%d节的开始时刻格式错误", i+1)
+		}
+		endClock, err := common.ParseClock(period.End)
+		if err != nil {
+			return nil, fmt.Errorf("第%d节的结束时刻格式错误", i+1)
+		}
+		if endClock <= startClock {
+			return nil, fmt.Errorf("第%d节的结束时刻必须晚于开始时刻", i+1)
+		}
+		if i > 0 && startClock < grid.ends[i-1] {
+			return nil, errors.New("节次必须按时间顺序排列且不能重叠")
+		}
+		grid.starts = append(grid.starts, startClock)
+		grid.ends = append(grid.ends, endClock)
+	}
+	return grid, nil
+}
+
+// dayIndex 返回日期在网格中对应的星期下标, 不可排课的日期返回-1
+func (g *timetableGrid) dayIndex(date time.Time) int {
+	weekday := isoWeekday(date)
+	for i, w := range g.weekdays {
+		if w == weekday {
+			return i
+		}
+	}
+	return -1
+}
+
+// markBusy 把一段已占用的时间标记到与之重叠的节次上
+func (g *timetableGrid) markBusy(busy []bool, start time.Time, end time.Time) {
+	for day := dateOf(start); day.Before(end); day = day.AddDate(0, 0, 1) {
+		d := g.dayIndex(day)
+		if d < 0 {
+			continue
+		}
+		for p := range g.starts {
+			if day.Add(g.starts[p]).Before(end) && day.Add(g.ends[p]).After(start) {
+				busy[d*len(g.starts)+p] = true
+			}
+		}
+	}
+}
+
+// courseTimes 把每周的上课节次展开为[from, to]内的具体上课时间, 跳过学期外和放假的日子
+func (g *timetableGrid) courseTimes(meetings []TimetableMeeting, from time.Time, to time.Time, calendar *calendarView) []model.CourseTime {
+	var times []model.CourseTime
+	for day := dateOf(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		weekday := isoWeekday(day)
+		info := calendar.day(day)
+		if !info.InTerm || (info.Holiday != "" && !info.MakeUp) {
+			continue
+		}
+		for _, meeting := range meetings {
+			if meeting.Weekday != weekday {
+				continue
+			}
+			times = append(times, model.CourseTime{
+				StartTime: day.Add(g.starts[meeting.Period]),
+				EndTime:   day.Add(g.ends[meeting.Period+meeting.Length-1]),
+			})
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].StartTime.Before(times[j].StartTime)
+	})
+	return times
+}
+
+// GenerateTimetable 根据课程的周学时、教师和教室要求自动排课, 保存为待审核的课表草案;
+// 已有课程占用的教师和教室时间以及教师不能上课的时间段视为不可用, 教师希望上课的时间段优先安排
+func (t *Timetable) GenerateTimetable(req TimetableRequest) (*model.TimetableDraft, error) {
+	grid, err := newTimetableGrid(req.Weekdays, req.Periods)
+	if err != nil {
+		return nil, err
+	}
+	if req.ToDate.Before(req.FromDate) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+	if len(req.Courses) == 0 {
+		return nil, errors.New("没有需要排课的课程")
+	}
+	slots := len(grid.weekdays) * len(grid.starts)
+	names := make(map[string]bool)
+	teacherSet := make(map[int64]bool)
+	for _, course := range req.Courses {
+		if names[course.CourseName] {
+			return nil, fmt.Errorf("课程名称重复: %s", course.CourseName)
+		}
+		names[course.CourseName] = true
+		if len(course.TeacherIDs) == 0 {
+			return nil, fmt.Errorf("课程%s没有指定教师", course.CourseName)
+		}
+		if course.BlockPeriods <= 0 || course.BlockPeriods > len(grid.starts) {
+			return nil, fmt.Errorf("课程%s每次连续上课的节数无效", course.CourseName)
+		}
+		if course.WeeklyHours <= 0 || course.WeeklyHours%course.BlockPeriods != 0 {
+			return nil, fmt.Errorf("课程%s的周学时必须是每次连续节数的整数倍", course.CourseName)
+		}
+		for _, teacherID := range course.TeacherIDs {
+			teacherSet[teacherID] = true
+		}
+	}
+	var existingNames []string
+	if err := model.DB.Model(&model.Course{}).Where("course_name IN ?", keys(names)).
+		Pluck("course_name", &existingNames).Error; err != nil {
+		return nil, err
+	}
+	if len(existingNames) > 0 {
+		return nil, fmt.Errorf("课程已存在: %s", existingNames[0])
+	}
+	teacherIDs := make([]int64, 0, len(teacherSet))
+	for teacherID := range teacherSet {
+		teacherIDs = append(teacherIDs, teacherID)
+	}
+	var teacherCount int64
+	if err := model.DB.Model(&model.Teacher{}).Where("id IN ?", teacherIDs).Count(&teacherCount).Error; err != nil {
+		return nil, err
+	}
+	if int(teacherCount) != len(teacherIDs) {
+		return nil, errors.New("存在不存在的教师")
+	}
+
+	var rooms []model.Room
+	if err := model.DB.Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	from, to := dateOf(req.FromDate), dateOf(req.ToDate).AddDate(0, 0, 1)
+	input := timetable.Input{
+		Days:        len(grid.weekdays),
+		Periods:     len(grid.starts),
+		TeacherBusy: make(map[int64][]bool),
+		RoomBusy:    make(map[int64][]bool),
+	}
+	var roomTimes []struct {
+		RoomID    int64
+		StartTime time.Time
+		EndTime   time.Time
+	}
+	if err := roomOccupancy(model.DB, from, to).
+		Select(sessionRoomColumn + " AS room_id, course_time.start_time, course_time.end_time").
+		Scan(&roomTimes).Error; err != nil {
+		return nil, err
+	}
+	var bookedTimes []struct {
+		RoomID    int64
+		StartTime time.Time
+		EndTime   time.Time
+	}
+	if err := bookingOccupancy(model.DB, from, to).
+		Select("room_booking_time.room_id, room_booking_time.start_time, room_booking_time.end_time").
+		Scan(&bookedTimes).Error; err != nil {
+		return nil, err
+	}
+	roomTimes = append(roomTimes, bookedTimes...)
+	for _, occupied := range roomTimes {
+		if input.RoomBusy[occupied.RoomID] == nil {
+			input.RoomBusy[occupied.RoomID] = make([]bool, slots)
+		}
+		grid.markBusy(input.RoomBusy[occupied.RoomID], occupied.StartTime, occupied.EndTime)
+	}
+	var teacherTimes []struct {
+		TeacherID int64
+		StartTime time.Time
+		EndTime   time.Time
+	}
+	if err := model.DB.Model(&model.CourseTime{}).
+		Joins("JOIN course ON course.course_id = course_time.course_id AND course.deleted_at IS NULL").
+		Joins("JOIN course_teacher ON course_teacher.course_id = course_time.course_id").
+		Where("course_teacher.teacher_id IN ? AND course_time.start_time < ? AND course_time.end_time > ?", teacherIDs, to, from).
+		Select("course_teacher.teacher_id, course_time.start_time, course_time.end_time").
+		Scan(&teacherTimes).Error; err != nil {
+		return nil, err
+	}
+	for _, occupied := range teacherTimes {
+		if input.TeacherBusy[occupied.TeacherID] == nil {
+			input.TeacherBusy[occupied.TeacherID] = make([]bool, slots)
+		}
+		grid.markBusy(input.TeacherBusy[occupied.TeacherID], occupied.StartTime, occupied.EndTime)
+	}
+	// 教师不能上课的时间段是硬约束, 希望上课的时间段作为偏好
+	availability, err := loadTeacherAvailability(model.DB, teacherIDs, from, to.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	input.Unavailable = make(map[int64][]bool)
+	for teacherID, blocks := range availability {
+		for _, block := range blocks {
+			d := -1
+			for i, weekday := range grid.weekdays {
+				if weekday == block.Weekday {
+					d = i
+				}
+			}
+			if d < 0 {
+				continue
+			}
+			startClock, _ := common.ParseClock(block.StartClock)
+			endClock, _ := common.ParseClock(block.EndClock)
+			for p := range grid.starts {
+				if grid.starts[p] >= endClock || grid.ends[p] <= startClock {
+					continue
+				}
+				switch block.Kind {
+				case model.AvailabilityUnavailable:
+					if input.Unavailable[teacherID] == nil {
+						input.Unavailable[teacherID] = make([]bool, slots)
+					}
+					input.Unavailable[teacherID][d*len(grid.starts)+p] = true
+				case model.AvailabilityPreferred:
+					input.Preferences = append(input.Preferences, timetable.Preference{
+						TeacherID: teacherID,
+						Day:       d,
+						Period:    p,
+						Weight:    -1,
+					})
+				}
+			}
+		}
+	}
+	for _, pref := range req.Preferences {
+		d := -1
+		for i, weekday := range grid.weekdays {
+			if weekday == pref.Weekday {
+				d = i
+			}
+		}
+		if d < 0 || pref.Period < 0 || pref.Period >= len(grid.starts) {
+			return nil, fmt.Errorf("教师%d的偏好节次无效", pref.TeacherID)
+		}
+		input.Preferences = append(input.Preferences, timetable.Preference{
+			TeacherID: pref.TeacherID,
+			Day:       d,
+			Period:    pref.Period,
+			Weight:    pref.Weight,
+		})
+	}
+	for _, course := range req.Courses {
+		requirement := course.Room
+		if requirement.MinSeats < course.Capacity {
+			requirement.MinSeats = course.Capacity
+		}
+		candidates := rankRooms(rooms, requirement)
+		if len(candidates) > maxRoomCandidates {
+			candidates = candidates[:maxRoomCandidates]
+		}
+		solverCourse := timetable.Course{
+			TeacherIDs: course.TeacherIDs,
+			Meetings:   course.WeeklyHours / course.BlockPeriods,
+			Length:     course.BlockPeriods,
+		}
+		for _, candidate := range candidates {
+			solverCourse.RoomIDs = append(solverCourse.RoomIDs, candidate.Room.ID)
+		}
+		input.Courses = append(input.Courses, solverCourse)
+	}
+
+	result := timetable.Solve(input)
+
+	weekdaysJSON, err := json.Marshal(grid.weekdays)
+	if err != nil {
+		return nil, err
+	}
+	periodsJSON, err := json.Marshal(req.Periods)
+	if err != nil {
+		return nil, err
+	}
+	draft := model.TimetableDraft{
+		Name:     req.Name,
+		Status:   model.TimetableDraftPending,
+		FromDate: dateOf(req.FromDate),
+		ToDate:   dateOf(req.ToDate),
+		Weekdays: weekdaysJSON,
+		Periods:  periodsJSON,
+		Cost:     result.Cost,
+	}
+	placements := make(map[int]timetable.Placement, len(result.Placements))
+	for _, placement := range result.Placements {
+		placements[placement.Course] = placement
+	}
+	reasons := make(map[int]string, len(result.Unplaced))
+	for _, unplaced := range result.Unplaced {
+		reasons[unplaced.Course] = unplaced.Reason
+	}
+	for i, course := range req.Courses {
+		teacherIDsJSON, err := json.Marshal(course.TeacherIDs)
+		if err != nil {
+			return nil, err
+		}
+		draftCourse := model.TimetableCourse{
+			CourseName: course.CourseName,
+			Capacity:   course.Capacity,
+			TeacherIDs: teacherIDsJSON,
+			Reason:     reasons[i],
+		}
+		meetings := []TimetableMeeting{}
+		if placement, ok := placements[i]; ok {
+			roomID := placement.RoomID
+			draftCourse.RoomID = &roomID
+			for _, meeting := range placement.Meetings {
+				meetings = append(meetings, TimetableMeeting{
+					Weekday: grid.weekdays[meeting.Day],
+					Period:  meeting.Period,
+					Length:  meeting.Length,
+				})
+			}
+			sort.Slice(meetings, func(a, b int) bool {
+				if meetings[a].Weekday != meetings[b].Weekday {
+					return meetings[a].Weekday < meetings[b].Weekday
+				}
+				return meetings[a].Period < meetings[b].Period
+			})
+		}
+		if draftCourse.Meetings, err = json.Marshal(meetings); err != nil {
+			return nil, err
+		}
+		draft.Courses = append(draft.Courses, draftCourse)
+	}
+	if err := model.DB.Create(&draft).Error; err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// GetTimetableDrafts 获取课表草案列表
+func (t *Timetable) GetTimetableDrafts() ([]model.TimetableDraft, error) {
+	var drafts []model.TimetableDraft
+	if err := model.DB.Order("id DESC").Find(&drafts).Error; err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// GetTimetableDraft 获取课表草案详情
+func (t *Timetable) GetTimetableDraft(draftID int64) (*model.TimetableDraft, error) {
+	var draft model.TimetableDraft
+	err := model.DB.Preload("Courses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&draft, draftID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("课表草案不存在")
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// DiscardTimetableDraft 放弃待审核的课表草案
+func (t *Timetable) DiscardTimetableDraft(draftID int64) error {
+	result := model.DB.Model(&model.TimetableDraft{}).
+		Where("id = ? AND status = ?", draftID, model.TimetableDraftPending).
+		Update("status", model.TimetableDraftDiscarded)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("课表草案不存在或已处理")
+	}
+	return nil
+}
+
+// ApplyTimetableDraft 把审核后的课表草案批量创建为课程, 未能安排的课程被跳过;
+// 创建前重新检查教室和教师冲突, 任一课程失败时整个草案都不会应用
+func (t *Timetable) ApplyTimetableDraft(draftID int64) (int, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var draft model.TimetableDraft
+	if err := tx.Preload("Courses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&draft, draftID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("课表草案不存在")
+		}
+		return 0, err
+	}
+	if draft.Status != model.TimetableDraftPending {
+		tx.Rollback()
+		return 0, fmt.Errorf("该草案状态为%s, 不能应用", draft.Status)
+	}
+	var weekdays []int
+	var periods []TimetablePeriod
+	if err := json.Unmarshal(draft.Weekdays, &weekdays); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := json.Unmarshal(draft.Periods, &periods); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	grid, err := newTimetableGrid(weekdays, periods)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	calendar, err := loadCalendar(tx, draft.FromDate, draft.ToDate.AddDate(0, 0, 1))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	created := 0
+	for _, draftCourse := range draft.Courses {
+		if draftCourse.RoomID == nil {
+			continue
+		}
+		courseID, err := applyTimetableCourse(tx, grid, &draft, draftCourse, calendar)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("课程%s: %w", draftCourse.CourseName, err)
+		}
+		if err := tx.Model(&draftCourse).Update("course_id", courseID).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		created++
+	}
+	if err := tx.Model(&draft).Update("status", model.TimetableDraftApplied).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// applyTimetableCourse 按草案中的安排创建一门课程
+func applyTimetableCourse(tx *gorm.DB, grid *timetableGrid, draft *model.TimetableDraft, draftCourse model.TimetableCourse, calendar *calendarView) (int64, error) {
+	var meetings []TimetableMeeting
+	if err := json.Unmarshal(draftCourse.Meetings, &meetings); err != nil {
+		return 0, err
+	}
+	var teacherIDs []int64
+	if err := json.Unmarshal(draftCourse.TeacherIDs, &teacherIDs); err != nil {
+		return 0, err
+	}
+	times := grid.courseTimes(meetings, draft.FromDate, draft.ToDate, calendar)
+	if len(times) == 0 {
+		return 0, errors.New("日期范围内没有可以上课的日子")
+	}
+	var count int64
+	if err := tx.Model(&model.Course{}).Where("course_name = ?", draftCourse.CourseName).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, errors.New("该课程已存在且未被删除")
+	}
+	room, err := findCourseRoom(tx, *draftCourse.RoomID, draftCourse.Capacity)
+	if err != nil {
+		return 0, err
+	}
+	conflict, err := roomConflict(tx, room.ID, 0, times)
+	if err != nil {
+		return 0, err
+	}
+	if conflict {
+		return 0, errors.New("该地点在指定时间内已被占用")
+	}
+	for _, teacherID := range teacherIDs {
+		conflict, err := teacherConflict(tx, teacherID, 0, times)
+		if err != nil {
+			return 0, err
+		}
+		if conflict {
+			return 0, errors.New("教师在指定时间内已被安排其他课程")
+		}
+	}
+	if _, err := checkTeacherAvailability(tx, teacherIDs, times); err != nil {
+		return 0, err
+	}
+	course := model.Course{
+		CourseName:  draftCourse.CourseName,
+		Capacity:    draftCourse.Capacity,
+		CourseTimes: times,
+		Location:    room.Label(),
+		RoomID:      &room.ID,
+	}
+	if err := tx.Create(&course).Error; err != nil {
+		return 0, err
+	}
+	var courseTeachers []model.CourseTeacher
+	for _, teacherID := range teacherIDs {
+		courseTeachers = append(courseTeachers, model.CourseTeacher{CourseID: course.CourseID, TeacherID: teacherID})
+	}
+	if err := tx.Create(&courseTeachers).Error; err != nil {
+		return 0, err
+	}
+	return course.CourseID, nil
+}
+
+// TimetableMeetingTime 返回草案中一次上课的起止时刻(HH:MM)
+func (t *Timetable) TimetableMeetingTime(draft *model.TimetableDraft, meeting TimetableMeeting) (string, string, error) {
+	var periods []TimetablePeriod
+	if err := json.Unmarshal(draft.Periods, &periods); err != nil {
+		return "", "", err
+	}
+	if meeting.Period < 0 || meeting.Period+meeting.Length > len(periods) {
+		return "", "", errors.New("无效的节次")
+	}
+	return periods[meeting.Period].Start, periods[meeting.Period+meeting.Length-1].End, nil
+}
+
+func keys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,339 @@
+package timetable
+
+import (
+	"sort"
+)
+
+// 软约束的代价权重
+const (
+	preferenceWeight = 10 // 教师偏好, 乘以偏好本身的权重
+	sameDayWeight    = 20 // 同一门课一天上两次
+	gapWeight        = 3  // 教师当天课程之间每空一节
+	newDayWeight     = 2  // 教师多出一个有课的日子
+	roomRankWeight   = 1  // 教室在候选列表中每靠后一位(座位浪费更多)
+	slotLoadDivisor  = 10 // 同一时段已有课程越多代价越高, 使课程在一周内分散
+	improvePasses    = 2  // 局部改进的轮数
+)
+
+type Course struct {
+	TeacherIDs []int64
+	Meetings   int     // 每周上课次数
+	Length     int     // 每次连续上课的节数
+	RoomIDs    []int64 // 满足要求的候选教室, 按优先顺序排列
+}
+
+type Preference struct {
+	TeacherID int64
+	Day       int
+	Period    int
+	Weight    int // 大于0表示希望避开该节次, 小于0表示希望安排在该节次
+}
+
+type Input struct {
+	Days        int
+	Periods     int
+	Courses     []Course
+	TeacherBusy map[int64][]bool // 已被其他课程占用的节次, 下标为 day*Periods+period
+	RoomBusy    map[int64][]bool
+	Preferences []Preference
+	// Unavailable 教师不可用的节次, 与TeacherBusy一样是硬约束
+	Unavailable map[int64][]bool
+}
+
+type Meeting struct {
+	Day    int
+	Period int
+	Length int
+}
+
+type Placement struct {
+	Course   int
+	RoomID   int64
+	Meetings []Meeting
+	Cost     int
+}
+
+type Unplaced struct {
+	Course int
+	Reason string
+}
+
+type Result struct {
+	Placements []Placement
+	Unplaced   []Unplaced
+	Cost       int
+}
+
+type solver struct {
+	in          Input
+	teacherBusy map[int64][]bool
+	roomBusy    map[int64][]bool
+	preference  map[int64][]int
+	slotLoad    []int
+}
+
+// Solve 生成满足硬约束(教师和教室不重叠)并尽量降低软约束代价的课表;
+// 对同样的输入总是得到同样的结果
+func Solve(in Input) Result {
+	slots := in.Days * in.Periods
+	s := &solver{
+		in:          in,
+		teacherBusy: make(map[int64][]bool),
+		roomBusy:    make(map[int64][]bool),
+		preference:  make(map[int64][]int),
+		slotLoad:    make([]int, slots),
+	}
+	for _, course := range in.Courses {
+		for _, teacherID := range course.TeacherIDs {
+			s.teacherBusy[teacherID] = cloneSlots(in.TeacherBusy[teacherID], slots)
+			for i, unavailable := range in.Unavailable[teacherID] {
+				if unavailable && i < slots {
+					s.teacherBusy[teacherID][i] = true
+				}
+			}
+		}
+		for _, roomID := range course.RoomIDs {
+			s.roomBusy[roomID] = cloneSlots(in.RoomBusy[roomID], slots)
+		}
+	}
+	for _, pref := range in.Preferences {
+		if pref.Day < 0 || pref.Day >= in.Days || pref.Period < 0 || pref.Period >= in.Periods {
+			continue
+		}
+		if s.preference[pref.TeacherID] == nil {
+			s.preference[pref.TeacherID] = make([]int, slots)
+		}
+		s.preference[pref.TeacherID][pref.Day*in.Periods+pref.Period] += pref.Weight
+	}
+
+	order := make([]int, len(in.Courses))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := in.Courses[order[i]], in.Courses[order[j]]
+		if len(a.RoomIDs) != len(b.RoomIDs) {
+			return len(a.RoomIDs) < len(b.RoomIDs)
+		}
+		if a.Meetings*a.Length != b.Meetings*b.Length {
+			return a.Meetings*a.Length > b.Meetings*b.Length
+		}
+		return len(a.TeacherIDs) > len(b.TeacherIDs)
+	})
+
+	placements := make(map[int]*Placement)
+	var result Result
+	for _, index := range order {
+		course := in.Courses[index]
+		if len(course.RoomIDs) == 0 {
+			result.Unplaced = append(result.Unplaced, Unplaced{Course: index, Reason: "没有满足座位数和设施要求的教室"})
+			continue
+		}
+		placement := s.bestPlacement(index)
+		if placement == nil {
+			result.Unplaced = append(result.Unplaced, Unplaced{Course: index, Reason: "教师或教室没有足够的空闲节次"})
+			continue
+		}
+		s.mark(course, placement, true)
+		placements[index] = placement
+	}
+
+	// 局部改进: 依次把每门课拿出来重新安排, 只接受代价更低的位置
+	for pass := 0; pass < improvePasses; pass++ {
+		improved := false
+		for _, index := range order {
+			current, ok := placements[index]
+			if !ok {
+				continue
+			}
+			course := in.Courses[index]
+			s.mark(course, current, false)
+			oldCost := s.placementCost(index, current)
+			candidate := s.bestPlacement(index)
+			if candidate != nil && candidate.Cost < oldCost {
+				placements[index] = candidate
+				current = candidate
+				improved = true
+			} else {
+				current.Cost = oldCost
+			}
+			s.mark(course, current, true)
+		}
+		if !improved {
+			break
+		}
+	}
+
+	for _, index := range order {
+		if placement, ok := placements[index]; ok {
+			result.Placements = append(result.Placements, *placement)
+			result.Cost += placement.Cost
+		}
+	}
+	sort.Slice(result.Placements, func(i, j int) bool {
+		return result.Placements[i].Course < result.Placements[j].Course
+	})
+	sort.Slice(result.Unplaced, func(i, j int) bool {
+		return result.Unplaced[i].Course < result.Unplaced[j].Course
+	})
+	return result
+}
+
+// bestPlacement 在所有候选教室中找出代价最低的安排, 找不到时返回nil
+func (s *solver) bestPlacement(index int) *Placement {
+	course := s.in.Courses[index]
+	var best *Placement
+	for rank, roomID := range course.RoomIDs {
+		placement := &Placement{Course: index, RoomID: roomID, Cost: rank * roomRankWeight}
+		ok := true
+		for m := 0; m < course.Meetings; m++ {
+			meeting, cost, found := s.bestMeeting(course, roomID, placement.Meetings)
+			if !found {
+				ok = false
+				break
+			}
+			placement.Meetings = append(placement.Meetings, meeting)
+			placement.Cost += cost
+			s.markMeeting(course, roomID, meeting, true)
+		}
+		for _, meeting := range placement.Meetings {
+			s.markMeeting(course, roomID, meeting, false)
+		}
+		if ok && (best == nil || placement.Cost < best.Cost) {
+			best = placement
+		}
+	}
+	return best
+}
+
+// bestMeeting 为课程的一次上课选择代价最低的节次, 代价相同时取较早的节次
+func (s *solver) bestMeeting(course Course, roomID int64, placed []Meeting) (Meeting, int, bool) {
+	var best Meeting
+	bestCost := 0
+	found := false
+	for day := 0; day < s.in.Days; day++ {
+		for period := 0; period+course.Length <= s.in.Periods; period++ {
+			meeting := Meeting{Day: day, Period: period, Length: course.Length}
+			if !s.free(course, roomID, meeting) {
+				continue
+			}
+			cost := s.meetingCost(course, meeting, placed)
+			if !found || cost < bestCost {
+				best, bestCost, found = meeting, cost, true
+			}
+		}
+	}
+	return best, bestCost, found
+}
+
+func (s *solver) free(course Course, roomID int64, meeting Meeting) bool {
+	for p := meeting.Period; p < meeting.Period+meeting.Length; p++ {
+		slot := meeting.Day*s.in.Periods + p
+		if s.roomBusy[roomID][slot] {
+			return false
+		}
+		for _, teacherID := range course.TeacherIDs {
+			if s.teacherBusy[teacherID][slot] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// meetingCost 计算在当前占用情况下安排一次上课的软约束代价
+func (s *solver) meetingCost(course Course, meeting Meeting, placed []Meeting) int {
+	cost := 0
+	for _, other := range placed {
+		if other.Day == meeting.Day {
+			cost += sameDayWeight
+		}
+	}
+	for p := meeting.Period; p < meeting.Period+meeting.Length; p++ {
+		cost += s.slotLoad[meeting.Day*s.in.Periods+p] / slotLoadDivisor
+	}
+	for _, teacherID := range course.TeacherIDs {
+		if pref := s.preference[teacherID]; pref != nil {
+			for p := meeting.Period; p < meeting.Period+meeting.Length; p++ {
+				cost += pref[meeting.Day*s.in.Periods+p] * preferenceWeight
+			}
+		}
+		cost += s.gapCost(teacherID, meeting)
+	}
+	return cost
+}
+
+// gapCost 教师当天已有课程时, 按与最近一节课之间空出的节数计算代价; 当天没有课时计新开一天的代价
+func (s *solver) gapCost(teacherID int64, meeting Meeting) int {
+	busy := s.teacherBusy[teacherID]
+	base := meeting.Day * s.in.Periods
+	nearest := -1
+	for p := 0; p < s.in.Periods; p++ {
+		if !busy[base+p] {
+			continue
+		}
+		var distance int
+		switch {
+		case p < meeting.Period:
+			distance = meeting.Period - p - 1
+		default:
+			distance = p - (meeting.Period + meeting.Length)
+		}
+		if nearest < 0 || distance < nearest {
+			nearest = distance
+		}
+	}
+	if nearest < 0 {
+		return newDayWeight
+	}
+	return nearest * gapWeight
+}
+
+// placementCost 重新计算一门课当前安排的代价, 调用前须先把这门课从占用中移除
+func (s *solver) placementCost(index int, placement *Placement) int {
+	course := s.in.Courses[index]
+	cost := 0
+	for rank, roomID := range course.RoomIDs {
+		if roomID == placement.RoomID {
+			cost = rank * roomRankWeight
+			break
+		}
+	}
+	var placed []Meeting
+	for _, meeting := range placement.Meetings {
+		cost += s.meetingCost(course, meeting, placed)
+		placed = append(placed, meeting)
+		s.markMeeting(course, placement.RoomID, meeting, true)
+	}
+	for _, meeting := range placement.Meetings {
+		s.markMeeting(course, placement.RoomID, meeting, false)
+	}
+	return cost
+}
+
+func (s *solver) mark(course Course, placement *Placement, busy bool) {
+	for _, meeting := range placement.Meetings {
+		s.markMeeting(course, placement.RoomID, meeting, busy)
+	}
+}
+
+func (s *solver) markMeeting(course Course, roomID int64, meeting Meeting, busy bool) {
+	delta := 1
+	if !busy {
+		delta = -1
+	}
+	for p := meeting.Period; p < meeting.Period+meeting.Length; p++ {
+		slot := meeting.Day*s.in.Periods + p
+		s.roomBusy[roomID][slot] = busy
+		for _, teacherID := range course.TeacherIDs {
+			s.teacherBusy[teacherID][slot] = busy
+		}
+		s.slotLoad[slot] += delta
+	}
+}
+
+func cloneSlots(src []bool, size int) []bool {
+	dst := make([]bool, size)
+	copy(dst, src)
+	return dst
+}
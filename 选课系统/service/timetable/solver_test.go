@@ -0,0 +1,208 @@
+package timetable
+
+import (
+	"reflect"
+	"testing"
+)
+
+// busySlots 生成days*periods个节次的占用表, 把给出的(day, period)标记为已占用
+func busySlots(days, periods int, taken ...[2]int) []bool {
+	slots := make([]bool, days*periods)
+	for _, t := range taken {
+		slots[t[0]*periods+t[1]] = true
+	}
+	return slots
+}
+
+// checkHardConstraints 检查结果是否满足硬约束: 节次在范围内, 上课次数和节数正确,
+// 同一教师和同一教室不重叠, 且不占用已有课程和教师不可用的节次
+func checkHardConstraints(t *testing.T, in Input, result Result) {
+	t.Helper()
+	teacherUsed := make(map[int64]map[int]int)
+	roomUsed := make(map[int64]map[int]int)
+	use := func(used map[int64]map[int]int, id int64, slot, course int, what string) {
+		if used[id] == nil {
+			used[id] = make(map[int]int)
+		}
+		if other, ok := used[id][slot]; ok {
+			t.Errorf("%s %d 在节次 %d 同时安排了课程 %d 和 %d", what, id, slot, other, course)
+		}
+		used[id][slot] = course
+	}
+	for _, placement := range result.Placements {
+		course := in.Courses[placement.Course]
+		if len(placement.Meetings) != course.Meetings {
+			t.Errorf("课程 %d 安排了 %d 次, 需要 %d 次", placement.Course, len(placement.Meetings), course.Meetings)
+		}
+		inRooms := false
+		for _, roomID := range course.RoomIDs {
+			if roomID == placement.RoomID {
+				inRooms = true
+			}
+		}
+		if !inRooms {
+			t.Errorf("课程 %d 安排在不在候选列表中的教室 %d", placement.Course, placement.RoomID)
+		}
+		for _, meeting := range placement.Meetings {
+			if meeting.Length != course.Length {
+				t.Errorf("课程 %d 每次 %d 节, 需要 %d 节", placement.Course, meeting.Length, course.Length)
+			}
+			if meeting.Day < 0 || meeting.Day >= in.Days || meeting.Period < 0 || meeting.Period+meeting.Length > in.Periods {
+				t.Errorf("课程 %d 的安排 %+v 超出范围", placement.Course, meeting)
+				continue
+			}
+			for p := meeting.Period; p < meeting.Period+meeting.Length; p++ {
+				slot := meeting.Day*in.Periods + p
+				use(roomUsed, placement.RoomID, slot, placement.Course, "教室")
+				if busy := in.RoomBusy[placement.RoomID]; slot < len(busy) && busy[slot] {
+					t.Errorf("课程 %d 占用了教室 %d 已被占用的节次 %d", placement.Course, placement.RoomID, slot)
+				}
+				for _, teacherID := range course.TeacherIDs {
+					use(teacherUsed, teacherID, slot, placement.Course, "教师")
+					if busy := in.TeacherBusy[teacherID]; slot < len(busy) && busy[slot] {
+						t.Errorf("课程 %d 占用了教师 %d 已有课程的节次 %d", placement.Course, teacherID, slot)
+					}
+					if unavailable := in.Unavailable[teacherID]; slot < len(unavailable) && unavailable[slot] {
+						t.Errorf("课程 %d 占用了教师 %d 不可用的节次 %d", placement.Course, teacherID, slot)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSolveHardConstraints(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       Input
+		placed   int
+		unplaced []int
+	}{
+		{
+			name: "同一教师的两门课不重叠",
+			in: Input{
+				Days: 1, Periods: 2,
+				Courses: []Course{
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{20}},
+				},
+			},
+			placed: 2,
+		},
+		{
+			name: "同一教室的两门课不重叠",
+			in: Input{
+				Days: 1, Periods: 2,
+				Courses: []Course{
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+					{TeacherIDs: []int64{2}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+				},
+			},
+			placed: 2,
+		},
+		{
+			name: "合上的课程对所有教师都不重叠",
+			in: Input{
+				Days: 1, Periods: 3,
+				Courses: []Course{
+					{TeacherIDs: []int64{1, 2}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+					{TeacherIDs: []int64{2}, Meetings: 1, Length: 1, RoomIDs: []int64{20}},
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{30}},
+				},
+			},
+			placed: 3,
+		},
+		{
+			name: "避开已有课程和教师不可用的节次",
+			in: Input{
+				Days: 1, Periods: 3,
+				Courses: []Course{
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+				},
+				TeacherBusy: map[int64][]bool{1: busySlots(1, 3, [2]int{0, 0})},
+				Unavailable: map[int64][]bool{1: busySlots(1, 3, [2]int{0, 1})},
+			},
+			placed: 1,
+		},
+		{
+			name: "避开教室已被占用的节次",
+			in: Input{
+				Days: 1, Periods: 2,
+				Courses: []Course{
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+				},
+				RoomBusy: map[int64][]bool{10: busySlots(1, 2, [2]int{0, 0})},
+			},
+			placed: 1,
+		},
+		{
+			name: "连续多节的课程不跨出当天",
+			in: Input{
+				Days: 2, Periods: 3,
+				Courses: []Course{
+					{TeacherIDs: []int64{1}, Meetings: 2, Length: 2, RoomIDs: []int64{10}},
+				},
+				TeacherBusy: map[int64][]bool{1: busySlots(2, 3, [2]int{0, 1}, [2]int{1, 1})},
+			},
+			unplaced: []int{0},
+		},
+		{
+			name: "没有候选教室的课程不安排",
+			in: Input{
+				Days: 1, Periods: 2,
+				Courses: []Course{
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1},
+					{TeacherIDs: []int64{2}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+				},
+			},
+			placed:   1,
+			unplaced: []int{0},
+		},
+		{
+			name: "节次不够时后排的课程不安排",
+			in: Input{
+				Days: 1, Periods: 2,
+				Courses: []Course{
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+					{TeacherIDs: []int64{1}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+				},
+			},
+			placed:   2,
+			unplaced: []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Solve(tt.in)
+			checkHardConstraints(t, tt.in, result)
+			if len(result.Placements) != tt.placed {
+				t.Errorf("安排了 %d 门课程, 期望 %d 门", len(result.Placements), tt.placed)
+			}
+			var unplaced []int
+			for _, item := range result.Unplaced {
+				unplaced = append(unplaced, item.Course)
+			}
+			if !reflect.DeepEqual(unplaced, tt.unplaced) {
+				t.Errorf("未安排的课程为 %v, 期望 %v", unplaced, tt.unplaced)
+			}
+		})
+	}
+}
+
+func TestSolveDeterministic(t *testing.T) {
+	in := Input{
+		Days: 5, Periods: 4,
+		Courses: []Course{
+			{TeacherIDs: []int64{1}, Meetings: 2, Length: 2, RoomIDs: []int64{10, 20}},
+			{TeacherIDs: []int64{1, 2}, Meetings: 1, Length: 1, RoomIDs: []int64{10}},
+			{TeacherIDs: []int64{2}, Meetings: 3, Length: 1, RoomIDs: []int64{20, 10}},
+		},
+		Preferences: []Preference{{TeacherID: 1, Day: 0, Period: 0, Weight: 5}},
+	}
+	first := Solve(in)
+	checkHardConstraints(t, in, first)
+	if second := Solve(in); !reflect.DeepEqual(first, second) {
+		t.Errorf("同样的输入得到不同的结果:\n%+v\n%+v", first, second)
+	}
+}
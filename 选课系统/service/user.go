@@ -4,9 +4,11 @@ import (
 	"errors"
 	"finaltenzor/model"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type User struct{}
@@ -50,7 +52,7 @@ func (us *User) GetCurrentUserStatus(studentID string) (string, string, error) {
 	return user.UserName, user.UserID, nil
 }
 
-// GrabCourse 抢课
+// GrabCourse 抢课, 锁住课程后检查容量和时间冲突, 防止同时抢课超出容量
 func (us *User) GrabCourse(studentID string, courseID int64) error {
 	tx := model.DB.Begin()
 	defer func() {
@@ -59,42 +61,29 @@ func (us *User) GrabCourse(studentID string, courseID int64) error {
 		}
 	}()
 	var course model.Course
-	if err := tx.Where("course_id = ?", courseID).First(&course).Error; err != nil {
-		return errors.New("课程未找到")
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("CourseTimes").
+		Where("course_id = ?", courseID).First(&course).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("课程未找到")
+		}
+		return err
 	}
 	var student model.User
 	if err := tx.Where("user_id = ?", studentID).First(&student).Error; err != nil {
+		tx.Rollback()
 		return errors.New("学生未找到")
 	}
-	var courseStudent model.CourseStudent
-	if err := tx.Where("student_id = ? AND course_id = ?", student.UserID, courseID).First(&courseStudent).Error; err == nil {
-		return errors.New("学生已经抢过该课程")
-	}
-	var schedule []model.Course
-	err := tx.Preload("CourseTimes").
-		Joins("JOIN course_student ON course_student.course_id = course.course_id").
-		Where("course_student.student_id = ?", student.UserID).
-		Find(&schedule).Error
-	if err == nil {
-		for _, existingCourse := range schedule {
-			for _, courseTime := range existingCourse.CourseTimes {
-				for _, newCourseTime := range course.CourseTimes {
-					if courseTime.StartTime.Before(newCourseTime.EndTime) && newCourseTime.StartTime.Before(courseTime.EndTime) {
-						return errors.New("学生课程时间冲突，无法选择该课程")
-					}
-				}
-			}
-		}
-	}
-	var count int64
-	if err := tx.Model(&model.CourseStudent{}).Where("course_id = ?", courseID).Count(&count).Error; err != nil {
+	counts, err := enrollmentCounts(tx, []int64{courseID})
+	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	if count >= int64(course.Capacity) {
-		return errors.New("课程容量已满，无法选择该课程")
+	if err := checkGrab(tx, student.UserID, course, counts[courseID], nil); err != nil {
+		tx.Rollback()
+		return err
 	}
-	courseStudent = model.CourseStudent{
+	courseStudent := model.CourseStudent{
 		StudentID: student.UserID,
 		CourseID:  courseID,
 	}
@@ -124,47 +113,28 @@ func (us *User) GetGrabbedCourses(studentID string) ([]model.Course, int, error)
 	return courses, len(courses), nil
 }
 
-// GetUserSchedule 获取用户的课表
-func (us *User) GetUserSchedule(studentID string) ([]model.Course, error) {
-	var courses []model.Course
-	err := model.DB.Preload("CourseTimes").
-		Joins("JOIN course_student ON course_student.course_id = course.course_id").
-		Where("course_student.student_id = ?", studentID).
-		Find(&courses).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("用户未选课")
-		}
-		return nil, err
-	}
-	return courses, nil
+// GetUserSchedule 获取用户在[start, end)时间段内的课表
+func (us *User) GetUserSchedule(studentID string, start time.Time, end time.Time) (*WeekSchedule, error) {
+	return loadWeekSchedule(model.DB.Joins("JOIN course_student ON course_student.course_id = course.course_id").
+		Where("course_student.student_id = ?", studentID), start, end)
 }
 
 // GetCoursesList 获取课程列表
-func (us *User) GetCoursesList(page int, limit int, courseName string, teachers []string, location string, times []model.CourseTime) ([]model.Course, int, error) {
-	TeacherService := TeacherService{}
+func (us *User) GetCoursesList(page int, limit int, courseName string, teacherIDs []int64, teachers []string, location string, times []model.CourseTime) ([]model.Course, int, error) {
 	var courses []model.Course
 	var total int64
 	query := model.DB.Model(&model.Course{}).Preload("CourseTimes")
 	if courseName != "" {
 		query = query.Where("course_name LIKE ?", "%"+courseName+"%")
 	}
-	if len(teachers) > 0 {
-		var teacherIDs []int64
-		for _, teacherName := range teachers {
-			teacher, err := TeacherService.FindTeacherByName(teacherName)
-			if err != nil {
-				return nil, 0, err
-			}
-			if teacher == nil {
-				return nil, 0, errors.New("教师未找到: " + teacherName)
-			}
-			teacherIDs = append(teacherIDs, teacher.ID)
-		}
-		if len(teacherIDs) > 0 {
-			query = query.Joins("JOIN course_teacher ON course_teacher.course_id = course.course_id").
-				Where("course_teacher.teacher_id IN (?)", teacherIDs)
+	if len(teacherIDs) > 0 || len(teachers) > 0 {
+		// 同名教师有多位或姓名不存在时报错, 与保存课程时的规则相同
+		resolved, err := resolveCourseTeachers(model.DB, teacherIDs, teachers)
+		if err != nil {
+			return nil, 0, err
 		}
+		query = query.Where("course.course_id IN (?)",
+			model.DB.Model(&model.CourseTeacher{}).Select("course_id").Where("teacher_id IN ?", resolved))
 	}
 	if len(times) > 0 {
 		var timeConditions []string
@@ -176,7 +146,8 @@ func (us *User) GetCoursesList(page int, limit int, courseName string, teachers
 		query = query.Where(strings.Join(timeConditions, " OR "), timeArgs...)
 	}
 	if location != "" {
-		query = query.Where("location LIKE ?", "%"+location+"%")
+		query = query.Where("course.location LIKE ? OR course.course_id IN (SELECT course_id FROM course_time WHERE course_time.location LIKE ?)",
+			"%"+location+"%", "%"+location+"%")
 	}
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -196,17 +167,29 @@ func (us *User) UserGetCourseDetail(courseID int64) (*model.Course, error) {
 	return &course, nil
 }
 
-// GiveUpCourse 放弃课程
+// GiveUpCourse 放弃课程, 空出的名额会提醒关注该课程的学生
 func (us *User) GiveUpCourse(studentID string, courseID string) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
 	var courseStudent model.CourseStudent
-	if err := model.DB.Where("student_id = ? AND course_id = ?", studentID, courseID).First(&courseStudent).Error; err != nil {
+	if err := tx.Where("student_id = ? AND course_id = ?", studentID, courseID).First(&courseStudent).Error; err != nil {
+		tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("学生未选这门课程")
 		}
 		return err
 	}
-	if err := model.DB.Where("student_id = ? AND course_id = ?", studentID, courseID).Delete(&courseStudent).Error; err != nil {
+	if err := tx.Where("student_id = ? AND course_id = ?", studentID, courseID).Delete(&courseStudent).Error; err != nil {
+		tx.Rollback()
 		return err
 	}
-	return nil
+	if err := notifyWatchers(tx, courseStudent.CourseID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
 }
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"finaltenzor/model"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// 合讲课程的课时分摊方式
+const (
+	WorkloadSplitFull  = "full"  // 每位教师都计全部课时
+	WorkloadSplitEqual = "equal" // 课时在授课教师之间平均分摊
+	WorkloadSplitShare = "share" // 按设置的分摊比例, 未设置比例的教师平分剩余课时
+)
+
+type TeacherWorkload struct {
+	Teacher      model.Teacher
+	Courses      int
+	Students     int
+	ContactHours float64
+	Weeks        []WorkloadWeek
+}
+
+type WorkloadWeek struct {
+	StartDate time.Time
+	Hours     float64
+}
+
+// courseShares 按分摊方式计算每门课每位教师承担的课时比例
+func courseShares(rows []model.CourseTeacher, split string) map[int64]map[int64]float64 {
+	byCourse := make(map[int64][]model.CourseTeacher)
+	for _, row := range rows {
+		byCourse[row.CourseID] = append(byCourse[row.CourseID], row)
+	}
+	shares := make(map[int64]map[int64]float64, len(byCourse))
+	for courseID, teachers := range byCourse {
+		shares[courseID] = make(map[int64]float64, len(teachers))
+		switch split {
+		case WorkloadSplitFull:
+			for _, teacher := range teachers {
+				shares[courseID][teacher.TeacherID] = 1
+			}
+		case WorkloadSplitShare:
+			assigned, unset := 0.0, 0
+			for _, teacher := range teachers {
+				assigned += teacher.HourShare
+				if teacher.HourShare == 0 {
+					unset++
+				}
+			}
+			for _, teacher := range teachers {
+				switch {
+				case teacher.HourShare > 0:
+					shares[courseID][teacher.TeacherID] = teacher.HourShare
+				case assigned < 1:
+					shares[courseID][teacher.TeacherID] = (1 - assigned) / float64(unset)
+				}
+			}
+		default:
+			for _, teacher := range teachers {
+				shares[courseID][teacher.TeacherID] = 1 / float64(len(teachers))
+			}
+		}
+	}
+	return shares
+}
+
+// GetTeacherWorkload 统计[start, end)内每位教师的授课课时、课程数、学生数以及每周课时分布,
+// department不为空时只统计该院系的教师
+func (t *TeacherService) GetTeacherWorkload(start time.Time, end time.Time, department string, split string) ([]TeacherWorkload, []time.Time, error) {
+	if !end.After(start) {
+		return nil, nil, errors.New("结束日期必须晚于开始日期")
+	}
+	var teachers []model.Teacher
+	query := model.DB.Order("id")
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	if err := query.Find(&teachers).Error; err != nil {
+		return nil, nil, err
+	}
+	var weeks []time.Time
+	for week, _ := WeekOf(start); week.Before(end); week = week.AddDate(0, 0, 7) {
+		weeks = append(weeks, week)
+	}
+	workloads := make([]TeacherWorkload, 0, len(teachers))
+	if len(teachers) == 0 {
+		return workloads, weeks, nil
+	}
+	teacherIDs := make([]int64, 0, len(teachers))
+	for _, teacher := range teachers {
+		teacherIDs = append(teacherIDs, teacher.ID)
+	}
+
+	var courseIDs []int64
+	if err := model.DB.Model(&model.CourseTeacher{}).
+		Joins("JOIN course ON course.course_id = course_teacher.course_id AND course.deleted_at IS NULL").
+		Where("course_teacher.teacher_id IN ? AND EXISTS (SELECT 1 FROM course_time WHERE course_time.course_id = course.course_id AND start_time < ? AND end_time > ?)",
+			teacherIDs, end, start).
+		Distinct().Pluck("course_teacher.course_id", &courseIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	var courseTeachers []model.CourseTeacher
+	var times []model.CourseTime
+	if len(courseIDs) > 0 {
+		// 分摊比例按课程的全部授课教师计算, 不受院系筛选影响
+		if err := model.DB.Where("course_id IN ?", courseIDs).Find(&courseTeachers).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := model.DB.Where("course_id IN ? AND start_time < ? AND end_time > ?", courseIDs, end, start).
+			Find(&times).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	enrolled, err := enrollmentCounts(model.DB, courseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	shares := courseShares(courseTeachers, split)
+
+	index := make(map[int64]int, len(teachers))
+	for i, teacher := range teachers {
+		index[teacher.ID] = i
+		workloads = append(workloads, TeacherWorkload{Teacher: teacher, Weeks: make([]WorkloadWeek, len(weeks))})
+		for w, week := range weeks {
+			workloads[i].Weeks[w].StartDate = week
+		}
+	}
+	for _, courseTeacher := range courseTeachers {
+		i, ok := index[courseTeacher.TeacherID]
+		if !ok {
+			continue
+		}
+		workloads[i].Courses++
+		workloads[i].Students += enrolled[courseTeacher.CourseID]
+	}
+	for _, courseTime := range times {
+		hours := courseTime.EndTime.Sub(courseTime.StartTime).Hours()
+		week := int(dateOf(courseTime.StartTime).Sub(weeks[0]).Hours() / 24 / 7)
+		for teacherID, share := range shares[courseTime.CourseID] {
+			i, ok := index[teacherID]
+			if !ok {
+				continue
+			}
+			workloads[i].ContactHours += hours * share
+			if week >= 0 && week < len(weeks) {
+				workloads[i].Weeks[week].Hours += hours * share
+			}
+		}
+	}
+	sort.SliceStable(workloads, func(i, j int) bool {
+		if workloads[i].Teacher.Department != workloads[j].Teacher.Department {
+			return workloads[i].Teacher.Department < workloads[j].Teacher.Department
+		}
+		return workloads[i].Teacher.ID < workloads[j].Teacher.ID
+	})
+	return workloads, weeks, nil
+}
+
+type TeacherShare struct {
+	TeacherID int64
+	Share     float64
+}
+
+// SetTeacherShares 设置合讲课程各教师的课时分摊比例, 未在shares中出现的教师保持原比例, 全部比例之和不能超过1
+func (t *TeacherService) SetTeacherShares(courseID int64, shares []TeacherShare) error {
+	for _, share := range shares {
+		if share.Share < 0 || share.Share > 1 {
+			return errors.New("分摊比例必须在0到1之间")
+		}
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	var teachers []model.CourseTeacher
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("course_id = ?", courseID).
+		Find(&teachers).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	stored := make(map[int64]float64, len(teachers))
+	for _, teacher := range teachers {
+		stored[teacher.TeacherID] = teacher.HourShare
+	}
+	for _, share := range shares {
+		if _, ok := stored[share.TeacherID]; !ok {
+			tx.Rollback()
+			return errors.New("该教师不是这门课程的授课教师")
+		}
+		stored[share.TeacherID] = share.Share
+	}
+	total := 0.0
+	for _, share := range stored {
+		total += share
+	}
+	if total > 1.0001 {
+		tx.Rollback()
+		return fmt.Errorf("分摊比例之和不能超过1, 加上其他授课教师的比例共为%.4g", total)
+	}
+	for _, share := range shares {
+		if err := tx.Model(&model.CourseTeacher{}).
+			Where("course_id = ? AND teacher_id = ?", courseID, share.TeacherID).
+			Update("hour_share", share.Share).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,43 @@
+/*
+ 生产环境不会自动迁移(APP_PROD), 升级到教室表时按顺序执行本脚本:
+ 把课程表中的上课地点文本转换为教室, 形如"教学楼 101"的地点按第一个空格拆分为教学楼和教室编号,
+ 座位数取在该地点上课的课程的最大容量
+*/
+
+SET NAMES utf8mb4;
+
+ALTER TABLE `course` ADD COLUMN `room_id` bigint NULL DEFAULT NULL COMMENT '教室ID' AFTER `location`;
+ALTER TABLE `course` ADD INDEX `idx_course_room_id`(`room_id` ASC) USING BTREE;
+
+CREATE TABLE IF NOT EXISTS `room`  (
+  `building` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '教学楼',
+  `name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '教室编号',
+  `seats` int NOT NULL COMMENT '座位数',
+  `projector` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否有投影仪',
+  `lab` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否为实验室',
+  `accessible` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否无障碍',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_room_building_name`(`building` ASC, `name` ASC) USING BTREE,
+  INDEX `idx_room_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+INSERT INTO `room` (`building`, `name`, `seats`, `created_at`, `updated_at`)
+SELECT
+  IF(LOCATE(' ', TRIM(`location`)) > 0, SUBSTRING_INDEX(TRIM(`location`), ' ', 1), ''),
+  IF(LOCATE(' ', TRIM(`location`)) > 0, TRIM(SUBSTRING(TRIM(`location`), LOCATE(' ', TRIM(`location`)) + 1)), TRIM(`location`)),
+  MAX(`capacity`),
+  NOW(3),
+  NOW(3)
+FROM `course`
+WHERE `room_id` IS NULL AND `location` <> ''
+GROUP BY TRIM(`location`)
+ON DUPLICATE KEY UPDATE `seats` = GREATEST(`room`.`seats`, VALUES(`seats`));
+
+UPDATE `course`
+JOIN `room` ON TRIM(`course`.`location`) = IF(`room`.`building` = '', `room`.`name`, CONCAT(`room`.`building`, ' ', `room`.`name`))
+SET `course`.`room_id` = `room`.`id`
+WHERE `course`.`room_id` IS NULL;
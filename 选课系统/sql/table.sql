@@ -17,6 +17,47 @@
 SET NAMES utf8mb4;
 SET FOREIGN_KEY_CHECKS = 0;
 
+-- ----------------------------
+-- Table structure for attendance
+-- ----------------------------
+DROP TABLE IF EXISTS `attendance`;
+CREATE TABLE `attendance`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `course_id` bigint DEFAULT NULL,
+  `session_start` datetime(3) DEFAULT NULL,
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci DEFAULT NULL,
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci DEFAULT NULL,
+  `method` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci DEFAULT NULL,
+  `marked_by` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE KEY `idx_attendance_session_student` (`course_id`,`session_start`,`student_id`),
+  KEY `idx_attendance_student_id` (`student_id`),
+  KEY `idx_attendance_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for calendar_event
+-- ----------------------------
+DROP TABLE IF EXISTS `calendar_event`;
+CREATE TABLE `calendar_event`  (
+  `uid` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT 'ICS事件UID',
+  `kind` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '事件类型',
+  `name` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '事件名称',
+  `start_date` date NOT NULL COMMENT '开始日期',
+  `end_date` date NOT NULL COMMENT '结束日期(含)',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_calendar_event_uid`(`uid` ASC) USING BTREE,
+  INDEX `idx_calendar_event_kind`(`kind` ASC) USING BTREE,
+  INDEX `idx_calendar_event_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for course
 -- ----------------------------
@@ -24,12 +65,22 @@ DROP TABLE IF EXISTS `course`;
 CREATE TABLE `course`  (
   `course_id` bigint NOT NULL AUTO_INCREMENT COMMENT '课程ID',
   `course_name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '课程名称',
+  `course_code` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '课程代码, 同一门课历次开课共用, 培养方案按代码匹配',
   `capacity` int NOT NULL COMMENT '课程容量',
   `location` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '上课地点',
+  `room_id` bigint NULL DEFAULT NULL COMMENT '教室ID',
+  `credits` decimal(4, 1) NOT NULL DEFAULT 0.0 COMMENT '学分',
+  `category` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '课程类别, 如通识必修、专业选修',
+  `grade_scale_id` bigint NULL DEFAULT NULL COMMENT '成绩等级制ID, 为空时使用默认等级制',
+  `min_enrollment` int NOT NULL DEFAULT 0 COMMENT '最低开课人数',
+  `alternative_course_id` bigint NULL DEFAULT NULL COMMENT '停开时的替代课程ID',
   `created_at` datetime(3) NOT NULL COMMENT '创建时间',
   `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
   `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
   PRIMARY KEY (`course_id`) USING BTREE,
+  INDEX `idx_course_room_id`(`room_id` ASC) USING BTREE,
+  INDEX `idx_course_course_code`(`course_code` ASC) USING BTREE,
+  INDEX `idx_course_category`(`category` ASC) USING BTREE,
   INDEX `idx_course_deleted_at`(`deleted_at` ASC) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 107 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
 
@@ -48,7 +99,8 @@ CREATE TABLE `course_student`  (
 DROP TABLE IF EXISTS `course_teacher`;
 CREATE TABLE `course_teacher`  (
   `course_id` int UNSIGNED NOT NULL COMMENT '课程ID',
-  `teacher_id` int UNSIGNED NOT NULL COMMENT '教师ID'
+  `teacher_id` int UNSIGNED NOT NULL COMMENT '教师ID',
+  `hour_share` decimal(5, 4) NOT NULL DEFAULT 0.0000 COMMENT '合讲课程的课时分摊比例, 0表示未设置'
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
 
 -- ----------------------------
@@ -59,24 +111,467 @@ CREATE TABLE `course_time`  (
   `course_id` bigint NULL DEFAULT NULL COMMENT '课程ID',
   `start_time` datetime NOT NULL COMMENT '开始时间',
   `end_time` datetime NOT NULL COMMENT '结束时间',
+  `room_id` bigint NULL DEFAULT NULL COMMENT '本次上课的教室ID, 为空时使用课程的教室',
+  `location` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '本次上课教室的名称',
+  `online` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否线上上课',
+  `meeting_link` varchar(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '线上上课的会议链接',
   INDEX `fk_course_course_times`(`course_id` ASC) USING BTREE,
+  INDEX `idx_course_time_room_id`(`room_id` ASC) USING BTREE,
   CONSTRAINT `fk_course_course_times` FOREIGN KEY (`course_id`) REFERENCES `course` (`course_id`) ON DELETE RESTRICT ON UPDATE RESTRICT
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for course_watch
+-- ----------------------------
+DROP TABLE IF EXISTS `course_watch`;
+CREATE TABLE `course_watch`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  `course_id` bigint NOT NULL COMMENT '课程ID',
+  `notified_at` datetime(3) NULL DEFAULT NULL COMMENT '最近一次提醒的时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE KEY `idx_course_watch_student_course` (`student_id`,`course_id`),
+  KEY `idx_course_watch_course_id` (`course_id`),
+  KEY `idx_course_watch_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for degree_program
+-- ----------------------------
+DROP TABLE IF EXISTS `degree_program`;
+CREATE TABLE `degree_program`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `major` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '专业',
+  `entry_year` int NOT NULL COMMENT '入学年份',
+  `name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '培养方案名称',
+  `requirements` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci COMMENT '毕业要求',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE KEY `idx_degree_program_major_year` (`major`,`entry_year`),
+  KEY `idx_degree_program_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for evaluation_response
+-- ----------------------------
+DROP TABLE IF EXISTS `evaluation_response`;
+CREATE TABLE `evaluation_response`  (
+  `id` char(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '随机主键',
+  `survey_id` bigint NOT NULL COMMENT '问卷ID',
+  `course_id` bigint NOT NULL COMMENT '课程ID',
+  `answers` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci COMMENT '答案',
+  PRIMARY KEY (`id`) USING BTREE,
+  KEY `idx_evaluation_response_course` (`survey_id`,`course_id`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for evaluation_submission
+-- ----------------------------
+DROP TABLE IF EXISTS `evaluation_submission`;
+CREATE TABLE `evaluation_submission`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `survey_id` bigint NOT NULL COMMENT '问卷ID',
+  `course_id` bigint NOT NULL COMMENT '课程ID',
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE KEY `idx_evaluation_submission_student` (`survey_id`,`course_id`,`student_id`),
+  KEY `idx_evaluation_submission_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for evaluation_survey
+-- ----------------------------
+DROP TABLE IF EXISTS `evaluation_survey`;
+CREATE TABLE `evaluation_survey`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `title` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '问卷标题',
+  `questions` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci COMMENT '问卷题目',
+  `start_time` datetime NOT NULL COMMENT '评教开始时间',
+  `end_time` datetime NOT NULL COMMENT '评教结束时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  KEY `idx_evaluation_survey_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for exam
+-- ----------------------------
+DROP TABLE IF EXISTS `exam`;
+CREATE TABLE `exam`  (
+  `plan_id` bigint UNSIGNED NOT NULL COMMENT '考试安排方案ID',
+  `course_id` bigint UNSIGNED NOT NULL COMMENT '课程ID',
+  `room_id` bigint UNSIGNED NULL DEFAULT NULL COMMENT '考场教室ID',
+  `room` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '考场',
+  `start_time` datetime NOT NULL COMMENT '开始时间',
+  `end_time` datetime NOT NULL COMMENT '结束时间',
+  `students` int NOT NULL COMMENT '应考人数',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_exam_plan_id`(`plan_id` ASC) USING BTREE,
+  INDEX `idx_exam_course_id`(`course_id` ASC) USING BTREE,
+  INDEX `idx_exam_room_id`(`room_id` ASC) USING BTREE,
+  INDEX `idx_exam_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for exam_plan
+-- ----------------------------
+DROP TABLE IF EXISTS `exam_plan`;
+CREATE TABLE `exam_plan`  (
+  `name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '方案名称',
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '方案状态',
+  `unscheduled` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '未能安排的课程及原因',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_exam_plan_status`(`status` ASC) USING BTREE,
+  INDEX `idx_exam_plan_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for grade
+-- ----------------------------
+DROP TABLE IF EXISTS `grade`;
+CREATE TABLE `grade`  (
+  `course_id` bigint NOT NULL COMMENT '课程ID',
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  `scale_id` bigint NOT NULL COMMENT '录入时使用的成绩等级制ID',
+  `value` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '成绩(分数或等级)',
+  `points` decimal(4, 2) NULL DEFAULT NULL COMMENT '绩点, 通过/不通过制为空',
+  `passed` tinyint(1) NOT NULL COMMENT '是否通过',
+  `recorded_by` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '录入人的用户ID',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_grade_course_student`(`course_id` ASC, `student_id` ASC) USING BTREE,
+  INDEX `idx_grade_student_id`(`student_id` ASC) USING BTREE,
+  INDEX `idx_grade_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for grade_scale
+-- ----------------------------
+DROP TABLE IF EXISTS `grade_scale`;
+CREATE TABLE `grade_scale`  (
+  `name` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '名称',
+  `kind` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '等级制类型',
+  `rules` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '成绩与绩点的换算规则',
+  `is_default` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否为未指定等级制的课程使用的默认等级制',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_grade_scale_name`(`name` ASC) USING BTREE,
+  INDEX `idx_grade_scale_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for notice
+-- ----------------------------
+DROP TABLE IF EXISTS `notice`;
+CREATE TABLE `notice`  (
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  `kind` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '通知类型',
+  `course_id` bigint UNSIGNED NOT NULL COMMENT '相关课程ID',
+  `message` varchar(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '通知内容',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_notice_student_id`(`student_id` ASC) USING BTREE,
+  INDEX `idx_notice_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for office_hour
+-- ----------------------------
+DROP TABLE IF EXISTS `office_hour`;
+CREATE TABLE `office_hour`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `teacher_id` bigint NOT NULL COMMENT '教师ID',
+  `from_date` date NOT NULL COMMENT '生效开始日期',
+  `to_date` date NOT NULL COMMENT '生效结束日期(含)',
+  `weekday` tinyint NOT NULL COMMENT '星期几(1-7)',
+  `start_clock` varchar(5) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '开始时刻(HH:MM)',
+  `end_clock` varchar(5) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '结束时刻(HH:MM)',
+  `slot_minutes` int NOT NULL COMMENT '每个时段的分钟数',
+  `location` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '答疑地点',
+  `note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '说明',
+  PRIMARY KEY (`id`) USING BTREE,
+  KEY `idx_office_hour_teacher_id` (`teacher_id`),
+  KEY `idx_office_hour_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for office_hour_booking
+-- ----------------------------
+DROP TABLE IF EXISTS `office_hour_booking`;
+CREATE TABLE `office_hour_booking`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `office_hour_id` bigint NOT NULL COMMENT '答疑时间段ID',
+  `start_time` datetime NOT NULL COMMENT '时段开始时间',
+  `end_time` datetime NOT NULL COMMENT '时段结束时间',
+  `teacher_id` bigint NOT NULL COMMENT '教师ID',
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  `note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '预约说明, 如想讨论的问题',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE KEY `idx_office_hour_booking_slot` (`office_hour_id`,`start_time`),
+  KEY `idx_office_hour_booking_teacher_id` (`teacher_id`),
+  KEY `idx_office_hour_booking_student_id` (`student_id`),
+  KEY `idx_office_hour_booking_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for relocation_offer
+-- ----------------------------
+DROP TABLE IF EXISTS `relocation_offer`;
+CREATE TABLE `relocation_offer`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  `cancelled_course_id` bigint NOT NULL COMMENT '停开的课程ID',
+  `cancelled_course_name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '停开的课程名称',
+  `course_id` bigint NOT NULL COMMENT '替代课程ID',
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '状态',
+  PRIMARY KEY (`id`) USING BTREE,
+  KEY `idx_relocation_offer_student_id` (`student_id`),
+  KEY `idx_relocation_offer_course_id` (`course_id`),
+  KEY `idx_relocation_offer_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for room
+-- ----------------------------
+DROP TABLE IF EXISTS `room`;
+CREATE TABLE `room`  (
+  `building` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '教学楼',
+  `name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '教室编号',
+  `seats` int NOT NULL COMMENT '座位数',
+  `projector` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否有投影仪',
+  `lab` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否为实验室',
+  `accessible` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否无障碍',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_room_building_name`(`building` ASC, `name` ASC) USING BTREE,
+  INDEX `idx_room_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for room_booking
+-- ----------------------------
+DROP TABLE IF EXISTS `room_booking`;
+CREATE TABLE `room_booking`  (
+  `room_id` bigint NOT NULL COMMENT '教室ID',
+  `title` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '活动名称',
+  `kind` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '活动类型',
+  `booked_by` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '预订人的用户ID',
+  `teacher_id` bigint NULL DEFAULT NULL COMMENT '教师预订时的教师ID',
+  `note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '说明',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_room_booking_room_id`(`room_id` ASC) USING BTREE,
+  INDEX `idx_room_booking_teacher_id`(`teacher_id` ASC) USING BTREE,
+  INDEX `idx_room_booking_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for room_booking_time
+-- ----------------------------
+DROP TABLE IF EXISTS `room_booking_time`;
+CREATE TABLE `room_booking_time`  (
+  `booking_id` bigint NOT NULL COMMENT '预订ID',
+  `room_id` bigint NOT NULL COMMENT '教室ID, 与预订相同, 便于冲突查询',
+  `start_time` datetime NOT NULL COMMENT '开始时间',
+  `end_time` datetime NOT NULL COMMENT '结束时间',
+  INDEX `idx_room_booking_time_booking_id`(`booking_id` ASC) USING BTREE,
+  INDEX `idx_room_booking_time_room_id`(`room_id` ASC) USING BTREE,
+  CONSTRAINT `fk_room_booking_times` FOREIGN KEY (`booking_id`) REFERENCES `room_booking` (`id`) ON DELETE RESTRICT ON UPDATE RESTRICT
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for seat_swap
+-- ----------------------------
+DROP TABLE IF EXISTS `seat_swap`;
+CREATE TABLE `seat_swap`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  `give_course_id` bigint NOT NULL COMMENT '让出的课程ID',
+  `want_course_id` bigint NOT NULL COMMENT '想要的课程ID',
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '状态',
+  `match_id` bigint NULL DEFAULT NULL COMMENT '所在的匹配ID',
+  `accepted` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否已确认所在的匹配',
+  PRIMARY KEY (`id`) USING BTREE,
+  KEY `idx_seat_swap_student_id` (`student_id`),
+  KEY `idx_seat_swap_give_course_id` (`give_course_id`),
+  KEY `idx_seat_swap_want_course_id` (`want_course_id`),
+  KEY `idx_seat_swap_status` (`status`),
+  KEY `idx_seat_swap_match_id` (`match_id`),
+  KEY `idx_seat_swap_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for seat_swap_match
+-- ----------------------------
+DROP TABLE IF EXISTS `seat_swap_match`;
+CREATE TABLE `seat_swap_match`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '状态',
+  `reason` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '取消原因',
+  PRIMARY KEY (`id`) USING BTREE,
+  KEY `idx_seat_swap_match_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for student_profile
+-- ----------------------------
+DROP TABLE IF EXISTS `student_profile`;
+CREATE TABLE `student_profile`  (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  `student_id` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '学生ID',
+  `department` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '所属院系',
+  `major` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '专业',
+  `entry_year` int NOT NULL DEFAULT 0 COMMENT '入学年份',
+  `class_name` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '行政班',
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT 'active' COMMENT '学籍状态',
+  `email` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '电子邮箱',
+  `phone` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '联系电话',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE KEY `idx_student_profile_student_id` (`student_id`),
+  KEY `idx_student_profile_department` (`department`),
+  KEY `idx_student_profile_major` (`major`),
+  KEY `idx_student_profile_entry_year` (`entry_year`),
+  KEY `idx_student_profile_class_name` (`class_name`),
+  KEY `idx_student_profile_status` (`status`),
+  KEY `idx_student_profile_deleted_at` (`deleted_at`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for teacher
 -- ----------------------------
 DROP TABLE IF EXISTS `teacher`;
 CREATE TABLE `teacher`  (
   `name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '教师姓名',
+  `staff_id` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL DEFAULT NULL COMMENT '工号',
+  `department` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '所属院系',
+  `email` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '电子邮箱',
+  `phone` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '联系电话',
   `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
   `created_at` datetime(3) NOT NULL COMMENT '创建时间',
   `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
   `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
   PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_teacher_staff_id`(`staff_id` ASC) USING BTREE,
   INDEX `idx_teacher_deleted_at`(`deleted_at` ASC) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 6 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for teacher_availability
+-- ----------------------------
+DROP TABLE IF EXISTS `teacher_availability`;
+CREATE TABLE `teacher_availability`  (
+  `teacher_id` bigint UNSIGNED NOT NULL COMMENT '教师ID',
+  `kind` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '时间段类型',
+  `from_date` date NOT NULL COMMENT '生效开始日期(一般为学期开始)',
+  `to_date` date NOT NULL COMMENT '生效结束日期(含)',
+  `weekday` tinyint NOT NULL COMMENT '星期几(1-7)',
+  `start_clock` varchar(5) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '开始时刻(HH:MM)',
+  `end_clock` varchar(5) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '结束时刻(HH:MM)',
+  `note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '说明',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_teacher_availability_teacher_id`(`teacher_id` ASC) USING BTREE,
+  INDEX `idx_teacher_availability_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for timetable_course
+-- ----------------------------
+DROP TABLE IF EXISTS `timetable_course`;
+CREATE TABLE `timetable_course`  (
+  `draft_id` bigint UNSIGNED NOT NULL COMMENT '课表草案ID',
+  `course_name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '课程名称',
+  `course_code` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '课程代码, 同一门课历次开课共用, 培养方案按代码匹配',
+  `capacity` int NOT NULL COMMENT '课程容量',
+  `teacher_ids` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '授课教师ID',
+  `room_id` bigint NULL DEFAULT NULL COMMENT '安排的教室ID, 未能安排时为空',
+  `meetings` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '每周的上课节次',
+  `reason` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '' COMMENT '未能安排的原因',
+  `course_id` bigint NULL DEFAULT NULL COMMENT '应用后创建的课程ID',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_timetable_course_draft_id`(`draft_id` ASC) USING BTREE,
+  INDEX `idx_timetable_course_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for timetable_draft
+-- ----------------------------
+DROP TABLE IF EXISTS `timetable_draft`;
+CREATE TABLE `timetable_draft`  (
+  `name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '草案名称',
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '草案状态',
+  `from_date` date NOT NULL COMMENT '课表开始日期',
+  `to_date` date NOT NULL COMMENT '课表结束日期(含)',
+  `weekdays` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '可排课的星期',
+  `periods` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '每天的节次及起止时刻',
+  `cost` int NOT NULL DEFAULT 0 COMMENT '软约束总代价',
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键',
+  `created_at` datetime(3) NOT NULL COMMENT '创建时间',
+  `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
+  `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_timetable_draft_status`(`status` ASC) USING BTREE,
+  INDEX `idx_timetable_draft_deleted_at`(`deleted_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for user
 -- ----------------------------
@@ -87,10 +582,12 @@ CREATE TABLE `user`  (
   `user_name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '用户名',
   `password` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '密码',
   `auth` int NOT NULL COMMENT '权限',
+  `teacher_id` bigint NULL DEFAULT NULL COMMENT '教师账号关联的教师ID',
   `created_at` datetime(3) NOT NULL COMMENT '创建时间',
   `updated_at` datetime(3) NOT NULL COMMENT '更新时间',
   `deleted_at` datetime(3) NULL DEFAULT NULL COMMENT '删除时间',
   PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_user_teacher_id`(`teacher_id` ASC) USING BTREE,
   INDEX `idx_user_deleted_at`(`deleted_at` ASC) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 5 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci ROW_FORMAT = Dynamic;
 